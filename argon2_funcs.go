@@ -0,0 +1,362 @@
+package gokwallet
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+/*
+	argon2Key derives a key of keyLen bytes from password with Argon2 (RFC 9106), as KeePass databases use it (see KDBXOpts).
+	golang.org/x/crypto/argon2 only implements Argon2i and Argon2id, without a secret or associated data,
+	so this implements Argon2d and Argon2id (mode is argon2ModeD or argon2ModeID), versions 0x10 and 0x13, in full.
+	memory is in KiB; parallelism must be at least 1.
+*/
+func argon2Key(
+	mode, version uint32, password, salt, secret, ad []byte, iterations, memory, parallelism uint32, keyLen uint32,
+) (key []byte) {
+
+	var h0 [blake2b.Size + 8]byte
+	var hash hashWriter
+	var blocks []argon2Block
+	var laneLen uint32
+	var final argon2Block
+	var buf [argon2BlockLen * 8]byte
+
+	if iterations < 1 {
+		iterations = 1
+	}
+	if memory < 2*argon2SyncPoints*parallelism {
+		memory = 2 * argon2SyncPoints * parallelism
+	}
+
+	hash = newHashWriter()
+	for _, v := range []uint32{parallelism, keyLen, memory, iterations, version, mode} {
+		hash.uint32(v)
+	}
+	for _, b := range [][]byte{password, salt, secret, ad} {
+		hash.uint32(uint32(len(b)))
+		hash.Write(b)
+	}
+	hash.Sum(h0[:0])
+
+	// The memory used is rounded down to a multiple of the number of segments (after hashing the requested amount).
+	memory = memory / (argon2SyncPoints * parallelism) * (argon2SyncPoints * parallelism)
+	laneLen = memory / parallelism
+
+	blocks = make([]argon2Block, memory)
+	for lane := uint32(0); lane < parallelism; lane++ {
+		for idx := uint32(0); idx < 2; idx++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], idx)
+			binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+			argon2Hash(buf[:], h0[:])
+			blocks[lane*laneLen+idx].fromBytes(buf[:])
+		}
+	}
+
+	argon2Fill(blocks, mode, version, iterations, memory, parallelism)
+
+	for lane := uint32(0); lane < parallelism; lane++ {
+		for idx := range final {
+			final[idx] ^= blocks[lane*laneLen+laneLen-1][idx]
+		}
+	}
+	final.toBytes(buf[:])
+
+	key = make([]byte, keyLen)
+	argon2Hash(key, buf[:])
+
+	for idx := range blocks {
+		blocks[idx] = argon2Block{}
+	}
+	wipeBytes(buf[:])
+
+	return
+}
+
+// argon2Fill fills the memory blocks of Argon2 (see argon2Key), one slice of every lane (in parallel) at a time.
+func argon2Fill(blocks []argon2Block, mode, version, iterations, memory, parallelism uint32) {
+
+	var wg sync.WaitGroup
+	var laneLen uint32 = memory / parallelism
+	var segLen uint32 = laneLen / argon2SyncPoints
+
+	for pass := uint32(0); pass < iterations; pass++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			for lane := uint32(0); lane < parallelism; lane++ {
+				wg.Add(1)
+				go func(pass, slice, lane uint32) {
+					defer wg.Done()
+					argon2Segment(blocks, mode, version, iterations, memory, parallelism, laneLen, segLen, pass, slice, lane)
+				}(pass, slice, lane)
+			}
+			wg.Wait()
+		}
+	}
+
+	return
+}
+
+// argon2Segment fills a single segment (one slice of one lane) of Argon2 memory.
+func argon2Segment(
+	blocks []argon2Block, mode, version, iterations, memory, parallelism, laneLen, segLen, pass, slice, lane uint32,
+) {
+
+	var addresses argon2Block
+	var input argon2Block
+	var zero argon2Block
+	var index uint32
+	var offset uint32
+	var prev uint32
+	var rand uint64
+	var ref uint32
+	// Argon2id uses data-independent addressing (as Argon2i) for the first half of the first pass.
+	var independent bool = mode == argon2ModeID && pass == 0 && slice < argon2SyncPoints/2
+
+	if independent {
+		input[0] = uint64(pass)
+		input[1] = uint64(lane)
+		input[2] = uint64(slice)
+		input[3] = uint64(memory)
+		input[4] = uint64(iterations)
+		input[5] = uint64(mode)
+	}
+
+	if pass == 0 && slice == 0 {
+		// The first two blocks of each lane are already filled.
+		index = 2
+		if independent {
+			input[6]++
+			argon2Compress(&addresses, &input, &zero, false)
+			argon2Compress(&addresses, &addresses, &zero, false)
+		}
+	}
+
+	offset = lane*laneLen + slice*segLen + index
+
+	for ; index < segLen; index, offset = index+1, offset+1 {
+		prev = offset - 1
+		if index == 0 && slice == 0 {
+			// The last block of the lane.
+			prev += laneLen
+		}
+		if independent {
+			if index%argon2BlockLen == 0 {
+				input[6]++
+				argon2Compress(&addresses, &input, &zero, false)
+				argon2Compress(&addresses, &addresses, &zero, false)
+			}
+			rand = addresses[index%argon2BlockLen]
+		} else {
+			rand = blocks[prev][0]
+		}
+		ref = argon2RefIndex(rand, laneLen, segLen, parallelism, pass, slice, lane, index)
+		// Version 0x13 XORs over the previous pass; version 0x10 (and the first pass, over zeroed memory) overwrites.
+		argon2Compress(&blocks[offset], &blocks[prev], &blocks[ref], pass > 0 && version == argon2Version13)
+	}
+
+	return
+}
+
+// argon2RefIndex returns the (absolute) index of the reference block for block index of a segment.
+func argon2RefIndex(rand uint64, laneLen, segLen, parallelism, pass, slice, lane, index uint32) (ref uint32) {
+
+	var refLane uint32 = uint32(rand>>32) % parallelism
+	var area uint32
+	var start uint32
+	var rel uint64
+
+	if pass == 0 && slice == 0 {
+		refLane = lane
+	}
+
+	// area is the number of blocks that may be referenced, and start is where they start in the reference lane.
+	if pass == 0 {
+		area = slice * segLen
+		if slice == 0 || lane == refLane {
+			area += index
+		}
+	} else {
+		area = 3 * segLen
+		start = ((slice + 1) % argon2SyncPoints) * segLen
+		if lane == refLane {
+			area += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		area--
+	}
+
+	rel = rand & 0xffffffff
+	rel = (rel * rel) >> 32
+	rel = (rel * uint64(area)) >> 32
+
+	ref = refLane*laneLen + uint32((uint64(start)+uint64(area)-(rel+1))%uint64(laneLen))
+
+	return
+}
+
+// argon2Compress is the Argon2 compression function G: out = G(x, y), or out ^= G(x, y) if xor is true.
+func argon2Compress(out, x, y *argon2Block, xor bool) {
+
+	var r argon2Block
+	var q argon2Block
+
+	for idx := range r {
+		r[idx] = x[idx] ^ y[idx]
+	}
+	q = r
+
+	// Rows, then columns, of 16 words each (the block is an 8x8 matrix of 16-byte registers).
+	for row := 0; row < 8; row++ {
+		argon2Round(&q, 16*row, 16*row+1, 16*row+2, 16*row+3, 16*row+4, 16*row+5, 16*row+6, 16*row+7,
+			16*row+8, 16*row+9, 16*row+10, 16*row+11, 16*row+12, 16*row+13, 16*row+14, 16*row+15)
+	}
+	for col := 0; col < 8; col++ {
+		argon2Round(&q, 2*col, 2*col+1, 2*col+16, 2*col+17, 2*col+32, 2*col+33, 2*col+48, 2*col+49,
+			2*col+64, 2*col+65, 2*col+80, 2*col+81, 2*col+96, 2*col+97, 2*col+112, 2*col+113)
+	}
+
+	for idx := range out {
+		if xor {
+			out[idx] ^= r[idx] ^ q[idx]
+		} else {
+			out[idx] = r[idx] ^ q[idx]
+		}
+	}
+
+	return
+}
+
+// argon2Round is the BLAKE2b round (with Argon2's multiplication-hardened G) on 16 words of b.
+func argon2Round(b *argon2Block, i0, i1, i2, i3, i4, i5, i6, i7, i8, i9, i10, i11, i12, i13, i14, i15 int) {
+
+	argon2G(b, i0, i4, i8, i12)
+	argon2G(b, i1, i5, i9, i13)
+	argon2G(b, i2, i6, i10, i14)
+	argon2G(b, i3, i7, i11, i15)
+	argon2G(b, i0, i5, i10, i15)
+	argon2G(b, i1, i6, i11, i12)
+	argon2G(b, i2, i7, i8, i13)
+	argon2G(b, i3, i4, i9, i14)
+
+	return
+}
+
+// argon2G is the BlaMka quarter-round on words a, b, c, and d of blk.
+func argon2G(blk *argon2Block, a, b, c, d int) {
+
+	blk[a] += blk[b] + 2*uint64(uint32(blk[a]))*uint64(uint32(blk[b]))
+	blk[d] = rotr64(blk[d]^blk[a], 32)
+	blk[c] += blk[d] + 2*uint64(uint32(blk[c]))*uint64(uint32(blk[d]))
+	blk[b] = rotr64(blk[b]^blk[c], 24)
+	blk[a] += blk[b] + 2*uint64(uint32(blk[a]))*uint64(uint32(blk[b]))
+	blk[d] = rotr64(blk[d]^blk[a], 16)
+	blk[c] += blk[d] + 2*uint64(uint32(blk[c]))*uint64(uint32(blk[d]))
+	blk[b] = rotr64(blk[b]^blk[c], 63)
+
+	return
+}
+
+// argon2Hash is Argon2's variable-length hash function H' of in, filling out.
+func argon2Hash(out, in []byte) {
+
+	var hash hashWriter
+	var v [blake2b.Size]byte
+	var outLen int = len(out)
+
+	hash = newHashWriterSize(hashOutSize(outLen))
+	hash.uint32(uint32(outLen))
+	hash.Write(in)
+
+	if outLen <= blake2b.Size {
+		hash.Sum(out[:0])
+		return
+	}
+
+	hash.Sum(v[:0])
+	copy(out, v[:32])
+	out = out[32:]
+
+	for len(out) > blake2b.Size {
+		hash = newHashWriterSize(blake2b.Size)
+		hash.Write(v[:])
+		hash.Sum(v[:0])
+		copy(out, v[:32])
+		out = out[32:]
+	}
+
+	hash = newHashWriterSize(len(out))
+	hash.Write(v[:])
+	hash.Sum(out[:0])
+
+	return
+}
+
+// fromBytes sets an argon2Block from b (little-endian).
+func (a *argon2Block) fromBytes(b []byte) {
+
+	for idx := range a {
+		a[idx] = binary.LittleEndian.Uint64(b[idx*8:])
+	}
+
+	return
+}
+
+// toBytes writes an argon2Block to b (little-endian).
+func (a *argon2Block) toBytes(b []byte) {
+
+	for idx := range a {
+		binary.LittleEndian.PutUint64(b[idx*8:], a[idx])
+	}
+
+	return
+}
+
+// newHashWriter returns a hashWriter for BLAKE2b-512.
+func newHashWriter() (h hashWriter) {
+
+	h = newHashWriterSize(blake2b.Size)
+
+	return
+}
+
+// newHashWriterSize returns a hashWriter for (unkeyed) BLAKE2b with an output of size bytes.
+func newHashWriterSize(size int) (h hashWriter) {
+
+	// blake2b.New only fails for a bad size or key, and neither can happen here.
+	h.Hash, _ = blake2b.New(size, nil)
+
+	return
+}
+
+// uint32 writes v, little-endian, to a hashWriter.
+func (h hashWriter) uint32(v uint32) {
+
+	var b [4]byte
+
+	binary.LittleEndian.PutUint32(b[:], v)
+	h.Write(b[:])
+
+	return
+}
+
+// hashOutSize returns n, or blake2b.Size if n is larger.
+func hashOutSize(n int) (m int) {
+
+	m = n
+	if m > blake2b.Size {
+		m = blake2b.Size
+	}
+
+	return
+}
+
+// rotr64 rotates v right by n bits.
+func rotr64(v uint64, n uint) (r uint64) {
+
+	r = v>>n | v<<(64-n)
+
+	return
+}
@@ -0,0 +1,31 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestArgon2 tests argon2Key against the known-answer tests of RFC 9106 (section 5).
+func TestArgon2(t *testing.T) {
+
+	var key []byte
+	var password []byte = bytes.Repeat([]byte{0x01}, 32)
+	var salt []byte = bytes.Repeat([]byte{0x02}, 16)
+	var secret []byte = bytes.Repeat([]byte{0x03}, 8)
+	var ad []byte = bytes.Repeat([]byte{0x04}, 12)
+
+	for _, kat := range []struct {
+		name string
+		mode uint32
+		tag  string
+	}{
+		{"Argon2d", argon2ModeD, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"Argon2id", argon2ModeID, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	} {
+		key = argon2Key(kat.mode, argon2Version13, password, salt, secret, ad, 3, 32, 4, 32)
+		if hex.EncodeToString(key) != kat.tag {
+			t.Errorf("%v tag %x does not match RFC 9106 tag %v", kat.name, key, kat.tag)
+		}
+	}
+}
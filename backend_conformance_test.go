@@ -0,0 +1,179 @@
+package gokwallet
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+/*
+	testBackendConformance runs the shared Backend conformance suite against b.
+	Every Backend implementation should pass it; it exercises the Backend through the regular
+	WalletManager/Wallet/Folder/WalletItem API.
+*/
+func testBackendConformance(t *testing.T, b Backend) {
+
+	var err error
+	var ok bool
+	var raw []byte
+	var names []string
+	var r *RecurseOpts
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var p *Password
+	var m *Map
+	var blob *Blob
+	var u *UnknownItem
+
+	r = &RecurseOpts{
+		Folders:        true,
+		AllWalletItems: true,
+	}
+
+	if wm, err = NewWalletManagerBackend(b, r, appIdTest); err != nil {
+		t.Fatalf("failed to get WalletManager '%v': %v", appIdTest, err)
+	}
+	defer wm.Close()
+
+	if w, err = NewWallet(wm, walletTest.String(), r); err != nil {
+		t.Fatalf("failed to get Wallet '%v:%v': %v", appIdTest, walletTest.String(), err)
+	}
+	defer w.Delete()
+
+	if names, err = wm.WalletNames(); err != nil {
+		t.Errorf("failed to get wallet names: %v", err)
+	} else if !testHasString(names, w.Name) {
+		t.Errorf("wallet '%v' not in wallet names %#v", w.Name, names)
+	}
+
+	if err = w.CreateFolder(folderTest.String()); err != nil {
+		t.Fatalf("failed to create Folder '%v:%v': %v", w.Name, folderTest.String(), err)
+	}
+	if ok, err = w.HasFolder(folderTest.String()); err != nil {
+		t.Errorf("failed to run HasFolder for '%v:%v': %v", w.Name, folderTest.String(), err)
+	} else if !ok {
+		t.Errorf("HasFolder returned false for '%v:%v'", w.Name, folderTest.String())
+	}
+	if ok, err = w.FolderExists(folderTest.String()); err != nil {
+		t.Errorf("failed to run FolderExists for '%v:%v': %v", w.Name, folderTest.String(), err)
+	} else if !ok {
+		t.Errorf("FolderExists returned false for '%v:%v'", w.Name, folderTest.String())
+	}
+	if names, err = w.ListFolders(); err != nil {
+		t.Errorf("failed to run ListFolders for '%v': %v", w.Name, err)
+	} else if !testHasString(names, folderTest.String()) {
+		t.Errorf("folder '%v' not in ListFolders %#v", folderTest.String(), names)
+	}
+
+	if f, err = NewFolder(w, folderTest.String(), r); err != nil {
+		t.Fatalf("failed to get Folder '%v:%v': %v", w.Name, folderTest.String(), err)
+	}
+
+	if p, err = f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword in '%v:%v': %v", w.Name, f.Name, err)
+	} else if p.Value != testPassword {
+		t.Errorf("Password value '%v' does not match expected '%v'", p.Value, testPassword)
+	}
+	if m, err = f.WriteMap(mapTest.String(), testMap); err != nil {
+		t.Fatalf("failed to WriteMap in '%v:%v': %v", w.Name, f.Name, err)
+	} else if !reflect.DeepEqual(m.Value, testMap) {
+		t.Errorf("Map value '%#v' does not match expected '%#v'", m.Value, testMap)
+	}
+	if blob, err = f.WriteBlob(blobTest.String(), testBytes); err != nil {
+		t.Fatalf("failed to WriteBlob in '%v:%v': %v", w.Name, f.Name, err)
+	} else if !bytes.Equal(blob.Value, testBytes) {
+		t.Errorf("Blob value '%#v' does not match expected '%#v'", blob.Value, testBytes)
+	}
+	if u, err = f.WriteUnknown(unknownItemTest.String(), testBytes); err != nil {
+		t.Fatalf("failed to WriteUnknown in '%v:%v': %v", w.Name, f.Name, err)
+	} else if !bytes.Equal(u.Value, testBytes) {
+		t.Errorf("UnknownItem value '%#v' does not match expected '%#v'", u.Value, testBytes)
+	}
+
+	if err = f.Update(); err != nil {
+		t.Errorf("failed to update Folder '%v:%v': %v", w.Name, f.Name, err)
+	}
+	if len(f.Passwords) != 1 || len(f.Maps) != 1 || len(f.BinaryData) != 1 || len(f.Unknown) != 1 {
+		t.Errorf(
+			"unexpected entry counts in '%v:%v': %v passwords, %v maps, %v blobs, %v unknown",
+			w.Name, f.Name, len(f.Passwords), len(f.Maps), len(f.BinaryData), len(f.Unknown),
+		)
+	}
+	if names, err = f.ListEntries(); err != nil {
+		t.Errorf("failed to run ListEntries in '%v:%v': %v", w.Name, f.Name, err)
+	} else if len(names) != 4 {
+		t.Errorf("expected 4 entries in '%v:%v', got %#v", w.Name, f.Name, names)
+	}
+
+	if err = m.SetValue(testMapReplace); err != nil {
+		t.Errorf("failed to set Map value: %v", err)
+	}
+	if err = m.Update(); err != nil {
+		t.Errorf("failed to update Map: %v", err)
+	} else if !reflect.DeepEqual(m.Value, testMapReplace) {
+		t.Errorf("Map value '%#v' does not match expected '%#v'", m.Value, testMapReplace)
+	}
+
+	// Raw values must be interchangeable: writing a Password's raw value back must yield the same Password.
+	if raw, err = b.ReadEntry(w.handle, f.Name, p.Name, wm.AppID); err != nil {
+		t.Errorf("failed to ReadEntry '%v:%v:%v': %v", w.Name, f.Name, p.Name, err)
+	} else {
+		if err = f.WriteEntry(passwordTestRename.String(), KwalletdEnumTypePassword, raw); err != nil {
+			t.Errorf("failed to WriteEntry raw Password: %v", err)
+		} else if p, err = NewPassword(f, passwordTestRename.String(), r); err != nil {
+			t.Errorf("failed to get raw-copied Password: %v", err)
+		} else if p.Value != testPassword {
+			t.Errorf("raw-copied Password value '%v' does not match expected '%v'", p.Value, testPassword)
+		}
+		if err = f.RemoveEntry(passwordTestRename.String()); err != nil {
+			t.Errorf("failed to remove raw-copied Password: %v", err)
+		}
+	}
+
+	p = f.Passwords[passwordTest.String()]
+	if err = p.Rename(passwordTestRename.String()); err != nil {
+		t.Errorf("failed to rename Password: %v", err)
+	}
+	if ok, err = f.HasEntry(passwordTest.String()); err != nil {
+		t.Errorf("failed to run HasEntry: %v", err)
+	} else if ok {
+		t.Errorf("old Password name '%v' still exists after rename", passwordTest.String())
+	}
+	if ok, err = p.Exists(); err != nil {
+		t.Errorf("failed to run Exists: %v", err)
+	} else if !ok {
+		t.Errorf("renamed Password '%v' does not exist", p.Name)
+	}
+
+	if err = p.Delete(); err != nil {
+		t.Errorf("failed to delete Password: %v", err)
+	}
+	if ok, err = f.HasEntry(passwordTestRename.String()); err != nil {
+		t.Errorf("failed to run HasEntry: %v", err)
+	} else if ok {
+		t.Errorf("Password '%v' still exists after delete", passwordTestRename.String())
+	}
+
+	if err = f.Delete(); err != nil {
+		t.Errorf("failed to delete Folder '%v:%v': %v", w.Name, f.Name, err)
+	}
+	if ok, err = w.HasFolder(folderTest.String()); err != nil {
+		t.Errorf("failed to run HasFolder: %v", err)
+	} else if ok {
+		t.Errorf("Folder '%v:%v' still exists after delete", w.Name, folderTest.String())
+	}
+}
+
+// testHasString returns true if s is in list.
+func testHasString(list []string, s string) (found bool) {
+
+	var idx int
+
+	sort.Strings(list)
+	idx = sort.SearchStrings(list, s)
+	found = idx < len(list) && list[idx] == s
+
+	return
+}
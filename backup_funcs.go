@@ -0,0 +1,518 @@
+package gokwallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+	Backup writes an encrypted, self-contained backup archive of a Wallet (every Folder and WalletItem, with types preserved)
+	to out, and returns its BackupManifest. Use Wallet.Restore to restore it (into this or any other Wallet).
+
+	The encryption key is derived from passphrase with scrypt (using a random salt), and the archive is encrypted and
+	authenticated with AES-256-GCM, so nothing but the archive format version and KDF parameters is stored in plaintext.
+	passphrase is not modified or kept; wipe it yourself if needed.
+	To split the key among several custodians instead of using a passphrase, see Wallet.BackupShares.
+*/
+func (w *Wallet) Backup(out io.Writer, passphrase []byte) (manifest *BackupManifest, err error) {
+
+	var payload *backupPayload
+
+	if len(passphrase) == 0 {
+		err = ErrBackupPassphrase
+		return
+	}
+
+	if payload, err = w.backupPayload(); err != nil {
+		return
+	}
+	defer payload.wipe()
+
+	if err = writePassphraseBackup(out, passphrase, payload); err != nil {
+		return
+	}
+
+	manifest = payload.Manifest
+
+	return
+}
+
+/*
+	Restore restores a backup archive written by Wallet.Backup (from any Wallet) into Wallet w, creating Folders as needed,
+	and returns the archive's BackupManifest and an ImportResult for each WalletItem restored.
+	The archive is fully decrypted and checked against its BackupManifest before anything is written.
+	opts may be nil (everything is then restored, with ConflictFail); see RestoreOpts for restoring selected Folders
+	and for a dry run. Errors for individual WalletItems do not stop the restore; err is then a MultiError of them.
+*/
+func (w *Wallet) Restore(in io.Reader, passphrase []byte, opts *RestoreOpts) (manifest *BackupManifest, results []*ImportResult, err error) {
+
+	if len(passphrase) == 0 {
+		err = ErrBackupPassphrase
+		return
+	}
+
+	if manifest, results, err = w.restore(in, opts, func(hdr *backupHeader) (key []byte, err error) {
+		key, err = hdr.deriveKey(passphrase)
+		return
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	restore restores a backup archive from in into Wallet w (see Wallet.Restore),
+	using getKey to get the archive key from its backupHeader.
+*/
+func (w *Wallet) restore(
+	in io.Reader, opts *RestoreOpts, getKey func(hdr *backupHeader) (key []byte, err error),
+) (manifest *BackupManifest, results []*ImportResult, err error) {
+
+	var hdr *backupHeader
+	var aad []byte
+	var sealed []byte
+	var key []byte
+	var payload *backupPayload
+
+	if opts == nil {
+		opts = new(RestoreOpts)
+	}
+	if err = opts.Policy.validate(); err != nil {
+		return
+	}
+
+	if hdr, aad, sealed, err = readBackup(in); err != nil {
+		return
+	}
+
+	if key, err = getKey(hdr); err != nil {
+		return
+	}
+	defer wipeBytes(key)
+
+	if payload, err = openBackup(hdr, aad, sealed, key); err != nil {
+		return
+	}
+	defer payload.wipe()
+
+	manifest = payload.Manifest
+
+	if results, err = w.restorePayload(payload, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+// backupPayload returns the backupPayload (a WalletSnapshot and its BackupManifest) of a Wallet.
+func (w *Wallet) backupPayload() (payload *backupPayload, err error) {
+
+	var ws *WalletSnapshot
+	var bf *BackupFolder
+	var sum [sha256.Size]byte
+
+	if ws, err = w.Snapshot(); err != nil {
+		return
+	}
+
+	payload = &backupPayload{
+		Manifest: &BackupManifest{
+			Version: BackupVersion,
+			Wallet:  w.Name,
+			AppID:   w.wm.AppID,
+			Created: time.Now(),
+			Folders: make([]*BackupFolder, 0, len(ws.Folders)),
+		},
+		Wallet: ws,
+	}
+
+	for _, fs := range ws.Folders {
+		bf = &BackupFolder{
+			Name:    fs.Name,
+			Entries: make([]*BackupEntry, 0, len(fs.Entries)),
+		}
+		for _, es := range fs.Entries {
+			// The archive needs the values, whether or not they're shown elsewhere.
+			es.showRaw = true
+			sum = sha256.Sum256(es.raw)
+			bf.Entries = append(bf.Entries, &BackupEntry{
+				Name:   es.Name,
+				Type:   es.Type,
+				Size:   len(es.raw),
+				SHA256: hex.EncodeToString(sum[:]),
+			})
+		}
+		payload.Manifest.Folders = append(payload.Manifest.Folders, bf)
+	}
+
+	return
+}
+
+// restorePayload writes (or, for RestoreOpts.DryRun, checks) the selected Folders of a verified backupPayload to Wallet w.
+func (w *Wallet) restorePayload(payload *backupPayload, opts *RestoreOpts) (results []*ImportResult, err error) {
+
+	var f *Folder
+	var exists bool
+	var res *ImportResult
+	var want map[string]bool
+	var errs []error = make([]error, 0)
+
+	if err = w.walletCheck(); err != nil {
+		return
+	}
+
+	if len(opts.Folders) != 0 {
+		want = make(map[string]bool, len(opts.Folders))
+		for _, fn := range opts.Folders {
+			if payload.Wallet.Folder(fn) == nil {
+				err = fmt.Errorf("%w: %#v", ErrBackupNoFolder, fn)
+				return
+			}
+			want[fn] = true
+		}
+	}
+
+	results = make([]*ImportResult, 0)
+
+	for _, fs := range payload.Wallet.Folders {
+		if want != nil && !want[fs.Name] {
+			continue
+		}
+		if exists, err = w.HasFolder(fs.Name); err == nil && !exists && !opts.DryRun {
+			err = w.CreateFolder(fs.Name)
+		}
+		if err == nil {
+			f, err = NewFolder(w, fs.Name, &RecurseOpts{})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("folder %#v: %w", fs.Name, err))
+			err = nil
+			continue
+		}
+		for _, es := range fs.Entries {
+			res = &ImportResult{
+				Wallet: w.Name,
+				Folder: fs.Name,
+				CopyResult: &CopyResult{
+					Entry:     es.Name,
+					DestEntry: es.Name,
+					Type:      es.Type,
+				},
+			}
+			// A Folder that doesn't exist yet (in a dry run) can't have conflicts.
+			if exists || !opts.DryRun {
+				if err = f.putEntry(res.CopyResult, es.raw, opts.Policy, opts.DryRun); err != nil {
+					res.Err = err
+					errs = append(errs, fmt.Errorf("%#v/%#v/%#v: %w", w.Name, fs.Name, es.Name, err))
+					err = nil
+				}
+			}
+			results = append(results, res)
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// verify checks that the WalletSnapshot in a backupPayload matches its BackupManifest exactly.
+func (p *backupPayload) verify() (err error) {
+
+	var fs *FolderSnapshot
+	var es *EntrySnapshot
+	var sum [sha256.Size]byte
+
+	if p.Manifest == nil || p.Wallet == nil {
+		err = fmt.Errorf("%w: missing manifest or wallet", ErrBackupCorrupt)
+		return
+	}
+
+	if len(p.Manifest.Folders) != len(p.Wallet.Folders) {
+		err = fmt.Errorf("%w: %d folders, %d in the manifest", ErrBackupCorrupt, len(p.Wallet.Folders), len(p.Manifest.Folders))
+		return
+	}
+
+	for idx, bf := range p.Manifest.Folders {
+		if fs = p.Wallet.Folders[idx]; bf == nil || fs == nil || fs.Name != bf.Name || len(fs.Entries) != len(bf.Entries) {
+			err = fmt.Errorf("%w: folder %d", ErrBackupCorrupt, idx)
+			return
+		}
+		for eIdx, be := range bf.Entries {
+			if es = fs.Entries[eIdx]; be == nil || es == nil || es.Name != be.Name || es.Type != be.Type {
+				err = fmt.Errorf("%w: folder %#v: entry %d", ErrBackupCorrupt, fs.Name, eIdx)
+				return
+			}
+			sum = sha256.Sum256(es.raw)
+			if len(es.raw) != be.Size || hex.EncodeToString(sum[:]) != be.SHA256 {
+				err = fmt.Errorf("%w: %#v/%#v: checksum mismatch", ErrBackupCorrupt, fs.Name, es.Name)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// wipe zeroes the raw values in a backupPayload.
+func (p *backupPayload) wipe() {
+
+	if p == nil || p.Wallet == nil {
+		return
+	}
+
+	for _, fs := range p.Wallet.Folders {
+		if fs == nil {
+			continue
+		}
+		for _, es := range fs.Entries {
+			if es != nil {
+				wipeBytes(es.raw)
+			}
+		}
+	}
+
+	return
+}
+
+// deriveKey derives the encryption key of a backup archive from passphrase, as described by its backupHeader.
+func (h *backupHeader) deriveKey(passphrase []byte) (key []byte, err error) {
+
+	if len(passphrase) == 0 {
+		err = ErrBackupPassphrase
+		return
+	}
+
+	switch h.KDF {
+	case backupKDFScrypt:
+		if !h.scryptBounded() {
+			err = fmt.Errorf("%w: scrypt parameters N=%d r=%d p=%d", ErrBackupFormat, h.ScryptN, h.ScryptR, h.ScryptP)
+			return
+		}
+		if key, err = scrypt.Key(passphrase, h.Salt, h.ScryptN, h.ScryptR, h.ScryptP, backupKeyLen); err != nil {
+			err = fmt.Errorf("%w: %v", ErrBackupFormat, err)
+			return
+		}
+	case backupKDFShamir:
+		err = fmt.Errorf("%w: the archive key is split into key shares (see Wallet.RestoreShares)", ErrBackupFormat)
+		return
+	default:
+		err = fmt.Errorf("%w: KDF %#v", ErrBackupFormat, h.KDF)
+		return
+	}
+
+	return
+}
+
+/*
+	scryptBounded returns true if the scrypt parameters of a backupHeader are within the limits accepted when restoring
+	(see backupScryptMaxN, backupScryptMaxP, and backupScryptMaxMem).
+*/
+func (h *backupHeader) scryptBounded() (bounded bool) {
+
+	if h.ScryptN <= 1 || h.ScryptN > backupScryptMaxN || h.ScryptR <= 0 || h.ScryptP <= 0 || h.ScryptP > backupScryptMaxP {
+		return
+	}
+
+	// Checked first so the product below can't overflow.
+	if int64(h.ScryptR) > backupScryptMaxMem/128 {
+		return
+	}
+
+	bounded = 128*int64(h.ScryptR)*int64(h.ScryptN) <= backupScryptMaxMem
+
+	return
+}
+
+// writePassphraseBackup writes payload to out as a backup archive encrypted with a key derived from passphrase.
+func writePassphraseBackup(out io.Writer, passphrase []byte, payload *backupPayload) (err error) {
+
+	var key []byte
+	var hdr *backupHeader = &backupHeader{
+		Version: BackupVersion,
+		KDF:     backupKDFScrypt,
+		Salt:    make([]byte, backupSaltLen),
+		ScryptN: backupScryptN,
+		ScryptR: backupScryptR,
+		ScryptP: backupScryptP,
+		Cipher:  backupCipher,
+	}
+
+	if _, err = rand.Read(hdr.Salt); err != nil {
+		return
+	}
+
+	if key, err = hdr.deriveKey(passphrase); err != nil {
+		return
+	}
+	defer wipeBytes(key)
+
+	if err = writeBackup(out, hdr, key, payload); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	writeBackup encrypts payload with key and writes it, as a backup archive with header hdr, to out.
+	hdr.Nonce is set to a new random nonce.
+*/
+func writeBackup(out io.Writer, hdr *backupHeader, key []byte, payload *backupPayload) (err error) {
+
+	var aead cipher.AEAD
+	var hdrJSON []byte
+	var plain []byte
+	var sealed []byte
+	var buf bytes.Buffer
+
+	if aead, err = newBackupAEAD(key); err != nil {
+		return
+	}
+
+	hdr.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(hdr.Nonce); err != nil {
+		return
+	}
+
+	if hdrJSON, err = json.Marshal(hdr); err != nil {
+		return
+	}
+
+	buf.WriteString(backupMagic)
+	if err = binary.Write(&buf, binary.BigEndian, uint32(len(hdrJSON))); err != nil {
+		return
+	}
+	buf.Write(hdrJSON)
+
+	if plain, err = json.Marshal(payload); err != nil {
+		return
+	}
+	defer wipeBytes(plain)
+
+	// The header is authenticated (as additional data) along with the payload.
+	sealed = aead.Seal(nil, hdr.Nonce, plain, buf.Bytes())
+	buf.Write(sealed)
+
+	if _, err = buf.WriteTo(out); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	readBackup reads a backup archive from in, returning its header, the bytes that are authenticated as additional data
+	(backupMagic and the header), and the sealed (encrypted) payload.
+*/
+func readBackup(in io.Reader) (hdr *backupHeader, aad []byte, sealed []byte, err error) {
+
+	var raw []byte
+	var hdrLen uint32
+	var start int = len(backupMagic) + 4
+
+	if raw, err = io.ReadAll(in); err != nil {
+		return
+	}
+
+	if len(raw) < start || string(raw[:len(backupMagic)]) != backupMagic {
+		err = ErrBackupFormat
+		return
+	}
+
+	if hdrLen = binary.BigEndian.Uint32(raw[len(backupMagic):start]); hdrLen > backupMaxHeader || int(hdrLen) > len(raw)-start {
+		err = fmt.Errorf("%w: bad header length", ErrBackupFormat)
+		return
+	}
+
+	hdr = new(backupHeader)
+	if err = json.Unmarshal(raw[start:start+int(hdrLen)], hdr); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBackupFormat, err)
+		hdr = nil
+		return
+	}
+
+	if hdr.Version < 1 || hdr.Version > BackupVersion {
+		err = fmt.Errorf("%w: version %d (supported: 1 to %d)", ErrBackupFormat, hdr.Version, BackupVersion)
+		hdr = nil
+		return
+	}
+	if hdr.Cipher != backupCipher {
+		err = fmt.Errorf("%w: cipher %#v", ErrBackupFormat, hdr.Cipher)
+		hdr = nil
+		return
+	}
+
+	aad = raw[:start+int(hdrLen)]
+	sealed = raw[start+int(hdrLen):]
+
+	return
+}
+
+// openBackup decrypts, parses, and verifies the sealed payload of a backup archive.
+func openBackup(hdr *backupHeader, aad, sealed, key []byte) (payload *backupPayload, err error) {
+
+	var aead cipher.AEAD
+	var plain []byte
+
+	if aead, err = newBackupAEAD(key); err != nil {
+		return
+	}
+
+	if len(hdr.Nonce) != aead.NonceSize() {
+		err = fmt.Errorf("%w: bad nonce", ErrBackupFormat)
+		return
+	}
+
+	if plain, err = aead.Open(nil, hdr.Nonce, sealed, aad); err != nil {
+		err = ErrBackupDecrypt
+		return
+	}
+	defer wipeBytes(plain)
+
+	payload = new(backupPayload)
+	if err = json.Unmarshal(plain, payload); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBackupCorrupt, err)
+		payload = nil
+		return
+	}
+
+	if err = payload.verify(); err != nil {
+		payload.wipe()
+		payload = nil
+		return
+	}
+
+	return
+}
+
+// newBackupAEAD returns the backupCipher AEAD for key.
+func newBackupAEAD(key []byte) (aead cipher.AEAD, err error) {
+
+	var block cipher.Block
+
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,153 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestBackup tests writing and restoring (including selectively, and as a dry run) an encrypted backup archive.
+func TestBackup(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var e2 *testEnv
+	var buf bytes.Buffer
+	var archive []byte
+	var tampered []byte
+	var crafted []byte
+	var manifest *BackupManifest
+	var results []*ImportResult
+	var doc *ExportDocument
+	var doc2 *ExportDocument
+	var folders []string
+	var pw string
+	var passphrase []byte = []byte("correct horse battery staple")
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+	if _, err = e.wm.Put("kwallet://"+e.w.Name+"/Other/entry", "other"); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+
+	if _, err = e.w.Backup(&buf, nil); !errors.Is(err, ErrBackupPassphrase) {
+		t.Errorf("expected ErrBackupPassphrase, got %v", err)
+	}
+	if manifest, err = e.w.Backup(&buf, passphrase); err != nil {
+		t.Fatalf("failed to Backup: %v", err)
+	}
+	archive = buf.Bytes()
+	if len(manifest.Folders) != 2 || manifest.Wallet != e.w.Name {
+		t.Errorf("unexpected BackupManifest: %+v", manifest)
+	}
+	if bytes.Contains(archive, []byte(testPassword)) || bytes.Contains(archive, testBytes) ||
+		bytes.Contains(archive, []byte(passwordTest.String())) {
+		t.Errorf("backup archive contains plaintext")
+	}
+
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+
+	// Wrong passphrase, and a modified archive.
+	if _, _, err = e2.w.Restore(bytes.NewReader(archive), []byte("wrong"), nil); !errors.Is(err, ErrBackupDecrypt) {
+		t.Errorf("expected ErrBackupDecrypt for a wrong passphrase, got %v", err)
+	}
+	tampered = append([]byte{}, archive...)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, _, err = e2.w.Restore(bytes.NewReader(tampered), passphrase, nil); !errors.Is(err, ErrBackupDecrypt) {
+		t.Errorf("expected ErrBackupDecrypt for a modified archive, got %v", err)
+	}
+	if _, _, err = e2.w.Restore(bytes.NewReader([]byte("not a backup")), passphrase, nil); !errors.Is(err, ErrBackupFormat) {
+		t.Errorf("expected ErrBackupFormat, got %v", err)
+	}
+
+	// Selective restore.
+	if _, _, err = e2.w.Restore(bytes.NewReader(archive), passphrase, &RestoreOpts{Folders: []string{"Missing"}}); !errors.Is(err, ErrBackupNoFolder) {
+		t.Errorf("expected ErrBackupNoFolder, got %v", err)
+	}
+	if _, results, err = e2.w.Restore(bytes.NewReader(archive), passphrase, &RestoreOpts{Folders: []string{"Other"}}); err != nil {
+		t.Fatalf("failed to Restore selectively: %v", err)
+	}
+	if len(results) != 1 || results[0].Folder != "Other" {
+		t.Errorf("unexpected selective restore results: %v", len(results))
+	}
+	if folders, err = e2.w.ListFolders(); err != nil {
+		t.Fatalf("failed to ListFolders: %v", err)
+	}
+	for _, fn := range folders {
+		if fn == e.f.Name {
+			if pw, _ = e2.wm.ResolveString("kwallet://" + e2.w.Name + "/" + e.f.Name + "/" + passwordTest.String()); pw != "" {
+				t.Errorf("Folder %#v was restored but not selected", fn)
+			}
+		}
+	}
+
+	// Dry run: conflicts are reported but nothing is written.
+	if _, err = e2.f.WritePassword(passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, results, err = e2.w.Restore(bytes.NewReader(archive), passphrase, &RestoreOpts{DryRun: true}); err == nil {
+		t.Errorf("expected a conflict in a dry run")
+	}
+	for _, r := range results {
+		if r.Entry == passwordTest.String() && !errors.Is(r.Err, ErrEntryExists) {
+			t.Errorf("expected ErrEntryExists for %v, got %v", r.Entry, r.Err)
+		}
+	}
+	if _, results, err = e2.w.Restore(bytes.NewReader(archive), passphrase, &RestoreOpts{DryRun: true, Policy: ConflictOverwrite}); err != nil {
+		t.Fatalf("failed dry run: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("expected 5 dry run results, got %d", len(results))
+	}
+	if pw, err = e2.wm.ResolveString("kwallet://" + e2.w.Name + "/" + e2.f.Name + "/" + passwordTest.String()); err != nil || pw != testPasswordReplace {
+		t.Errorf("dry run wrote a value: %#v (err: %v)", pw, err)
+	}
+
+	// Full restore.
+	if manifest, _, err = e2.w.Restore(bytes.NewReader(archive), passphrase, &RestoreOpts{Policy: ConflictOverwrite}); err != nil {
+		t.Fatalf("failed to Restore: %v", err)
+	}
+	if manifest.Wallet != e.w.Name {
+		t.Errorf("unexpected restored BackupManifest: %+v", manifest)
+	}
+	if doc, err = e.w.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if doc2, err = e2.w.Export(); err != nil {
+		t.Fatalf("failed to Export restored Wallet: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Wallets, doc2.Wallets) {
+		t.Errorf("restored Wallet does not match the backed up one")
+	}
+
+	// Crafted scrypt parameters are rejected before deriving a key.
+	for _, hdr := range []*backupHeader{
+		{ScryptN: 1 << 14, ScryptR: 1 << 20, ScryptP: 1},
+		{ScryptN: backupScryptMaxN, ScryptR: backupScryptR * 2, ScryptP: 1},
+		{ScryptN: 1 << 14, ScryptR: 1, ScryptP: 1 << 20},
+	} {
+		hdr.Version = BackupVersion
+		hdr.KDF = backupKDFScrypt
+		hdr.Cipher = backupCipher
+		hdr.Salt = make([]byte, backupSaltLen)
+		if crafted, err = json.Marshal(hdr); err != nil {
+			t.Fatalf("failed to marshal backupHeader: %v", err)
+		}
+		buf.Reset()
+		buf.WriteString(backupMagic)
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(crafted)))
+		buf.Write(crafted)
+		if _, _, err = e2.w.Restore(&buf, passphrase, nil); !errors.Is(err, ErrBackupFormat) {
+			t.Errorf("expected ErrBackupFormat for scrypt N=%d r=%d p=%d, got %v", hdr.ScryptN, hdr.ScryptR, hdr.ScryptP, err)
+		}
+	}
+}
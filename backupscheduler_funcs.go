@@ -0,0 +1,415 @@
+package gokwallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	NewBackupScheduler returns a BackupScheduler that backs up the Wallets of a WalletManager to directory dir
+	every interval, encrypted with passphrase (a copy of which is kept; see Wallet.Backup).
+	The other fields (e.g. BackupScheduler.Wallets and BackupScheduler.Retention) may be set before calling
+	BackupScheduler.Run or BackupScheduler.Start.
+*/
+func NewBackupScheduler(wm *WalletManager, dir string, passphrase []byte, interval time.Duration) (s *BackupScheduler, err error) {
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if dir == "" {
+		err = ErrBackupDir
+		return
+	}
+
+	if len(passphrase) == 0 {
+		err = ErrBackupPassphrase
+		return
+	}
+
+	s = &BackupScheduler{
+		Interval:   interval,
+		Dir:        dir,
+		wm:         wm,
+		passphrase: make([]byte, len(passphrase)),
+	}
+	copy(s.passphrase, passphrase)
+
+	return
+}
+
+/*
+	Run backs up each Wallet of a BackupScheduler (see BackupScheduler.Wallets) once, skipping unchanged ones,
+	and then prunes old backup archives (see BackupScheduler.Retention).
+	Failures for individual Wallets do not stop the run; each is passed to BackupScheduler.OnFailure,
+	and err is a MultiError of them.
+*/
+func (s *BackupScheduler) Run() (report *BackupReport, err error) {
+
+	var walletNames []string
+	var res *BackupResult
+	var errs []error = make([]error, 0)
+
+	report = &BackupReport{
+		Started: time.Now(),
+		Wallets: make([]*BackupResult, 0),
+	}
+
+	if walletNames, err = s.walletNames(); err == nil {
+		err = os.MkdirAll(s.Dir, backupDirMode)
+	}
+	if err != nil {
+		s.finish(report, err)
+		return
+	}
+
+	for _, wn := range walletNames {
+		res = s.backup(wn)
+		if res.Err != nil {
+			res.Error = res.Err.Error()
+			errs = append(errs, fmt.Errorf("wallet %#v: %w", wn, res.Err))
+			if s.OnFailure != nil {
+				s.OnFailure(res)
+			}
+		}
+		report.Wallets = append(report.Wallets, res)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	s.finish(report, err)
+
+	return
+}
+
+/*
+	Start runs a BackupScheduler in the background until BackupScheduler.Stop is called:
+	a run (see BackupScheduler.Run) is made immediately and then every BackupScheduler.Interval.
+	Each BackupReport is passed to BackupScheduler.OnRun.
+*/
+func (s *BackupScheduler) Start() (err error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running {
+		err = ErrSchedulerRunning
+		return
+	}
+
+	if s.Interval <= 0 {
+		err = ErrBackupInterval
+		return
+	}
+
+	s.running = true
+	s.stop = make(chan bool)
+	s.done = make(chan bool)
+
+	go s.run(s.stop, s.done)
+
+	return
+}
+
+// Stop stops a running BackupScheduler (see BackupScheduler.Start), waiting for any run in progress to finish.
+func (s *BackupScheduler) Stop() {
+
+	var done chan bool
+
+	s.lock.Lock()
+
+	if !s.running {
+		s.lock.Unlock()
+		return
+	}
+
+	// The lock isn't held while waiting for a run in progress, which may take a while.
+	s.running = false
+	close(s.stop)
+	done = s.done
+	s.lock.Unlock()
+
+	<-done
+
+	return
+}
+
+// finish completes report.
+func (s *BackupScheduler) finish(report *BackupReport, err error) {
+
+	report.Finished = time.Now()
+	report.Err = err
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return
+}
+
+// run is the loop of a running BackupScheduler.
+func (s *BackupScheduler) run(stop, done chan bool) {
+
+	var ticker *time.Ticker
+	var report *BackupReport
+
+	defer close(done)
+
+	ticker = time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, _ = s.Run()
+		if s.OnRun != nil {
+			s.OnRun(report)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backup backs up (unless unchanged) and then prunes Wallet walletName.
+func (s *BackupScheduler) backup(walletName string) (res *BackupResult) {
+
+	var err error
+	var closeErr error
+	var tmp *os.File
+	var files []*backupFile
+	var payload *backupPayload
+	var now time.Time = time.Now().UTC()
+
+	res = &BackupResult{
+		Wallet: walletName,
+	}
+
+	defer func() {
+		res.Err = err
+	}()
+
+	if err = s.wm.withWallet(walletName, func(w *Wallet) (err error) {
+		payload, err = w.backupPayload()
+		return
+	}); err != nil {
+		return
+	}
+	defer payload.wipe()
+
+	res.Fingerprint = payload.fingerprint(s.passphrase)
+
+	if files, err = s.files(walletName); err != nil {
+		return
+	}
+
+	if len(files) != 0 && files[0].fingerprint == res.Fingerprint {
+		res.Unchanged = true
+	} else {
+		// Write to a temporary file first, so an interrupted backup never looks like a complete one.
+		if tmp, err = os.CreateTemp(s.Dir, ".tmp-*"); err != nil {
+			return
+		}
+		if err = writePassphraseBackup(tmp, s.passphrase, payload); err == nil {
+			err = tmp.Sync()
+		}
+		if closeErr = tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			res.Path = filepath.Join(
+				s.Dir,
+				fmt.Sprintf(
+					"%v_%v_%v%v", url.PathEscape(walletName), now.Format(backupTimeFormat), res.Fingerprint, BackupFileExt,
+				),
+			)
+			err = os.Rename(tmp.Name(), res.Path)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			res.Path = ""
+			return
+		}
+		files = append([]*backupFile{{path: res.Path, wallet: walletName, taken: now, fingerprint: res.Fingerprint}}, files...)
+	}
+
+	if res.Pruned, err = s.prune(files); err != nil {
+		return
+	}
+
+	return
+}
+
+// files returns the backup archives of Wallet walletName in BackupScheduler.Dir, newest first.
+func (s *BackupScheduler) files(walletName string) (files []*backupFile, err error) {
+
+	var ok bool
+	var bf *backupFile
+	var dirEntries []os.DirEntry
+
+	if dirEntries, err = os.ReadDir(s.Dir); err != nil {
+		return
+	}
+
+	files = make([]*backupFile, 0)
+
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		if bf, ok = parseBackupFileName(de.Name()); !ok || bf.wallet != walletName {
+			continue
+		}
+		bf.path = filepath.Join(s.Dir, de.Name())
+		files = append(files, bf)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].taken.After(files[j].taken) })
+
+	return
+}
+
+// prune removes the backup archives in files (newest first) that BackupScheduler.Retention does not keep.
+func (s *BackupScheduler) prune(files []*backupFile) (pruned []string, err error) {
+
+	var year int
+	var week int
+	var day string
+	var weekKey string
+	var keep bool
+	var days map[string]bool = make(map[string]bool)
+	var weeks map[string]bool = make(map[string]bool)
+	var errs []error = make([]error, 0)
+
+	if s.Retention.Daily <= 0 && s.Retention.Weekly <= 0 {
+		return
+	}
+
+	for idx, bf := range files {
+		keep = idx == 0
+		day = bf.taken.Format("2006-01-02")
+		if !days[day] && len(days) < s.Retention.Daily {
+			days[day] = true
+			keep = true
+		}
+		year, week = bf.taken.ISOWeek()
+		weekKey = fmt.Sprintf("%d-W%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < s.Retention.Weekly {
+			weeks[weekKey] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err = os.Remove(bf.path); err != nil {
+			errs = append(errs, err)
+			err = nil
+			continue
+		}
+		pruned = append(pruned, bf.path)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// walletNames returns the names of the Wallets to back up.
+func (s *BackupScheduler) walletNames() (walletNames []string, err error) {
+
+	if len(s.Wallets) != 0 {
+		walletNames = s.Wallets
+		return
+	}
+
+	if walletNames, err = s.wm.WalletNames(); err != nil {
+		return
+	}
+
+	sort.Strings(walletNames)
+
+	return
+}
+
+/*
+	fingerprint returns a fingerprint of the contents of a backupPayload (every Folder and WalletItem, but not when it was taken).
+	It is an HMAC keyed with key (the backup passphrase), so it can be used in file names without revealing anything about the contents.
+*/
+func (p *backupPayload) fingerprint(key []byte) (fp string) {
+
+	var lenBuf [8]byte
+	var fields []string = make([]string, 0)
+	var mac hash.Hash = hmac.New(sha256.New, key)
+
+	for _, bf := range p.Manifest.Folders {
+		fields = append(fields, bf.Name)
+		for _, be := range bf.Entries {
+			fields = append(fields, be.Name, be.Type.String(), be.SHA256)
+		}
+	}
+
+	// Each field is length-prefixed so that different contents can't produce the same input.
+	for _, f := range fields {
+		binary.BigEndian.PutUint64(lenBuf[:], uint64(len(f)))
+		mac.Write(lenBuf[:])
+		mac.Write([]byte(f))
+	}
+
+	fp = hex.EncodeToString(mac.Sum(nil))[:backupFingerprintLen]
+
+	return
+}
+
+// parseBackupFileName parses the name of a backup archive written by a BackupScheduler. ok is false if it isn't one.
+func parseBackupFileName(name string) (bf *backupFile, ok bool) {
+
+	var err error
+	var parts []string
+	var walletName string
+	var taken time.Time
+
+	if !strings.HasSuffix(name, BackupFileExt) {
+		return
+	}
+
+	// Wallet names are escaped, but may still contain "_", so the time and fingerprint are taken from the end.
+	if parts = strings.Split(strings.TrimSuffix(name, BackupFileExt), "_"); len(parts) < 3 {
+		return
+	}
+
+	if walletName, err = url.PathUnescape(strings.Join(parts[:len(parts)-2], "_")); err != nil || walletName == "" {
+		return
+	}
+
+	if taken, err = time.Parse(backupTimeFormat, parts[len(parts)-2]); err != nil {
+		return
+	}
+
+	if len(parts[len(parts)-1]) != backupFingerprintLen {
+		return
+	}
+
+	bf = &backupFile{
+		wallet:      walletName,
+		taken:       taken,
+		fingerprint: parts[len(parts)-1],
+	}
+	ok = true
+
+	return
+}
@@ -0,0 +1,135 @@
+package gokwallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestBackupScheduler tests BackupScheduler runs, skipping unchanged Wallets, retention, and failure reporting.
+func TestBackupScheduler(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var e2 *testEnv
+	var s *BackupScheduler
+	var dir string = t.TempDir()
+	var report *BackupReport
+	var res *BackupResult
+	var archive []byte
+	var old string
+	var failed []*BackupResult
+	var wm *WalletManager
+	var mem *MemoryBackend
+	var handles int
+	var runs chan *BackupReport = make(chan *BackupReport, 4)
+	var passphrase []byte = []byte("scheduled passphrase")
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	if _, err = NewBackupScheduler(e.wm, "", passphrase, time.Hour); !errors.Is(err, ErrBackupDir) {
+		t.Errorf("expected ErrBackupDir, got %v", err)
+	}
+	if s, err = NewBackupScheduler(e.wm, dir, passphrase, time.Hour); err != nil {
+		t.Fatalf("failed to get BackupScheduler: %v", err)
+	}
+	s.Wallets = []string{e.w.Name}
+	s.Retention = BackupRetention{Daily: 2}
+	s.OnFailure = func(r *BackupResult) { failed = append(failed, r) }
+
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if report, err = s.Run(); err != nil {
+		t.Fatalf("failed to Run: %v", err)
+	}
+	if res = report.Wallets[0]; res.Path == "" || res.Unchanged || res.Fingerprint == "" {
+		t.Fatalf("unexpected first BackupResult: %+v", res)
+	}
+	// The Wallets opened for a run are closed again.
+	if len(mem.handles) != handles {
+		t.Errorf("Run leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+
+	// The archive must be restorable.
+	if archive, err = os.ReadFile(res.Path); err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+	if _, _, err = e2.w.Restore(bytes.NewReader(archive), passphrase, nil); err != nil {
+		t.Errorf("failed to Restore scheduled archive: %v", err)
+	}
+
+	if report, err = s.Run(); err != nil {
+		t.Fatalf("failed to Run: %v", err)
+	}
+	if !report.Wallets[0].Unchanged || report.Wallets[0].Path != "" || report.Wallets[0].Fingerprint != res.Fingerprint {
+		t.Errorf("expected an unchanged Wallet to be skipped: %+v", report.Wallets[0])
+	}
+
+	// Older archives: two of them on days beyond the retention are pruned.
+	for _, days := range []int{10, 9, 2} {
+		old = filepath.Join(dir, fmt.Sprintf(
+			"%v_%v_%v%v",
+			url.PathEscape(e.w.Name), time.Now().UTC().AddDate(0, 0, -days).Format(backupTimeFormat), "0123456789abcdef", BackupFileExt,
+		))
+		if err = os.WriteFile(old, []byte("old"), 0600); err != nil {
+			t.Fatalf("failed to write old archive: %v", err)
+		}
+	}
+	if _, err = e.f.WritePassword(passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if report, err = s.Run(); err != nil {
+		t.Fatalf("failed to Run: %v", err)
+	}
+	if res = report.Wallets[0]; res.Path == "" || res.Unchanged {
+		t.Errorf("expected a changed Wallet to be backed up: %+v", res)
+	}
+	// Today's first archive, and those from 9 and 10 days ago.
+	if len(res.Pruned) != 3 {
+		t.Errorf("expected 3 pruned archives, got %v", res.Pruned)
+	}
+
+	// In-process scheduling.
+	s.OnRun = func(r *BackupReport) { runs <- r }
+	if err = s.Start(); err != nil {
+		t.Fatalf("failed to Start: %v", err)
+	}
+	if err = s.Start(); !errors.Is(err, ErrSchedulerRunning) {
+		t.Errorf("expected ErrSchedulerRunning, got %v", err)
+	}
+	select {
+	case report = <-runs:
+		if report.Err != nil || !report.Wallets[0].Unchanged {
+			t.Errorf("unexpected scheduled BackupReport: %+v", report)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("BackupScheduler did not run")
+	}
+	s.Stop()
+
+	// Failures are reported.
+	if wm, err = NewWalletManagerBackend(&folderListFailBackend{Backend: e.wm.Backend()}, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if s, err = NewBackupScheduler(wm, dir, passphrase, time.Hour); err != nil {
+		t.Fatalf("failed to get BackupScheduler: %v", err)
+	}
+	s.Wallets = []string{e.w.Name}
+	s.OnFailure = func(r *BackupResult) { failed = append(failed, r) }
+	if _, err = s.Run(); err == nil || len(failed) != 1 || !errors.Is(failed[0].Err, ErrOperationFailed) {
+		t.Errorf("expected a reported failure, got %v (%d failures)", err, len(failed))
+	}
+}
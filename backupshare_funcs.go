@@ -0,0 +1,275 @@
+package gokwallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	BackupShares writes an encrypted backup archive of a Wallet to out, as with Wallet.Backup, but instead of deriving
+	the key from a passphrase it uses a random key that is split into count Shamir key shares, any threshold of which
+	recover it (fewer reveal nothing about it). threshold must be at least 2, and count at most BackupShareMax.
+
+	The shares are returned as printable text (see ParseBackupShare), to be given to separate custodians;
+	the key itself is never stored. Use Wallet.RestoreShares to restore the archive.
+	Each call uses a new key, so shares only ever open the archive they were made with.
+*/
+func (w *Wallet) BackupShares(out io.Writer, threshold, count int) (manifest *BackupManifest, shares []string, err error) {
+
+	var key []byte = make([]byte, backupKeyLen)
+	var raw [][]byte
+	var payload *backupPayload
+	var hdr *backupHeader = &backupHeader{
+		Version:        BackupVersion,
+		KDF:            backupKDFShamir,
+		ShareID:        make([]byte, backupShareIDLen),
+		ShareThreshold: threshold,
+		ShareCount:     count,
+		Cipher:         backupCipher,
+	}
+
+	defer wipeBytes(key)
+
+	if threshold < 2 || threshold > count || count > BackupShareMax {
+		err = ErrBackupShareCount
+		return
+	}
+
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	if _, err = rand.Read(hdr.ShareID); err != nil {
+		return
+	}
+
+	if raw, err = shamirSplit(key, threshold, count); err != nil {
+		return
+	}
+
+	if payload, err = w.backupPayload(); err != nil {
+		return
+	}
+	defer payload.wipe()
+
+	if err = writeBackup(out, hdr, key, payload); err != nil {
+		return
+	}
+
+	shares = make([]string, 0, count)
+	for idx, r := range raw {
+		shares = append(shares, encodeBackupShare(hdr.ShareID, threshold, idx+1, r))
+		wipeBytes(r)
+	}
+
+	manifest = payload.Manifest
+
+	return
+}
+
+/*
+	RestoreShares restores a backup archive written by Wallet.BackupShares into Wallet w, recovering its key from shares
+	(at least the threshold it was split with, in any order; duplicates are ignored).
+	Otherwise it is the same as Wallet.Restore.
+	ErrBackupShares is returned if the shares don't belong to the archive or there are too few of them,
+	and ErrBackupShare if one can't be parsed.
+*/
+func (w *Wallet) RestoreShares(in io.Reader, shares []string, opts *RestoreOpts) (manifest *BackupManifest, results []*ImportResult, err error) {
+
+	var parsed []*BackupShare
+
+	if parsed, err = parseBackupShares(shares); err != nil {
+		return
+	}
+
+	if manifest, results, err = w.restore(in, opts, func(hdr *backupHeader) (key []byte, err error) {
+		key, err = combineBackupShares(hdr, parsed)
+		return
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ParseBackupShare parses a key share written by Wallet.BackupShares. Case, spaces, and line breaks are ignored,
+	and a checksum catches most typos (see ErrBackupShare).
+	The returned BackupShare identifies the archive the share belongs to, so custodians can check their shares
+	without the archive.
+*/
+func ParseBackupShare(share string) (bs *BackupShare, err error) {
+
+	var raw []byte
+	var sum [sha256.Size]byte
+	var body []byte
+	var b strings.Builder
+
+	for _, r := range strings.ToUpper(share) {
+		switch r {
+		case ' ', '\t', '\r', '\n', '-':
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	if !strings.HasPrefix(b.String(), backupSharePrefix) {
+		err = fmt.Errorf("%w: missing %v prefix", ErrBackupShare, backupSharePrefix)
+		return
+	}
+
+	if raw, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(
+		strings.TrimPrefix(b.String(), backupSharePrefix),
+	); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBackupShare, err)
+		return
+	}
+	defer wipeBytes(raw)
+
+	// version, ID, threshold, index, value, checksum
+	if len(raw) < 1+backupShareIDLen+2+1+backupShareCheckLen {
+		err = fmt.Errorf("%w: too short", ErrBackupShare)
+		return
+	}
+
+	body = raw[:len(raw)-backupShareCheckLen]
+	if sum = sha256.Sum256(body); !bytes.Equal(sum[:backupShareCheckLen], raw[len(body):]) {
+		err = fmt.Errorf("%w: checksum mismatch", ErrBackupShare)
+		return
+	}
+
+	if body[0] != backupShareVersion {
+		err = fmt.Errorf("%w: version %d", ErrBackupShare, body[0])
+		return
+	}
+
+	bs = &BackupShare{
+		ArchiveID: hex.EncodeToString(body[1 : 1+backupShareIDLen]),
+		Threshold: int(body[1+backupShareIDLen]),
+		Index:     int(body[2+backupShareIDLen]),
+		value:     make([]byte, len(body)-(3+backupShareIDLen)),
+	}
+	copy(bs.value, body[3+backupShareIDLen:])
+
+	if bs.Threshold < 2 || bs.Index < 1 {
+		err = fmt.Errorf("%w: bad threshold or index", ErrBackupShare)
+		bs = nil
+		return
+	}
+
+	return
+}
+
+// String returns a representation of a BackupShare (without its secret part).
+func (b BackupShare) String() (str string) {
+
+	str = fmt.Sprintf("BackupShare{ArchiveID: %q, Threshold: %d, Index: %d}", b.ArchiveID, b.Threshold, b.Index)
+
+	return
+}
+
+// Format prints BackupShare.String for every verb (see formatRedacted).
+func (b BackupShare) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, b.String())
+
+	return
+}
+
+// combineBackupShares recovers the key of the backup archive with header hdr from shares.
+func combineBackupShares(hdr *backupHeader, shares []*BackupShare) (key []byte, err error) {
+
+	var xs []byte
+	var ys [][]byte
+	var seen map[int]bool = make(map[int]bool)
+	var archiveID string = hex.EncodeToString(hdr.ShareID)
+
+	if hdr.KDF != backupKDFShamir {
+		err = fmt.Errorf("%w: the archive is not protected by key shares (KDF %#v)", ErrBackupFormat, hdr.KDF)
+		return
+	}
+
+	for _, bs := range shares {
+		if bs.ArchiveID != archiveID || bs.Threshold != hdr.ShareThreshold || bs.Index > hdr.ShareCount {
+			err = fmt.Errorf("%w: share %d is for a different archive (%v)", ErrBackupShares, bs.Index, bs.ArchiveID)
+			return
+		}
+		if seen[bs.Index] {
+			continue
+		}
+		seen[bs.Index] = true
+		xs = append(xs, byte(bs.Index))
+		ys = append(ys, bs.value)
+	}
+
+	if len(xs) < hdr.ShareThreshold {
+		err = fmt.Errorf("%w: %d of the %d shares needed", ErrBackupShares, len(xs), hdr.ShareThreshold)
+		return
+	}
+
+	if key, err = shamirCombine(xs, ys); err != nil {
+		return
+	}
+
+	if len(key) != backupKeyLen {
+		wipeBytes(key)
+		key = nil
+		err = fmt.Errorf("%w: bad key length", ErrBackupShares)
+		return
+	}
+
+	return
+}
+
+// parseBackupShares parses each of shares (see ParseBackupShare).
+func parseBackupShares(shares []string) (parsed []*BackupShare, err error) {
+
+	var bs *BackupShare
+
+	parsed = make([]*BackupShare, 0, len(shares))
+
+	for idx, s := range shares {
+		if bs, err = ParseBackupShare(s); err != nil {
+			err = fmt.Errorf("share %d: %w", idx+1, err)
+			parsed = nil
+			return
+		}
+		parsed = append(parsed, bs)
+	}
+
+	return
+}
+
+// encodeBackupShare returns the text encoding of a key share (see ParseBackupShare).
+func encodeBackupShare(id []byte, threshold, index int, value []byte) (share string) {
+
+	var enc string
+	var sum [sha256.Size]byte
+	var raw []byte = make([]byte, 0, 1+len(id)+2+len(value)+backupShareCheckLen)
+	var groups []string = []string{backupSharePrefix}
+
+	defer wipeBytes(raw)
+
+	raw = append(raw, backupShareVersion)
+	raw = append(raw, id...)
+	raw = append(raw, byte(threshold), byte(index))
+	raw = append(raw, value...)
+	sum = sha256.Sum256(raw)
+	raw = append(raw, sum[:backupShareCheckLen]...)
+
+	enc = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+	for len(enc) > backupShareGroup {
+		groups = append(groups, enc[:backupShareGroup])
+		enc = enc[backupShareGroup:]
+	}
+	groups = append(groups, enc)
+
+	share = strings.Join(groups, "-")
+
+	return
+}
@@ -0,0 +1,146 @@
+package gokwallet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestBackupShares tests splitting a backup archive key into key shares and restoring with them.
+func TestBackupShares(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var e2 *testEnv
+	var buf bytes.Buffer
+	var other bytes.Buffer
+	var archive []byte
+	var shares []string
+	var otherShares []string
+	var bs *BackupShare
+	var manifest *BackupManifest
+	var results []*ImportResult
+	var pw string
+	var typo []byte
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	for _, tc := range [][2]int{{1, 3}, {4, 3}, {2, BackupShareMax + 1}} {
+		if _, _, err = e.w.BackupShares(&buf, tc[0], tc[1]); !errors.Is(err, ErrBackupShareCount) {
+			t.Errorf("expected ErrBackupShareCount for %d of %d, got %v", tc[0], tc[1], err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("archive written despite invalid share counts")
+	}
+
+	if manifest, shares, err = e.w.BackupShares(&buf, 3, 5); err != nil {
+		t.Fatalf("failed to BackupShares: %v", err)
+	}
+	archive = buf.Bytes()
+	if len(shares) != 5 || manifest.Wallet != e.w.Name {
+		t.Fatalf("unexpected shares/manifest: %d shares, %+v", len(shares), manifest)
+	}
+	if bytes.Contains(archive, []byte(testPassword)) {
+		t.Errorf("backup archive contains plaintext")
+	}
+
+	// Parsing (ignoring case and whitespace) and checksums.
+	if bs, err = ParseBackupShare(" " + strings.ToLower(shares[1]) + "\n"); err != nil {
+		t.Fatalf("failed to ParseBackupShare: %v", err)
+	}
+	if bs.Index != 2 || bs.Threshold != 3 || bs.ArchiveID == "" {
+		t.Errorf("unexpected BackupShare: %v", bs)
+	}
+	if strings.Contains(bs.String(), shares[1]) {
+		t.Errorf("BackupShare.String contains the share")
+	}
+	typo = []byte(shares[0])
+	if typo[20] == 'A' {
+		typo[20] = 'B'
+	} else {
+		typo[20] = 'A'
+	}
+	if _, err = ParseBackupShare(string(typo)); !errors.Is(err, ErrBackupShare) {
+		t.Errorf("expected ErrBackupShare for a typo, got %v", err)
+	}
+
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+
+	// A passphrase can't open it, too few shares can't, and neither can shares of another archive.
+	if _, _, err = e2.w.Restore(bytes.NewReader(archive), []byte("passphrase"), nil); !errors.Is(err, ErrBackupFormat) {
+		t.Errorf("expected ErrBackupFormat restoring with a passphrase, got %v", err)
+	}
+	if _, _, err = e2.w.RestoreShares(bytes.NewReader(archive), []string{shares[0], shares[3], shares[3]}, nil); !errors.Is(err, ErrBackupShares) {
+		t.Errorf("expected ErrBackupShares for too few shares, got %v", err)
+	}
+	if _, otherShares, err = e.w.BackupShares(&other, 3, 5); err != nil {
+		t.Fatalf("failed to BackupShares: %v", err)
+	}
+	if _, _, err = e2.w.RestoreShares(bytes.NewReader(archive), otherShares[:3], nil); !errors.Is(err, ErrBackupShares) {
+		t.Errorf("expected ErrBackupShares for another archive's shares, got %v", err)
+	}
+
+	// Any three shares, in any order.
+	if _, results, err = e2.w.RestoreShares(
+		bytes.NewReader(archive), []string{shares[4], shares[0], shares[2]}, &RestoreOpts{DryRun: true},
+	); err != nil {
+		t.Fatalf("failed to RestoreShares (dry run): %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("expected 4 dry-run results, got %d", len(results))
+	}
+	if manifest, _, err = e2.w.RestoreShares(bytes.NewReader(archive), []string{shares[3], shares[1], shares[2]}, nil); err != nil {
+		t.Fatalf("failed to RestoreShares: %v", err)
+	}
+	if manifest.Wallet != e.w.Name {
+		t.Errorf("unexpected restored manifest: %+v", manifest)
+	}
+	if pw, err = e2.wm.ResolveString("kwallet://" + e2.w.Name + "/" + e2.f.Name + "/" + passwordTest.String()); err != nil || pw != testPassword {
+		t.Errorf("restored password mismatch: %v (%v)", pw, err)
+	}
+}
+
+// TestShamir tests the Shamir secret sharing used for backup archive key shares.
+func TestShamir(t *testing.T) {
+
+	var err error
+	var shares [][]byte
+	var secret []byte
+	var recovered []byte
+	var x byte
+
+	for a := 1; a < 256; a++ {
+		if x = gfMul(byte(a), gfInv(byte(a))); x != 1 {
+			t.Fatalf("gfInv(%d) is wrong: a * inv = %d", a, x)
+		}
+	}
+
+	secret = []byte("0123456789abcdef0123456789abcdef")
+	if shares, err = shamirSplit(secret, 2, 3); err != nil {
+		t.Fatalf("failed to shamirSplit: %v", err)
+	}
+	for _, xs := range [][]byte{{1, 2}, {2, 3}, {3, 1}, {1, 2, 3}} {
+		var ys [][]byte
+		for _, x = range xs {
+			ys = append(ys, shares[x-1])
+		}
+		if recovered, err = shamirCombine(xs, ys); err != nil {
+			t.Fatalf("failed to shamirCombine %v: %v", xs, err)
+		}
+		if !bytes.Equal(recovered, secret) {
+			t.Errorf("shamirCombine %v recovered the wrong secret", xs)
+		}
+	}
+	if recovered, err = shamirCombine([]byte{1}, shares[:1]); err != nil || bytes.Equal(recovered, secret) {
+		t.Errorf("a single share of a 2-of-3 split recovered the secret (%v)", err)
+	}
+}
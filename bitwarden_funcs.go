@@ -0,0 +1,423 @@
+package gokwallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+/*
+	ExportBitwarden writes a Wallet to out as a Bitwarden unencrypted JSON export, which Bitwarden (and Vaultwarden) can import.
+	The export is NOT encrypted; handle it as carefully as the Wallet itself.
+
+	Each Folder is a Bitwarden folder, and each WalletItem is an item named after it:
+
+		* a Password is a login item with its password set; if the Folder also has a Map named after the Password
+		  with BitwardenLoginSuffix appended (as Wallet.ImportBitwarden writes them), that Map's "username", "uris"
+		  (one per line), "notes", and "totp" keys are the login's fields and its other keys are custom fields,
+		* any other Map is a secure note with its "notes" key as its notes and its other keys as (hidden) custom fields, and
+		* a Blob (or UnknownItem) is a secure note with its value (base64-encoded) as its notes
+		  and a custom field recording its type (see BitwardenTypeKey).
+
+	A Map with a BitwardenTypeKey key cannot be exported.
+*/
+func (w *Wallet) ExportBitwarden(out io.Writer) (err error) {
+
+	var ws *WalletSnapshot
+	var ew *ExportWallet
+	var file *bitwardenFile
+	var enc *json.Encoder
+
+	if ws, err = w.Snapshot(); err != nil {
+		return
+	}
+	if ew, err = exportWallet(ws); err != nil {
+		return
+	}
+
+	if file, err = bitwardenFromExport(ew); err != nil {
+		return
+	}
+
+	enc = json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(file); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ImportBitwarden imports a Bitwarden unencrypted JSON export into Wallet w
+	(see ParseBitwarden for how it is converted, and Wallet.Import for opts). Like Wallet.Import, it writes
+	each item with Folder.WritePassword, Folder.WriteMap, or Folder.WriteBlob.
+*/
+func (w *Wallet) ImportBitwarden(in io.Reader, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var doc *ExportDocument
+
+	if doc, err = ParseBitwarden(in); err != nil {
+		return
+	}
+
+	if results, err = w.Import(doc, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ParseBitwarden reads a Bitwarden unencrypted JSON export from in and returns it as an ExportDocument
+	with a single ExportWallet named "Bitwarden", which can be imported (e.g. with Wallet.Import, or WalletManager.Import
+	with ImportOpts.Wallet set). Encrypted exports are not supported (ErrBitwardenUnsupported).
+
+	Each Bitwarden folder becomes an ExportFolder of the same name (nested folders are already named "Parent/Child"),
+	and items not in a folder go in an ExportFolder named "Passwords". Deleted items are skipped.
+	Each item is named after its name (made unique within its ExportFolder with a " (1)", " (2)", etc. suffix):
+
+		* a login item becomes a Password of its password and, if it has any other fields, a Map named after it
+		  with BitwardenLoginSuffix appended, with its "username", "uris" (one per line), "notes", and "totp" (if set)
+		  and a key for each custom field,
+		* a secure note exported by Wallet.ExportBitwarden for a Blob or UnknownItem (see BitwardenTypeKey) becomes one again, and
+		* any other item (secure note, card, or identity) becomes a Map of its "notes" (if set),
+		  its (non-empty) card or identity fields, and a key for each custom field.
+
+	An item that would be taken for the login Map of a Password (i.e. named "<X>: login" next to a login item "<X>")
+	is made unique the same way.
+	Custom fields with the same name as another key are made unique with a " (1)", " (2)", etc. suffix,
+	and linked custom fields (which have no value) are skipped.
+*/
+func ParseBitwarden(in io.Reader) (doc *ExportDocument, err error) {
+
+	var file *bitwardenFile = new(bitwardenFile)
+	var ew *ExportWallet
+
+	if err = json.NewDecoder(in).Decode(file); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBitwardenFormat, err)
+		return
+	}
+	if file.Encrypted {
+		err = ErrBitwardenUnsupported
+		return
+	}
+
+	if ew, err = bitwardenToExport(file); err != nil {
+		return
+	}
+
+	doc = newExportDocument("")
+	doc.Wallets = append(doc.Wallets, ew)
+
+	if err = doc.validate(); err != nil {
+		doc = nil
+		return
+	}
+
+	return
+}
+
+// bitwardenFromExport converts an ExportWallet to a Bitwarden export (see Wallet.ExportBitwarden).
+func bitwardenFromExport(ew *ExportWallet) (file *bitwardenFile, err error) {
+
+	var folderID string
+	var item *bitwardenItem
+	var ok bool
+	var byName map[string]*ExportEntry
+	var login *ExportEntry
+
+	file = &bitwardenFile{
+		Folders: make([]*bitwardenFolder, 0, len(ew.Folders)),
+		Items:   make([]*bitwardenItem, 0),
+	}
+
+	for _, ef := range ew.Folders {
+		folderID = uuid.New().String()
+		file.Folders = append(file.Folders, &bitwardenFolder{
+			ID:   folderID,
+			Name: ef.Name,
+		})
+
+		byName = make(map[string]*ExportEntry, len(ef.Entries))
+		for _, ee := range ef.Entries {
+			byName[ee.Name] = ee
+		}
+
+		for _, ee := range ef.Entries {
+			if ee.Type == KwalletdEnumTypeMap {
+				if _, ok = ee.Map[BitwardenTypeKey]; ok {
+					err = fmt.Errorf(
+						"%w: %#v/%#v/%#v: map key %#v", ErrBitwardenUnsupported, ew.Name, ef.Name, ee.Name, BitwardenTypeKey,
+					)
+					return
+				}
+				// Written as part of the login item of its Password.
+				if login = byName[strings.TrimSuffix(ee.Name, BitwardenLoginSuffix)]; login != nil && login.Type == KwalletdEnumTypePassword {
+					continue
+				}
+			}
+
+			item = &bitwardenItem{
+				ID:       uuid.New().String(),
+				FolderID: &folderID,
+				Name:     ee.Name,
+			}
+
+			switch ee.Type {
+			case KwalletdEnumTypePassword:
+				item.Type = bitwardenItemLogin
+				item.Login = &bitwardenLogin{
+					URIs:     make([]*bitwardenURI, 0),
+					Password: stringPtr(ee.Password),
+				}
+				if login = byName[ee.Name+BitwardenLoginSuffix]; login != nil && login.Type == KwalletdEnumTypeMap {
+					bitwardenFieldsFromMap(item, login.Map)
+				}
+			case KwalletdEnumTypeMap:
+				item.Type = bitwardenItemSecureNote
+				item.SecureNote = new(bitwardenSecureNote)
+				bitwardenFieldsFromMap(item, ee.Map)
+			default:
+				item.Type = bitwardenItemSecureNote
+				item.SecureNote = new(bitwardenSecureNote)
+				item.Notes = stringPtr(base64.StdEncoding.EncodeToString(ee.Data))
+				item.Fields = []*bitwardenField{
+					{Name: BitwardenTypeKey, Value: stringPtr(ee.Type.String()), Type: bitwardenFieldText},
+				}
+			}
+
+			file.Items = append(file.Items, item)
+		}
+	}
+
+	return
+}
+
+/*
+	bitwardenFieldsFromMap sets the notes (and, for a login item, its login fields) of item from the keys of m,
+	and adds a (hidden) custom field for each other key.
+*/
+func bitwardenFieldsFromMap(item *bitwardenItem, m map[string]string) {
+
+	var keys []string = make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch {
+		case k == bitwardenKeyNotes:
+			item.Notes = stringPtr(m[k])
+		case item.Login != nil && k == bitwardenKeyUsername:
+			item.Login.Username = stringPtr(m[k])
+		case item.Login != nil && k == bitwardenKeyTOTP:
+			item.Login.TOTP = stringPtr(m[k])
+		case item.Login != nil && k == bitwardenKeyURIs:
+			for _, u := range strings.Split(m[k], "\n") {
+				if u == "" {
+					continue
+				}
+				item.Login.URIs = append(item.Login.URIs, &bitwardenURI{URI: u})
+			}
+		default:
+			item.Fields = append(item.Fields, &bitwardenField{
+				Name:  k,
+				Value: stringPtr(m[k]),
+				Type:  bitwardenFieldHidden,
+			})
+		}
+	}
+
+	return
+}
+
+// bitwardenToExport converts a Bitwarden export to an ExportWallet (see ParseBitwarden).
+func bitwardenToExport(file *bitwardenFile) (ew *ExportWallet, err error) {
+
+	var ef *ExportFolder
+	var ok bool
+	var folderName string
+	var base string
+	var name string
+	var found []*ExportEntry
+	var folderNames map[string]string = make(map[string]string, len(file.Folders))
+	var folders map[string]*ExportFolder = make(map[string]*ExportFolder)
+	var names map[string]map[string]bool = make(map[string]map[string]bool)
+
+	for _, bf := range file.Folders {
+		if bf == nil {
+			continue
+		}
+		folderNames[bf.ID] = bf.Name
+	}
+
+	ew = &ExportWallet{
+		Name:    bitwardenWallet,
+		Folders: make([]*ExportFolder, 0),
+	}
+
+	for _, item := range file.Items {
+		if item == nil || item.DeletedDate != nil {
+			continue
+		}
+
+		folderName = ""
+		if item.FolderID != nil {
+			if folderName, ok = folderNames[*item.FolderID]; !ok {
+				err = fmt.Errorf("%w: item %#v is in an unknown folder", ErrBitwardenFormat, item.Name)
+				ew = nil
+				return
+			}
+		}
+		if folderName == "" {
+			folderName = bitwardenDefaultFolder
+		}
+
+		if ef = folders[folderName]; ef == nil {
+			ef = &ExportFolder{
+				Name:    folderName,
+				Entries: make([]*ExportEntry, 0),
+			}
+			folders[folderName] = ef
+			names[folderName] = make(map[string]bool)
+			ew.Folders = append(ew.Folders, ef)
+		}
+
+		/*
+			A login's Map is named after its Password, so both names must be free.
+			Likewise, an item named like a login's Map (e.g. a secure note named "<X>: login") mustn't sit next to a
+			Password X, or Wallet.ExportBitwarden would take it for X's login Map.
+		*/
+		if base = item.Name; base == "" {
+			base = bitwardenUntitled
+		}
+		name = base
+		for idx := 1; names[folderName][name] || names[folderName][name+BitwardenLoginSuffix] ||
+			(strings.HasSuffix(name, BitwardenLoginSuffix) && names[folderName][strings.TrimSuffix(name, BitwardenLoginSuffix)]); idx++ {
+			name = fmt.Sprintf("%v (%d)", base, idx)
+		}
+
+		if found, err = bitwardenItemToExport(item, name); err != nil {
+			err = fmt.Errorf("folder %#v: %w", folderName, err)
+			ew = nil
+			return
+		}
+		for _, ee := range found {
+			names[folderName][ee.Name] = true
+			ef.Entries = append(ef.Entries, ee)
+		}
+	}
+
+	return
+}
+
+// bitwardenItemToExport converts a Bitwarden item to one or two ExportEntry named after name (see ParseBitwarden).
+func bitwardenItemToExport(item *bitwardenItem, name string) (entries []*ExportEntry, err error) {
+
+	var ee *ExportEntry
+	var uris []string
+	var typeName string
+	var s string
+	var ok bool
+	var fields map[string]string = make(map[string]string)
+	var taken map[string]bool = make(map[string]bool)
+	var set func(k, v string)
+
+	set = func(k, v string) {
+		k = uniqueName(k, taken)
+		taken[k] = true
+		fields[k] = v
+	}
+
+	if item.Notes != nil {
+		set(bitwardenKeyNotes, *item.Notes)
+	}
+
+	switch item.Type {
+	case bitwardenItemLogin:
+		if item.Login != nil {
+			if item.Login.Username != nil {
+				set(bitwardenKeyUsername, *item.Login.Username)
+			}
+			if len(item.Login.URIs) != 0 {
+				uris = make([]string, 0, len(item.Login.URIs))
+				for _, u := range item.Login.URIs {
+					if u != nil {
+						uris = append(uris, u.URI)
+					}
+				}
+				set(bitwardenKeyURIs, strings.Join(uris, "\n"))
+			}
+			if item.Login.TOTP != nil {
+				set(bitwardenKeyTOTP, *item.Login.TOTP)
+			}
+		}
+	default:
+		for _, props := range []map[string]interface{}{item.Card, item.Identity} {
+			for _, k := range interfaceMapKeys(props) {
+				if s, ok = props[k].(string); ok && s != "" {
+					set(k, s)
+				}
+			}
+		}
+	}
+
+	for _, f := range item.Fields {
+		if f == nil || f.Value == nil {
+			continue
+		}
+		if f.Name == BitwardenTypeKey && item.Type == bitwardenItemSecureNote {
+			typeName = *f.Value
+			continue
+		}
+		if f.Name == "" {
+			set(bitwardenUntitled, *f.Value)
+		} else {
+			set(f.Name, *f.Value)
+		}
+	}
+
+	ee = &ExportEntry{
+		Name: name,
+	}
+
+	switch {
+	case item.Type == bitwardenItemLogin:
+		ee.Type = KwalletdEnumTypePassword
+		if item.Login != nil && item.Login.Password != nil {
+			ee.Password = *item.Login.Password
+		}
+		entries = append(entries, ee)
+		if len(fields) != 0 {
+			entries = append(entries, &ExportEntry{
+				Name: name + BitwardenLoginSuffix,
+				Type: KwalletdEnumTypeMap,
+				Map:  fields,
+			})
+		}
+	case typeName != "":
+		if err = ee.Type.UnmarshalText([]byte(typeName)); err != nil || ee.Type == KwalletdEnumTypePassword || ee.Type == KwalletdEnumTypeMap {
+			err = fmt.Errorf("%w: item %#v: bad %v %#v", ErrBitwardenFormat, item.Name, BitwardenTypeKey, typeName)
+			return
+		}
+		if ee.Data, err = base64.StdEncoding.DecodeString(fields[bitwardenKeyNotes]); err != nil {
+			err = fmt.Errorf("%w: item %#v: %v", ErrBitwardenFormat, item.Name, err)
+			return
+		}
+		entries = append(entries, ee)
+	default:
+		ee.Type = KwalletdEnumTypeMap
+		ee.Map = fields
+		entries = append(entries, ee)
+	}
+
+	return
+}
@@ -0,0 +1,322 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bitwardenForeignDoc is a Bitwarden unencrypted JSON export (not exported by gokwallet).
+const bitwardenForeignDoc string = `{
+  "encrypted": false,
+  "folders": [
+    {"id": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a01", "name": "Internet"},
+    {"id": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a02", "name": "Internet/Email"}
+  ],
+  "items": [
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000001",
+      "organizationId": null,
+      "folderId": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a01",
+      "type": 1,
+      "reprompt": 0,
+      "name": "Router",
+      "notes": "admin UI",
+      "favorite": true,
+      "fields": [
+        {"name": "PIN", "value": "1234", "type": 1, "linkedId": null},
+        {"name": "username", "value": "backup", "type": 0, "linkedId": null},
+        {"name": "Linked", "value": null, "type": 3, "linkedId": 100}
+      ],
+      "login": {
+        "uris": [{"match": null, "uri": "https://192.168.1.1"}, {"match": 3, "uri": "https://router.lan"}],
+        "username": "admin",
+        "password": "hunter2",
+        "totp": null
+      },
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000002",
+      "organizationId": null,
+      "folderId": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a01",
+      "type": 1,
+      "reprompt": 0,
+      "name": "Router",
+      "notes": null,
+      "favorite": false,
+      "login": {"uris": [], "username": null, "password": "second", "totp": null},
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000006",
+      "organizationId": null,
+      "folderId": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a01",
+      "type": 1,
+      "reprompt": 0,
+      "name": "Wifi",
+      "notes": null,
+      "favorite": false,
+      "login": {"uris": [], "username": null, "password": "wpa", "totp": null},
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000007",
+      "organizationId": null,
+      "folderId": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a01",
+      "type": 2,
+      "reprompt": 0,
+      "name": "Wifi: login",
+      "notes": "guest network",
+      "favorite": false,
+      "secureNote": {"type": 0},
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000003",
+      "organizationId": null,
+      "folderId": "0b8d6a38-0c5e-4a8e-9d3f-6f1d2c3b4a02",
+      "type": 2,
+      "reprompt": 0,
+      "name": "Recovery codes",
+      "notes": "aaaa-bbbb",
+      "favorite": false,
+      "secureNote": {"type": 0},
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000004",
+      "organizationId": null,
+      "folderId": null,
+      "type": 3,
+      "reprompt": 0,
+      "name": "Visa",
+      "notes": null,
+      "favorite": false,
+      "card": {"cardholderName": "J Doe", "brand": "Visa", "number": "4111111111111111", "expMonth": "1", "expYear": "2030", "code": null},
+      "collectionIds": null
+    },
+    {
+      "id": "5f2a1c7e-1111-4c1b-8a1e-000000000005",
+      "organizationId": null,
+      "folderId": null,
+      "type": 1,
+      "reprompt": 0,
+      "name": "Deleted",
+      "notes": null,
+      "favorite": false,
+      "login": {"uris": [], "username": null, "password": "gone", "totp": null},
+      "collectionIds": null,
+      "deletedDate": "2024-01-01T00:00:00.000Z"
+    }
+  ]
+}
+`
+
+// TestBitwarden tests exporting a Wallet to a Bitwarden JSON export and importing it back.
+func TestBitwarden(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var e2 *testEnv
+	var buf bytes.Buffer
+	var file *bitwardenFile = new(bitwardenFile)
+	var doc *ExportDocument
+	var doc2 *ExportDocument
+	var results []*ImportResult
+	var fb *failBackend
+	var wm *WalletManager
+	var w *Wallet
+	var login *bitwardenItem
+	var loginMap map[string]string = map[string]string{
+		"username": "me",
+		"uris":     "https://example.com\nhttps://example.org",
+		"notes":    "",
+		"totp":     "otpauth://totp/x?secret=ABC",
+		"PIN":      "0000",
+	}
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+	if _, err = e.f.WritePassword("site", "s3cret"); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, err = e.f.WriteMap("site"+BitwardenLoginSuffix, loginMap); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+
+	if err = e.w.ExportBitwarden(&buf); err != nil {
+		t.Fatalf("failed to ExportBitwarden: %v", err)
+	}
+	if err = json.Unmarshal(buf.Bytes(), file); err != nil {
+		t.Fatalf("failed to unmarshal Bitwarden export: %v", err)
+	}
+	// The Password and its login Map are a single login item.
+	for _, item := range file.Items {
+		switch item.Name {
+		case "site":
+			login = item
+		case "site" + BitwardenLoginSuffix:
+			t.Errorf("login Map exported as its own item")
+		}
+	}
+	if login == nil || login.Type != bitwardenItemLogin || login.Login == nil {
+		t.Fatalf("no login item exported: %#v", login)
+	}
+	if *login.Login.Password != "s3cret" || *login.Login.Username != "me" || len(login.Login.URIs) != 2 ||
+		*login.Login.TOTP != loginMap["totp"] || *login.Notes != "" || len(login.Fields) != 1 || login.Fields[0].Name != "PIN" {
+		t.Errorf("unexpected login item: %#v", login)
+	}
+
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+	// Items are written as typed WalletItems (e.g. Folder.WritePassword), not as raw values.
+	fb = newFailBackend(e2.wm.Backend())
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if w, err = NewWallet(wm, e2.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if results, err = w.ImportBitwarden(bytes.NewReader(buf.Bytes()), nil); err != nil {
+		t.Fatalf("failed to ImportBitwarden: %v", err)
+	}
+	if len(results) != 6 {
+		t.Errorf("expected 6 import results, got %d", len(results))
+	}
+	if fb.calls["WritePassword"] != 2 || fb.calls["WriteMap"] != 2 {
+		t.Errorf("unexpected writes importing a Bitwarden export: %v", fb.calls)
+	}
+
+	// Every WalletItem comes back with the same type and value.
+	if doc, err = e.w.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if doc2, err = e2.w.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Wallets[0].Folders, doc2.Wallets[0].Folders) {
+		t.Errorf("Bitwarden round trip mismatch")
+	}
+
+	// Blank lines in "uris" aren't URIs.
+	if _, err = e.f.WriteMap("site"+BitwardenLoginSuffix, map[string]string{"uris": "\nhttps://example.com\n\n"}); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	buf.Reset()
+	if err = e.w.ExportBitwarden(&buf); err != nil {
+		t.Fatalf("failed to ExportBitwarden: %v", err)
+	}
+	file = new(bitwardenFile)
+	if err = json.Unmarshal(buf.Bytes(), file); err != nil {
+		t.Fatalf("failed to unmarshal Bitwarden export: %v", err)
+	}
+	for _, item := range file.Items {
+		if item.Name == "site" && (item.Login == nil || len(item.Login.URIs) != 1 || item.Login.URIs[0].URI != "https://example.com") {
+			t.Errorf("unexpected URIs exported: %#v", item.Login)
+		}
+	}
+
+	if _, err = ParseBitwarden(strings.NewReader(`{"encrypted": true, "items": []}`)); !errors.Is(err, ErrBitwardenUnsupported) {
+		t.Errorf("expected ErrBitwardenUnsupported for an encrypted export, got %v", err)
+	}
+	if _, err = ParseBitwarden(strings.NewReader("not an export")); !errors.Is(err, ErrBitwardenFormat) {
+		t.Errorf("expected ErrBitwardenFormat, got %v", err)
+	}
+
+	if _, err = e.f.WriteMap("typed", map[string]string{BitwardenTypeKey: "blob"}); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if err = e.w.ExportBitwarden(&buf); !errors.Is(err, ErrBitwardenUnsupported) {
+		t.Errorf("expected ErrBitwardenUnsupported for a Map with a %v key, got %v", BitwardenTypeKey, err)
+	}
+}
+
+// TestBitwardenForeign tests importing a Bitwarden export not written by gokwallet.
+func TestBitwardenForeign(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var doc *ExportDocument
+	var got map[string]*ExportEntry
+	var folders []string
+	var expected map[string]*ExportEntry = map[string]*ExportEntry{
+		"Internet/Router": {Name: "Router", Type: KwalletdEnumTypePassword, Password: "hunter2"},
+		"Internet/Router" + BitwardenLoginSuffix: {
+			Name: "Router" + BitwardenLoginSuffix,
+			Type: KwalletdEnumTypeMap,
+			Map: map[string]string{
+				"notes":        "admin UI",
+				"username":     "admin",
+				"uris":         "https://192.168.1.1\nhttps://router.lan",
+				"PIN":          "1234",
+				"username (1)": "backup",
+			},
+		},
+		"Internet/Router (1)": {Name: "Router (1)", Type: KwalletdEnumTypePassword, Password: "second"},
+		"Internet/Wifi":       {Name: "Wifi", Type: KwalletdEnumTypePassword, Password: "wpa"},
+		// Not named "Wifi: login", which would make it Wifi's login Map.
+		"Internet/Wifi: login (1)": {
+			Name: "Wifi: login (1)",
+			Type: KwalletdEnumTypeMap,
+			Map:  map[string]string{"notes": "guest network"},
+		},
+		"Internet/Email/Recovery codes": {
+			Name: "Recovery codes",
+			Type: KwalletdEnumTypeMap,
+			Map:  map[string]string{"notes": "aaaa-bbbb"},
+		},
+		"Passwords/Visa": {
+			Name: "Visa",
+			Type: KwalletdEnumTypeMap,
+			Map: map[string]string{
+				"brand":          "Visa",
+				"cardholderName": "J Doe",
+				"expMonth":       "1",
+				"expYear":        "2030",
+				"number":         "4111111111111111",
+			},
+		},
+	}
+
+	if doc, err = ParseBitwarden(strings.NewReader(bitwardenForeignDoc)); err != nil {
+		t.Fatalf("failed to ParseBitwarden: %v", err)
+	}
+	if doc.Wallets[0].Name != bitwardenWallet {
+		t.Errorf("unexpected wallet name %#v", doc.Wallets[0].Name)
+	}
+	got = make(map[string]*ExportEntry)
+	for _, ef := range doc.Wallets[0].Folders {
+		folders = append(folders, ef.Name)
+		for _, ee := range ef.Entries {
+			got[ef.Name+"/"+ee.Name] = ee
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected entries:\n%#v\nexpected:\n%#v", got, expected)
+	}
+	if !reflect.DeepEqual(folders, []string{"Internet", "Internet/Email", "Passwords"}) {
+		t.Errorf("unexpected folders: %v", folders)
+	}
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if _, err = e.w.ImportBitwarden(strings.NewReader(bitwardenForeignDoc), nil); err != nil {
+		t.Fatalf("failed to ImportBitwarden: %v", err)
+	}
+
+	if _, err = ParseBitwarden(strings.NewReader(
+		`{"encrypted": false, "folders": [], "items": [{"type": 1, "name": "x", "folderId": "missing"}]}`,
+	)); !errors.Is(err, ErrBitwardenFormat) {
+		t.Errorf("expected ErrBitwardenFormat for an unknown folder, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 )
 
 /*
@@ -28,7 +32,7 @@ func NewBlob(f *Folder, keyName string, recursion *RecurseOpts) (blob *Blob, err
 	}
 	blob.isInit = true
 
-	if blob.Recurse.AllWalletItems || blob.Recurse.Blobs {
+	if !blob.Recurse.Lazy && (blob.Recurse.AllWalletItems || blob.Recurse.Blobs) {
 		if err = blob.Update(); err != nil {
 			return
 		}
@@ -73,39 +77,297 @@ func (b *Blob) Rename(newName string) (err error) {
 	return
 }
 
-// SetValue will replace this Blob's Blob.Value.
+// SetValue will replace this Blob's Blob.Value (or, in secure mode, Blob.Secret).
 func (b *Blob) SetValue(newValue []byte) (err error) {
 
 	if _, err = b.folder.WriteBlob(b.Name, newValue); err != nil {
 		return
 	}
 
-	b.Value = newValue
+	if err = b.setValue(newValue); err != nil {
+		return
+	}
 
 	return
 }
 
-// Update fetches a Blob's Blob.Value.
+/*
+	SetValueIf replaces this Blob's value with newValue, but only if its stored value is still expectedOld.
+	Otherwise (or if the Blob was removed or replaced by another type) a *ConflictError is returned, nothing is written,
+	and Blob.Value is refreshed with the stored value so the caller can retry.
+*/
+func (b *Blob) SetValueIf(expectedOld, newValue []byte) (err error) {
+
+	var cur []byte
+
+	if cur, err = b.folder.casEntry(
+		b.Name,
+		KwalletdEnumTypeStream,
+		func(cur []byte) (isMatch bool, err error) {
+			isMatch = bytes.Equal(cur, expectedOld)
+			return
+		},
+		newValue,
+	); err != nil {
+		if cur != nil && errors.Is(err, ErrConflict) {
+			// The *ConflictError is what matters to the caller.
+			_ = b.setValue(cur)
+		}
+		return
+	}
+
+	if err = b.setValue(newValue); err != nil {
+		return
+	}
+
+	return
+}
+
+// Update fetches a Blob's Blob.Value (or, in secure mode, Blob.Secret).
 func (b *Blob) Update() (err error) {
 
-	var call *dbus.Call
-	var v dbus.Variant
+	var value []byte
+	var secret *SecretBytes
+
+	if value, err = b.read(); err != nil {
+		return
+	}
+
+	if b.folder.wallet.wm.secure {
+		defer wipeBytes(value)
+		if secret, err = b.folder.wallet.wm.newSecret(value); err != nil {
+			return
+		}
+		_ = b.Secret.Wipe()
+		b.Secret = secret
+		b.loaded = true
+		return
+	}
+
+	b.Value = value
+	b.loaded = true
+
+	return
+}
+
+/*
+	GetValue returns a Blob's value, fetching it if it has not been fetched (or set) yet,
+	e.g. if the Blob was created with RecurseOpts.Lazy.
+	The fetched value is only kept (in Blob.Value, or Blob.Secret in secure mode) if RecurseOpts.CacheValues is true;
+	otherwise it is fetched on every call. A kept value is returned as-is (not copied), so it is zeroed by Blob.Wipe.
+	Unlike reading Blob.Value directly, a failed fetch (e.g. an *ExpiredError) is returned rather than an empty value.
+*/
+func (b *Blob) GetValue() (value []byte, err error) {
+
+	if !b.loaded && b.Recurse != nil && b.Recurse.CacheValues {
+		if err = b.Update(); err != nil {
+			return
+		}
+	}
+
+	if b.loaded {
+		value = b.value()
+		return
+	}
+
+	if value, err = b.read(); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Wipe wipes this Blob's value from memory: Blob.Secret is wiped (see SecretBytes.Wipe) and Blob.Value is zeroed
+	(even if it is the slice that was passed to Blob.SetValue) and dropped, so it will be fetched again if needed.
+*/
+func (b *Blob) Wipe() (err error) {
+
+	err = b.Secret.Wipe()
+
+	wipeBytes(b.Value)
+
+	b.Secret = nil
+	b.Value = nil
+	b.loaded = false
+
+	return
+}
+
+// setValue sets a Blob's known value (in Blob.Secret in secure mode, otherwise Blob.Value).
+func (b *Blob) setValue(value []byte) (err error) {
+
+	var secret *SecretBytes
+
+	if b.folder.wallet.wm.secure {
+		if secret, err = b.folder.wallet.wm.newSecret(value); err != nil {
+			return
+		}
+		_ = b.Secret.Wipe()
+		b.Secret = secret
+		b.Value = nil
+	} else {
+		b.Value = value
+	}
+
+	b.loaded = true
+
+	return
+}
+
+// value returns a Blob's known value (from Blob.Secret in secure mode, otherwise Blob.Value).
+func (b *Blob) value() (value []byte) {
+
+	if b.Secret != nil {
+		value = b.Secret.Bytes()
+		return
+	}
+
+	value = b.Value
+
+	return
+}
+
+// read fetches a Blob's value from the Backend.
+func (b *Blob) read() (value []byte, err error) {
 
 	if err = b.folder.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = b.Dbus.Call(
-		DbusWMReadEntry, 0, b.folder.wallet.handle, b.folder.Name, b.Name, b.folder.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = b.folder.expiryCheck(b.Name); err != nil {
+		return
+	}
+
+	if value, err = b.folder.wallet.wm.Backend().ReadEntry(
+		b.folder.wallet.handle, b.folder.Name, b.Name, b.folder.wallet.wm.AppID,
+	); err != nil {
 		return
 	}
-	if err = call.Store(&v); err != nil {
+
+	return
+}
+
+/*
+	Metadata returns the EntryMetadata of this Blob (see Folder.EntryMetadata).
+	md is nil if no metadata has been recorded for it yet.
+*/
+func (b *Blob) Metadata() (md *EntryMetadata, err error) {
+
+	if md, err = b.folder.EntryMetadata(b.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetNote sets the EntryMetadata.Note of this Blob.
+func (b *Blob) SetNote(note string) (err error) {
+
+	if err = b.folder.SetEntryNote(b.Name, note); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetTags replaces the EntryMetadata.Tags of this Blob.
+func (b *Blob) SetTags(tags ...string) (err error) {
+
+	if err = b.folder.SetEntryTags(b.Name, tags...); err != nil {
+		return
+	}
+
+	return
+}
+
+// History returns the retained previous values of this Blob, newest first (see Folder.History).
+func (b *Blob) History() (versions []*HistoryVersion, err error) {
+
+	if versions, err = b.folder.History(b.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// RestoreVersion restores HistoryVersion versionID of this Blob (see Folder.RestoreVersion) and updates Blob.Value.
+func (b *Blob) RestoreVersion(versionID string) (err error) {
+
+	if err = b.folder.RestoreVersion(b.Name, versionID); err != nil {
+		return
+	}
+
+	if err = b.Update(); err != nil {
 		return
 	}
 
-	b.Value = v.Value().([]byte)
+	return
+}
+
+// Expiry returns when this Blob expires (see Folder.EntryExpiry). expires is zero if it does not expire.
+func (b *Blob) Expiry() (expires time.Time, err error) {
+
+	if expires, err = b.folder.EntryExpiry(b.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetExpiry sets when this Blob expires (see Folder.SetEntryExpiry). A zero expires removes its expiry.
+func (b *Blob) SetExpiry(expires time.Time) (err error) {
+
+	if err = b.folder.SetEntryExpiry(b.Name, expires); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	String returns a representation of a Blob with its value replaced by RedactedValue,
+	unless WalletManager.SetShowValues(true) was called.
+*/
+func (b Blob) String() (str string) {
+
+	var value string = RedactedValue
+
+	if b.wm.ShowValuesEnabled() {
+		value = bytesString(b.value(), true)
+	}
+
+	str = fmt.Sprintf("Blob{Name: %q, Value: %v}", b.Name, value)
+
+	return
+}
+
+// Format prints Blob.String for every verb (see formatRedacted).
+func (b Blob) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, b.String())
+
+	return
+}
+
+// MarshalJSON encodes a Blob with its value replaced by RedactedValue, unless WalletManager.SetShowValues(true) was called.
+func (b Blob) MarshalJSON() (out []byte, err error) {
+
+	var value interface{} = RedactedValue
+
+	if b.wm.ShowValuesEnabled() {
+		value = b.value()
+	}
+
+	if out, err = json.Marshal(&struct {
+		*jsonBlob
+		Value interface{} `json:"value"`
+	}{
+		jsonBlob: (*jsonBlob)(&b),
+		Value:    value,
+	}); err != nil {
+		return
+	}
 
 	return
 }
@@ -0,0 +1,194 @@
+package gokwallet
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+	EnableCache enables a read cache on a WalletManager: Folder listings and WalletItem values (and types) read through it
+	are kept for ttl, so repeated reads of the same WalletItem don't go to kwalletd each time.
+	Writes made through the WalletManager invalidate exactly what they affect. With a DbusBackend,
+	the kwalletd signals (DbusWMSignalFolderUpdated, DbusWMSignalFolderListUpdated, DbusWMSignalWalletClosed,
+	and DbusWMSignalWalletDeleted) likewise invalidate changes made by other applications;
+	with other Backends, those are only seen once ttl has passed.
+
+	Cached values are kept serialized and are wiped (zeroed) when evicted or invalidated.
+	Calling EnableCache again changes the TTL; see also WalletManager.DisableCache.
+	Both may be called while other goroutines (e.g. a Sweeper or BackupScheduler) are using the WalletManager.
+*/
+func (wm *WalletManager) EnableCache(ttl time.Duration) (err error) {
+
+	var existed bool
+	var c *cachingBackend
+	var sigs chan *WalletSignal
+	var stopSigs func()
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if ttl <= 0 {
+		err = ErrCacheTTL
+		return
+	}
+
+	wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var ok bool
+		var t *trackingBackend
+
+		next = cur
+
+		if c = cachingBackendOf(cur); c != nil {
+			existed = true
+			return
+		}
+
+		// The cache goes directly above the base Backend so that bookkeeping writes invalidate it too.
+		if t, ok = cur.(*trackingBackend); ok {
+			c = newCachingBackend(t.Backend, ttl)
+			t = newTrackingBackend(t)
+			t.Backend = c
+			next = t
+			return
+		}
+
+		c = newCachingBackend(cur, ttl)
+		next = c
+
+		return
+	})
+
+	if existed {
+		c.shutdown()
+		c.lock.Lock()
+		c.ttl = ttl
+		c.lock.Unlock()
+	}
+
+	if sigs, stopSigs, err = wm.watchSignals(
+		DbusWMSignalFolderUpdated, DbusWMSignalFolderListUpdated, DbusWMSignalWalletClosed, DbusWMSignalWalletDeleted,
+	); err != nil {
+		if !errors.Is(err, ErrNoSignals) {
+			return
+		}
+		err = nil
+	}
+
+	c.start(sigs, stopSigs)
+
+	return
+}
+
+// CacheEnabled returns true if WalletManager.EnableCache has been called (and not undone by WalletManager.DisableCache).
+func (wm *WalletManager) CacheEnabled() (enabled bool) {
+
+	enabled = wm.cache() != nil
+
+	return
+}
+
+// CacheStats returns the statistics of a WalletManager's read cache (see WalletManager.EnableCache).
+func (wm *WalletManager) CacheStats() (stats *CacheStats, err error) {
+
+	var c *cachingBackend
+
+	if c = wm.cache(); c == nil {
+		err = ErrCacheDisabled
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats = new(CacheStats)
+	*stats = c.stats
+	stats.Entries = len(c.entries)
+
+	return
+}
+
+// FlushCache wipes and removes everything in a WalletManager's read cache.
+func (wm *WalletManager) FlushCache() (err error) {
+
+	var c *cachingBackend
+
+	if c = wm.cache(); c == nil {
+		err = ErrCacheDisabled
+		return
+	}
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = true
+		return
+	})
+
+	return
+}
+
+// DisableCache disables a WalletManager's read cache, wiping everything in it. It does nothing if the cache is not enabled.
+func (wm *WalletManager) DisableCache() (err error) {
+
+	var c *cachingBackend
+
+	wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var ok bool
+		var t *trackingBackend
+
+		next = cur
+
+		if c = cachingBackendOf(cur); c == nil {
+			return
+		}
+
+		if t, ok = cur.(*trackingBackend); ok {
+			t = newTrackingBackend(t)
+			t.Backend = c.Backend
+			next = t
+			return
+		}
+
+		next = c.Backend
+
+		return
+	})
+
+	if c == nil {
+		return
+	}
+
+	c.shutdown()
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = true
+		return
+	})
+
+	return
+}
+
+// cache returns the cachingBackend of a WalletManager, or nil if the read cache is not enabled.
+func (wm *WalletManager) cache() (c *cachingBackend) {
+
+	c = cachingBackendOf(wm.Backend())
+
+	return
+}
+
+// cachingBackendOf returns the cachingBackend in backend (directly, or under a trackingBackend), or nil if there is none.
+func cachingBackendOf(backend Backend) (c *cachingBackend) {
+
+	var ok bool
+	var t *trackingBackend
+
+	if t, ok = backend.(*trackingBackend); ok {
+		c, _ = t.Backend.(*cachingBackend)
+		return
+	}
+
+	c, _ = backend.(*cachingBackend)
+
+	return
+}
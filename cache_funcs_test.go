@@ -0,0 +1,132 @@
+package gokwallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCache tests the WalletManager read cache.
+func TestCache(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var c *cachingBackend
+	var stats *CacheStats
+	var value string
+	var raw []byte
+	var uri string
+	var resolveErr error
+	var done chan struct{} = make(chan struct{})
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	uri = "kwallet://" + e.w.Name + "/" + e.f.Name + "/" + passwordTest.String()
+
+	if _, err = e.wm.CacheStats(); !errors.Is(err, ErrCacheDisabled) {
+		t.Errorf("expected ErrCacheDisabled, got %v", err)
+	}
+	if err = e.wm.EnableCache(0); !errors.Is(err, ErrCacheTTL) {
+		t.Errorf("expected ErrCacheTTL, got %v", err)
+	}
+	if err = e.wm.EnableCache(time.Minute); err != nil {
+		t.Fatalf("failed to EnableCache: %v", err)
+	}
+	defer e.wm.DisableCache()
+	// Bookkeeping must go through (and invalidate) the cache too.
+	if err = e.wm.EnableMetadata(); err != nil {
+		t.Fatalf("failed to EnableMetadata: %v", err)
+	}
+	if c = e.wm.cache(); c == nil {
+		t.Fatalf("cache not found under the trackingBackend")
+	}
+
+	if _, err = e.wm.Put(uri, testPassword); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if value, err = e.wm.ResolveString(uri); err != nil || value != testPassword {
+			t.Fatalf("unexpected value %#v (err: %v)", value, err)
+		}
+	}
+	if stats, err = e.wm.CacheStats(); err != nil {
+		t.Fatalf("failed to get CacheStats: %v", err)
+	}
+	if stats.Hits == 0 || stats.Misses == 0 || stats.Entries == 0 {
+		t.Errorf("unexpected CacheStats: %#v", stats)
+	}
+
+	// Writes through the WalletManager invalidate.
+	if _, err = e.wm.Put(uri, testPasswordReplace); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+	if value, err = e.wm.ResolveString(uri); err != nil || value != testPasswordReplace {
+		t.Errorf("stale value after write: %#v (err: %v)", value, err)
+	}
+
+	// Writes by others are only seen after a signal (or the TTL).
+	if err = baseBackend(e.wm.backend).WritePassword(
+		e.w.handle, e.f.Name, passwordTest.String(), testPassword, e.wm.AppID,
+	); err != nil {
+		t.Fatalf("failed to write behind the cache: %v", err)
+	}
+	if value, _ = e.wm.ResolveString(uri); value != testPasswordReplace {
+		t.Errorf("expected the cached value, got %#v", value)
+	}
+	c.signal(&WalletSignal{Name: DbusWMSignalFolderUpdated, Wallet: e.w.Name, Folder: "unrelated"})
+	if value, _ = e.wm.ResolveString(uri); value != testPasswordReplace {
+		t.Errorf("expected the cached value after an unrelated signal, got %#v", value)
+	}
+	c.signal(&WalletSignal{Name: DbusWMSignalFolderUpdated, Wallet: e.w.Name, Folder: e.f.Name})
+	if value, _ = e.wm.ResolveString(uri); value != testPassword {
+		t.Errorf("stale value after signal: %#v", value)
+	}
+
+	// Evicted values are wiped.
+	c.lock.Lock()
+	for k, ce := range c.entries {
+		if k.kind == cacheKindPassword && k.folder == e.f.Name && k.entry == passwordTest.String() {
+			raw = ce.raw
+		}
+		ce.expires = time.Now().Add(-time.Second)
+	}
+	c.lock.Unlock()
+	if raw == nil {
+		t.Fatalf("Password value not cached")
+	}
+	c.evict()
+	if !bytes.Equal(raw, make([]byte, len(raw))) {
+		t.Errorf("evicted value was not wiped: %#v", raw)
+	}
+	if stats, _ = e.wm.CacheStats(); stats.Entries != 0 || stats.Evictions == 0 || stats.Signals != 2 {
+		t.Errorf("unexpected CacheStats after eviction: %#v", stats)
+	}
+
+	if err = e.wm.DisableCache(); err != nil || e.wm.CacheEnabled() || !e.wm.MetadataEnabled() {
+		t.Errorf("failed to DisableCache: %v", err)
+	}
+
+	// Enabling and disabling the cache is safe while other goroutines (e.g. a Sweeper) use the WalletManager.
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, resolveErr = e.wm.ResolveString(uri); resolveErr != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err = e.wm.EnableCache(time.Minute); err != nil {
+			t.Fatalf("failed to EnableCache: %v", err)
+		}
+		if err = e.wm.DisableCache(); err != nil {
+			t.Fatalf("failed to DisableCache: %v", err)
+		}
+	}
+	<-done
+	if resolveErr != nil {
+		t.Errorf("failed to ResolveString while toggling the cache: %v", resolveErr)
+	}
+}
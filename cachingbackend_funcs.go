@@ -0,0 +1,679 @@
+package gokwallet
+
+import (
+	"time"
+)
+
+// newCachingBackend wraps backend in a cachingBackend with the given TTL.
+func newCachingBackend(backend Backend, ttl time.Duration) (c *cachingBackend) {
+
+	c = &cachingBackend{
+		Backend: backend,
+		ttl:     ttl,
+		entries: make(map[cacheKey]*cacheEntry),
+		wallets: make(map[int32]string),
+	}
+
+	return
+}
+
+// Open opens a wallet, recording the handle's Wallet name.
+func (c *cachingBackend) Open(walletName, appID string) (handle int32, err error) {
+
+	if handle, err = c.Backend.Open(walletName, appID); err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	c.wallets[handle] = walletName
+	c.lock.Unlock()
+
+	return
+}
+
+/*
+	Close closes a wallet handle, invalidating everything cached for its Wallet
+	unless (without force) the Wallet is still open afterwards (e.g. through other handles).
+*/
+func (c *cachingBackend) Close(handle int32, force bool, appID string) (err error) {
+
+	var ok bool
+	var wn string
+	var stillOpen bool
+
+	if err = c.Backend.Close(handle, force, appID); err == nil && !force {
+		c.lock.Lock()
+		wn, ok = c.wallets[handle]
+		c.lock.Unlock()
+		if ok {
+			stillOpen, _ = c.Backend.IsOpen(wn)
+		}
+	}
+
+	if !stillOpen {
+		c.invalidateHandle(handle)
+		return
+	}
+
+	c.lock.Lock()
+	delete(c.wallets, handle)
+	c.lock.Unlock()
+
+	return
+}
+
+// CloseWallet closes a wallet for all applications, invalidating everything cached for it.
+func (c *cachingBackend) CloseWallet(walletName string, force bool) (err error) {
+
+	err = c.Backend.CloseWallet(walletName, force)
+
+	c.invalidateWallet(walletName, true)
+
+	return
+}
+
+// CloseAllWallets closes all wallets, invalidating the whole cache.
+func (c *cachingBackend) CloseAllWallets() (err error) {
+
+	err = c.Backend.CloseAllWallets()
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = true
+		return
+	})
+
+	return
+}
+
+// DeleteWallet deletes a wallet, invalidating everything cached for it.
+func (c *cachingBackend) DeleteWallet(walletName string) (err error) {
+
+	err = c.Backend.DeleteWallet(walletName)
+
+	c.invalidateWallet(walletName, true)
+
+	return
+}
+
+// FolderList returns the (possibly cached) folder names of a wallet.
+func (c *cachingBackend) FolderList(handle int32, appID string) (folderNames []string, err error) {
+
+	folderNames, err = c.list(c.key(handle, cacheKindFolders, "", ""), func() (names []string, err error) {
+		names, err = c.Backend.FolderList(handle, appID)
+		return
+	})
+
+	return
+}
+
+// CreateFolder creates a folder, invalidating the folder listing.
+func (c *cachingBackend) CreateFolder(handle int32, folderName, appID string) (err error) {
+
+	err = c.Backend.CreateFolder(handle, folderName, appID)
+
+	c.invalidateFolder(handle, folderName, true)
+
+	return
+}
+
+// RemoveFolder removes a folder, invalidating the folder listing and everything cached for the folder.
+func (c *cachingBackend) RemoveFolder(handle int32, folderName, appID string) (err error) {
+
+	err = c.Backend.RemoveFolder(handle, folderName, appID)
+
+	c.invalidateFolder(handle, folderName, true)
+
+	return
+}
+
+// EntryList returns the (possibly cached) entry names of a folder.
+func (c *cachingBackend) EntryList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = c.list(c.key(handle, cacheKindEntries, folderName, ""), func() (names []string, err error) {
+		names, err = c.Backend.EntryList(handle, folderName, appID)
+		return
+	})
+
+	return
+}
+
+// PasswordList returns the (possibly cached) Password entry names of a folder.
+func (c *cachingBackend) PasswordList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = c.list(c.key(handle, cacheKindPasswords, folderName, ""), func() (names []string, err error) {
+		names, err = c.Backend.PasswordList(handle, folderName, appID)
+		return
+	})
+
+	return
+}
+
+// MapList returns the (possibly cached) Map entry names of a folder.
+func (c *cachingBackend) MapList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = c.list(c.key(handle, cacheKindMaps, folderName, ""), func() (names []string, err error) {
+		names, err = c.Backend.MapList(handle, folderName, appID)
+		return
+	})
+
+	return
+}
+
+// EntryType returns the (possibly cached) type of an entry.
+func (c *cachingBackend) EntryType(handle int32, folderName, entryName, appID string) (entryType kwalletdEnumType, err error) {
+
+	var ce *cacheEntry
+	var gen uint64
+	var key cacheKey = c.key(handle, cacheKindType, folderName, entryName)
+
+	if ce, gen = c.get(key); ce != nil {
+		entryType = ce.entryType
+		return
+	}
+
+	if entryType, err = c.Backend.EntryType(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	c.put(key, &cacheEntry{entryType: entryType}, gen)
+
+	return
+}
+
+// ReadEntry returns the (possibly cached) raw value of an entry.
+func (c *cachingBackend) ReadEntry(handle int32, folderName, entryName, appID string) (value []byte, err error) {
+
+	value, err = c.value(c.key(handle, cacheKindRaw, folderName, entryName), func() (raw []byte, err error) {
+		raw, err = c.Backend.ReadEntry(handle, folderName, entryName, appID)
+		return
+	})
+
+	return
+}
+
+// ReadPassword returns the (possibly cached) value of a Password entry.
+func (c *cachingBackend) ReadPassword(handle int32, folderName, entryName, appID string) (value string, err error) {
+
+	var raw []byte
+
+	if raw, err = c.value(c.key(handle, cacheKindPassword, folderName, entryName), func() (raw []byte, err error) {
+		var s string
+		if s, err = c.Backend.ReadPassword(handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		raw = []byte(s)
+		return
+	}); err != nil {
+		return
+	}
+
+	value = string(raw)
+	wipeBytes(raw)
+
+	return
+}
+
+// ReadMap returns the (possibly cached) value of a Map entry.
+func (c *cachingBackend) ReadMap(handle int32, folderName, entryName, appID string) (value map[string]string, err error) {
+
+	var raw []byte
+
+	if raw, err = c.value(c.key(handle, cacheKindMap, folderName, entryName), func() (raw []byte, err error) {
+		var m map[string]string
+		if m, err = c.Backend.ReadMap(handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		raw, err = mapToBytes(m)
+		return
+	}); err != nil {
+		return
+	}
+
+	value, _, err = bytesToMap(raw)
+	wipeBytes(raw)
+
+	return
+}
+
+// WriteEntry writes a raw value as an entry, invalidating what is cached for it.
+func (c *cachingBackend) WriteEntry(
+	handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string,
+) (err error) {
+
+	err = c.Backend.WriteEntry(handle, folderName, entryName, entryType, value, appID)
+
+	c.invalidateEntry(handle, folderName, entryName)
+
+	return
+}
+
+// WritePassword writes a Password entry, invalidating what is cached for it.
+func (c *cachingBackend) WritePassword(handle int32, folderName, entryName, value, appID string) (err error) {
+
+	err = c.Backend.WritePassword(handle, folderName, entryName, value, appID)
+
+	c.invalidateEntry(handle, folderName, entryName)
+
+	return
+}
+
+// WriteMap writes a Map entry, invalidating what is cached for it.
+func (c *cachingBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	err = c.Backend.WriteMap(handle, folderName, entryName, value, appID)
+
+	c.invalidateEntry(handle, folderName, entryName)
+
+	return
+}
+
+// RemoveEntry removes an entry, invalidating what is cached for it.
+func (c *cachingBackend) RemoveEntry(handle int32, folderName, entryName, appID string) (err error) {
+
+	err = c.Backend.RemoveEntry(handle, folderName, entryName, appID)
+
+	c.invalidateEntry(handle, folderName, entryName)
+
+	return
+}
+
+// RenameEntry renames an entry, invalidating what is cached for both names.
+func (c *cachingBackend) RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	err = c.Backend.RenameEntry(handle, folderName, entryName, newEntryName, appID)
+
+	c.invalidateEntry(handle, folderName, entryName)
+	c.invalidateEntry(handle, folderName, newEntryName)
+
+	return
+}
+
+// Release stops a cachingBackend, wipes everything cached, and releases the wrapped Backend.
+func (c *cachingBackend) Release() (err error) {
+
+	c.shutdown()
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = true
+		return
+	})
+
+	if err = c.Backend.Release(); err != nil {
+		return
+	}
+
+	return
+}
+
+// unwrap returns the Backend wrapped by a cachingBackend.
+func (c *cachingBackend) unwrap() (backend Backend) {
+
+	backend = c.Backend
+
+	return
+}
+
+/*
+	start starts the background goroutine of a cachingBackend, which evicts expired listings and values every TTL
+	and applies the kwalletd signals received on sigs (if not nil) until cachingBackend.shutdown is called.
+*/
+func (c *cachingBackend) start(sigs chan *WalletSignal, stopSigs func()) {
+
+	var stop chan bool = make(chan bool)
+	var done chan bool = make(chan bool)
+
+	c.lock.Lock()
+	c.stop, c.done = stop, done
+	c.lock.Unlock()
+
+	go c.run(c.ttl, stop, done, sigs, stopSigs)
+
+	return
+}
+
+// shutdown stops the background goroutine of a cachingBackend, if running.
+func (c *cachingBackend) shutdown() {
+
+	var stop chan bool
+	var done chan bool
+
+	c.lock.Lock()
+	stop, done = c.stop, c.done
+	c.stop, c.done = nil, nil
+	c.lock.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+
+	return
+}
+
+// run is the background goroutine of a cachingBackend (see cachingBackend.start).
+func (c *cachingBackend) run(interval time.Duration, stop, done chan bool, sigs chan *WalletSignal, stopSigs func()) {
+
+	var ticker *time.Ticker = time.NewTicker(interval)
+	var sig *WalletSignal
+
+	defer close(done)
+	defer ticker.Stop()
+
+	if stopSigs != nil {
+		defer stopSigs()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.evict()
+		case sig = <-sigs:
+			c.signal(sig)
+		}
+	}
+}
+
+// signal invalidates what a kwalletd signal says has changed.
+func (c *cachingBackend) signal(sig *WalletSignal) {
+
+	c.lock.Lock()
+	c.stats.Signals++
+	c.lock.Unlock()
+
+	if sig.Wallet == "" {
+		c.invalidateHandle(sig.Handle)
+		return
+	}
+
+	switch sig.Name {
+	case DbusWMSignalFolderUpdated:
+		c.invalidate(func(k cacheKey) (isMatch bool) {
+			isMatch = k.folder == sig.Folder && c.inWallet(k, sig.Wallet)
+			return
+		})
+	case DbusWMSignalFolderListUpdated:
+		// The signal doesn't say which Folders were added or removed.
+		c.invalidateWallet(sig.Wallet, false)
+	case DbusWMSignalWalletClosed, DbusWMSignalWalletDeleted:
+		c.invalidateWallet(sig.Wallet, true)
+	}
+
+	return
+}
+
+/*
+	key returns the cacheKey for a listing or value. It is per Wallet name if the Wallet of handle is known
+	(i.e. it was opened through the cachingBackend), so it is shared by all handles for that Wallet.
+*/
+func (c *cachingBackend) key(handle int32, kind, folderName, entryName string) (key cacheKey) {
+
+	var ok bool
+
+	key = cacheKey{
+		kind:   kind,
+		folder: folderName,
+		entry:  entryName,
+	}
+
+	c.lock.Lock()
+	if key.wallet, ok = c.wallets[handle]; !ok {
+		key.handle = handle
+	}
+	c.lock.Unlock()
+
+	return
+}
+
+/*
+	get returns a copy of the cacheEntry for key (nil if not cached or expired, which evicts it), counting a hit or miss.
+	gen is to be passed to cachingBackend.put if a value is then read from the Backend.
+*/
+func (c *cachingBackend) get(key cacheKey) (ce *cacheEntry, gen uint64) {
+
+	var ok bool
+	var cached *cacheEntry
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	gen = c.gen
+
+	if cached, ok = c.entries[key]; ok && time.Now().After(cached.expires) {
+		wipeCacheEntry(cached)
+		delete(c.entries, key)
+		c.stats.Evictions++
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return
+	}
+
+	c.stats.Hits++
+
+	ce = &cacheEntry{
+		expires:   cached.expires,
+		entryType: cached.entryType,
+	}
+	if cached.names != nil {
+		ce.names = make([]string, len(cached.names))
+		copy(ce.names, cached.names)
+	}
+	if cached.raw != nil {
+		ce.raw = make([]byte, len(cached.raw))
+		copy(ce.raw, cached.raw)
+	}
+
+	return
+}
+
+// put caches ce for key, unless anything was invalidated since gen (as returned by cachingBackend.get).
+func (c *cachingBackend) put(key cacheKey, ce *cacheEntry, gen uint64) {
+
+	var ok bool
+	var old *cacheEntry
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if gen != c.gen {
+		wipeCacheEntry(ce)
+		return
+	}
+
+	if old, ok = c.entries[key]; ok {
+		wipeCacheEntry(old)
+	}
+
+	ce.expires = time.Now().Add(c.ttl)
+	c.entries[key] = ce
+
+	return
+}
+
+// list returns the cached listing for key, calling fetch (and caching the result) if needed.
+func (c *cachingBackend) list(key cacheKey, fetch func() (names []string, err error)) (names []string, err error) {
+
+	var ce *cacheEntry
+	var gen uint64
+	var cached []string
+
+	if ce, gen = c.get(key); ce != nil {
+		names = ce.names
+		return
+	}
+
+	if names, err = fetch(); err != nil {
+		return
+	}
+
+	cached = make([]string, len(names))
+	copy(cached, names)
+	c.put(key, &cacheEntry{names: cached}, gen)
+
+	return
+}
+
+/*
+	value returns a copy of the cached (serialized) value for key, calling fetch (and caching a copy of the result) if needed.
+	The caller owns (and should wipe) raw.
+*/
+func (c *cachingBackend) value(key cacheKey, fetch func() (raw []byte, err error)) (raw []byte, err error) {
+
+	var ce *cacheEntry
+	var gen uint64
+	var cached []byte
+
+	if ce, gen = c.get(key); ce != nil {
+		raw = ce.raw
+		return
+	}
+
+	if raw, err = fetch(); err != nil {
+		return
+	}
+
+	cached = make([]byte, len(raw))
+	copy(cached, raw)
+	c.put(key, &cacheEntry{raw: cached}, gen)
+
+	return
+}
+
+// evict removes (and wipes) every expired cacheEntry.
+func (c *cachingBackend) evict() {
+
+	var now time.Time = time.Now()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, ce := range c.entries {
+		if now.After(ce.expires) {
+			wipeCacheEntry(ce)
+			delete(c.entries, k)
+			c.stats.Evictions++
+		}
+	}
+
+	return
+}
+
+// invalidate removes (and wipes) every cacheEntry whose key matches. match is called with the lock held.
+func (c *cachingBackend) invalidate(match func(k cacheKey) (isMatch bool)) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.gen++
+
+	for k, ce := range c.entries {
+		if match(k) {
+			wipeCacheEntry(ce)
+			delete(c.entries, k)
+			c.stats.Invalidations++
+		}
+	}
+
+	return
+}
+
+// invalidateEntry invalidates the listings of Folder folderName and everything cached for entry entryName in it.
+func (c *cachingBackend) invalidateEntry(handle int32, folderName, entryName string) {
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = c.sameWallet(k, handle) && k.folder == folderName && (k.entry == "" || k.entry == entryName)
+		return
+	})
+
+	return
+}
+
+// invalidateFolder invalidates everything cached for Folder folderName, and the folder listing if withList.
+func (c *cachingBackend) invalidateFolder(handle int32, folderName string, withList bool) {
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = c.sameWallet(k, handle) && (k.folder == folderName || (withList && k.kind == cacheKindFolders))
+		return
+	})
+
+	return
+}
+
+// invalidateHandle invalidates everything cached for the Wallet of a handle and forgets the handle.
+func (c *cachingBackend) invalidateHandle(handle int32) {
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = c.sameWallet(k, handle)
+		return
+	})
+
+	c.lock.Lock()
+	delete(c.wallets, handle)
+	c.lock.Unlock()
+
+	return
+}
+
+/*
+	invalidateWallet invalidates everything cached for Wallet walletName (and for any handle of an unknown Wallet),
+	and forgets its handles if forget.
+*/
+func (c *cachingBackend) invalidateWallet(walletName string, forget bool) {
+
+	c.invalidate(func(k cacheKey) (isMatch bool) {
+		isMatch = c.inWallet(k, walletName)
+		return
+	})
+
+	if !forget {
+		return
+	}
+
+	c.lock.Lock()
+	for h, wn := range c.wallets {
+		if wn == walletName {
+			delete(c.wallets, h)
+		}
+	}
+	c.lock.Unlock()
+
+	return
+}
+
+// inWallet returns true if k is (or, if cached for an unknown handle, may be) for Wallet walletName. The lock must be held.
+func (c *cachingBackend) inWallet(k cacheKey, walletName string) (isWallet bool) {
+
+	isWallet = k.wallet == "" || k.wallet == walletName
+
+	return
+}
+
+// sameWallet returns true if k is (or may be) for the same Wallet as handle. The lock must be held.
+func (c *cachingBackend) sameWallet(k cacheKey, handle int32) (isSame bool) {
+
+	var ok bool
+	var wn string
+
+	if wn, ok = c.wallets[handle]; !ok {
+		isSame = true
+		return
+	}
+
+	isSame = c.inWallet(k, wn)
+
+	return
+}
+
+// wipeCacheEntry zeroes the cached value of a cacheEntry and drops its references.
+func wipeCacheEntry(ce *cacheEntry) {
+
+	wipeBytes(ce.raw)
+	ce.raw = nil
+	ce.names = nil
+
+	return
+}
@@ -0,0 +1,203 @@
+/*
+	gokwallet is a command-line utility for gokwallet.
+
+	Usage:
+
+		gokwallet backup -dir <directory> [options]
+
+	The backup subcommand writes encrypted backups of KWallet wallets (see gokwallet.BackupScheduler).
+	The passphrase is read from the file given by -passphrase-file or, if that isn't given,
+	the GOKWALLET_BACKUP_PASSPHRASE environment variable.
+	With -interval 0 (the default), it backs up once and exits non-zero if any wallet failed;
+	otherwise it runs until interrupted.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"r00t2.io/gokwallet"
+)
+
+const (
+	passphraseEnv string = "GOKWALLET_BACKUP_PASSPHRASE"
+)
+
+// walletList is a flag.Value for a flag that can be repeated and/or take a comma-separated list.
+type walletList []string
+
+func main() {
+
+	var err error
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "backup":
+		err = backup(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown subcommand %#v\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gokwallet: %v\n", err)
+		os.Exit(1)
+	}
+
+	return
+}
+
+// usage prints the top-level usage.
+func usage() {
+
+	fmt.Fprintln(os.Stderr, "Usage: gokwallet <subcommand> [options]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Subcommands:")
+	fmt.Fprintln(os.Stderr, "  backup    write encrypted backups of wallets (see gokwallet backup -h)")
+
+	return
+}
+
+// backup runs the backup subcommand.
+func backup(args []string) (err error) {
+
+	var wm *gokwallet.WalletManager
+	var s *gokwallet.BackupScheduler
+	var report *gokwallet.BackupReport
+	var passphrase []byte
+	var wallets walletList
+	var sigs chan os.Signal
+	var fs *flag.FlagSet = flag.NewFlagSet("backup", flag.ExitOnError)
+	var dir *string = fs.String("dir", "", "directory to write backup archives to (required)")
+	var interval *time.Duration = fs.Duration("interval", 0, "time between backups; 0 backs up once and exits")
+	var keepDaily *int = fs.Int("keep-daily", 0, "number of days to keep the newest backup of (0 and -keep-weekly 0 keep everything)")
+	var keepWeekly *int = fs.Int("keep-weekly", 0, "number of ISO weeks to keep the newest backup of")
+	var appID *string = fs.String("app-id", gokwallet.DefaultAppID, "app ID to use with kwalletd")
+	var walletDir *string = fs.String("wallet-dir", "", "use the wallet files in this directory (see gokwallet.FileBackend) instead of kwalletd")
+	var passFile *string = fs.String(
+		"passphrase-file", "", fmt.Sprintf("file containing the backup passphrase (default: $%v)", passphraseEnv),
+	)
+
+	fs.Var(&wallets, "wallet", "wallet to back up; may be repeated or comma-separated (default: all wallets)")
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if *passFile != "" {
+		if passphrase, err = os.ReadFile(*passFile); err != nil {
+			return
+		}
+		passphrase = bytes.TrimRight(passphrase, "\r\n")
+	} else {
+		passphrase = []byte(os.Getenv(passphraseEnv))
+	}
+	defer func() {
+		for idx := range passphrase {
+			passphrase[idx] = 0
+		}
+	}()
+
+	if *walletDir != "" {
+		wm, err = gokwallet.NewWalletManagerFiles(&gokwallet.RecurseOpts{}, *appID, *walletDir)
+	} else {
+		wm, err = gokwallet.NewWalletManager(&gokwallet.RecurseOpts{}, *appID)
+	}
+	if err != nil {
+		return
+	}
+	defer wm.Close()
+
+	if s, err = gokwallet.NewBackupScheduler(wm, *dir, passphrase, *interval); err != nil {
+		return
+	}
+	s.Wallets = wallets
+	s.Retention = gokwallet.BackupRetention{
+		Daily:  *keepDaily,
+		Weekly: *keepWeekly,
+	}
+	s.OnFailure = func(res *gokwallet.BackupResult) {
+		fmt.Fprintf(os.Stderr, "gokwallet: backup of wallet %#v failed: %v\n", res.Wallet, res.Err)
+	}
+	s.OnRun = func(report *gokwallet.BackupReport) {
+		printReport(report)
+	}
+
+	if *interval <= 0 {
+		report, err = s.Run()
+		printReport(report)
+		return
+	}
+
+	sigs = make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	if err = s.Start(); err != nil {
+		return
+	}
+
+	<-sigs
+	s.Stop()
+
+	return
+}
+
+// printReport prints a summary of a BackupReport to stdout.
+func printReport(report *gokwallet.BackupReport) {
+
+	if report == nil {
+		return
+	}
+
+	for _, res := range report.Wallets {
+		switch {
+		case res.Err != nil:
+			continue
+		case res.Unchanged:
+			fmt.Printf("%v: unchanged (%v)\n", res.Wallet, res.Fingerprint)
+		default:
+			fmt.Printf("%v: wrote %v\n", res.Wallet, res.Path)
+		}
+		for _, p := range res.Pruned {
+			fmt.Printf("%v: pruned %v\n", res.Wallet, p)
+		}
+	}
+
+	return
+}
+
+// String implements flag.Value.
+func (w *walletList) String() (s string) {
+
+	s = strings.Join(*w, ",")
+
+	return
+}
+
+// Set implements flag.Value.
+func (w *walletList) Set(val string) (err error) {
+
+	for _, wn := range strings.Split(val, ",") {
+		if wn = strings.TrimSpace(wn); wn != "" {
+			*w = append(*w, wn)
+		}
+	}
+
+	return
+}
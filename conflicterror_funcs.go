@@ -0,0 +1,21 @@
+package gokwallet
+
+import (
+	"fmt"
+)
+
+// Error returns a string representation of a ConflictError (to conform with the error interface).
+func (e *ConflictError) Error() (errStr string) {
+
+	errStr = fmt.Sprintf("%v: %#v/%#v/%#v: %v", ErrConflict.Error(), e.Wallet, e.Folder, e.Entry, e.Reason)
+
+	return
+}
+
+// Is returns true if target is ErrConflict, so that errors.Is(err, ErrConflict) works for a ConflictError.
+func (e *ConflictError) Is(target error) (isErr bool) {
+
+	isErr = target == ErrConflict
+
+	return
+}
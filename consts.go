@@ -1,5 +1,10 @@
 package gokwallet
 
+import (
+	"os"
+	"sync"
+)
+
 // KwalletD Dbus returns.
 const (
 	DbusSuccess int32 = 0
@@ -45,7 +50,13 @@ var (
 		Maps:           false,
 		Blobs:          false,
 		UnknownItems:   false,
+		Lazy:           false,
+		CacheValues:    false,
 	}
+	// recurseFiltersLock guards RecurseOpts.filters, as a RecurseOpts (e.g. DefaultRecurseOpts) may be shared.
+	recurseFiltersLock sync.Mutex
+	// lockedMemory is the pool of locked memory SecretBytes are allocated from (see lockedAlloc).
+	lockedMemory *lockedPool = new(lockedPool)
 )
 
 // WalletManager interface.
@@ -202,6 +213,367 @@ const (
 	DbusWMWritePassword string = DbusInterfaceWM + ".writePassword"
 )
 
+// Dbus signals (emitted by DbusInterfaceWM). These are the member names.
+const (
+	// DbusWMSignalFolderUpdated is emitted (with the Wallet and Folder names) when a Folder's WalletItems change.
+	DbusWMSignalFolderUpdated string = "folderUpdated"
+	// DbusWMSignalFolderListUpdated is emitted (with the Wallet name) when Folders are added to or removed from a Wallet.
+	DbusWMSignalFolderListUpdated string = "folderListUpdated"
+	// DbusWMSignalWalletClosed is emitted (with the Wallet name, or just its handle) when a Wallet is closed.
+	DbusWMSignalWalletClosed string = "walletClosed"
+	// DbusWMSignalWalletDeleted is emitted (with the Wallet name) when a Wallet is deleted.
+	DbusWMSignalWalletDeleted string = "walletDeleted"
+)
+
+// Diff operations.
+const (
+	// DiffAdded indicates a Wallet, Folder, WalletItem, or Map key was added.
+	DiffAdded DiffOp = "added"
+	// DiffRemoved indicates a Wallet, Folder, WalletItem, or Map key was removed.
+	DiffRemoved DiffOp = "removed"
+	// DiffModified indicates a WalletItem's value (or a Map key's value) changed.
+	DiffModified DiffOp = "modified"
+	// DiffTypeChanged indicates a WalletItem was replaced by one of a different type.
+	DiffTypeChanged DiffOp = "type_changed"
+)
+
+// StatePlan actions. Each maps to a single Backend call.
+const (
+	// StateCreateFolder creates a Folder (Backend.CreateFolder).
+	StateCreateFolder StateAction = "create_folder"
+	// StateWriteEntry adds or replaces a WalletItem (Backend.WriteEntry).
+	StateWriteEntry StateAction = "write_entry"
+	// StateRenameEntry renames a WalletItem (Backend.RenameEntry).
+	StateRenameEntry StateAction = "rename_entry"
+	// StateRemoveEntry removes a WalletItem (Backend.RemoveEntry).
+	StateRemoveEntry StateAction = "remove_entry"
+)
+
+// Walk kinds.
+const (
+	// WalkKindWallet is a WalkEntry for a Wallet.
+	WalkKindWallet WalkKind = "wallet"
+	// WalkKindFolder is a WalkEntry for a Folder.
+	WalkKindFolder WalkKind = "folder"
+	// WalkKindItem is a WalkEntry for a WalletItem.
+	WalkKindItem WalkKind = "item"
+)
+
+// cachingBackend cache kinds (see cacheKey).
+const (
+	cacheKindFolders   string = "folders"
+	cacheKindEntries   string = "entries"
+	cacheKindPasswords string = "passwords"
+	cacheKindMaps      string = "maps"
+	cacheKindType      string = "type"
+	cacheKindRaw       string = "raw"
+	cacheKindPassword  string = "password"
+	cacheKindMap       string = "map"
+)
+
+// Tx actions.
+const (
+	// TxWriteEntry adds or replaces a WalletItem.
+	TxWriteEntry TxAction = "write_entry"
+	// TxRemoveEntry removes a WalletItem.
+	TxRemoveEntry TxAction = "remove_entry"
+	// TxRenameEntry renames a WalletItem.
+	TxRenameEntry TxAction = "rename_entry"
+)
+
+// ConflictPolicy values.
+const (
+	// ConflictFail returns ErrEntryExists if the destination entry exists. An empty ConflictPolicy is the same as ConflictFail.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictSkip leaves an existing destination entry alone and does not copy the source entry.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces an existing destination entry.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictRename copies the source entry to a new, unused name (e.g. "name (1)") in the destination.
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// Export documents (see ExportDocument).
+const (
+	// ExportFormat identifies an ExportDocument (ExportDocument.Format).
+	ExportFormat string = "gokwallet-export"
+	// ExportVersion is the ExportDocument.Version written by this library (and the newest it can import).
+	ExportVersion int = 1
+)
+
+// Backup archives (see Wallet.Backup).
+const (
+	// BackupVersion is the version of the backup archive format written by this library (and the newest it can restore).
+	BackupVersion int = 1
+	// backupMagic starts every backup archive.
+	backupMagic string = "GOKWALLET-BACKUP\n"
+	// backupMaxHeader is the largest backup archive header that is accepted.
+	backupMaxHeader uint32 = 64 * 1024
+	// backupKDFScrypt is the backupHeader.KDF for a key derived from a passphrase with scrypt.
+	backupKDFScrypt string = "scrypt"
+	// backupKDFShamir is the backupHeader.KDF for a random key split into Shamir key shares (see Wallet.BackupShares).
+	backupKDFShamir string = "shamir"
+	// backupCipher is the backupHeader.Cipher used to encrypt (and authenticate) backup archives.
+	backupCipher string = "AES-256-GCM"
+	// backupKeyLen is the length of the key used for backupCipher.
+	backupKeyLen int = 32
+	// backupSaltLen is the length of the random salt used with backupKDFScrypt.
+	backupSaltLen int = 16
+	/*
+		backupScryptN, backupScryptR, and backupScryptP are the scrypt parameters used for new backup archives.
+		When restoring, N is limited to backupScryptMaxN and p to backupScryptMaxP, and the memory scrypt needs
+		(128*N*r bytes) to backupScryptMaxMem, so a crafted archive cannot exhaust memory or CPU.
+	*/
+	backupScryptN      int   = 1 << 15
+	backupScryptR      int   = 8
+	backupScryptP      int   = 1
+	backupScryptMaxN   int   = 1 << 20
+	backupScryptMaxP   int   = 16
+	backupScryptMaxMem int64 = 1 << 30
+)
+
+// Backup archive key shares (see Wallet.BackupShares).
+const (
+	// BackupShareMax is the largest number of key shares a backup archive key can be split into.
+	BackupShareMax int = 255
+	// backupSharePrefix starts the text encoding of every key share.
+	backupSharePrefix string = "GKWSHARE"
+	// backupShareVersion is the version of the key share encoding.
+	backupShareVersion byte = 1
+	// backupShareIDLen is the length of backupHeader.ShareID.
+	backupShareIDLen int = 8
+	// backupShareCheckLen is the length of the checksum (a truncated SHA-256) at the end of an encoded key share.
+	backupShareCheckLen int = 4
+	// backupShareGroup is the number of characters in each dash-separated group of an encoded key share.
+	backupShareGroup int = 4
+)
+
+// KeePass (KDBX 4) databases (see Wallet.ExportKDBX).
+const (
+	// DefaultKDBXArgon2Memory is the default KDBXOpts.Argon2Memory (64 MiB).
+	DefaultKDBXArgon2Memory uint64 = 64 << 20
+	// DefaultKDBXArgon2Iterations is the default KDBXOpts.Argon2Iterations.
+	DefaultKDBXArgon2Iterations uint64 = 3
+	// DefaultKDBXArgon2Parallelism is the default KDBXOpts.Argon2Parallelism.
+	DefaultKDBXArgon2Parallelism uint32 = 2
+	/*
+		KDBXTypeKey is the key of the custom data item of a KeePass entry exported by Wallet.ExportKDBX
+		that records the type of the WalletItem ("password", "map", "blob", or "unknown"), so it is imported as the same type.
+	*/
+	KDBXTypeKey string = "gokwallet.type"
+
+	// kdbxSig1 and kdbxSig2 start every KeePass database; kdbxMajorVersion is the only format version supported.
+	kdbxSig1         uint32 = 0x9aa2d903
+	kdbxSig2         uint32 = 0xb54bfb67
+	kdbxMajorVersion uint32 = 4
+	// kdbxHeaderBlock is the block index used for the HMAC of the header.
+	kdbxHeaderBlock uint64 = 0xffffffffffffffff
+	// kdbxBlockSize is the size of the HMAC blocks written.
+	kdbxBlockSize int = 1 << 20
+	// kdbxMaxArgon2Memory is the most memory (in bytes) Argon2 may use to open a database.
+	kdbxMaxArgon2Memory uint64 = 2 << 30
+	// kdbxMaxArgon2Parallelism is the most Argon2 lanes a database may use.
+	kdbxMaxArgon2Parallelism uint32 = 256
+	// kdbxMaxArgon2Iterations is the most Argon2 passes a database may use (far more than KeePass clients choose for a second's delay).
+	kdbxMaxArgon2Iterations uint64 = 1 << 16
+	// kdbxMaxAESRounds is the most AES-KDF rounds a database may use (far more than KeePass clients choose for a second's delay).
+	kdbxMaxAESRounds uint64 = 1 << 30
+	// kdbxBinaryProtected is the attachment flag for "protect in memory".
+	kdbxBinaryProtected byte = 0x01
+	// kdbxDefaultFolder is the Folder name used for entries of a root group without a name.
+	kdbxDefaultFolder string = "Passwords"
+	// kdbxUntitled is the entry name used for entries without a title.
+	kdbxUntitled string = "Untitled"
+	// kdbxTimeEpoch is the number of seconds between 0001-01-01 (the KDBX 4 time epoch) and the Unix epoch.
+	kdbxTimeEpoch int64 = 62135596800
+)
+
+// KDBX outer header field IDs.
+const (
+	kdbxHdrEnd         byte = 0
+	kdbxHdrCipherID    byte = 2
+	kdbxHdrCompression byte = 3
+	kdbxHdrMasterSeed  byte = 4
+	kdbxHdrIV          byte = 7
+	kdbxHdrKDF         byte = 11
+)
+
+// KDBX inner header field IDs.
+const (
+	kdbxInnerEnd       byte = 0
+	kdbxInnerStreamID  byte = 1
+	kdbxInnerStreamKey byte = 2
+	kdbxInnerBinary    byte = 3
+)
+
+// KDBX payload compression and inner random streams.
+const (
+	kdbxCompressNone   uint32 = 0
+	kdbxCompressGzip   uint32 = 1
+	kdbxStreamNone     uint32 = 0
+	kdbxStreamChaCha20 uint32 = 3
+)
+
+// KDBX cipher and KDF UUIDs (hex-encoded).
+const (
+	kdbxCipherAES256   string = "31c1f2e6bf714350be5805216afc5aff"
+	kdbxCipherTwofish  string = "ad68f29f576f4bb9a36ad47af965346c"
+	kdbxCipherChaCha20 string = "d6038a2b8b6f4cb5a524339a31dbb59a"
+	kdbxKDFAES         string = "c9d9f39a628a4460bf740d08c18a4fea"
+	kdbxKDFArgon2d     string = "ef636ddf8c29444b91f7a9a403e30a0c"
+	kdbxKDFArgon2id    string = "9e298b1956db4773b23dfc3ec6f0a1e6"
+)
+
+// KDBX KDF parameter (VariantDictionary) keys.
+const (
+	kdbxKDFUUID        string = "$UUID"
+	kdbxKDFRounds      string = "R"
+	kdbxKDFSeed        string = "S"
+	kdbxKDFSalt        string = "S"
+	kdbxKDFParallelism string = "P"
+	kdbxKDFMemory      string = "M"
+	kdbxKDFIterations  string = "I"
+	kdbxKDFVersion     string = "V"
+	kdbxKDFSecret      string = "K"
+	kdbxKDFAssocData   string = "A"
+)
+
+// KDBX VariantDictionary version and value types.
+const (
+	kdbxVariantDictVersion uint16 = 0x0100
+	kdbxVariantEnd         byte   = 0x00
+	kdbxVariantUint32      byte   = 0x04
+	kdbxVariantUint64      byte   = 0x05
+	kdbxVariantBool        byte   = 0x08
+	kdbxVariantInt32       byte   = 0x0c
+	kdbxVariantInt64       byte   = 0x0d
+	kdbxVariantString      byte   = 0x18
+	kdbxVariantBytes       byte   = 0x42
+)
+
+// Standard KeePass entry string fields.
+const (
+	kdbxFieldTitle    string = "Title"
+	kdbxFieldUserName string = "UserName"
+	kdbxFieldPassword string = "Password"
+	kdbxFieldURL      string = "URL"
+	kdbxFieldNotes    string = "Notes"
+)
+
+// Bitwarden (unencrypted JSON) exports (see Wallet.ExportBitwarden).
+const (
+	/*
+		BitwardenTypeKey is the name of the custom field of a Bitwarden secure note exported by Wallet.ExportBitwarden
+		for a Blob or UnknownItem that records its type ("blob" or "unknown"); its notes are then the (base64-encoded) value.
+	*/
+	BitwardenTypeKey string = "gokwallet.type"
+	/*
+		BitwardenLoginSuffix is appended to the name of a Bitwarden login item to name the Map
+		holding its username, URIs, notes, TOTP secret, and custom fields (its password is a Password named after the item).
+	*/
+	BitwardenLoginSuffix string = ": login"
+
+	// bitwardenWallet is the name of the ExportWallet returned by ParseBitwarden.
+	bitwardenWallet string = "Bitwarden"
+	// bitwardenDefaultFolder is the Folder name used for Bitwarden items not in a folder.
+	bitwardenDefaultFolder string = "Passwords"
+	// bitwardenUntitled is the name used for Bitwarden items and custom fields without one.
+	bitwardenUntitled string = "Untitled"
+)
+
+// Bitwarden item and custom field types.
+const (
+	bitwardenItemLogin      int = 1
+	bitwardenItemSecureNote int = 2
+	bitwardenFieldText      int = 0
+	bitwardenFieldHidden    int = 1
+)
+
+// Map keys for the standard fields of a Bitwarden item.
+const (
+	bitwardenKeyUsername string = "username"
+	bitwardenKeyURIs     string = "uris"
+	bitwardenKeyNotes    string = "notes"
+	bitwardenKeyTOTP     string = "totp"
+)
+
+// Argon2 (see argon2Key).
+const (
+	argon2ModeD      uint32 = 0
+	argon2ModeID     uint32 = 2
+	argon2Version10  uint32 = 0x10
+	argon2Version13  uint32 = 0x13
+	argon2SyncPoints uint32 = 4
+	// argon2BlockLen is the number of uint64 words in an argon2Block (1 KiB).
+	argon2BlockLen = 128
+)
+
+// BackupScheduler.
+const (
+	/*
+		BackupFileExt is the file extension of the backup archives written by a BackupScheduler,
+		which are named <wallet>_<time>_<fingerprint><BackupFileExt> (the Wallet name is escaped as with url.PathEscape).
+	*/
+	BackupFileExt string = ".kwbackup"
+	// backupTimeFormat is the (UTC) time format in backup archive file names.
+	backupTimeFormat string = "20060102T150405.000000000Z"
+	// backupFingerprintLen is the length of the (hex-encoded) fingerprint in backup archive file names.
+	backupFingerprintLen int = 16
+	// backupDirMode is the permissions used for a BackupScheduler.Dir created by a BackupScheduler.
+	backupDirMode os.FileMode = 0700
+)
+
+// URIScheme is the scheme of kwallet:// URIs (see ItemPath).
+const URIScheme string = "kwallet"
+
+// Reserved Folders. These are hidden from Wallet.ListFolders (and thus Wallet.Update, Wallet.Snapshot, etc.).
+const (
+	// ReservedFolderPrefix is the prefix of Folder names that gokwallet uses for its own bookkeeping.
+	ReservedFolderPrefix string = ".gokwallet-"
+	// MetadataFolder is the Folder EntryMetadata is kept in (see WalletManager.EnableMetadata).
+	MetadataFolder string = ReservedFolderPrefix + "metadata"
+	// HistoryFolder is the Folder previous values are retained in (see WalletManager.EnableHistory).
+	HistoryFolder string = ReservedFolderPrefix + "history"
+	// ExpiryFolder is the Folder expiry times are kept in (see WalletManager.EnableExpiry).
+	ExpiryFolder string = ReservedFolderPrefix + "expiry"
+)
+
+// History.
+const (
+	// HistoryDepthDefault, used with Folder.SetHistoryDepth, makes a Folder use the WalletManager's history depth.
+	HistoryDepthDefault int = -1
+	// historyConfigEntry is the Map in HistoryFolder that holds per-Folder history depths.
+	historyConfigEntry string = "depths"
+)
+
+// EntryMetadata Map keys (as stored in MetadataFolder).
+const (
+	metadataKeyCreated    string = "created"
+	metadataKeyModified   string = "modified"
+	metadataKeyModifiedBy string = "modified_by"
+	metadataKeyTags       string = "tags"
+	metadataKeyNote       string = "note"
+)
+
+// RedactedValue is used in place of secret values that should not be shown (e.g. in a Diff).
+const RedactedValue string = "[REDACTED]"
+
+// Locked memory (see lockedPool).
+const (
+	// lockedSlotMin is the smallest slot handed out from a lockedSlab; slot sizes are powers of two from it up to half a page.
+	lockedSlotMin int = 32
+)
+
+// FileBackend.
+const (
+	// WalletFileExt is the file extension of wallet files in a FileBackend.
+	WalletFileExt string = ".xml"
+	// walletFileMode is the permissions used for wallet files written by a FileBackend.
+	walletFileMode os.FileMode = 0600
+	// walletDirMode is the permissions used for a FileBackend.Dir if it needs to be created.
+	walletDirMode os.FileMode = 0700
+)
+
 // Dbus paths.
 const (
 	// DbusPath is the path for DbusService.
@@ -0,0 +1,310 @@
+package gokwallet
+
+import (
+	"fmt"
+)
+
+/*
+	CopyEntry copies WalletItem entryName from Folder f to Folder dst as newEntryName (or entryName, if newEntryName is empty).
+	dst may be in a different Wallet, or even a different WalletManager.
+	The WalletItem is copied as its raw value and type, so Password, Map, Blob, and UnknownItem entries are all preserved as-is.
+	policy determines what happens if dst already has an entry named newEntryName.
+*/
+func (f *Folder) CopyEntry(entryName string, dst *Folder, newEntryName string, policy ConflictPolicy) (res *CopyResult, err error) {
+
+	var raw []byte
+
+	if newEntryName == "" {
+		newEntryName = entryName
+	}
+
+	res = &CopyResult{
+		Entry:     entryName,
+		DestEntry: newEntryName,
+	}
+
+	if err = policy.validate(); err != nil {
+		res.Err = err
+		return
+	}
+
+	if res.Type, raw, err = f.readRaw(entryName); err != nil {
+		res.Err = err
+		return
+	}
+
+	if err = dst.putEntry(res, raw, policy, false); err != nil {
+		res.Err = err
+		return
+	}
+
+	return
+}
+
+/*
+	MoveEntry is like Folder.CopyEntry, but removes the source WalletItem once it has been copied.
+	If the copy is skipped (see ConflictSkip), the source WalletItem is left in place.
+	Moving a WalletItem onto itself is a no-op.
+*/
+func (f *Folder) MoveEntry(entryName string, dst *Folder, newEntryName string, policy ConflictPolicy) (res *CopyResult, err error) {
+
+	if newEntryName == "" {
+		newEntryName = entryName
+	}
+
+	if f.sameAs(dst) && entryName == newEntryName {
+		res = &CopyResult{
+			Entry:     entryName,
+			DestEntry: newEntryName,
+		}
+		res.Type, _, res.Err = f.readRaw(entryName)
+		err = res.Err
+		return
+	}
+
+	if res, err = f.CopyEntry(entryName, dst, newEntryName, policy); err != nil || res.Skipped {
+		return
+	}
+
+	if err = transferMetadata(f, entryName, dst, res.DestEntry); err != nil {
+		res.Err = err
+		return
+	}
+
+	if err = transferHistory(f, entryName, dst, res.DestEntry); err != nil {
+		res.Err = err
+		return
+	}
+
+	if err = transferExpiry(f, entryName, dst, res.DestEntry); err != nil {
+		res.Err = err
+		return
+	}
+
+	if err = f.RemoveEntry(entryName); err != nil {
+		res.Err = err
+		return
+	}
+
+	// Removing the source retains its last value; that (and the rest of its history) now lives on at dst.
+	if err = f.ClearHistory(entryName); err != nil {
+		res.Err = err
+		return
+	}
+
+	return
+}
+
+/*
+	CopyTo copies every WalletItem in Folder f to Folder dst (see Folder.CopyEntry), keeping their names.
+	A CopyResult is returned for each WalletItem; err is a MultiError of any individual failures.
+	Every WalletItem is attempted: a conflict (with ConflictFail) or other failure for one WalletItem
+	does not stop the rest from being copied.
+	dst must not be f itself (ErrCopySelf).
+*/
+func (f *Folder) CopyTo(dst *Folder, policy ConflictPolicy) (results []*CopyResult, err error) {
+
+	var entryNames []string
+	var res *CopyResult
+	var errs []error = make([]error, 0)
+
+	if err = policy.validate(); err != nil {
+		return
+	}
+
+	if f.sameAs(dst) {
+		err = fmt.Errorf("%w: %#v/%#v", ErrCopySelf, f.wallet.Name, f.Name)
+		return
+	}
+
+	if entryNames, err = f.ListEntries(); err != nil {
+		return
+	}
+
+	results = make([]*CopyResult, 0, len(entryNames))
+
+	for _, en := range entryNames {
+		if res, err = f.CopyEntry(en, dst, en, policy); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+		results = append(results, res)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+/*
+	CopyFolder copies Folder folderName (and all of its WalletItems) from Wallet w to Wallet dst as dstFolderName
+	(or folderName, if dstFolderName is empty). dst may be in a different WalletManager.
+	The destination Folder is created if it does not exist; policy applies to each WalletItem (see Folder.CopyTo).
+	Copying a Folder onto itself (the same Wallet and folder name) fails with ErrCopySelf.
+*/
+func (w *Wallet) CopyFolder(folderName string, dst *Wallet, dstFolderName string, policy ConflictPolicy) (results []*CopyResult, err error) {
+
+	var hasFolder bool
+	var src *Folder
+	var dstFolder *Folder
+
+	if dstFolderName == "" {
+		dstFolderName = folderName
+	}
+
+	if w.wm == dst.wm && w.Name == dst.Name && folderName == dstFolderName {
+		err = fmt.Errorf("%w: %#v/%#v", ErrCopySelf, w.Name, folderName)
+		return
+	}
+
+	if hasFolder, err = w.HasFolder(folderName); err != nil {
+		return
+	} else if !hasFolder {
+		err = fmt.Errorf("%w: %#v/%#v", ErrBackendNoFolder, w.Name, folderName)
+		return
+	}
+
+	if src, err = NewFolder(w, folderName, &RecurseOpts{}); err != nil {
+		return
+	}
+
+	if hasFolder, err = dst.HasFolder(dstFolderName); err != nil {
+		return
+	} else if !hasFolder {
+		if err = dst.CreateFolder(dstFolderName); err != nil {
+			return
+		}
+	}
+
+	if dstFolder, err = NewFolder(dst, dstFolderName, &RecurseOpts{}); err != nil {
+		return
+	}
+
+	if results, err = src.CopyTo(dstFolder, policy); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	putEntry writes raw as WalletItem res.DestEntry (of type res.Type) to Folder f, resolving a conflict with an existing
+	entry by that name according to policy. res.DestEntry, res.Skipped, and res.Overwritten are updated accordingly.
+	If dryRun is true, the conflict is resolved but nothing is written.
+*/
+func (f *Folder) putEntry(res *CopyResult, raw []byte, policy ConflictPolicy, dryRun bool) (err error) {
+
+	var exists bool
+
+	if exists, err = f.HasEntry(res.DestEntry); err != nil {
+		return
+	}
+
+	if exists {
+		switch policy {
+		case ConflictSkip:
+			res.Skipped = true
+			return
+		case ConflictOverwrite:
+			res.Overwritten = true
+		case ConflictRename:
+			if res.DestEntry, err = f.freeEntryName(res.DestEntry); err != nil {
+				return
+			}
+		default:
+			err = fmt.Errorf("%w: %#v/%#v/%#v", ErrEntryExists, f.wallet.Name, f.Name, res.DestEntry)
+			return
+		}
+	}
+
+	if dryRun {
+		return
+	}
+
+	if err = f.WriteEntry(res.DestEntry, res.Type, raw); err != nil {
+		return
+	}
+
+	return
+}
+
+// freeEntryName returns the first of "name (1)", "name (2)", etc. that does not exist in a Folder.
+func (f *Folder) freeEntryName(name string) (free string, err error) {
+
+	var exists bool = true
+
+	for idx := 1; exists; idx++ {
+		free = fmt.Sprintf("%v (%d)", name, idx)
+		if exists, err = f.HasEntry(free); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+	readRaw returns the type and raw (serialized) value of WalletItem entryName in a Folder.
+	Like reading a WalletItem, it returns an *ExpiredError if the WalletItem has expired.
+*/
+func (f *Folder) readRaw(entryName string) (entryType kwalletdEnumType, raw []byte, err error) {
+
+	if err = f.expiryCheck(entryName); err != nil {
+		return
+	}
+
+	if entryType, raw, err = f.readStored(entryName); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	readStored is like Folder.readRaw, but returns the value even if the WalletItem has expired.
+	It is for moving or restoring values as they are stored (e.g. Folder.Rename and Tx rollbacks), not for reading them.
+*/
+func (f *Folder) readStored(entryName string) (entryType kwalletdEnumType, raw []byte, err error) {
+
+	var exists bool
+
+	if exists, err = f.HasEntry(entryName); err != nil {
+		return
+	} else if !exists {
+		err = fmt.Errorf("%w: %#v/%#v/%#v", ErrBackendNoEntry, f.wallet.Name, f.Name, entryName)
+		return
+	}
+
+	if entryType, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	if raw, err = f.wallet.wm.Backend().ReadEntry(f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// sameAs returns true if Folder f and Folder other refer to the same Folder (in the same Wallet and WalletManager).
+func (f *Folder) sameAs(other *Folder) (isSame bool) {
+
+	isSame = f.wallet.wm == other.wallet.wm && f.wallet.Name == other.wallet.Name && f.Name == other.Name
+
+	return
+}
+
+// validate returns ErrConflictPolicy if a ConflictPolicy is not recognized.
+func (c ConflictPolicy) validate() (err error) {
+
+	switch c {
+	case "", ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		err = fmt.Errorf("%w: %#v", ErrConflictPolicy, string(c))
+	}
+
+	return
+}
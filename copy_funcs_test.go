@@ -0,0 +1,128 @@
+package gokwallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestCopy tests copying and moving WalletItems between Folders, Wallets, and WalletManagers.
+func TestCopy(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var dstWM *WalletManager
+	var dstW *Wallet
+	var dstF *Folder
+	var res *CopyResult
+	var results []*CopyResult
+	var entryType kwalletdEnumType
+	var raw []byte
+	var srcRaw []byte
+	var hasEntry bool
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	if dstWM, err = NewWalletManagerBackend(NewMemoryBackend(), e.r, appIdTest); err != nil {
+		t.Fatalf("failure getting destination WalletManager: %v", err)
+	}
+	if dstW, err = NewWallet(dstWM, walletTestAlt.String(), e.r); err != nil {
+		t.Fatalf("failure getting destination Wallet: %v", err)
+	}
+
+	// Whole-Folder copy across WalletManagers.
+	if results, err = e.w.CopyFolder(folderTest.String(), dstW, "", ConflictFail); err != nil {
+		t.Fatalf("failed to CopyFolder: %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("expected 4 CopyResults, got %v", len(results))
+	}
+	if dstF, err = NewFolder(dstW, folderTest.String(), e.r); err != nil {
+		t.Fatalf("failure getting destination Folder: %v", err)
+	}
+	for _, res = range results {
+		if _, srcRaw, err = e.f.readRaw(res.Entry); err != nil {
+			t.Fatalf("failed to read source %v: %v", res.Entry, err)
+		}
+		if entryType, raw, err = dstF.readRaw(res.DestEntry); err != nil {
+			t.Fatalf("failed to read copied %v: %v", res.DestEntry, err)
+		}
+		if entryType != res.Type || !bytes.Equal(raw, srcRaw) {
+			t.Errorf("copied %v does not match source (type %v, expected %v)", res.Entry, entryType, res.Type)
+		}
+	}
+	if err = dstF.Update(); err != nil {
+		t.Fatalf("failed to Update destination Folder: %v", err)
+	}
+	if dstF.Passwords[passwordTest.String()] == nil || dstF.Passwords[passwordTest.String()].Value != testPassword {
+		t.Errorf("copied Password not readable as a Password")
+	}
+
+	// Conflict policies.
+	if _, err = e.f.CopyEntry(passwordTest.String(), dstF, "", ConflictFail); !errors.Is(err, ErrEntryExists) {
+		t.Errorf("expected ErrEntryExists, got %v", err)
+	}
+	if results, err = e.f.CopyTo(dstF, ConflictSkip); err != nil {
+		t.Errorf("failed to CopyTo with ConflictSkip: %v", err)
+	}
+	for _, res = range results {
+		if !res.Skipped {
+			t.Errorf("expected %v to be skipped", res.Entry)
+		}
+	}
+	if res, err = e.f.CopyEntry(passwordTest.String(), dstF, "", ConflictRename); err != nil {
+		t.Errorf("failed to CopyEntry with ConflictRename: %v", err)
+	} else if res.DestEntry != passwordTest.String()+" (1)" {
+		t.Errorf("unexpected renamed destination %#v", res.DestEntry)
+	}
+	if _, err = e.f.WritePassword(passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if res, err = e.f.CopyEntry(passwordTest.String(), dstF, "", ConflictOverwrite); err != nil {
+		t.Errorf("failed to CopyEntry with ConflictOverwrite: %v", err)
+	} else if !res.Overwritten {
+		t.Errorf("expected CopyResult.Overwritten")
+	}
+	if _, err = e.f.CopyEntry(passwordTest.String(), dstF, "", "bogus"); !errors.Is(err, ErrConflictPolicy) {
+		t.Errorf("expected ErrConflictPolicy, got %v", err)
+	}
+
+	// A Folder can't be copied onto itself (with ConflictRename, that would duplicate every entry).
+	if _, err = e.w.CopyFolder(folderTest.String(), e.w, "", ConflictRename); !errors.Is(err, ErrCopySelf) {
+		t.Errorf("expected ErrCopySelf for CopyFolder, got %v", err)
+	}
+	if _, err = e.f.CopyTo(e.f, ConflictRename); !errors.Is(err, ErrCopySelf) {
+		t.Errorf("expected ErrCopySelf for CopyTo, got %v", err)
+	}
+	if hasEntry, err = e.f.HasEntry(passwordTest.String() + " (1)"); err != nil || hasEntry {
+		t.Errorf("copying a Folder onto itself duplicated entries (err: %v)", err)
+	}
+
+	// Moves.
+	if res, err = e.f.MoveEntry(blobTest.String(), dstF, blobTest.String()+"_moved", ConflictFail); err != nil {
+		t.Fatalf("failed to MoveEntry: %v", err)
+	}
+	if res.Type != KwalletdEnumTypeStream {
+		t.Errorf("moved Blob has type %v", res.Type)
+	}
+	if hasEntry, err = e.f.HasEntry(blobTest.String()); err != nil || hasEntry {
+		t.Errorf("moved Blob still exists in source (err: %v)", err)
+	}
+	if hasEntry, err = dstF.HasEntry(blobTest.String() + "_moved"); err != nil || !hasEntry {
+		t.Errorf("moved Blob does not exist in destination (err: %v)", err)
+	}
+	if _, err = e.f.MoveEntry(mapTest.String(), e.f, "", ConflictFail); err != nil {
+		t.Errorf("failed to MoveEntry onto itself: %v", err)
+	}
+	if hasEntry, err = e.f.HasEntry(mapTest.String()); err != nil || !hasEntry {
+		t.Errorf("Map moved onto itself no longer exists (err: %v)", err)
+	}
+	if _, err = e.f.MoveEntry(blobTest.String(), dstF, "", ConflictFail); !errors.Is(err, ErrBackendNoEntry) {
+		t.Errorf("expected ErrBackendNoEntry moving a nonexistent entry, got %v", err)
+	}
+}
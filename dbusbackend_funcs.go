@@ -0,0 +1,685 @@
+package gokwallet
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// NewDbusBackend returns a DbusBackend connected to kwalletd via the Dbus session bus.
+func NewDbusBackend() (backend *DbusBackend, err error) {
+
+	backend = &DbusBackend{
+		DbusObject: &DbusObject{
+			Conn: nil,
+			Dbus: nil,
+		},
+	}
+
+	if backend.DbusObject.Conn, err = dbus.SessionBus(); err != nil {
+		return
+	}
+	backend.DbusObject.Dbus = backend.DbusObject.Conn.Object(DbusService, dbus.ObjectPath(DbusPath))
+
+	return
+}
+
+// IsEnabled returns whether KWallet is enabled or not.
+func (d *DbusBackend) IsEnabled() (enabled bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMIsEnabled, 0,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&enabled); err != nil {
+		return
+	}
+
+	return
+}
+
+// Wallets returns the names of all wallets.
+func (d *DbusBackend) Wallets() (walletNames []string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMWallets, 0,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&walletNames); err != nil {
+		return
+	}
+
+	return
+}
+
+// LocalWallet returns the name of the "local" wallet.
+func (d *DbusBackend) LocalWallet() (walletName string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMLocalWallet, 0,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&walletName); err != nil {
+		return
+	}
+
+	return
+}
+
+// NetworkWallet returns the name of the "network" wallet.
+func (d *DbusBackend) NetworkWallet() (walletName string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMNetWallet, 0,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&walletName); err != nil {
+		return
+	}
+
+	return
+}
+
+// Open opens (unlocks) a wallet and returns its handle.
+func (d *DbusBackend) Open(walletName, appID string) (handle int32, err error) {
+
+	var call *dbus.Call
+	var handler *int32 = new(int32)
+
+	if call = d.Dbus.Call(
+		DbusWMOpen, 0, walletName, DefaultWindowID, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(handler); err != nil {
+		return
+	}
+
+	if handler == nil {
+		err = ErrDbusOpfailNoHandle
+		return
+	}
+
+	handle = *handler
+
+	return
+}
+
+// IsOpen returns whether a wallet is open ("unlocked") or not.
+func (d *DbusBackend) IsOpen(walletName string) (isOpen bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMIsOpen, 0, walletName,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&isOpen); err != nil {
+		return
+	}
+
+	return
+}
+
+// Close closes the wallet for a handle.
+func (d *DbusBackend) Close(handle int32, force bool, appID string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMClose, 0, handle, force, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// CloseWallet closes a wallet by name for all applications.
+func (d *DbusBackend) CloseWallet(walletName string, force bool) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMClose, 0, walletName, force,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// CloseAllWallets closes all wallets.
+func (d *DbusBackend) CloseAllWallets() (err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMCloseAllWallets, 0,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+
+	return
+}
+
+// DeleteWallet deletes a wallet.
+func (d *DbusBackend) DeleteWallet(walletName string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMDeleteWallet, 0, walletName,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// DisconnectApplication disconnects application appID from a wallet.
+func (d *DbusBackend) DisconnectApplication(walletName, appID string) (err error) {
+
+	var call *dbus.Call
+	var ok bool
+
+	if call = d.Dbus.Call(
+		DbusWMDisconnectApp, 0, walletName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&ok); err != nil {
+		return
+	}
+
+	if !ok {
+		err = ErrNoDisconnect
+	}
+
+	return
+}
+
+// Users returns the application IDs that have a wallet open.
+func (d *DbusBackend) Users(walletName string) (appIDs []string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMUsers, 0, walletName,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&appIDs); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ChangePassword changes (or sets) the password for a wallet.
+	Note that this is done via the windowing/graphical layer by kwalletd.
+*/
+func (d *DbusBackend) ChangePassword(walletName, appID string) (err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMChangePassword, 0, walletName, DefaultWindowID, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+
+	return
+}
+
+// FolderList returns the names of all folders in a wallet.
+func (d *DbusBackend) FolderList(handle int32, appID string) (folderNames []string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMFolderList, 0, handle, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&folderNames); err != nil {
+		return
+	}
+
+	return
+}
+
+// HasFolder returns whether a wallet contains a folder.
+func (d *DbusBackend) HasFolder(handle int32, folderName, appID string) (hasFolder bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMHasFolder, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&hasFolder); err != nil {
+		return
+	}
+
+	return
+}
+
+// FolderDoesNotExist returns whether a folder does *not* exist.
+func (d *DbusBackend) FolderDoesNotExist(walletName, folderName string) (notExist bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMFolderNotExist, 0, walletName, folderName,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&notExist); err != nil {
+		return
+	}
+
+	return
+}
+
+// CreateFolder creates a folder in a wallet.
+func (d *DbusBackend) CreateFolder(handle int32, folderName, appID string) (err error) {
+
+	var call *dbus.Call
+	var ok bool
+
+	if call = d.Dbus.Call(
+		DbusWMCreateFolder, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&ok); err != nil {
+		return
+	}
+
+	if !ok {
+		err = ErrNoCreate
+	}
+
+	return
+}
+
+// RemoveFolder removes a folder, and all of its entries, from a wallet.
+func (d *DbusBackend) RemoveFolder(handle int32, folderName, appID string) (err error) {
+
+	var call *dbus.Call
+	var success bool
+
+	if call = d.Dbus.Call(
+		DbusWMRemoveFolder, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&success); err != nil {
+		return
+	}
+
+	if !success {
+		err = ErrDbusOpfailRemoveFolder
+		return
+	}
+
+	return
+}
+
+// EntryList returns the names of all entries in a folder.
+func (d *DbusBackend) EntryList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMEntryList, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&entryNames); err != nil {
+		return
+	}
+
+	return
+}
+
+// PasswordList returns the names of all Password entries in a folder.
+func (d *DbusBackend) PasswordList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	var call *dbus.Call
+	var variant dbus.Variant
+
+	if call = d.Dbus.Call(
+		DbusWMPasswordList, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&variant); err != nil {
+		return
+	}
+
+	entryNames = bytemapKeys(variant)
+
+	return
+}
+
+// MapList returns the names of all Map entries in a folder.
+func (d *DbusBackend) MapList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	var call *dbus.Call
+	var variant dbus.Variant
+
+	if call = d.Dbus.Call(
+		DbusWMMapList, 0, handle, folderName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&variant); err != nil {
+		return
+	}
+
+	entryNames = bytemapKeys(variant)
+
+	return
+}
+
+// EntryType returns the type of an entry.
+func (d *DbusBackend) EntryType(handle int32, folderName, entryName, appID string) (entryType kwalletdEnumType, err error) {
+
+	var call *dbus.Call
+	var t int32
+
+	if call = d.Dbus.Call(
+		DbusWMEntryType, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&t); err != nil {
+		return
+	}
+
+	entryType = kwalletdEnumType(t)
+
+	return
+}
+
+// HasEntry returns whether a folder contains an entry.
+func (d *DbusBackend) HasEntry(handle int32, folderName, entryName, appID string) (hasEntry bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMHasEntry, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&hasEntry); err != nil {
+		return
+	}
+
+	return
+}
+
+// KeyDoesNotExist returns whether an entry does *not* exist.
+func (d *DbusBackend) KeyDoesNotExist(walletName, folderName, entryName string) (notExist bool, err error) {
+
+	var call *dbus.Call
+
+	if call = d.Dbus.Call(
+		DbusWMKeyNotExist, 0, walletName, folderName, entryName,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&notExist); err != nil {
+		return
+	}
+
+	return
+}
+
+// ReadEntry returns the raw (serialized) value of an entry.
+func (d *DbusBackend) ReadEntry(handle int32, folderName, entryName, appID string) (value []byte, err error) {
+
+	var call *dbus.Call
+	var v dbus.Variant
+
+	if call = d.Dbus.Call(
+		DbusWMReadEntry, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&v); err != nil {
+		return
+	}
+
+	value = v.Value().([]byte)
+
+	return
+}
+
+// ReadPassword returns the value of a Password entry.
+func (d *DbusBackend) ReadPassword(handle int32, folderName, entryName, appID string) (value string, err error) {
+
+	var call *dbus.Call
+	var b []byte
+
+	if call = d.Dbus.Call(
+		DbusWMReadPassword, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&b); err != nil {
+		return
+	}
+
+	value = string(b)
+
+	return
+}
+
+// ReadMap returns the value of a Map entry.
+func (d *DbusBackend) ReadMap(handle int32, folderName, entryName, appID string) (value map[string]string, err error) {
+
+	var call *dbus.Call
+	var b []byte
+
+	value = make(map[string]string, 0)
+
+	if call = d.Dbus.Call(
+		DbusWMReadMap, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&b); err != nil {
+		return
+	}
+
+	if len(b) != 0 {
+		if value, _, err = bytesToMap(b); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// WriteEntry writes a raw (serialized) value as an entry of type entryType.
+func (d *DbusBackend) WriteEntry(
+	handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string,
+) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMWriteEntry, 0, handle, folderName, entryName, value, int32(entryType), appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// WritePassword writes a Password entry.
+func (d *DbusBackend) WritePassword(handle int32, folderName, entryName, value, appID string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMWritePassword, 0, handle, folderName, entryName, value, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// WriteMap writes a Map entry.
+func (d *DbusBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+	var b []byte
+
+	if b, err = mapToBytes(value); err != nil {
+		return
+	}
+
+	if call = d.Dbus.Call(
+		DbusWMWriteMap, 0, handle, folderName, entryName, b, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// RemoveEntry removes an entry from a folder.
+func (d *DbusBackend) RemoveEntry(handle int32, folderName, entryName, appID string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMRemoveEntry, 0, handle, folderName, entryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// RenameEntry renames an entry in a folder.
+func (d *DbusBackend) RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	var call *dbus.Call
+	var rslt int32
+
+	if call = d.Dbus.Call(
+		DbusWMRenameEntry, 0, handle, folderName, entryName, newEntryName, appID,
+	); call.Err != nil {
+		err = call.Err
+		return
+	}
+	if err = call.Store(&rslt); err != nil {
+		return
+	}
+
+	err = resultCheck(rslt)
+
+	return
+}
+
+// Release closes the Dbus connection.
+func (d *DbusBackend) Release() (err error) {
+
+	if err = d.Conn.Close(); err != nil {
+		return
+	}
+
+	return
+}
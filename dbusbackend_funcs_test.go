@@ -0,0 +1,21 @@
+package gokwallet
+
+import (
+	"testing"
+)
+
+// TestDbusBackend runs the Backend conformance suite against a DbusBackend (if kwalletd is available).
+func TestDbusBackend(t *testing.T) {
+
+	var b *DbusBackend
+	var err error
+
+	if b, err = NewDbusBackend(); err != nil {
+		t.Skipf("Dbus session bus not available: %v", err)
+	}
+	if _, err = b.IsEnabled(); err != nil {
+		t.Skipf("kwalletd not available: %v", err)
+	}
+
+	testBackendConformance(t, b)
+}
@@ -0,0 +1,505 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+	DiffSnapshots returns what changed going from Snapshot from to Snapshot to.
+	If opts is nil, values are redacted.
+	Either Snapshot may be nil (which is treated as empty).
+	Snapshots decoded from JSON saved without their values are compared by digest (see EntrySnapshot.MarshalJSON),
+	so changed Maps are reported without their keys; ErrSnapshotRedacted is returned if a digest is missing.
+*/
+func DiffSnapshots(from, to *Snapshot, opts *DiffOpts) (d *Diff, err error) {
+
+	var names []string
+	var fromWallet *WalletSnapshot
+	var toWallet *WalletSnapshot
+	var inFrom bool
+	var inTo bool
+	var oldWallets map[string]*WalletSnapshot = make(map[string]*WalletSnapshot)
+	var newWallets map[string]*WalletSnapshot = make(map[string]*WalletSnapshot)
+
+	if err = from.digestCheck(); err != nil {
+		return
+	}
+	if err = to.digestCheck(); err != nil {
+		return
+	}
+
+	d = &Diff{
+		Changes: make([]*DiffChange, 0),
+	}
+
+	if from != nil {
+		for _, w := range from.Wallets {
+			oldWallets[w.Name] = w
+		}
+	}
+	if to != nil {
+		for _, w := range to.Wallets {
+			newWallets[w.Name] = w
+		}
+	}
+
+	names = walletSnapshotKeys(oldWallets, newWallets)
+
+	for _, wn := range names {
+		fromWallet, inFrom = oldWallets[wn]
+		toWallet, inTo = newWallets[wn]
+		switch {
+		case inFrom && !inTo:
+			d.Changes = append(d.Changes, &DiffChange{Op: DiffRemoved, Wallet: wn})
+		case !inFrom && inTo:
+			d.Changes = append(d.Changes, &DiffChange{Op: DiffAdded, Wallet: wn})
+		}
+		d.Changes = append(d.Changes, diffWallets(wn, fromWallet, toWallet, opts)...)
+	}
+
+	return
+}
+
+/*
+	DiffWalletSnapshots returns what changed going from WalletSnapshot from to WalletSnapshot to.
+	If opts is nil, values are redacted.
+	Either WalletSnapshot may be nil (which is treated as empty). See DiffSnapshots for redacted WalletSnapshots.
+*/
+func DiffWalletSnapshots(from, to *WalletSnapshot, opts *DiffOpts) (d *Diff, err error) {
+
+	var walletName string
+
+	if err = from.digestCheck(); err != nil {
+		return
+	}
+	if err = to.digestCheck(); err != nil {
+		return
+	}
+
+	if to != nil {
+		walletName = to.Name
+	} else if from != nil {
+		walletName = from.Name
+	}
+
+	d = &Diff{
+		Changes: diffWallets(walletName, from, to, opts),
+	}
+
+	return
+}
+
+/*
+	DiffFolderSnapshots returns what changed going from FolderSnapshot from to FolderSnapshot to.
+	If opts is nil, values are redacted.
+	Either FolderSnapshot may be nil (which is treated as empty). See DiffSnapshots for redacted FolderSnapshots.
+	DiffChange.Wallet is left empty.
+*/
+func DiffFolderSnapshots(from, to *FolderSnapshot, opts *DiffOpts) (d *Diff, err error) {
+
+	var folderName string
+
+	if err = from.digestCheck(); err != nil {
+		return
+	}
+	if err = to.digestCheck(); err != nil {
+		return
+	}
+
+	if to != nil {
+		folderName = to.Name
+	} else if from != nil {
+		folderName = from.Name
+	}
+
+	d = &Diff{
+		Changes: diffFolders("", folderName, from, to, opts),
+	}
+
+	return
+}
+
+// Diff returns the differences between this Wallet ("from") and another Wallet ("to"). See DiffWalletSnapshots.
+func (w *Wallet) Diff(other *Wallet, opts *DiffOpts) (d *Diff, err error) {
+
+	var from *WalletSnapshot
+	var to *WalletSnapshot
+
+	if from, err = w.Snapshot(); err != nil {
+		return
+	}
+	if to, err = other.Snapshot(); err != nil {
+		return
+	}
+
+	if d, err = DiffWalletSnapshots(from, to, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+// Diff returns the differences between this Folder ("from") and another Folder ("to"). See DiffFolderSnapshots.
+func (f *Folder) Diff(other *Folder, opts *DiffOpts) (d *Diff, err error) {
+
+	var from *FolderSnapshot
+	var to *FolderSnapshot
+
+	if from, err = f.Snapshot(); err != nil {
+		return
+	}
+	if to, err = other.Snapshot(); err != nil {
+		return
+	}
+
+	if d, err = DiffFolderSnapshots(from, to, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+// IsEmpty returns true if a Diff has no changes.
+func (d *Diff) IsEmpty() (isEmpty bool) {
+
+	isEmpty = d == nil || len(d.Changes) == 0
+
+	return
+}
+
+// JSON renders a Diff as (indented) JSON.
+func (d *Diff) JSON() (b []byte, err error) {
+
+	if b, err = json.MarshalIndent(d, "", "  "); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Text renders a Diff as human-readable text, one change per line.
+	Lines are prefixed with "+" (added), "-" (removed), "~" (modified), or "!" (type changed).
+*/
+func (d *Diff) Text() (s string) {
+
+	var buf *strings.Builder = new(strings.Builder)
+
+	if d.IsEmpty() {
+		return
+	}
+
+	for _, c := range d.Changes {
+		fmt.Fprintf(buf, "%v %v", c.Op.symbol(), c.path())
+		switch {
+		case c.Op == DiffTypeChanged:
+			fmt.Fprintf(buf, " (%v -> %v)", c.OldType, c.NewType)
+		case c.Op == DiffAdded && c.NewType != nil:
+			fmt.Fprintf(buf, " (%v)", c.NewType)
+		case c.Op != DiffAdded && c.OldType != nil:
+			fmt.Fprintf(buf, " (%v)", c.OldType)
+		}
+		if c.OldValue != nil || c.NewValue != nil {
+			fmt.Fprintf(buf, ": %v -> %v", diffTextValue(c.OldValue), diffTextValue(c.NewValue))
+		}
+		buf.WriteString("\n")
+		for _, k := range c.MapKeys {
+			fmt.Fprintf(buf, "\t%v key %#v", k.Op.symbol(), k.Key)
+			if k.OldValue != nil || k.NewValue != nil {
+				fmt.Fprintf(buf, ": %v -> %v", diffTextValue(k.OldValue), diffTextValue(k.NewValue))
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	s = buf.String()
+
+	return
+}
+
+// path returns a "wallet/folder/entry"-style path for a DiffChange.
+func (c *DiffChange) path() (p string) {
+
+	var parts []string = make([]string, 0, 3)
+
+	if c.Wallet != "" {
+		parts = append(parts, fmt.Sprintf("%#v", c.Wallet))
+	}
+	if c.Folder != "" {
+		parts = append(parts, fmt.Sprintf("%#v", c.Folder))
+	}
+	if c.Entry != "" {
+		parts = append(parts, fmt.Sprintf("%#v", c.Entry))
+	}
+
+	p = strings.Join(parts, "/")
+
+	return
+}
+
+// symbol returns the single-character prefix used for a DiffOp by Diff.Text.
+func (o DiffOp) symbol() (s string) {
+
+	switch o {
+	case DiffAdded:
+		s = "+"
+	case DiffRemoved:
+		s = "-"
+	case DiffModified:
+		s = "~"
+	case DiffTypeChanged:
+		s = "!"
+	default:
+		s = "?"
+	}
+
+	return
+}
+
+// diffEntries compares two EntrySnapshot objects with the same name. change is nil if they are identical.
+func diffEntries(walletName, folderName string, from, to *EntrySnapshot, opts *DiffOpts) (change *DiffChange) {
+
+	var oldMap map[string]string
+	var newMap map[string]string
+	var oldErr error
+	var newErr error
+
+	if from == nil && to == nil {
+		return
+	}
+
+	change = &DiffChange{
+		Wallet: walletName,
+		Folder: folderName,
+	}
+
+	switch {
+	case from == nil:
+		change.Op = DiffAdded
+		change.Entry = to.Name
+		change.NewType = entryTypePtr(to.Type)
+		change.NewValue = diffValue(to, opts)
+		return
+	case to == nil:
+		change.Op = DiffRemoved
+		change.Entry = from.Name
+		change.OldType = entryTypePtr(from.Type)
+		change.OldValue = diffValue(from, opts)
+		return
+	}
+
+	change.Entry = to.Name
+	change.OldType = entryTypePtr(from.Type)
+	change.NewType = entryTypePtr(to.Type)
+
+	if from.Type != to.Type {
+		change.Op = DiffTypeChanged
+		change.OldValue = diffValue(from, opts)
+		change.NewValue = diffValue(to, opts)
+		return
+	}
+
+	if bytes.Equal(from.sum(), to.sum()) {
+		change = nil
+		return
+	}
+
+	change.Op = DiffModified
+
+	if from.Type == KwalletdEnumTypeMap {
+		oldMap, oldErr = from.Map()
+		newMap, newErr = to.Map()
+		if oldErr == nil && newErr == nil {
+			// The serialized form isn't canonical (ordering), so the values may be the same.
+			if mapsEqual(oldMap, newMap) {
+				change = nil
+				return
+			}
+			change.MapKeys = diffMaps(oldMap, newMap, opts)
+			return
+		}
+	}
+
+	change.OldValue = diffValue(from, opts)
+	change.NewValue = diffValue(to, opts)
+
+	return
+}
+
+// diffFolders compares two FolderSnapshot objects with the same name.
+func diffFolders(walletName, folderName string, from, to *FolderSnapshot, opts *DiffOpts) (changes []*DiffChange) {
+
+	var names []string
+	var change *DiffChange
+	var oldEntries map[string]*EntrySnapshot = make(map[string]*EntrySnapshot)
+	var newEntries map[string]*EntrySnapshot = make(map[string]*EntrySnapshot)
+
+	changes = make([]*DiffChange, 0)
+
+	if from != nil {
+		for _, e := range from.Entries {
+			oldEntries[e.Name] = e
+		}
+	}
+	if to != nil {
+		for _, e := range to.Entries {
+			newEntries[e.Name] = e
+		}
+	}
+
+	names = entrySnapshotKeys(oldEntries, newEntries)
+
+	for _, en := range names {
+		if change = diffEntries(walletName, folderName, oldEntries[en], newEntries[en], opts); change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	return
+}
+
+// diffMaps compares two Map values.
+func diffMaps(from, to map[string]string, opts *DiffOpts) (keys []*DiffMapKey) {
+
+	var names []string
+	var oldV string
+	var newV string
+	var inOld bool
+	var inNew bool
+	var show bool = opts != nil && opts.ShowValues
+
+	keys = make([]*DiffMapKey, 0)
+
+	names = stringMapKeys(from, to)
+
+	for _, k := range names {
+		oldV, inOld = from[k]
+		newV, inNew = to[k]
+		if !show {
+			oldV = RedactedValue
+			newV = RedactedValue
+		}
+		switch {
+		case inOld && !inNew:
+			keys = append(keys, &DiffMapKey{Op: DiffRemoved, Key: k, OldValue: stringPtr(oldV)})
+		case !inOld && inNew:
+			keys = append(keys, &DiffMapKey{Op: DiffAdded, Key: k, NewValue: stringPtr(newV)})
+		case from[k] != to[k]:
+			keys = append(keys, &DiffMapKey{Op: DiffModified, Key: k, OldValue: stringPtr(oldV), NewValue: stringPtr(newV)})
+		}
+	}
+
+	return
+}
+
+// diffWallets compares two WalletSnapshot objects with the same name.
+func diffWallets(walletName string, from, to *WalletSnapshot, opts *DiffOpts) (changes []*DiffChange) {
+
+	var names []string
+	var fromFolder *FolderSnapshot
+	var toFolder *FolderSnapshot
+	var inFrom bool
+	var inTo bool
+	var oldFolders map[string]*FolderSnapshot = make(map[string]*FolderSnapshot)
+	var newFolders map[string]*FolderSnapshot = make(map[string]*FolderSnapshot)
+
+	changes = make([]*DiffChange, 0)
+
+	if from != nil {
+		for _, f := range from.Folders {
+			oldFolders[f.Name] = f
+		}
+	}
+	if to != nil {
+		for _, f := range to.Folders {
+			newFolders[f.Name] = f
+		}
+	}
+
+	names = folderSnapshotKeys(oldFolders, newFolders)
+
+	for _, fn := range names {
+		fromFolder, inFrom = oldFolders[fn]
+		toFolder, inTo = newFolders[fn]
+		switch {
+		case inFrom && !inTo:
+			changes = append(changes, &DiffChange{Op: DiffRemoved, Wallet: walletName, Folder: fn})
+		case !inFrom && inTo:
+			changes = append(changes, &DiffChange{Op: DiffAdded, Wallet: walletName, Folder: fn})
+		}
+		changes = append(changes, diffFolders(walletName, fn, fromFolder, toFolder, opts)...)
+	}
+
+	return
+}
+
+/*
+	diffTextValue formats a DiffChange/DiffMapKey value for Diff.Text.
+	Absent values (e.g. the "old" value of an added entry) are shown as "(none)", and empty ones as "".
+*/
+func diffTextValue(v *string) (s string) {
+
+	if v == nil {
+		s = "(none)"
+		return
+	}
+
+	if *v == RedactedValue {
+		s = *v
+		return
+	}
+
+	s = fmt.Sprintf("%#v", *v)
+
+	return
+}
+
+/*
+	diffValue returns the value of an EntrySnapshot to use in a DiffChange.
+	It is RedactedValue unless opts.ShowValues is true and the EntrySnapshot has its value, in which case
+	Password values are returned as-is, Map values as JSON, and Blob/UnknownItem values as base64.
+*/
+func diffValue(e *EntrySnapshot, opts *DiffOpts) (v *string) {
+
+	var err error
+	var s string
+	var m map[string]string
+	var b []byte
+
+	if opts == nil || !opts.ShowValues || e.redacted {
+		v = stringPtr(RedactedValue)
+		return
+	}
+
+	switch e.Type {
+	case KwalletdEnumTypePassword:
+		if s, err = e.Password(); err == nil {
+			v = &s
+			return
+		}
+	case KwalletdEnumTypeMap:
+		if m, err = e.Map(); err == nil {
+			if b, err = json.Marshal(m); err == nil {
+				v = stringPtr(string(b))
+				return
+			}
+		}
+	}
+
+	v = stringPtr(base64.StdEncoding.EncodeToString(e.raw))
+
+	return
+}
+
+// entryTypePtr returns a pointer to a copy of t.
+func entryTypePtr(t kwalletdEnumType) (p *kwalletdEnumType) {
+
+	p = new(kwalletdEnumType)
+	*p = t
+
+	return
+}
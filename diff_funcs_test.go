@@ -0,0 +1,136 @@
+package gokwallet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestDiff tests diffing two Snapshot objects.
+func TestDiff(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var from *Snapshot
+	var to *Snapshot
+	var d *Diff
+	var b []byte
+	var txt string
+	var ops map[string]DiffOp = make(map[string]DiffOp)
+	var mapChange *DiffChange
+	var newMap map[string]string = make(map[string]string)
+	var changedKey string
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	if from, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+
+	if d, err = DiffSnapshots(from, from, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("expected an empty Diff for identical Snapshots, got:\n%v", d.Text())
+	}
+
+	for k, v := range testMap {
+		newMap[k] = v
+		changedKey = k
+	}
+	newMap[changedKey] = testPasswordReplace
+
+	if _, err = e.f.WritePassword(passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, err = e.f.WriteMap(mapTest.String(), newMap); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if err = e.f.RemoveEntry(blobTest.String()); err != nil {
+		t.Fatalf("failed to RemoveEntry: %v", err)
+	}
+	if err = e.f.RemoveEntry(unknownItemTest.String()); err != nil {
+		t.Fatalf("failed to RemoveEntry: %v", err)
+	}
+	if _, err = e.f.WritePassword(unknownItemTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, err = e.f.WritePassword(passwordTestRename.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+
+	if to, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+
+	if d, err = DiffSnapshots(from, to, nil); err != nil {
+		t.Fatalf("failed to DiffSnapshots: %v", err)
+	}
+
+	for _, c := range d.Changes {
+		ops[c.Entry] = c.Op
+		if c.Entry == mapTest.String() {
+			mapChange = c
+		}
+	}
+
+	for entry, op := range map[string]DiffOp{
+		passwordTest.String():       DiffModified,
+		mapTest.String():            DiffModified,
+		blobTest.String():           DiffRemoved,
+		unknownItemTest.String():    DiffTypeChanged,
+		passwordTestRename.String(): DiffAdded,
+	} {
+		if ops[entry] != op {
+			t.Errorf("expected op '%v' for '%v', got '%v'", op, entry, ops[entry])
+		}
+	}
+
+	if mapChange == nil || len(mapChange.MapKeys) != 1 || mapChange.MapKeys[0].Key != changedKey {
+		t.Errorf("unexpected Map key changes: %#v", mapChange)
+	}
+
+	txt = d.Text()
+	if strings.Contains(txt, testPasswordReplace) || strings.Contains(txt, testPassword) {
+		t.Errorf("redacted Diff text contains a secret value:\n%v", txt)
+	}
+	if b, err = d.JSON(); err != nil {
+		t.Errorf("failed to render Diff as JSON: %v", err)
+	} else if strings.Contains(string(b), testPasswordReplace) {
+		t.Errorf("redacted Diff JSON contains a secret value:\n%v", string(b))
+	}
+
+	if d, err = DiffSnapshots(from, to, &DiffOpts{ShowValues: true}); err != nil {
+		t.Fatalf("failed to DiffSnapshots: %v", err)
+	}
+	if txt = d.Text(); !strings.Contains(txt, testPasswordReplace) {
+		t.Errorf("unredacted Diff text does not contain the new value:\n%v", txt)
+	}
+	if b, err = d.JSON(); err != nil {
+		t.Errorf("failed to render Diff as JSON: %v", err)
+	} else if err = json.Unmarshal(b, new(Diff)); err != nil {
+		t.Errorf("failed to parse Diff JSON: %v", err)
+	}
+
+	t.Logf("Diff:\n%v", txt)
+
+	// An empty value is shown differently from an absent one.
+	d = &Diff{Changes: []*DiffChange{{
+		Op:      DiffModified,
+		Wallet:  "w",
+		Folder:  "f",
+		Entry:   "m",
+		MapKeys: diffMaps(map[string]string{"a": ""}, map[string]string{"a": "x", "b": ""}, &DiffOpts{ShowValues: true}),
+	}}}
+	txt = d.Text()
+	if !strings.Contains(txt, `key "a": "" -> "x"`) || !strings.Contains(txt, `key "b": (none) -> ""`) {
+		t.Errorf("empty and absent values not told apart in Diff text:\n%v", txt)
+	}
+	if b, err = d.JSON(); err != nil {
+		t.Errorf("failed to render Diff as JSON: %v", err)
+	} else if !strings.Contains(string(b), `"new_value": ""`) || strings.Count(string(b), `"old_value"`) != 1 {
+		t.Errorf("empty and absent values not told apart in Diff JSON:\n%v", string(b))
+	}
+}
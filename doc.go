@@ -70,6 +70,20 @@ Thus, the hierarchy (as exposed by this library) looks like this:
 This is an approximation, but should show a relatively accurate representation of the model.
 Note that most systems are likely to only have a single wallet, "kdewallet".
 
+Backends
+
+Everything a WalletManager does goes through a Backend, which mirrors the kwalletd Dbus API.
+NewWalletManager uses a DbusBackend (i.e. kwalletd itself), but the same Wallet, Folder, and WalletItem
+methods work against other Backends as well:
+
+- DbusBackend talks to kwalletd via Dbus (the default).
+
+- FileBackend keeps each Wallet as an unencrypted, kwalletmanager-compatible XML file in a directory (see NewWalletManagerFiles).
+
+- MemoryBackend keeps Wallets in memory only; it's handy for tests and offline processing.
+
+Use NewWalletManagerBackend to use a specific Backend.
+
 Usage
 
 Full documentation can be found via inline documentation.
@@ -77,6 +91,11 @@ Additionally, use either https://pkg.go.dev/r00t2.io/gokwallet or https://pkg.go
 
 You most likely do *not* want to call any New<object> function directly;
 NewWalletManager with its RecurseOpts parameter (`recursion`) should get you everything you want/need.
+Setting RecurseOpts.Lazy creates the WalletItems without reading their secrets;
+each is then fetched on demand by its GetValue method (e.g. Password.GetValue).
+
+WalletItem values are redacted (see RedactedValue) when printed with fmt or encoded to JSON,
+so logging a WalletManager, Wallet, Folder, or WalletItem is safe; use WalletManager.SetShowValues to include them.
 
 Here's a quick demonstration:
 
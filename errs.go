@@ -28,6 +28,72 @@ var (
 	ErrNoDisconnect error = errors.New("failed to disconnect wallet from application")
 	// ErrInvalidMap will get triggered if a populated map[string]string (even an empty one) is expected but a nil is received.
 	ErrInvalidMap error = errors.New("invalid map; cannot be nil")
+	// ErrEntryExists occurs if copying or moving a WalletItem to a name that already exists (see ConflictFail).
+	ErrEntryExists error = errors.New("the destination WalletItem already exists")
+	// ErrCopySelf occurs if copying a Folder onto itself (see Folder.CopyTo).
+	ErrCopySelf error = errors.New("cannot copy a Folder onto itself")
+	// ErrFolderExists occurs if renaming a Folder to the name of a Folder that already exists.
+	ErrFolderExists error = errors.New("the destination Folder already exists")
+	// ErrVerifyFailed occurs if a copied WalletItem does not match its source when read back.
+	ErrVerifyFailed error = errors.New("a copied WalletItem does not match its source")
+	// ErrConflict is the sentinel for a ConflictError (use errors.Is).
+	ErrConflict error = errors.New("the WalletItem was changed by another writer")
+	// ErrMetadataDisabled occurs if EntryMetadata is requested but WalletManager.EnableMetadata has not been called.
+	ErrMetadataDisabled error = errors.New("entry metadata is not enabled for this WalletManager")
+	/*
+		ErrBookkeeping is wrapped around an error updating gokwallet's own bookkeeping (EntryMetadata, expiry, or history)
+		after the change to the WalletItem itself has already succeeded (use errors.Is). The WalletItem is not rolled back.
+	*/
+	ErrBookkeeping error = errors.New("the WalletItem was changed, but its bookkeeping could not be updated")
+	// ErrNoVersion occurs if a HistoryVersion does not exist.
+	ErrNoVersion error = errors.New("the specified history version does not exist")
+	// ErrExpired is the sentinel for an ExpiredError (use errors.Is).
+	ErrExpired error = errors.New("the WalletItem has expired")
+	// ErrExpiryDisabled occurs if an expiry is set or requested but WalletManager.EnableExpiry has not been called.
+	ErrExpiryDisabled error = errors.New("entry expiry is not enabled for this WalletManager")
+	// ErrSweeperRunning occurs if starting a Sweeper that is already running.
+	ErrSweeperRunning error = errors.New("the Sweeper is already running")
+	// ErrCacheTTL occurs if enabling the read cache with a TTL that is not positive.
+	ErrCacheTTL error = errors.New("the cache TTL must be greater than zero")
+	// ErrCacheDisabled occurs if using the read cache of a WalletManager that does not have it enabled.
+	ErrCacheDisabled error = errors.New("the read cache is not enabled")
+	// ErrNoMlock occurs if locked memory is requested (see WalletManager.EnableSecureMode) on a platform without mlock support.
+	ErrNoMlock error = errors.New("locking memory is not supported on this platform")
+	// ErrLockedMemory occurs if memory being freed was not allocated from locked memory (see SecretBytes.Wipe).
+	ErrLockedMemory error = errors.New("memory was not allocated as locked memory")
+	// ErrNoSignals occurs if Dbus signals are requested from a Backend that is not a DbusBackend.
+	ErrNoSignals error = errors.New("the Backend does not provide kwalletd signals")
+	// ErrInvalidPath occurs if a kwallet:// URI or item path (see ItemPath) cannot be parsed.
+	ErrInvalidPath error = errors.New("invalid kwallet:// URI or item path")
+	// ErrNoWallet occurs if a Wallet that does not exist is referenced (e.g. by WalletManager.Resolve).
+	ErrNoWallet error = errors.New("the specified Wallet does not exist")
+	// ErrNoMapKey occurs if an ItemPath.Key does not exist in its Map.
+	ErrNoMapKey error = errors.New("the specified Map key does not exist")
+	// ErrPathValue occurs if WalletManager.Put is given a value of an unsupported type.
+	ErrPathValue error = errors.New("unsupported value type for a WalletItem")
+	// ErrSearchQuery occurs if a SearchQuery is invalid (e.g. a pattern does not compile).
+	ErrSearchQuery error = errors.New("invalid SearchQuery")
+	// ErrRecurseFilter occurs if a RecurseOpts name filter (e.g. RecurseOpts.IncludeFolders) is not a valid glob.
+	ErrRecurseFilter error = errors.New("invalid RecurseOpts filter")
+	// ErrWalkKind occurs if a WalletItem is requested from a WalkEntry that is not a WalkKindItem.
+	ErrWalkKind error = errors.New("the WalkEntry is not a WalletItem")
+	// ErrTxDone occurs if using a Tx that has already been committed or rolled back.
+	ErrTxDone error = errors.New("the transaction has already been committed or rolled back")
+	// ErrConflictPolicy occurs if a ConflictPolicy is not recognized.
+	ErrConflictPolicy error = errors.New("unknown ConflictPolicy")
+	// ErrUnknownEntryType occurs if a WalletItem type name is not recognized.
+	ErrUnknownEntryType error = errors.New("unknown WalletItem type")
+)
+
+/*
+	Walk sentinels. These are returned by a WalkFunc to prune a walk (see WalletManager.Walk);
+	they are never returned by the walk itself.
+*/
+var (
+	// SkipFolder skips the rest of the current Folder (or, if returned for a WalkKindFolder WalkEntry, all of it).
+	SkipFolder error = errors.New("skip this folder")
+	// SkipWallet skips the rest of the current Wallet (or, if returned for a WalkKindWallet WalkEntry, all of it).
+	SkipWallet error = errors.New("skip this wallet")
 )
 
 // Dbus Operation failures.
@@ -55,3 +121,101 @@ var (
 	// ErrInitUnknownItem occurs if an UnknownItem is not initialized properly.
 	ErrInitUnknownItem error = errors.New("an UnknownItem was not properly initialized")
 )
+
+// Backend errors.
+var (
+	// ErrBackendHandle occurs if a Backend is given a wallet handle that is invalid or has been closed.
+	ErrBackendHandle error = errors.New("invalid or closed wallet handle")
+	// ErrBackendNoFolder occurs if a Backend is asked for a Folder that does not exist.
+	ErrBackendNoFolder error = errors.New("the specified Folder does not exist")
+	// ErrBackendNoEntry occurs if a Backend is asked for a WalletItem that does not exist.
+	ErrBackendNoEntry error = errors.New("the specified WalletItem does not exist")
+	// ErrBackendEntryType occurs if a WalletItem is read as a type it is not (e.g. a Blob via Backend.ReadMap).
+	ErrBackendEntryType error = errors.New("the WalletItem is not of the requested type")
+	// ErrBackendUnsupported occurs if a Backend does not support the requested operation.
+	ErrBackendUnsupported error = errors.New("operation not supported by this Backend")
+	// ErrBackendBadWallet occurs if a wallet file could not be parsed.
+	ErrBackendBadWallet error = errors.New("invalid or unsupported wallet file")
+	// ErrBackendBadQString occurs if a serialized QString value could not be parsed.
+	ErrBackendBadQString error = errors.New("invalid serialized QString")
+)
+
+// Snapshot errors.
+var (
+	/*
+		ErrSnapshotRedacted occurs if the values of a Snapshot decoded from JSON are needed, but it was saved without them
+		(see EntrySnapshot.MarshalJSON), or (for a Diff) without their digests either.
+	*/
+	ErrSnapshotRedacted error = errors.New("the Snapshot was saved without its values")
+)
+
+// Desired-state errors.
+var (
+	// ErrStateSpec occurs if a StateSpec is invalid (e.g. an entry is declared more than once in a Folder).
+	ErrStateSpec error = errors.New("invalid StateSpec")
+	// ErrStateAction occurs if a StateOp has an unknown StateAction.
+	ErrStateAction error = errors.New("unknown StateAction")
+	/*
+		ErrStateValue occurs if applying a StateWriteEntry StateOp that has no value to write,
+		e.g. one from a StatePlan decoded from JSON (which never includes values); plan again from the StateSpec instead.
+	*/
+	ErrStateValue error = errors.New("StateOp has no value to write")
+)
+
+// Export/import errors.
+var (
+	// ErrExportFormat occurs if a document is not an ExportDocument (i.e. its ExportDocument.Format is not ExportFormat).
+	ErrExportFormat error = errors.New("not a gokwallet export document")
+	// ErrExportVersion occurs if an ExportDocument has a version this library does not support.
+	ErrExportVersion error = errors.New("unsupported export document version")
+	// ErrExportDocument occurs if an ExportDocument is invalid (e.g. an entry has no name or is listed more than once).
+	ErrExportDocument error = errors.New("invalid ExportDocument")
+)
+
+// Backup errors.
+var (
+	// ErrBackupFormat occurs if a backup archive is not one (or is of an unsupported version or KDF/cipher).
+	ErrBackupFormat error = errors.New("not a gokwallet backup archive, or an unsupported one")
+	// ErrBackupDecrypt occurs if a backup archive cannot be decrypted: the passphrase is wrong or the archive was modified.
+	ErrBackupDecrypt error = errors.New("failed to decrypt the backup archive (wrong passphrase, or the archive was modified)")
+	// ErrBackupCorrupt occurs if the contents of a (decrypted) backup archive do not match its BackupManifest.
+	ErrBackupCorrupt error = errors.New("the backup archive does not match its manifest")
+	// ErrBackupPassphrase occurs if an empty passphrase is used for a backup archive.
+	ErrBackupPassphrase error = errors.New("a backup passphrase must not be empty")
+	// ErrBackupNoFolder occurs if RestoreOpts.Folders names a Folder that is not in the backup archive.
+	ErrBackupNoFolder error = errors.New("the specified Folder is not in the backup archive")
+	// ErrBackupDir occurs if a BackupScheduler has no destination directory.
+	ErrBackupDir error = errors.New("a BackupScheduler requires a destination directory")
+	// ErrBackupInterval occurs if starting a BackupScheduler without a positive BackupScheduler.Interval.
+	ErrBackupInterval error = errors.New("the BackupScheduler interval must be greater than zero")
+	// ErrSchedulerRunning occurs if starting a BackupScheduler that is already running.
+	ErrSchedulerRunning error = errors.New("the BackupScheduler is already running")
+	// ErrBackupShare occurs if a backup archive key share cannot be parsed (e.g. it was mistyped).
+	ErrBackupShare error = errors.New("invalid backup key share")
+	// ErrBackupShareCount occurs if a backup archive key is to be split with an invalid threshold or number of shares.
+	ErrBackupShareCount error = errors.New("the share threshold must be at least 2 and at most the number of shares (at most BackupShareMax)")
+	// ErrBackupShares occurs if the given key shares cannot recover the key of a backup archive (too few, or mismatched).
+	ErrBackupShares error = errors.New("the key shares cannot recover the backup archive key")
+)
+
+// KeePass (KDBX) errors.
+var (
+	// ErrKDBXFormat occurs if a file is not a KeePass database (or is malformed).
+	ErrKDBXFormat error = errors.New("not a KeePass (KDBX) database, or a malformed one")
+	// ErrKDBXUnsupported occurs if a KeePass database uses a format version, cipher, KDF, etc. that is not supported.
+	ErrKDBXUnsupported error = errors.New("unsupported KeePass database (only KDBX 4 with a password is supported)")
+	// ErrKDBXPassword occurs if exporting a KeePass database with an empty password.
+	ErrKDBXPassword error = errors.New("a KeePass database password must not be empty")
+	// ErrKDBXCredentials occurs if the password for a KeePass database is wrong.
+	ErrKDBXCredentials error = errors.New("wrong password for the KeePass database (or the header was modified)")
+	// ErrKDBXCorrupt occurs if a KeePass database fails authentication or cannot be decoded after being unlocked.
+	ErrKDBXCorrupt error = errors.New("the KeePass database is corrupt or was modified")
+)
+
+// Bitwarden errors.
+var (
+	// ErrBitwardenFormat occurs if a file is not a Bitwarden JSON export (or is malformed).
+	ErrBitwardenFormat error = errors.New("not a Bitwarden JSON export, or a malformed one")
+	// ErrBitwardenUnsupported occurs if a Bitwarden export is encrypted, or a WalletItem cannot be exported to one.
+	ErrBitwardenUnsupported error = errors.New("unsupported Bitwarden export (only unencrypted JSON exports are supported)")
+)
@@ -0,0 +1,24 @@
+package gokwallet
+
+import (
+	"fmt"
+	"time"
+)
+
+// Error returns a string representation of an ExpiredError (to conform with the error interface).
+func (e *ExpiredError) Error() (errStr string) {
+
+	errStr = fmt.Sprintf(
+		"%v: %#v/%#v/%#v (expired %v)", ErrExpired.Error(), e.Wallet, e.Folder, e.Entry, e.Expired.Format(time.RFC3339),
+	)
+
+	return
+}
+
+// Is returns true if target is ErrExpired, so that errors.Is(err, ErrExpired) works for an ExpiredError.
+func (e *ExpiredError) Is(target error) (isErr bool) {
+
+	isErr = target == ErrExpired
+
+	return
+}
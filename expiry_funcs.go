@@ -0,0 +1,271 @@
+package gokwallet
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+	EnableExpiry turns on WalletItem expiry for a WalletManager (see Folder.SetEntryExpiry and Folder.TTL).
+	Expiry times are kept in the reserved Folder ExpiryFolder of each Wallet and follow their WalletItem
+	through renames and removals made through this WalletManager.
+
+	Expired WalletItems are not removed on their own; reading one returns an *ExpiredError until a Sweeper removes it.
+*/
+func (wm *WalletManager) EnableExpiry() (err error) {
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var t *trackingBackend = newTrackingBackend(cur)
+
+		t.expiry = true
+		next = t
+
+		return
+	})
+
+	return
+}
+
+// ExpiryEnabled returns true if WalletManager.EnableExpiry has been called.
+func (wm *WalletManager) ExpiryEnabled() (enabled bool) {
+
+	var t *trackingBackend
+	var ok bool
+
+	if t, ok = wm.Backend().(*trackingBackend); ok {
+		enabled = t.expiry
+	}
+
+	return
+}
+
+/*
+	SetEntryExpiry sets when WalletItem entryName in a Folder expires. A zero expires removes its expiry.
+	The expiry is kept until it is changed or the WalletItem is removed. Rewriting the WalletItem via a Folder's Write* methods
+	resets it to Folder.TTL from then if the Folder has a TTL, and otherwise only clears it if it has already passed.
+*/
+func (f *Folder) SetEntryExpiry(entryName string, expires time.Time) (err error) {
+
+	if err = f.expiryCheckEnabled(entryName); err != nil {
+		return
+	}
+
+	if expires.IsZero() {
+		err = removeExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID)
+		return
+	}
+
+	if err = writeExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, expires, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// EntryExpiry returns when WalletItem entryName in a Folder expires. expires is zero if it does not expire.
+func (f *Folder) EntryExpiry(entryName string) (expires time.Time, err error) {
+
+	if err = f.expiryCheckEnabled(entryName); err != nil {
+		return
+	}
+
+	if expires, err = readExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	refreshExpiry is called after WalletItem entryName has been written via one of a Folder's Write* methods.
+	It sets its expiry to Folder.TTL from now if the Folder has a TTL, or else removes its expiry if it has already passed
+	(a freshly-written value shouldn't read as expired).
+*/
+func (f *Folder) refreshExpiry(entryName string) (err error) {
+
+	var expires time.Time
+
+	if !f.wallet.wm.ExpiryEnabled() {
+		return
+	}
+
+	if f.TTL > 0 {
+		err = writeExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, time.Now().Add(f.TTL), f.wallet.wm.AppID)
+		return
+	}
+
+	if expires, err = readExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	} else if expires.IsZero() || expires.After(time.Now()) {
+		return
+	}
+
+	if err = removeExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// expiryCheck returns an *ExpiredError if WalletItem entryName has expired (and expiry is enabled).
+func (f *Folder) expiryCheck(entryName string) (err error) {
+
+	var expires time.Time
+
+	if !f.wallet.wm.ExpiryEnabled() {
+		return
+	}
+
+	if expires, err = readExpiry(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	if !expires.IsZero() && !expires.After(time.Now()) {
+		err = &ExpiredError{
+			Wallet:  f.wallet.Name,
+			Folder:  f.Name,
+			Entry:   entryName,
+			Expired: expires,
+		}
+		return
+	}
+
+	return
+}
+
+// expiryCheckEnabled returns an error if expiry is not enabled or WalletItem entryName does not exist.
+func (f *Folder) expiryCheckEnabled(entryName string) (err error) {
+
+	var exists bool
+
+	if !f.wallet.wm.ExpiryEnabled() {
+		err = ErrExpiryDisabled
+		return
+	}
+
+	if exists, err = f.HasEntry(entryName); err != nil {
+		return
+	} else if !exists {
+		err = fmt.Errorf("%w: %#v/%#v/%#v", ErrBackendNoEntry, f.wallet.Name, f.Name, entryName)
+		return
+	}
+
+	return
+}
+
+// ttlCheck returns ErrExpiryDisabled if a Folder has a TTL but expiry is not enabled, so nothing is written without its expiry.
+func (f *Folder) ttlCheck() (err error) {
+
+	if f.TTL > 0 && !f.wallet.wm.ExpiryEnabled() {
+		err = ErrExpiryDisabled
+		return
+	}
+
+	return
+}
+
+/*
+	transferExpiry carries the expiry of WalletItem srcEntry in Folder src over to WalletItem dstEntry in Folder dst
+	(e.g. when moving a WalletItem). It is a no-op unless both have expiry enabled and srcEntry has an expiry.
+*/
+func transferExpiry(src *Folder, srcEntry string, dst *Folder, dstEntry string) (err error) {
+
+	var expires time.Time
+
+	if !src.wallet.wm.ExpiryEnabled() || !dst.wallet.wm.ExpiryEnabled() {
+		return
+	}
+
+	if expires, err = readExpiry(src.wallet.wm.Backend(), src.wallet.handle, src.Name, srcEntry, src.wallet.wm.AppID); err != nil {
+		return
+	} else if expires.IsZero() {
+		return
+	}
+
+	if err = writeExpiry(dst.wallet.wm.Backend(), dst.wallet.handle, dst.Name, dstEntry, expires, dst.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// readExpiry reads the expiry of entryName in folderName. expires is zero if there is none.
+func readExpiry(backend Backend, handle int32, folderName, entryName, appID string) (expires time.Time, err error) {
+
+	var exists bool
+	var s string
+	var key string = metadataKey(folderName, entryName)
+
+	if exists, err = backend.HasEntry(handle, ExpiryFolder, key, appID); err != nil || !exists {
+		return
+	}
+
+	if s, err = backend.ReadPassword(handle, ExpiryFolder, key, appID); err != nil {
+		return
+	}
+
+	if expires, err = time.Parse(time.RFC3339Nano, s); err != nil {
+		return
+	}
+
+	return
+}
+
+// removeExpiry removes the expiry of entryName in folderName (if any).
+func removeExpiry(backend Backend, handle int32, folderName, entryName, appID string) (err error) {
+
+	var exists bool
+	var key string = metadataKey(folderName, entryName)
+
+	if exists, err = backend.HasEntry(handle, ExpiryFolder, key, appID); err != nil || !exists {
+		return
+	}
+
+	if err = backend.RemoveEntry(handle, ExpiryFolder, key, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// writeExpiry sets the expiry of entryName in folderName.
+func writeExpiry(backend Backend, handle int32, folderName, entryName string, expires time.Time, appID string) (err error) {
+
+	if err = backend.WritePassword(
+		handle, ExpiryFolder, metadataKey(folderName, entryName), expires.UTC().Format(time.RFC3339Nano), appID,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// splitMetadataKey is the inverse of metadataKey. ok is false if key is not a valid key.
+func splitMetadataKey(key string) (folderName, entryName string, ok bool) {
+
+	var err error
+	var parts []string = strings.SplitN(key, "/", 2)
+
+	if len(parts) != 2 {
+		return
+	}
+
+	if folderName, err = url.PathUnescape(parts[0]); err != nil {
+		return
+	}
+	if entryName, err = url.PathUnescape(parts[1]); err != nil {
+		return
+	}
+
+	ok = true
+
+	return
+}
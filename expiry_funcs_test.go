@@ -0,0 +1,128 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestExpiry tests WalletItem expiry.
+func TestExpiry(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var p *Password
+	var expires time.Time
+	var expErr *ExpiredError
+	var exists bool
+	var fs *FolderSnapshot
+	var past time.Time = time.Now().Add(-time.Minute)
+	var renamedFolder string = folderTest.String() + "_exp"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+
+	if p, err = e.f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if err = p.SetExpiry(past); !errors.Is(err, ErrExpiryDisabled) {
+		t.Errorf("expected ErrExpiryDisabled, got %v", err)
+	}
+
+	// A Folder.TTL without expiry enabled fails the write before anything is written.
+	e.f.TTL = time.Hour
+	if _, err = e.f.WritePassword(passwordTest.String(), testPasswordReplace); !errors.Is(err, ErrExpiryDisabled) {
+		t.Errorf("expected ErrExpiryDisabled from WritePassword, got %v", err)
+	}
+	if _, err = e.f.WriteMap(mapTest.String(), testMap); !errors.Is(err, ErrExpiryDisabled) {
+		t.Errorf("expected ErrExpiryDisabled from WriteMap, got %v", err)
+	}
+	if err = e.f.WriteEntry(blobTest.String(), KwalletdEnumTypeStream, testBytes); !errors.Is(err, ErrExpiryDisabled) {
+		t.Errorf("expected ErrExpiryDisabled from WriteEntry, got %v", err)
+	}
+	e.f.TTL = 0
+	if err = p.Update(); err != nil || p.Value != testPassword {
+		t.Errorf("Password written despite ErrExpiryDisabled: %#v (err: %v)", p.Value, err)
+	}
+	if exists, err = e.f.HasEntry(mapTest.String()); err != nil || exists {
+		t.Errorf("Map written despite ErrExpiryDisabled (err: %v)", err)
+	}
+	if exists, err = e.f.HasEntry(blobTest.String()); err != nil || exists {
+		t.Errorf("Blob written despite ErrExpiryDisabled (err: %v)", err)
+	}
+
+	if err = e.wm.EnableExpiry(); err != nil {
+		t.Fatalf("failed to EnableExpiry: %v", err)
+	}
+	if !e.wm.ExpiryEnabled() {
+		t.Fatalf("ExpiryEnabled is false after EnableExpiry")
+	}
+
+	if expires, err = p.Expiry(); err != nil || !expires.IsZero() {
+		t.Errorf("expected no expiry, got %v (err: %v)", expires, err)
+	}
+
+	// Folder.TTL applies to Write*.
+	e.f.TTL = time.Hour
+	if p, err = e.f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword with a TTL: %v", err)
+	}
+	if expires, err = p.Expiry(); err != nil || expires.Before(time.Now().Add(59*time.Minute)) || expires.After(time.Now().Add(time.Hour)) {
+		t.Errorf("unexpected expiry from Folder.TTL: %v (err: %v)", expires, err)
+	}
+	e.f.TTL = 0
+
+	// Expired but not swept.
+	if err = p.SetExpiry(past); err != nil {
+		t.Fatalf("failed to SetExpiry: %v", err)
+	}
+	if err = p.Update(); !errors.As(err, &expErr) || !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected an ExpiredError, got %v", err)
+	}
+	if expErr.Wallet != e.w.Name || expErr.Folder != e.f.Name || expErr.Entry != p.Name || !expErr.Expired.Equal(past) {
+		t.Errorf("unexpected ExpiredError: %#v", expErr)
+	}
+	if exists, err = p.Exists(); err != nil || !exists {
+		t.Errorf("expired Password removed without a Sweeper (err: %v)", err)
+	}
+	// Copies and Snapshots don't read it either.
+	if _, err = e.f.CopyEntry(p.Name, e.f, p.Name+"_copy", ConflictFail); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired copying an expired Password, got %v", err)
+	}
+	if fs, err = e.f.Snapshot(); err != nil || fs.Entry(p.Name) != nil {
+		t.Errorf("expired Password included in a Snapshot (err: %v)", err)
+	}
+
+	// Renames carry the expiry along.
+	if err = p.Rename(passwordTestRename.String()); err != nil {
+		t.Fatalf("failed to Rename: %v", err)
+	}
+	if expires, err = p.Expiry(); err != nil || !expires.Equal(past) {
+		t.Errorf("expiry not carried over by Rename: %v (err: %v)", expires, err)
+	}
+	if err = e.f.Rename(renamedFolder); err != nil {
+		t.Fatalf("failed to Folder.Rename: %v", err)
+	}
+	if expires, err = p.Expiry(); err != nil || !expires.Equal(past) {
+		t.Errorf("expiry not carried over by Folder.Rename: %v (err: %v)", expires, err)
+	}
+
+	// A fresh value isn't born expired.
+	if p, err = e.f.WritePassword(p.Name, testPasswordReplace); err != nil {
+		t.Fatalf("failed to rewrite an expired Password: %v", err)
+	}
+	if expires, err = p.Expiry(); err != nil || !expires.IsZero() {
+		t.Errorf("passed expiry not cleared by a rewrite: %v (err: %v)", expires, err)
+	}
+
+	if err = p.SetExpiry(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to SetExpiry: %v", err)
+	}
+	if err = p.Delete(); err != nil {
+		t.Fatalf("failed to Delete: %v", err)
+	}
+	if exists, err = e.w.wm.backend.HasEntry(e.w.handle, ExpiryFolder, metadataKey(e.f.Name, p.Name), appIdTest); err != nil || exists {
+		t.Errorf("expiry not removed with its WalletItem (err: %v)", err)
+	}
+}
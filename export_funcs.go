@@ -0,0 +1,607 @@
+package gokwallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/*
+	ParseExport parses an ExportDocument from JSON (as written by json.Marshal of an ExportDocument) and validates it.
+	ErrExportFormat is returned if b is not an ExportDocument, and ErrExportVersion if it is of a newer version than ExportVersion.
+*/
+func ParseExport(b []byte) (doc *ExportDocument, err error) {
+
+	var hdr struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+	}
+
+	// The header is checked first so that a newer document isn't rejected for some field it has changed.
+	if err = json.Unmarshal(b, &hdr); err != nil {
+		err = fmt.Errorf("%w: %v", ErrExportFormat, err)
+		return
+	}
+	if err = checkExportHeader(hdr.Format, hdr.Version); err != nil {
+		return
+	}
+
+	doc = new(ExportDocument)
+
+	if err = json.Unmarshal(b, doc); err != nil {
+		err = fmt.Errorf("%w: %v", ErrExportDocument, err)
+		doc = nil
+		return
+	}
+
+	if err = doc.validate(); err != nil {
+		doc = nil
+		return
+	}
+
+	return
+}
+
+/*
+	Export returns an ExportDocument of every Wallet (and all of their Folders and WalletItems) in a WalletManager.
+	Each Wallet is opened if it isn't already. WalletManager.Wallets is not modified.
+	Use json.Marshal to write it, and ParseExport and WalletManager.Import to read it back.
+*/
+func (wm *WalletManager) Export() (doc *ExportDocument, err error) {
+
+	var snap *Snapshot
+	var ew *ExportWallet
+
+	if snap, err = wm.Snapshot(); err != nil {
+		return
+	}
+
+	doc = newExportDocument(wm.AppID)
+
+	for _, ws := range snap.Wallets {
+		if ew, err = exportWallet(ws); err != nil {
+			doc = nil
+			return
+		}
+		doc.Wallets = append(doc.Wallets, ew)
+	}
+
+	return
+}
+
+/*
+	Import writes the WalletItems in an ExportDocument to the Wallets (and Folders) they were exported from,
+	creating (opening) the Wallets and creating the Folders as needed. opts may be nil (ImportOpts.Policy is then ConflictFail).
+	Each WalletItem is written with the Write method for its type (e.g. Folder.WritePassword, Folder.WriteMap, Folder.WriteBlob).
+	An ImportResult is returned for each ExportEntry. Errors for individual WalletItems (e.g. conflicts with ConflictFail)
+	do not stop the import; err is then a MultiError of them.
+*/
+func (wm *WalletManager) Import(doc *ExportDocument, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var walletName string
+	var found []*ImportResult
+	var errs []error = make([]error, 0)
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if opts, err = doc.importCheck(opts); err != nil {
+		return
+	}
+
+	results = make([]*ImportResult, 0)
+
+	for _, ew := range doc.Wallets {
+		walletName = ew.Name
+		if opts.Wallet != "" {
+			walletName = opts.Wallet
+		}
+		found = nil
+		err = wm.withWallet(walletName, func(w *Wallet) (err error) {
+			found, err = w.importFolders(ew.Folders, opts)
+			return
+		})
+		results = append(results, found...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wallet %#v: %w", walletName, err))
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// Export returns an ExportDocument of a Wallet and all of its Folders and WalletItems (see WalletManager.Export).
+func (w *Wallet) Export() (doc *ExportDocument, err error) {
+
+	var ws *WalletSnapshot
+	var ew *ExportWallet
+
+	if ws, err = w.Snapshot(); err != nil {
+		return
+	}
+
+	if ew, err = exportWallet(ws); err != nil {
+		return
+	}
+
+	doc = newExportDocument(w.wm.AppID)
+	doc.Wallets = append(doc.Wallets, ew)
+
+	return
+}
+
+/*
+	Import is like WalletManager.Import, but writes the Folders of every ExportWallet in doc to Wallet w
+	(regardless of ExportWallet.Name).
+*/
+func (w *Wallet) Import(doc *ExportDocument, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var found []*ImportResult
+	var errs []error = make([]error, 0)
+
+	if opts, err = doc.importCheck(opts); err != nil {
+		return
+	}
+
+	results = make([]*ImportResult, 0)
+
+	for _, ew := range doc.Wallets {
+		found, err = w.importFolders(ew.Folders, opts)
+		results = append(results, found...)
+		if err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// importFolders writes folders to Wallet w, creating them as needed.
+func (w *Wallet) importFolders(folders []*ExportFolder, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var f *Folder
+	var exists bool
+	var found []*ImportResult
+	var errs []error = make([]error, 0)
+
+	if err = w.walletCheck(); err != nil {
+		return
+	}
+
+	results = make([]*ImportResult, 0)
+
+	for _, ef := range folders {
+		if exists, err = w.HasFolder(ef.Name); err == nil && !exists {
+			err = w.CreateFolder(ef.Name)
+		}
+		if err == nil {
+			f, err = NewFolder(w, ef.Name, &RecurseOpts{})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("folder %#v: %w", ef.Name, err))
+			err = nil
+			continue
+		}
+		found, err = f.importEntries(ef.Entries, opts)
+		results = append(results, found...)
+		if err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// Export returns an ExportDocument of a Folder and all of its WalletItems (see WalletManager.Export).
+func (f *Folder) Export() (doc *ExportDocument, err error) {
+
+	var fs *FolderSnapshot
+	var ef *ExportFolder
+
+	if fs, err = f.Snapshot(); err != nil {
+		return
+	}
+
+	if ef, err = exportFolder(fs); err != nil {
+		return
+	}
+
+	doc = newExportDocument(f.wallet.wm.AppID)
+	doc.Wallets = append(doc.Wallets, &ExportWallet{
+		Name:    f.wallet.Name,
+		Folders: []*ExportFolder{ef},
+	})
+
+	return
+}
+
+/*
+	Import is like WalletManager.Import, but writes the WalletItems of every ExportFolder in doc to Folder f
+	(regardless of ExportWallet.Name and ExportFolder.Name).
+*/
+func (f *Folder) Import(doc *ExportDocument, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var found []*ImportResult
+	var errs []error = make([]error, 0)
+
+	if opts, err = doc.importCheck(opts); err != nil {
+		return
+	}
+
+	results = make([]*ImportResult, 0)
+
+	for _, ew := range doc.Wallets {
+		for _, ef := range ew.Folders {
+			found, err = f.importEntries(ef.Entries, opts)
+			results = append(results, found...)
+			if err != nil {
+				errs = append(errs, err)
+				err = nil
+			}
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// importEntries writes entries to Folder f. err is a MultiError of the individual failures (also in each ImportResult.Err).
+func (f *Folder) importEntries(entries []*ExportEntry, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var res *ImportResult
+	var errs []error = make([]error, 0)
+
+	results = make([]*ImportResult, 0, len(entries))
+
+	for _, ee := range entries {
+		if res, err = f.importEntry(ee, opts); err != nil {
+			errs = append(errs, fmt.Errorf("%#v/%#v/%#v: %w", f.wallet.Name, f.Name, ee.Name, err))
+			err = nil
+		}
+		results = append(results, res)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// importEntry writes a single ExportEntry to Folder f.
+func (f *Folder) importEntry(ee *ExportEntry, opts *ImportOpts) (res *ImportResult, err error) {
+
+	var exists bool
+	var entryType kwalletdEnumType
+	var cur map[string]string
+
+	res = &ImportResult{
+		Wallet: f.wallet.Name,
+		Folder: f.Name,
+		CopyResult: &CopyResult{
+			Entry:     ee.Name,
+			DestEntry: ee.Name,
+			Type:      ee.Type,
+		},
+	}
+
+	defer func() {
+		res.Err = err
+	}()
+
+	if opts.MergeMaps && ee.Type == KwalletdEnumTypeMap {
+		if exists, err = f.HasEntry(ee.Name); err != nil {
+			return
+		}
+		if exists {
+			if entryType, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, f.Name, ee.Name, f.wallet.wm.AppID); err != nil {
+				return
+			}
+		}
+		if exists && entryType == KwalletdEnumTypeMap {
+			if cur, err = f.wallet.wm.Backend().ReadMap(f.wallet.handle, f.Name, ee.Name, f.wallet.wm.AppID); err != nil {
+				return
+			}
+			for k, v := range ee.Map {
+				cur[k] = v
+			}
+			if _, err = f.WriteMap(ee.Name, cur); err != nil {
+				return
+			}
+			res.Merged = true
+			return
+		}
+	}
+
+	// Only resolves the conflict (if any); the value is written below.
+	if err = f.putEntry(res.CopyResult, nil, opts.Policy, true); err != nil || res.Skipped {
+		return
+	}
+
+	if err = f.writeExportEntry(res.DestEntry, ee); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	writeExportEntry writes the value of ExportEntry ee to Folder f as WalletItem entryName,
+	with the Write method for its type (e.g. Folder.WritePassword).
+*/
+func (f *Folder) writeExportEntry(entryName string, ee *ExportEntry) (err error) {
+
+	var m map[string]string = ee.Map
+
+	switch ee.Type {
+	case KwalletdEnumTypePassword:
+		_, err = f.WritePassword(entryName, ee.Password)
+	case KwalletdEnumTypeMap:
+		if m == nil {
+			m = make(map[string]string)
+		}
+		_, err = f.WriteMap(entryName, m)
+	case KwalletdEnumTypeStream:
+		_, err = f.WriteBlob(entryName, ee.Data)
+	case KwalletdEnumTypeUnknown:
+		_, err = f.WriteUnknown(entryName, ee.Data)
+	default:
+		err = fmt.Errorf("%w: entry %#v: %v", ErrUnknownEntryType, ee.Name, ee.Type)
+	}
+
+	return
+}
+
+/*
+	String returns a representation of an ExportEntry with its value replaced by RedactedValue
+	(an ExportDocument is only meant to be serialized, e.g. with json.Marshal).
+*/
+func (e ExportEntry) String() (str string) {
+
+	str = fmt.Sprintf("ExportEntry{Name: %q, Type: %v, Value: %v}", e.Name, e.Type, RedactedValue)
+
+	return
+}
+
+// Format prints ExportEntry.String for every verb (see formatRedacted).
+func (e ExportEntry) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, e.String())
+
+	return
+}
+
+// raw returns the raw (serialized) value of an ExportEntry, as kwalletd stores it.
+func (e *ExportEntry) raw() (raw []byte, err error) {
+
+	switch e.Type {
+	case KwalletdEnumTypePassword:
+		raw = stringToQString(e.Password)
+	case KwalletdEnumTypeMap:
+		if e.Map == nil {
+			raw, err = mapToBytes(make(map[string]string))
+		} else {
+			raw, err = mapToBytes(e.Map)
+		}
+	case KwalletdEnumTypeStream, KwalletdEnumTypeUnknown:
+		raw = make([]byte, len(e.Data))
+		copy(raw, e.Data)
+	default:
+		err = fmt.Errorf("%w: entry %#v: %v", ErrUnknownEntryType, e.Name, e.Type)
+	}
+
+	return
+}
+
+// importCheck validates an ExportDocument and ImportOpts before importing, returning the ImportOpts to use.
+func (d *ExportDocument) importCheck(opts *ImportOpts) (checked *ImportOpts, err error) {
+
+	if d == nil {
+		err = fmt.Errorf("%w: nil document", ErrExportDocument)
+		return
+	}
+
+	if err = d.validate(); err != nil {
+		return
+	}
+
+	if opts == nil {
+		opts = new(ImportOpts)
+	}
+
+	if err = opts.Policy.validate(); err != nil {
+		return
+	}
+
+	checked = opts
+
+	return
+}
+
+// validate checks an ExportDocument for errors (see ErrExportFormat, ErrExportVersion, and ErrExportDocument).
+func (d *ExportDocument) validate() (err error) {
+
+	var wallets map[string]bool = make(map[string]bool, len(d.Wallets))
+	var folders map[string]bool
+	var entries map[string]bool
+
+	if err = checkExportHeader(d.Format, d.Version); err != nil {
+		return
+	}
+
+	for _, ew := range d.Wallets {
+		switch {
+		case ew == nil || ew.Name == "":
+			err = fmt.Errorf("%w: a wallet has no name", ErrExportDocument)
+		case wallets[ew.Name]:
+			err = fmt.Errorf("%w: wallet %#v is listed more than once", ErrExportDocument, ew.Name)
+		}
+		if err != nil {
+			return
+		}
+		wallets[ew.Name] = true
+		folders = make(map[string]bool, len(ew.Folders))
+		for _, ef := range ew.Folders {
+			switch {
+			case ef == nil || ef.Name == "":
+				err = fmt.Errorf("%w: wallet %#v: a folder has no name", ErrExportDocument, ew.Name)
+			case isReservedFolder(ef.Name):
+				err = fmt.Errorf("%w: wallet %#v: folder %#v is reserved", ErrExportDocument, ew.Name, ef.Name)
+			case folders[ef.Name]:
+				err = fmt.Errorf("%w: wallet %#v: folder %#v is listed more than once", ErrExportDocument, ew.Name, ef.Name)
+			}
+			if err != nil {
+				return
+			}
+			folders[ef.Name] = true
+			entries = make(map[string]bool, len(ef.Entries))
+			for _, ee := range ef.Entries {
+				switch {
+				case ee == nil || ee.Name == "":
+					err = fmt.Errorf("%w: %#v/%#v: an entry has no name", ErrExportDocument, ew.Name, ef.Name)
+				case entries[ee.Name]:
+					err = fmt.Errorf("%w: %#v/%#v: entry %#v is listed more than once", ErrExportDocument, ew.Name, ef.Name, ee.Name)
+				}
+				if err != nil {
+					return
+				}
+				if _, err = ee.raw(); err != nil {
+					err = fmt.Errorf("%w: %#v/%#v: %v", ErrExportDocument, ew.Name, ef.Name, err)
+					return
+				}
+				entries[ee.Name] = true
+			}
+		}
+	}
+
+	return
+}
+
+// checkExportHeader checks the ExportDocument.Format and ExportDocument.Version of a document.
+func checkExportHeader(format string, version int) (err error) {
+
+	if format != ExportFormat {
+		err = fmt.Errorf("%w: format %#v", ErrExportFormat, format)
+		return
+	}
+
+	if version < 1 || version > ExportVersion {
+		err = fmt.Errorf("%w: %d (supported: 1 to %d)", ErrExportVersion, version, ExportVersion)
+		return
+	}
+
+	return
+}
+
+// newExportDocument returns an empty ExportDocument of the current ExportVersion.
+func newExportDocument(appID string) (doc *ExportDocument) {
+
+	doc = &ExportDocument{
+		Format:   ExportFormat,
+		Version:  ExportVersion,
+		AppID:    appID,
+		Exported: time.Now(),
+		Wallets:  make([]*ExportWallet, 0),
+	}
+
+	return
+}
+
+// exportWallet converts a WalletSnapshot to an ExportWallet.
+func exportWallet(ws *WalletSnapshot) (ew *ExportWallet, err error) {
+
+	var ef *ExportFolder
+
+	ew = &ExportWallet{
+		Name:    ws.Name,
+		Folders: make([]*ExportFolder, 0, len(ws.Folders)),
+	}
+
+	for _, fs := range ws.Folders {
+		if ef, err = exportFolder(fs); err != nil {
+			err = fmt.Errorf("wallet %#v: %w", ws.Name, err)
+			ew = nil
+			return
+		}
+		ew.Folders = append(ew.Folders, ef)
+	}
+
+	return
+}
+
+// exportFolder converts a FolderSnapshot to an ExportFolder.
+func exportFolder(fs *FolderSnapshot) (ef *ExportFolder, err error) {
+
+	var ee *ExportEntry
+
+	ef = &ExportFolder{
+		Name:    fs.Name,
+		Entries: make([]*ExportEntry, 0, len(fs.Entries)),
+	}
+
+	for _, es := range fs.Entries {
+		if ee, err = exportEntry(es); err != nil {
+			err = fmt.Errorf("folder %#v: entry %#v: %w", fs.Name, es.Name, err)
+			ef = nil
+			return
+		}
+		ef.Entries = append(ef.Entries, ee)
+	}
+
+	return
+}
+
+// exportEntry converts an EntrySnapshot to an ExportEntry, decoding its value.
+func exportEntry(es *EntrySnapshot) (ee *ExportEntry, err error) {
+
+	ee = &ExportEntry{
+		Name: es.Name,
+		Type: es.Type,
+	}
+
+	switch es.Type {
+	case KwalletdEnumTypePassword:
+		if ee.Password, err = es.Password(); err != nil {
+			ee = nil
+			return
+		}
+	case KwalletdEnumTypeMap:
+		if ee.Map, err = es.Map(); err != nil {
+			ee = nil
+			return
+		}
+	default:
+		// Anything that isn't a Password, Map, or Blob is kept as-is, as an UnknownItem.
+		if es.Type != KwalletdEnumTypeStream {
+			ee.Type = KwalletdEnumTypeUnknown
+		}
+		ee.Data = es.Bytes()
+	}
+
+	return
+}
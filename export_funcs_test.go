@@ -0,0 +1,142 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExport tests exporting to, and importing from, an ExportDocument.
+func TestExport(t *testing.T) {
+
+	var err error
+	var b []byte
+	var e *testEnv
+	var e2 *testEnv
+	var doc *ExportDocument
+	var doc2 *ExportDocument
+	var results []*ImportResult
+	var ee *ExportEntry
+	var m map[string]string
+	var mem *MemoryBackend
+	var handles int
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	if doc, err = e.wm.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		t.Fatalf("failed to marshal ExportDocument: %v", err)
+	}
+	if !strings.Contains(string(b), `"format":"`+ExportFormat+`"`) || !strings.Contains(string(b), `"type":"blob"`) {
+		t.Errorf("unexpected ExportDocument JSON: %v", string(b))
+	}
+	if doc2, err = ParseExport(b); err != nil {
+		t.Fatalf("failed to ParseExport: %v", err)
+	}
+
+	for _, ee = range doc2.Wallets[0].Folders[0].Entries {
+		switch ee.Name {
+		case passwordTest.String():
+			if ee.Type != KwalletdEnumTypePassword || ee.Password != testPassword {
+				t.Errorf("unexpected exported Password: %v / %#v", ee.Type, ee.Password)
+			}
+		case mapTest.String():
+			if ee.Type != KwalletdEnumTypeMap || !reflect.DeepEqual(ee.Map, testMap) {
+				t.Errorf("unexpected exported Map: %v / %#v", ee.Type, ee.Map)
+			}
+		case blobTest.String():
+			if ee.Type != KwalletdEnumTypeStream || !bytes.Equal(ee.Data, testBytes) {
+				t.Errorf("unexpected exported Blob: %v / %#v", ee.Type, ee.Data)
+			}
+		case unknownItemTest.String():
+			if ee.Type != KwalletdEnumTypeUnknown || !bytes.Equal(ee.Data, testBytes) {
+				t.Errorf("unexpected exported UnknownItem: %v / %#v", ee.Type, ee.Data)
+			}
+		}
+	}
+
+	// Import into a fresh WalletManager must recreate everything.
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+	mem = baseBackend(e2.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if results, err = e2.wm.Import(doc2, nil); err != nil {
+		t.Fatalf("failed to Import: %v", err)
+	}
+	if len(results) != 4 {
+		t.Errorf("expected 4 ImportResults, got %d", len(results))
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("Import leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+	// (Map values are compared decoded; their serialized key order may differ.)
+	if doc, err = e2.wm.Export(); err != nil {
+		t.Fatalf("failed to Export imported WalletManager: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Wallets, doc2.Wallets) {
+		t.Errorf("imported Wallets do not match the exported ones")
+	}
+
+	// Conflicts.
+	if results, err = e2.wm.Import(doc2, nil); err == nil {
+		t.Errorf("expected conflicts importing again with ConflictFail")
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, ErrEntryExists) {
+			t.Errorf("expected ErrEntryExists for %v, got %v", r.Entry, r.Err)
+		}
+	}
+	if results, err = e2.f.Import(doc2, &ImportOpts{Policy: ConflictSkip}); err != nil {
+		t.Fatalf("failed to Import with ConflictSkip: %v", err)
+	}
+	for _, r := range results {
+		if !r.Skipped {
+			t.Errorf("expected %v to be skipped", r.Entry)
+		}
+	}
+
+	// Merging Maps keeps existing keys.
+	if _, err = e2.f.WriteMap(mapTest.String(), map[string]string{"extra": "kept"}); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if results, err = e2.w.Import(doc2, &ImportOpts{Policy: ConflictSkip, MergeMaps: true}); err != nil {
+		t.Fatalf("failed to Import with MergeMaps: %v", err)
+	}
+	if m, err = e2.wm.backend.ReadMap(e2.w.handle, e2.f.Name, mapTest.String(), appIdTest); err != nil {
+		t.Fatalf("failed to ReadMap: %v", err)
+	}
+	if m["extra"] != "kept" || len(m) != len(testMap)+1 {
+		t.Errorf("unexpected merged Map: %#v", m)
+	}
+	for _, r := range results {
+		if r.Merged != (r.Entry == mapTest.String()) {
+			t.Errorf("unexpected ImportResult.Merged for %v: %v", r.Entry, r.Merged)
+		}
+	}
+
+	// Documents that aren't valid.
+	if _, err = ParseExport([]byte(`{"format":"something-else","version":1}`)); !errors.Is(err, ErrExportFormat) {
+		t.Errorf("expected ErrExportFormat, got %v", err)
+	}
+	if _, err = ParseExport([]byte(`{"format":"` + ExportFormat + `","version":99,"wallets":[{"new":1}]}`)); !errors.Is(err, ErrExportVersion) {
+		t.Errorf("expected ErrExportVersion, got %v", err)
+	}
+	doc2.Wallets[0].Folders[0].Entries = append(doc2.Wallets[0].Folders[0].Entries, &ExportEntry{
+		Name: passwordTest.String(),
+		Type: KwalletdEnumTypePassword,
+	})
+	if _, err = e2.wm.Import(doc2, nil); !errors.Is(err, ErrExportDocument) {
+		t.Errorf("expected ErrExportDocument for a duplicate entry, got %v", err)
+	}
+}
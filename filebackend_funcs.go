@@ -0,0 +1,306 @@
+package gokwallet
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+	NewFileBackend returns a FileBackend for the wallet files in directory dirPath.
+	dirPath will be created if it does not exist.
+	Every file in dirPath ending in WalletFileExt is loaded as a wallet.
+*/
+func NewFileBackend(dirPath string) (backend *FileBackend, err error) {
+
+	var files []string
+	var errs []error = make([]error, 0)
+
+	if dirPath, err = filepath.Abs(dirPath); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(dirPath, walletDirMode); err != nil {
+		return
+	}
+
+	backend = &FileBackend{
+		MemoryBackend: NewMemoryBackend(),
+		Dir:           dirPath,
+	}
+
+	if files, err = filepath.Glob(filepath.Join(dirPath, "*"+WalletFileExt)); err != nil {
+		return
+	}
+
+	for _, fpath := range files {
+		if err = backend.load(fpath); err != nil {
+			errs = append(errs, err)
+			err = nil
+			continue
+		}
+	}
+
+	backend.MemoryBackend.onChange = backend.save
+	backend.MemoryBackend.onDelete = backend.remove
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// Open opens a wallet (creating its file if it doesn't exist) and returns a handle for it.
+func (f *FileBackend) Open(walletName, appID string) (handle int32, err error) {
+
+	if err = validWalletFileName(walletName); err != nil {
+		return
+	}
+
+	if handle, err = f.MemoryBackend.Open(walletName, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// WalletPath returns the path to the file for wallet walletName.
+func (f *FileBackend) WalletPath(walletName string) (fpath string) {
+
+	fpath = filepath.Join(f.Dir, walletName+WalletFileExt)
+
+	return
+}
+
+// load reads the wallet file at fpath into the FileBackend.
+func (f *FileBackend) load(fpath string) (err error) {
+
+	var b []byte
+	var xw xmlWallet
+	var w *memWallet
+	var walletName string
+
+	walletName = strings.TrimSuffix(filepath.Base(fpath), WalletFileExt)
+
+	if b, err = ioutil.ReadFile(fpath); err != nil {
+		return
+	}
+
+	if err = xml.Unmarshal(b, &xw); err != nil {
+		err = fmt.Errorf("%v: %w (%v)", fpath, ErrBackendBadWallet, err)
+		return
+	}
+
+	if w, err = xmlToMemWallet(&xw); err != nil {
+		err = fmt.Errorf("%v: %w", fpath, err)
+		return
+	}
+
+	f.lock.Lock()
+	f.wallets[walletName] = w
+	f.lock.Unlock()
+
+	return
+}
+
+// remove deletes the file for wallet walletName.
+func (f *FileBackend) remove(walletName string) (err error) {
+
+	if err = os.Remove(f.WalletPath(walletName)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	return
+}
+
+// save writes wallet walletName to its file.
+func (f *FileBackend) save(walletName string) (err error) {
+
+	var b []byte
+	var ok bool
+	var w *memWallet
+	var xw *xmlWallet
+	var tmp *os.File
+	var fpath string = f.WalletPath(walletName)
+
+	f.lock.RLock()
+	if w, ok = f.wallets[walletName]; !ok {
+		f.lock.RUnlock()
+		return
+	}
+	xw, err = memWalletToXml(walletName, w)
+	f.lock.RUnlock()
+	if err != nil {
+		return
+	}
+
+	if b, err = xml.MarshalIndent(xw, "", " "); err != nil {
+		return
+	}
+	b = append([]byte(xml.Header), b...)
+
+	// Write to a temporary file and rename it into place so a failed write never leaves a truncated wallet.
+	if tmp, err = ioutil.TempFile(f.Dir, "."+walletName+".*"); err != nil {
+		return
+	}
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err = os.Chmod(tmp.Name(), walletFileMode); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err = os.Rename(tmp.Name(), fpath); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	return
+}
+
+// memWalletToXml converts a memWallet to an xmlWallet. The caller must hold the MemoryBackend.lock.
+func memWalletToXml(walletName string, w *memWallet) (xw *xmlWallet, err error) {
+
+	var folderNames []string
+	var entryNames []string
+	var xf xmlFolder
+	var xm xmlMap
+	var e *memEntry
+	var s string
+	var m map[string]string
+	var mapKeys []string
+
+	xw = &xmlWallet{
+		Name:    walletName,
+		Folders: make([]xmlFolder, 0, len(w.folders)),
+	}
+
+	folderNames = make([]string, 0, len(w.folders))
+	for fn := range w.folders {
+		folderNames = append(folderNames, fn)
+	}
+	sort.Strings(folderNames)
+
+	for _, fn := range folderNames {
+		xf = xmlFolder{
+			Name: fn,
+		}
+		entryNames = make([]string, 0, len(w.folders[fn].entries))
+		for en := range w.folders[fn].entries {
+			entryNames = append(entryNames, en)
+		}
+		sort.Strings(entryNames)
+		for _, en := range entryNames {
+			e = w.folders[fn].entries[en]
+			switch e.entryType {
+			case KwalletdEnumTypePassword:
+				if s, err = qStringToString(e.value); err != nil {
+					return
+				}
+				xf.Passwords = append(xf.Passwords, xmlEntry{Name: en, Value: s})
+			case KwalletdEnumTypeMap:
+				xm = xmlMap{
+					Name:    en,
+					Entries: make([]xmlEntry, 0),
+				}
+				if len(e.value) != 0 {
+					if m, _, err = bytesToMap(e.value); err != nil {
+						return
+					}
+					mapKeys = make([]string, 0, len(m))
+					for k := range m {
+						mapKeys = append(mapKeys, k)
+					}
+					sort.Strings(mapKeys)
+					for _, k := range mapKeys {
+						xm.Entries = append(xm.Entries, xmlEntry{Name: k, Value: m[k]})
+					}
+				}
+				xf.Maps = append(xf.Maps, xm)
+			case KwalletdEnumTypeStream:
+				xf.Streams = append(xf.Streams, xmlEntry{Name: en, Value: base64.StdEncoding.EncodeToString(e.value)})
+			default:
+				xf.Unknown = append(xf.Unknown, xmlEntry{Name: en, Value: base64.StdEncoding.EncodeToString(e.value)})
+			}
+		}
+		xw.Folders = append(xw.Folders, xf)
+	}
+
+	return
+}
+
+// xmlToMemWallet converts an xmlWallet to a (closed) memWallet.
+func xmlToMemWallet(xw *xmlWallet) (w *memWallet, err error) {
+
+	var f *memFolder
+	var b []byte
+	var m map[string]string
+
+	w = &memWallet{
+		folders: make(map[string]*memFolder),
+		users:   make(map[string]int),
+		isOpen:  false,
+	}
+
+	for _, xf := range xw.Folders {
+		f = &memFolder{
+			entries: make(map[string]*memEntry),
+		}
+		for _, xe := range xf.Passwords {
+			f.entries[xe.Name] = &memEntry{entryType: KwalletdEnumTypePassword, value: stringToQString(xe.Value)}
+		}
+		for _, xm := range xf.Maps {
+			m = make(map[string]string, len(xm.Entries))
+			for _, xe := range xm.Entries {
+				m[xe.Name] = xe.Value
+			}
+			if b, err = mapToBytes(m); err != nil {
+				return
+			}
+			f.entries[xm.Name] = &memEntry{entryType: KwalletdEnumTypeMap, value: b}
+		}
+		for _, xe := range xf.Streams {
+			if b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(xe.Value)); err != nil {
+				return
+			}
+			f.entries[xe.Name] = &memEntry{entryType: KwalletdEnumTypeStream, value: b}
+		}
+		for _, xe := range xf.Unknown {
+			if b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(xe.Value)); err != nil {
+				return
+			}
+			f.entries[xe.Name] = &memEntry{entryType: KwalletdEnumTypeUnknown, value: b}
+		}
+		w.folders[xf.Name] = f
+	}
+
+	return
+}
+
+// validWalletFileName returns an error if walletName cannot safely be used as a FileBackend wallet filename.
+func validWalletFileName(walletName string) (err error) {
+
+	if walletName == "" || walletName == "." || walletName == ".." || strings.ContainsAny(walletName, "/\\\x00") {
+		err = fmt.Errorf("%w: invalid wallet name %#v", ErrBackendBadWallet, walletName)
+		return
+	}
+
+	return
+}
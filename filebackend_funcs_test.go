@@ -0,0 +1,72 @@
+package gokwallet
+
+import (
+	"testing"
+)
+
+// TestFileBackend runs the Backend conformance suite against a FileBackend and checks that wallets persist.
+func TestFileBackend(t *testing.T) {
+
+	var err error
+	var dir string = t.TempDir()
+	var b *FileBackend
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var p *Password
+	var m *Map
+	var bl *Blob
+	var r *RecurseOpts = &RecurseOpts{Wallets: true, Folders: true, AllWalletItems: true}
+
+	if b, err = NewFileBackend(dir); err != nil {
+		t.Fatalf("failed to get FileBackend for '%v': %v", dir, err)
+	}
+
+	testBackendConformance(t, b)
+
+	if wm, err = NewWalletManagerFiles(r, appIdTest, dir); err != nil {
+		t.Fatalf("failed to get WalletManager for '%v': %v", dir, err)
+	}
+	if w, err = NewWallet(wm, walletTest.String(), r); err != nil {
+		t.Fatalf("failed to get Wallet: %v", err)
+	}
+	if w.FilePath != b.WalletPath(walletTest.String()) {
+		t.Errorf("Wallet.FilePath '%v' does not match expected '%v'", w.FilePath, b.WalletPath(walletTest.String()))
+	}
+	if f, err = NewFolder(w, folderTest.String(), r); err != nil {
+		t.Fatalf("failed to get Folder: %v", err)
+	}
+	if _, err = f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, err = f.WriteMap(mapTest.String(), testMap); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if _, err = f.WriteBlob(blobTest.String(), testBytes); err != nil {
+		t.Fatalf("failed to WriteBlob: %v", err)
+	}
+
+	// Re-read the wallet from disk.
+	if wm, err = NewWalletManagerFiles(r, appIdTest, dir); err != nil {
+		t.Fatalf("failed to re-read WalletManager for '%v': %v", dir, err)
+	}
+	if w = wm.Wallets[walletTest.String()]; w == nil {
+		t.Fatalf("wallet '%v' not loaded from '%v'", walletTest.String(), dir)
+	}
+	if f = w.Folders[folderTest.String()]; f == nil {
+		t.Fatalf("folder '%v' not loaded from '%v'", folderTest.String(), w.FilePath)
+	}
+	if p = f.Passwords[passwordTest.String()]; p == nil || p.Value != testPassword {
+		t.Errorf("Password not persisted correctly: %#v", p)
+	}
+	if m = f.Maps[mapTest.String()]; m == nil || len(m.Value) != len(testMap) {
+		t.Errorf("Map not persisted correctly: %#v", m)
+	}
+	if bl = f.BinaryData[blobTest.String()]; bl == nil || string(bl.Value) != string(testBytes) {
+		t.Errorf("Blob not persisted correctly: %#v", bl)
+	}
+
+	if err = w.Delete(); err != nil {
+		t.Errorf("failed to delete Wallet: %v", err)
+	}
+}
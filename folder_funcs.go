@@ -1,7 +1,10 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
 )
 
 /*
@@ -16,6 +19,10 @@ func NewFolder(w *Wallet, name string, recursion *RecurseOpts) (folder *Folder,
 		return
 	}
 
+	if err = recursion.checkFilters(); err != nil {
+		return
+	}
+
 	folder = &Folder{
 		DbusObject: w.DbusObject,
 		Name:       name,
@@ -64,19 +71,11 @@ func (f *Folder) Delete() (err error) {
 // HasEntry specifies if a Folder has an entry (WalletItem item) by the give entryName.
 func (f *Folder) HasEntry(entryName string) (hasEntry bool, err error) {
 
-	var call *dbus.Call
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMHasEntry, 0, f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&hasEntry); err != nil {
+	if hasEntry, err = f.wallet.wm.Backend().HasEntry(f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
@@ -91,19 +90,11 @@ func (f *Folder) HasEntry(entryName string) (hasEntry bool, err error) {
 */
 func (f *Folder) KeyNotExist(entryName string) (doesNotExist bool, err error) {
 
-	var call *dbus.Call
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMKeyNotExist, 0, f.wallet.Name, f.Name, entryName,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&doesNotExist); err != nil {
+	if doesNotExist, err = f.wallet.wm.Backend().KeyDoesNotExist(f.wallet.Name, f.Name, entryName); err != nil {
 		return
 	}
 
@@ -113,19 +104,11 @@ func (f *Folder) KeyNotExist(entryName string) (doesNotExist bool, err error) {
 // ListEntries lists all entries (WalletItem items) in a Folder (regardless of type) by name.
 func (f *Folder) ListEntries() (entryNames []string, err error) {
 
-	var call *dbus.Call
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMEntryList, 0, f.wallet.handle, f.Name, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&entryNames); err != nil {
+	if entryNames, err = f.wallet.wm.Backend().EntryList(f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
@@ -135,24 +118,132 @@ func (f *Folder) ListEntries() (entryNames []string, err error) {
 // RemoveEntry removes a WalletItem from a Folder given its entryName (key).
 func (f *Folder) RemoveEntry(entryName string) (err error) {
 
-	var call *dbus.Call
-	var rslt int32
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if err = f.wallet.wm.Backend().RemoveEntry(f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Rename renames a Folder to newName.
+	kwalletd has no way of renaming a Folder, so this creates Folder newName, copies every WalletItem (with its type) to it,
+	verifies each copy by reading it back, and only then removes the original Folder.
+	If any step fails, Folder newName is removed again and the original Folder is left untouched.
+	Once the original Folder has been removed, the rename has succeeded; errors cleaning up its bookkeeping
+	(e.g. its old history) after that are ignored, and at worst leave stale bookkeeping behind.
+	newName must not already exist (ErrFolderExists).
+	The parent Wallet.Folders cache (if populated) is updated accordingly.
+*/
+func (f *Folder) Rename(newName string) (err error) {
+
+	var exists bool
+	var entryNames []string
+	var copied []byte
+	var copiedType kwalletdEnumType
+	var types map[string]kwalletdEnumType
+	var values map[string][]byte
+	var dst *Folder
+	var oldName string = f.Name
+
+	if newName == f.Name {
+		return
+	}
 
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMRemoveEntry, 0, f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if exists, err = f.wallet.HasFolder(newName); err != nil {
+		return
+	} else if exists {
+		err = fmt.Errorf("%w: %#v/%#v", ErrFolderExists, f.wallet.Name, newName)
 		return
 	}
-	if err = call.Store(&rslt); err != nil {
+
+	if entryNames, err = f.ListEntries(); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
+	types = make(map[string]kwalletdEnumType, len(entryNames))
+	values = make(map[string][]byte, len(entryNames))
+	for _, en := range entryNames {
+		if types[en], values[en], err = f.readStored(en); err != nil {
+			return
+		}
+	}
+
+	if err = f.wallet.CreateFolder(newName); err != nil {
+		return
+	}
+
+	if dst, err = NewFolder(f.wallet, newName, &RecurseOpts{}); err != nil {
+		err = f.renameRollback(newName, entryNames, err)
+		return
+	}
+
+	for _, en := range entryNames {
+		if err = dst.WriteEntry(en, types[en], values[en]); err != nil {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+		if err = transferMetadata(f, en, dst, en); err != nil {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+		if err = transferHistory(f, en, dst, en); err != nil {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+		if err = transferExpiry(f, en, dst, en); err != nil {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+	}
+
+	for _, en := range entryNames {
+		if copiedType, copied, err = dst.readStored(en); err != nil {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+		if copiedType != types[en] || !bytes.Equal(copied, values[en]) {
+			err = f.renameRollback(newName, entryNames, fmt.Errorf("%w: %#v/%#v/%#v", ErrVerifyFailed, f.wallet.Name, newName, en))
+			return
+		}
+	}
+
+	/*
+		If only the bookkeeping of the old Folder could not be cleaned up, the old Folder itself is already gone,
+		so the rename has succeeded; as below, that only leaves stale bookkeeping behind.
+	*/
+	if err = f.wallet.RemoveFolder(oldName); err != nil {
+		if !errors.Is(err, ErrBookkeeping) {
+			err = f.renameRollback(newName, entryNames, err)
+			return
+		}
+		err = nil
+	}
+
+	/*
+		The history was copied to the new Folder along with the WalletItems, so the old copy is no longer needed.
+		The rename has already succeeded at this point, so removing it is best effort (a failure only leaves stale history).
+	*/
+	for _, en := range entryNames {
+		if f.ClearHistory(en) != nil {
+			break
+		}
+	}
+
+	f.Name = newName
+
+	if f.wallet.Folders != nil && f.wallet.Folders[oldName] == f {
+		delete(f.wallet.Folders, oldName)
+		f.wallet.Folders[newName] = f
+	}
 
 	return
 }
@@ -160,33 +251,46 @@ func (f *Folder) RemoveEntry(entryName string) (err error) {
 // RenameEntry renames a WalletItem in a Folder from entryName to newEntryName.
 func (f *Folder) RenameEntry(entryName, newEntryName string) (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMRenameEntry, 0, f.wallet.handle, f.Name, entryName, newEntryName, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
+	if err = f.wallet.wm.Backend().RenameEntry(
+		f.wallet.handle, f.Name, entryName, newEntryName, f.wallet.wm.AppID,
+	); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
-
 	return
 }
 
-// Update runs all of the configured Update[type] methods for a Folder, depending on Folder.Recurse configuration.
+/*
+	Update runs all of the configured Update[type] methods for a Folder, depending on Folder.Recurse configuration
+	(including its RecurseOpts.IncludeEntries, RecurseOpts.ExcludeEntries, and RecurseOpts.MaxItems).
+*/
 func (f *Folder) Update() (err error) {
 
 	var errs []error = make([]error, 0)
 
+	if err = f.Recurse.checkFilters(); err != nil {
+		return
+	}
+
+	// Start from scratch so RecurseOpts.MaxItems counts only what this Update holds.
+	f.Truncated = false
+	if f.Recurse.AllWalletItems || f.Recurse.Passwords {
+		f.Passwords = nil
+	}
+	if f.Recurse.AllWalletItems || f.Recurse.Maps {
+		f.Maps = nil
+	}
+	if f.Recurse.AllWalletItems || f.Recurse.Blobs {
+		f.BinaryData = nil
+	}
+	if f.Recurse.AllWalletItems || f.Recurse.UnknownItems {
+		f.Unknown = nil
+	}
+
 	if f.Recurse.AllWalletItems || f.Recurse.Passwords {
 		if err = f.UpdatePasswords(); err != nil {
 			errs = append(errs, err)
@@ -222,10 +326,9 @@ func (f *Folder) Update() (err error) {
 // UpdateBlobs updates (populates) a Folder's Folder.BinaryData.
 func (f *Folder) UpdateBlobs() (err error) {
 
-	var call *dbus.Call
 	var mapKeys []string
+	var wanted bool
 	var isBlob bool
-	var variant dbus.Variant
 	var errs []error = make([]error, 0)
 
 	if err = f.wallet.walletCheck(); err != nil {
@@ -237,21 +340,20 @@ func (f *Folder) UpdateBlobs() (err error) {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMEntriesList, 0, f.wallet.handle, f.Name, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&variant); err != nil {
+	if mapKeys, err = f.wallet.wm.Backend().EntryList(f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
-	mapKeys = bytemapKeys(variant)
+	sort.Strings(mapKeys)
 
 	f.BinaryData = make(map[string]*Blob, len(mapKeys))
 
 	for _, k := range mapKeys {
+		if wanted, err = f.Recurse.wantEntry(k); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
 		if isBlob, err = f.isType(k, KwalletdEnumTypeStream); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -261,6 +363,11 @@ func (f *Folder) UpdateBlobs() (err error) {
 			continue
 		}
 
+		if f.full() {
+			f.Truncated = true
+			break
+		}
+
 		if f.BinaryData[k], err = NewBlob(f, k, f.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -278,30 +385,33 @@ func (f *Folder) UpdateBlobs() (err error) {
 // UpdateMaps updates (populates) a Folder's Folder.Maps.
 func (f *Folder) UpdateMaps() (err error) {
 
-	var call *dbus.Call
 	var mapKeys []string
-	var variant dbus.Variant
+	var wanted bool
 	var errs []error = make([]error, 0)
 
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMMapList, 0, f.wallet.handle, f.Name, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&variant); err != nil {
+	if mapKeys, err = f.wallet.wm.Backend().MapList(f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
-	mapKeys = bytemapKeys(variant)
+	sort.Strings(mapKeys)
 
 	f.Maps = make(map[string]*Map, len(mapKeys))
 
 	for _, k := range mapKeys {
+		if wanted, err = f.Recurse.wantEntry(k); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
+		if f.full() {
+			f.Truncated = true
+			break
+		}
+
 		if f.Maps[k], err = NewMap(f, k, f.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -319,9 +429,8 @@ func (f *Folder) UpdateMaps() (err error) {
 // UpdatePasswords updates (populates) a Folder's Folder.Passwords.
 func (f *Folder) UpdatePasswords() (err error) {
 
-	var call *dbus.Call
 	var mapKeys []string
-	var variant dbus.Variant
+	var wanted bool
 	var errs []error = make([]error, 0)
 
 	if err = f.wallet.walletCheck(); err != nil {
@@ -333,21 +442,25 @@ func (f *Folder) UpdatePasswords() (err error) {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMPasswordList, 0, f.wallet.handle, f.Name, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&variant); err != nil {
+	if mapKeys, err = f.wallet.wm.Backend().PasswordList(f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
-	mapKeys = bytemapKeys(variant)
+	sort.Strings(mapKeys)
 
 	f.Passwords = make(map[string]*Password, len(mapKeys))
 
 	for _, k := range mapKeys {
+		if wanted, err = f.Recurse.wantEntry(k); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
+		if f.full() {
+			f.Truncated = true
+			break
+		}
+
 		if f.Passwords[k], err = NewPassword(f, k, f.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -365,10 +478,9 @@ func (f *Folder) UpdatePasswords() (err error) {
 // UpdateUnknowns updates (populates) a Folder's Folder.Unknown.
 func (f *Folder) UpdateUnknowns() (err error) {
 
-	var call *dbus.Call
 	var mapKeys []string
+	var wanted bool
 	var isUnknown bool
-	var variant dbus.Variant
 	var errs []error = make([]error, 0)
 
 	if err = f.wallet.walletCheck(); err != nil {
@@ -380,21 +492,20 @@ func (f *Folder) UpdateUnknowns() (err error) {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMEntriesList, 0, f.wallet.handle, f.Name, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&variant); err != nil {
+	if mapKeys, err = f.wallet.wm.Backend().EntryList(f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
-	mapKeys = bytemapKeys(variant)
+	sort.Strings(mapKeys)
 
 	f.Unknown = make(map[string]*UnknownItem, len(mapKeys))
 
 	for _, k := range mapKeys {
+		if wanted, err = f.Recurse.wantEntry(k); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
 		if isUnknown, err = f.isType(k, KwalletdEnumTypeUnknown); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -404,6 +515,11 @@ func (f *Folder) UpdateUnknowns() (err error) {
 			continue
 		}
 
+		if f.full() {
+			f.Truncated = true
+			break
+		}
+
 		if f.Unknown[k], err = NewUnknownItem(f, k, f.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -439,9 +555,6 @@ func (f *Folder) WriteBlob(entryName string, entryValue []byte) (b *Blob, err er
 */
 func (f *Folder) WriteEntry(entryName string, entryType kwalletdEnumType, entryValue []byte) (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
@@ -451,17 +564,19 @@ func (f *Folder) WriteEntry(entryName string, entryType kwalletdEnumType, entryV
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMWriteEntry, 0, f.wallet.handle, f.Name, entryName, entryValue, int32(entryType), f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = f.ttlCheck(); err != nil {
 		return
 	}
-	if err = call.Store(&rslt); err != nil {
+
+	if err = f.wallet.wm.Backend().WriteEntry(
+		f.wallet.handle, f.Name, entryName, entryType, entryValue, f.wallet.wm.AppID,
+	); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
+	if err = f.refreshExpiry(entryName); err != nil {
+		return
+	}
 
 	return
 }
@@ -469,30 +584,22 @@ func (f *Folder) WriteEntry(entryName string, entryType kwalletdEnumType, entryV
 // WriteMap adds or replaces a Map to/in a Folder.
 func (f *Folder) WriteMap(entryName string, entryValue map[string]string) (m *Map, err error) {
 
-	var call *dbus.Call
-	var rslt int32
-	var b []byte
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if b, err = mapToBytes(entryValue); err != nil {
+	if err = f.ttlCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMWriteMap, 0, f.wallet.handle, f.Name, entryName, b, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = f.wallet.wm.Backend().WriteMap(f.wallet.handle, f.Name, entryName, entryValue, f.wallet.wm.AppID); err != nil {
 		return
 	}
-	if err = call.Store(&rslt); err != nil {
+
+	if err = f.refreshExpiry(entryName); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
-
 	if m, err = NewMap(f, entryName, f.Recurse); err != nil {
 		return
 	}
@@ -503,24 +610,21 @@ func (f *Folder) WriteMap(entryName string, entryValue map[string]string) (m *Ma
 // WritePassword adds or replaces a Password to/in a Folder.
 func (f *Folder) WritePassword(entryName, entryValue string) (p *Password, err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = f.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = f.Dbus.Call(
-		DbusWMWritePassword, 0, f.wallet.handle, f.Name, entryName, entryValue, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = f.ttlCheck(); err != nil {
 		return
 	}
-	if err = call.Store(&rslt); err != nil {
+
+	if err = f.wallet.wm.Backend().WritePassword(f.wallet.handle, f.Name, entryName, entryValue, f.wallet.wm.AppID); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
+	if err = f.refreshExpiry(entryName); err != nil {
+		return
+	}
 
 	if p, err = NewPassword(f, entryName, f.Recurse); err != nil {
 		return
@@ -547,25 +651,155 @@ func (f *Folder) WriteUnknown(entryName string, entryValue []byte) (u *UnknownIt
 	return
 }
 
-// isType checks if a certain key keyName is of type typeCheck (via KwalletdEnumType*).
-func (f *Folder) isType(keyName string, typeCheck kwalletdEnumType) (isOfType bool, err error) {
+/*
+	renameRollback removes the partially-created Folder newName after a failed Folder.Rename, along with any history
+	copied for entryNames, returning cause (and any rollback errors).
+*/
+func (f *Folder) renameRollback(newName string, entryNames []string, cause error) (err error) {
 
-	var call *dbus.Call
-	var entryType int32
+	var rbErr error
+	var dst *Folder
+	var errs []error = []error{cause}
 
-	if call = f.Dbus.Call(
-		DbusWMEntryType, 0, f.wallet.handle, f.Name, keyName, f.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if rbErr = f.wallet.RemoveFolder(newName); rbErr != nil {
+		errs = append(errs, fmt.Errorf("rollback of %#v/%#v failed: %w", f.wallet.Name, newName, rbErr))
+	}
+
+	if dst, rbErr = NewFolder(f.wallet, newName, &RecurseOpts{}); rbErr != nil {
+		errs = append(errs, fmt.Errorf("rollback of %#v/%#v history failed: %w", f.wallet.Name, newName, rbErr))
+		err = NewErrors(errs...)
 		return
 	}
-	if err = call.Store(&entryType); err != nil {
+
+	for _, en := range entryNames {
+		if rbErr = dst.ClearHistory(en); rbErr != nil {
+			errs = append(errs, fmt.Errorf("rollback of %#v/%#v/%#v history failed: %w", f.wallet.Name, newName, en, rbErr))
+		}
+	}
+
+	if len(errs) == 1 {
+		err = cause
 		return
 	}
 
-	if int32(typeCheck) == entryType {
+	err = NewErrors(errs...)
+
+	return
+}
+
+/*
+	casEntry re-reads WalletItem entryName and, only if it still exists, is still of type entryType,
+	and match returns true for its current (raw) value, writes newRaw in its place.
+	Otherwise a *ConflictError is returned and nothing is written.
+	cur is the raw value that was read (nil if the WalletItem no longer exists or is of another type).
+
+	kwalletd has no atomic compare-and-swap, so this narrows (but cannot entirely close) the window for lost updates.
+*/
+func (f *Folder) casEntry(
+	entryName string, entryType kwalletdEnumType, match func(cur []byte) (isMatch bool, err error), newRaw []byte,
+) (cur []byte, err error) {
+
+	var exists bool
+	var curType kwalletdEnumType
+	var isMatch bool
+	var conflict *ConflictError = &ConflictError{
+		Wallet: f.wallet.Name,
+		Folder: f.Name,
+		Entry:  entryName,
+	}
+
+	if exists, err = f.HasEntry(entryName); err != nil {
+		return
+	} else if !exists {
+		conflict.Reason = "entry no longer exists"
+		err = conflict
+		return
+	}
+
+	if curType, cur, err = f.readRaw(entryName); err != nil {
+		return
+	}
+
+	if curType != entryType {
+		cur = nil
+		conflict.Reason = fmt.Sprintf("entry is now of type %v", curType)
+		err = conflict
+		return
+	}
+
+	if isMatch, err = match(cur); err != nil {
+		return
+	} else if !isMatch {
+		conflict.Reason = "value has changed"
+		err = conflict
+		return
+	}
+
+	if err = f.WriteEntry(entryName, entryType, newRaw); err != nil {
+		return
+	}
+
+	return
+}
+
+// isType checks if a certain key keyName is of type typeCheck (via KwalletdEnumType*).
+func (f *Folder) isType(keyName string, typeCheck kwalletdEnumType) (isOfType bool, err error) {
+
+	var entryType kwalletdEnumType
+
+	if entryType, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, f.Name, keyName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	if typeCheck == entryType {
 		isOfType = true
 	}
 
 	return
 }
+
+// Wipe wipes the values of every WalletItem held by a Folder from memory (see e.g. Password.Wipe).
+func (f *Folder) Wipe() (err error) {
+
+	var errs []error = make([]error, 0)
+
+	for _, p := range f.Passwords {
+		if err = p.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	for _, m := range f.Maps {
+		if err = m.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	for _, b := range f.BinaryData {
+		if err = b.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	for _, u := range f.Unknown {
+		if err = u.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	return
+}
+
+// full returns true if a Folder holds RecurseOpts.MaxItems WalletItems (if set).
+func (f *Folder) full() (isFull bool) {
+
+	isFull = f.Recurse.MaxItems > 0 &&
+		len(f.Passwords)+len(f.Maps)+len(f.BinaryData)+len(f.Unknown) >= f.Recurse.MaxItems
+
+	return
+}
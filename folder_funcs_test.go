@@ -1,7 +1,10 @@
 package gokwallet
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestFolder(t *testing.T) {
@@ -135,3 +138,167 @@ func TestFolder(t *testing.T) {
 		t.Errorf("failed to delete Wallet '%v': %v", w.Name, err)
 	}
 }
+
+// TestFolderRename tests Folder.Rename, including rolling back a failed rename.
+func TestFolderRename(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var fb *failBackend
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var before *FolderSnapshot
+	var after *FolderSnapshot
+	var d *Diff
+	var exists bool
+	var oldName string = folderTest.String()
+	var newName string = folderTest.String() + "_renamed"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+	if before, err = e.f.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Folder: %v", err)
+	}
+
+	// A failure partway through must leave the original Folder intact and not leave the new one behind.
+	fb = newFailBackend(e.wm.Backend())
+	fb.failAfter["WriteEntry"] = 2
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if w, err = NewWallet(wm, walletTest.String(), &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if f, err = NewFolder(w, oldName, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Folder: %v", err)
+	}
+	if err = f.Rename(newName); !errors.Is(err, ErrOperationFailed) {
+		t.Errorf("expected ErrOperationFailed from a failed Folder.Rename, got %v", err)
+	}
+	if f.Name != oldName {
+		t.Errorf("Folder.Name changed after a failed Folder.Rename")
+	}
+	if exists, err = e.w.HasFolder(newName); err != nil {
+		t.Fatalf("failed to HasFolder: %v", err)
+	} else if exists {
+		t.Errorf("Folder %#v was not rolled back", newName)
+	}
+	if after, err = e.f.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Folder: %v", err)
+	}
+	if d, err = DiffFolderSnapshots(before, after, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("original Folder changed after a failed Folder.Rename")
+	}
+
+	if err = e.w.Update(); err != nil {
+		t.Fatalf("failed to Update Wallet: %v", err)
+	}
+	f = e.w.Folders[oldName]
+	if err = f.Rename(newName); err != nil {
+		t.Fatalf("failed to Folder.Rename: %v", err)
+	}
+	if f.Name != newName || e.w.Folders[newName] != f || e.w.Folders[oldName] != nil {
+		t.Errorf("Folder.Rename did not update Folder.Name/Wallet.Folders")
+	}
+	if exists, err = e.w.HasFolder(oldName); err != nil {
+		t.Fatalf("failed to HasFolder: %v", err)
+	} else if exists {
+		t.Errorf("Folder %#v still exists after Folder.Rename", oldName)
+	}
+	if after, err = f.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Folder: %v", err)
+	}
+	after.Name = before.Name
+	if d, err = DiffFolderSnapshots(before, after, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("renamed Folder does not match the original")
+	}
+
+	if err = f.Rename(oldName); err != nil {
+		t.Fatalf("failed to Folder.Rename back: %v", err)
+	}
+	if err = e.w.CreateFolder(newName); err != nil {
+		t.Fatalf("failed to CreateFolder: %v", err)
+	}
+	if err = f.Rename(newName); !errors.Is(err, ErrFolderExists) {
+		t.Errorf("expected ErrFolderExists, got %v", err)
+	}
+}
+
+// TestFolderLazy tests lazily-fetched WalletItem values (RecurseOpts.Lazy).
+func TestFolderLazy(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var f *Folder
+	var pw string
+	var m map[string]string
+	var raw []byte
+	var r *RecurseOpts = &RecurseOpts{AllWalletItems: true, Lazy: true}
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+
+	if f, err = NewFolder(e.w, e.f.Name, r); err != nil {
+		t.Fatalf("failed to get lazy Folder: %v", err)
+	}
+	if len(f.Passwords) != 1 || len(f.Maps) != 1 || len(f.BinaryData) != 1 || len(f.Unknown) != 1 {
+		t.Fatalf("lazy Folder is missing WalletItems: %#v", f)
+	}
+	for _, p := range f.Passwords {
+		if p.Value != "" {
+			t.Errorf("lazy Password was fetched on creation")
+		}
+		if pw, err = p.GetValue(); err != nil {
+			t.Errorf("failed to GetValue: %v", err)
+		} else if pw != testPassword {
+			t.Errorf("unexpected Password value %#v", pw)
+		}
+		if p.Value != "" {
+			t.Errorf("Password value was cached without RecurseOpts.CacheValues")
+		}
+		if err = f.SetEntryExpiry(p.Name, time.Now().Add(-time.Second)); err == nil {
+			t.Errorf("expected ErrExpiryDisabled")
+		}
+	}
+	for _, mp := range f.Maps {
+		if m, err = mp.GetValue(); err != nil || len(m) != len(testMap) {
+			t.Errorf("unexpected Map value %#v (err: %v)", m, err)
+		}
+	}
+	for _, b := range f.BinaryData {
+		if raw, err = b.GetValue(); err != nil || !bytes.Equal(raw, testBytes) {
+			t.Errorf("unexpected Blob value %#v (err: %v)", raw, err)
+		}
+	}
+
+	// Caching, and errors instead of empty values.
+	r.CacheValues = true
+	if err = e.wm.EnableExpiry(); err != nil {
+		t.Fatalf("failed to EnableExpiry: %v", err)
+	}
+	if f, err = NewFolder(e.w, e.f.Name, r); err != nil {
+		t.Fatalf("failed to get lazy Folder: %v", err)
+	}
+	for _, u := range f.Unknown {
+		if raw, err = u.GetValue(); err != nil || !bytes.Equal(u.Value, testBytes) {
+			t.Errorf("UnknownItem value was not cached: %#v (err: %v)", u.Value, err)
+		}
+	}
+	for _, p := range f.Passwords {
+		if err = p.SetExpiry(time.Now().Add(-time.Second)); err != nil {
+			t.Fatalf("failed to SetExpiry: %v", err)
+		}
+		if pw, err = p.GetValue(); !errors.Is(err, ErrExpired) {
+			t.Errorf("expected ErrExpired, got %#v (err: %v)", pw, err)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package gokwallet
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	EnableHistory turns on history retention for a WalletManager: whenever a Password, Map, or Blob is replaced
+	(with a different value) or removed through this WalletManager, its previous value is retained as a HistoryVersion
+	in the reserved Folder HistoryFolder of its Wallet. Up to depth previous values are kept per WalletItem;
+	the oldest are removed first. A depth of 0 only retains history for Folders with their own depth (see Folder.SetHistoryDepth).
+*/
+func (wm *WalletManager) EnableHistory(depth int) (err error) {
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if depth < 0 {
+		depth = 0
+	}
+
+	wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var t *trackingBackend = newTrackingBackend(cur)
+
+		t.history = true
+		t.historyDepth = depth
+		next = t
+
+		return
+	})
+
+	return
+}
+
+// HistoryDepth returns the default history depth of a WalletManager (0 if history is not enabled).
+func (wm *WalletManager) HistoryDepth() (depth int) {
+
+	var t *trackingBackend
+	var ok bool
+
+	if t, ok = wm.Backend().(*trackingBackend); ok && t.history {
+		depth = t.historyDepth
+	}
+
+	return
+}
+
+/*
+	SetHistoryDepth sets how many previous values are retained for each Password, Map, and Blob in a Folder,
+	overriding the WalletManager's depth (see WalletManager.EnableHistory). A depth of 0 disables history for the Folder,
+	and HistoryDepthDefault removes the override. The setting is stored in the Wallet, so it persists.
+	This enables history on the WalletManager (with a default depth of 0) if it is not already enabled.
+*/
+func (f *Folder) SetHistoryDepth(depth int) (err error) {
+
+	var exists bool
+	var t *trackingBackend
+	var m map[string]string = make(map[string]string)
+
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	f.wallet.wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var ok bool
+
+		if t, ok = cur.(*trackingBackend); ok && t.history {
+			next = cur
+			return
+		}
+
+		t = newTrackingBackend(cur)
+		t.history = true
+		t.historyDepth = 0
+		next = t
+
+		return
+	})
+
+	if exists, err = t.Backend.HasEntry(f.wallet.handle, HistoryFolder, historyConfigEntry, f.wallet.wm.AppID); err != nil {
+		return
+	} else if exists {
+		if m, err = t.Backend.ReadMap(f.wallet.handle, HistoryFolder, historyConfigEntry, f.wallet.wm.AppID); err != nil {
+			return
+		}
+	}
+
+	if depth < 0 {
+		delete(m, url.PathEscape(f.Name))
+	} else {
+		m[url.PathEscape(f.Name)] = strconv.Itoa(depth)
+	}
+
+	if err = t.Backend.WriteMap(f.wallet.handle, HistoryFolder, historyConfigEntry, m, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// HistoryDepth returns how many previous values are retained for each Password, Map, and Blob in a Folder.
+func (f *Folder) HistoryDepth() (depth int, err error) {
+
+	var t *trackingBackend
+	var ok bool
+
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if t, ok = f.wallet.wm.Backend().(*trackingBackend); !ok || !t.history {
+		return
+	}
+
+	if depth, err = folderHistoryDepth(t, f.wallet.handle, f.Name, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	History returns the retained previous values of WalletItem entryName in a Folder, newest first.
+	Any retained history is returned, even if history is not (or no longer) enabled.
+*/
+func (f *Folder) History(entryName string) (versions []*HistoryVersion, err error) {
+
+	var ids []string
+	var v *HistoryVersion
+
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if ids, err = listVersions(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	versions = make([]*HistoryVersion, 0, len(ids))
+
+	for idx := len(ids) - 1; idx >= 0; idx-- {
+		if v, err = f.readVersion(entryName, ids[idx]); err != nil {
+			return
+		}
+		versions = append(versions, v)
+	}
+
+	return
+}
+
+/*
+	RestoreVersion writes HistoryVersion versionID (see Folder.History) back as the value of WalletItem entryName,
+	with the type it had at the time. If history is enabled, the value being replaced is itself retained,
+	so a restore can be undone.
+*/
+func (f *Folder) RestoreVersion(entryName, versionID string) (err error) {
+
+	var v *HistoryVersion
+
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if v, err = f.readVersion(entryName, versionID); err != nil {
+		return
+	}
+
+	if err = f.WriteEntry(entryName, v.Type, v.Raw); err != nil {
+		return
+	}
+
+	return
+}
+
+// ClearHistory removes all retained previous values of WalletItem entryName in a Folder.
+func (f *Folder) ClearHistory(entryName string) (err error) {
+
+	var ids []string
+
+	if err = f.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if ids, err = listVersions(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	for _, id := range ids {
+		if err = f.wallet.wm.Backend().RemoveEntry(
+			f.wallet.handle, HistoryFolder, historyKey(f.Name, entryName, id), f.wallet.wm.AppID,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// readVersion reads HistoryVersion versionID of WalletItem entryName in a Folder.
+func (f *Folder) readVersion(entryName, versionID string) (v *HistoryVersion, err error) {
+
+	var exists bool
+	var nsec int64
+	var key string = historyKey(f.Name, entryName, versionID)
+
+	if nsec, err = strconv.ParseInt(versionID, 10, 64); err != nil {
+		err = fmt.Errorf("%w: %#v", ErrNoVersion, versionID)
+		return
+	}
+
+	if exists, err = f.wallet.wm.Backend().HasEntry(f.wallet.handle, HistoryFolder, key, f.wallet.wm.AppID); err != nil {
+		return
+	} else if !exists {
+		err = fmt.Errorf("%w: %#v/%#v/%#v@%v", ErrNoVersion, f.wallet.Name, f.Name, entryName, versionID)
+		return
+	}
+
+	v = &HistoryVersion{
+		ID:       versionID,
+		Replaced: time.Unix(0, nsec),
+	}
+
+	if v.Type, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, HistoryFolder, key, f.wallet.wm.AppID); err != nil {
+		return
+	}
+	if v.Raw, err = f.wallet.wm.Backend().ReadEntry(f.wallet.handle, HistoryFolder, key, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	transferHistory copies the retained history of WalletItem srcEntry in Folder src
+	to WalletItem dstEntry in Folder dst (e.g. when moving a WalletItem).
+*/
+func transferHistory(src *Folder, srcEntry string, dst *Folder, dstEntry string) (err error) {
+
+	var versions []*HistoryVersion
+
+	if versions, err = src.History(srcEntry); err != nil {
+		return
+	}
+
+	for _, v := range versions {
+		if err = untrackedBackend(dst.wallet.wm.Backend()).WriteEntry(
+			dst.wallet.handle, HistoryFolder, historyKey(dst.Name, dstEntry, v.ID), v.Type, v.Raw, dst.wallet.wm.AppID,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// folderHistoryDepth returns the history depth for folderName (its own, if set, otherwise the trackingBackend's).
+func folderHistoryDepth(t *trackingBackend, handle int32, folderName, appID string) (depth int, err error) {
+
+	var exists bool
+	var ok bool
+	var s string
+	var m map[string]string
+
+	depth = t.historyDepth
+
+	if exists, err = t.Backend.HasEntry(handle, HistoryFolder, historyConfigEntry, appID); err != nil || !exists {
+		return
+	}
+
+	if m, err = t.Backend.ReadMap(handle, HistoryFolder, historyConfigEntry, appID); err != nil {
+		return
+	}
+
+	if s, ok = m[url.PathEscape(folderName)]; ok {
+		if depth, err = strconv.Atoi(s); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// historyKey returns the name of the entry in HistoryFolder for version versionID of entryName in folderName.
+func historyKey(folderName, entryName, versionID string) (key string) {
+
+	key = metadataKey(folderName, entryName) + "#" + versionID
+
+	return
+}
+
+// listVersions returns the IDs of the retained versions of entryName in folderName, oldest first.
+func listVersions(backend Backend, handle int32, folderName, entryName, appID string) (ids []string, err error) {
+
+	var entries []string
+	var prefix string = historyKey(folderName, entryName, "")
+
+	if entries, err = backend.EntryList(handle, HistoryFolder, appID); err != nil {
+		return
+	}
+
+	ids = make([]string, 0)
+
+	for _, en := range entries {
+		if strings.HasPrefix(en, prefix) {
+			ids = append(ids, strings.TrimPrefix(en, prefix))
+		}
+	}
+
+	// IDs are fixed-width, so they sort chronologically.
+	sort.Strings(ids)
+
+	return
+}
+
+/*
+	retainVersion stores prior as a new HistoryVersion of entryName in folderName and trims the oldest versions.
+	Version IDs are the time the value was replaced (in nanoseconds); if that is not after the newest retained version
+	(e.g. two writes within the clock's resolution), the newest version's ID plus one is used instead so IDs stay unique.
+*/
+func retainVersion(t *trackingBackend, handle int32, folderName, entryName string, prior *EntrySnapshot, appID string) (err error) {
+
+	var depth int
+	var last int64
+	var id string
+	var ids []string
+	var nsec int64 = time.Now().UnixNano()
+
+	if depth, err = folderHistoryDepth(t, handle, folderName, appID); err != nil || depth <= 0 {
+		return
+	}
+
+	if ids, err = listVersions(t.Backend, handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if len(ids) != 0 {
+		if last, err = strconv.ParseInt(ids[len(ids)-1], 10, 64); err != nil {
+			return
+		}
+		if last >= nsec {
+			nsec = last + 1
+		}
+	}
+	id = fmt.Sprintf("%020d", nsec)
+
+	if err = t.Backend.WriteEntry(handle, HistoryFolder, historyKey(folderName, entryName, id), prior.Type, prior.raw, appID); err != nil {
+		return
+	}
+	ids = append(ids, id)
+
+	for idx := 0; idx < len(ids)-depth; idx++ {
+		if err = t.Backend.RemoveEntry(handle, HistoryFolder, historyKey(folderName, entryName, ids[idx]), appID); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// String returns a representation of a HistoryVersion with its value (HistoryVersion.Raw) replaced by RedactedValue.
+func (v HistoryVersion) String() (str string) {
+
+	str = fmt.Sprintf(
+		"HistoryVersion{ID: %q, Replaced: %v, Type: %v, Raw: %v}",
+		v.ID, v.Replaced.Format(time.RFC3339Nano), v.Type, RedactedValue,
+	)
+
+	return
+}
+
+// Format prints HistoryVersion.String for every verb (see formatRedacted).
+func (v HistoryVersion) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, v.String())
+
+	return
+}
@@ -0,0 +1,217 @@
+package gokwallet
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestHistory tests history retention.
+func TestHistory(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var p *Password
+	var depth int
+	var versions []*HistoryVersion
+	var fb *failBackend
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var exists bool
+	var future int64 = time.Now().Add(time.Hour).UnixNano()
+	var renamedFolder string = folderTest.String() + "_hist"
+	var rollbackFolder string = folderTest.String() + "_rollback"
+	var lastEntry string = "~last"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+
+	if err = e.wm.EnableHistory(2); err != nil {
+		t.Fatalf("failed to EnableHistory: %v", err)
+	}
+	if e.wm.HistoryDepth() != 2 {
+		t.Errorf("expected HistoryDepth 2, got %v", e.wm.HistoryDepth())
+	}
+
+	if p, err = e.f.WritePassword(passwordTest.String(), "v1"); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	for _, v := range []string{"v2", "v2", "v3", "v4"} {
+		if err = p.SetValue(v); err != nil {
+			t.Fatalf("failed to SetValue: %v", err)
+		}
+	}
+
+	// v1 was trimmed, and rewriting v2 didn't add a version.
+	if versions, err = p.History(); err != nil {
+		t.Fatalf("failed to get History: %v", err)
+	}
+	if len(versions) != 2 ||
+		historyValue(versions[0]) != "v3" || historyValue(versions[1]) != "v2" ||
+		versions[0].Type != KwalletdEnumTypePassword || versions[0].Replaced.Before(versions[1].Replaced) {
+		t.Fatalf("unexpected History: %#v", versions)
+	}
+
+	if err = p.RestoreVersion(versions[1].ID); err != nil {
+		t.Fatalf("failed to RestoreVersion: %v", err)
+	}
+	if p.Value != "v2" {
+		t.Errorf("expected restored value v2, got %#v", p.Value)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 2 || historyValue(versions[0]) != "v4" {
+		t.Errorf("replaced value not retained by RestoreVersion: %#v (err: %v)", versions, err)
+	}
+	if err = p.RestoreVersion("12345"); !errors.Is(err, ErrNoVersion) {
+		t.Errorf("expected ErrNoVersion, got %v", err)
+	}
+
+	// Renames carry history along.
+	if err = p.Rename(passwordTestRename.String()); err != nil {
+		t.Fatalf("failed to Rename: %v", err)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 2 {
+		t.Errorf("History not carried over by Rename: %#v (err: %v)", versions, err)
+	}
+	if err = e.f.Rename(renamedFolder); err != nil {
+		t.Fatalf("failed to Folder.Rename: %v", err)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 2 {
+		t.Errorf("History not carried over by Folder.Rename: %#v (err: %v)", versions, err)
+	}
+	if versions, err = e.f.History(passwordTest.String()); err != nil || len(versions) != 0 {
+		t.Errorf("stale History left behind: %#v (err: %v)", versions, err)
+	}
+
+	// Per-Folder depth.
+	if err = e.f.SetHistoryDepth(1); err != nil {
+		t.Fatalf("failed to SetHistoryDepth: %v", err)
+	}
+	if depth, err = e.f.HistoryDepth(); err != nil || depth != 1 {
+		t.Errorf("expected Folder depth 1, got %v (err: %v)", depth, err)
+	}
+	if err = p.SetValue("v5"); err != nil {
+		t.Fatalf("failed to SetValue: %v", err)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 1 || historyValue(versions[0]) != "v2" {
+		t.Errorf("Folder depth not honored: %#v (err: %v)", versions, err)
+	}
+	if err = e.f.SetHistoryDepth(HistoryDepthDefault); err != nil {
+		t.Fatalf("failed to reset SetHistoryDepth: %v", err)
+	}
+	if depth, err = e.f.HistoryDepth(); err != nil || depth != 2 {
+		t.Errorf("expected default depth 2, got %v (err: %v)", depth, err)
+	}
+
+	// Removal retains the last value.
+	if err = p.Delete(); err != nil {
+		t.Fatalf("failed to Delete: %v", err)
+	}
+	if versions, err = e.f.History(p.Name); err != nil || len(versions) != 2 || historyValue(versions[0]) != "v5" {
+		t.Errorf("removed value not retained: %#v (err: %v)", versions, err)
+	}
+	if err = e.f.RestoreVersion(p.Name, versions[0].ID); err != nil {
+		t.Fatalf("failed to RestoreVersion of a removed Password: %v", err)
+	}
+	if err = p.Update(); err != nil || p.Value != "v5" {
+		t.Errorf("expected v5 after restoring a removed Password, got %#v (err: %v)", p.Value, err)
+	}
+
+	if err = e.f.ClearHistory(p.Name); err != nil {
+		t.Fatalf("failed to ClearHistory: %v", err)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 0 {
+		t.Errorf("History not cleared: %#v (err: %v)", versions, err)
+	}
+
+	// Version IDs stay unique (and ordered) even if the clock doesn't move forward.
+	if err = untrackedBackend(e.wm.backend).WriteEntry(
+		e.w.handle, HistoryFolder, historyKey(e.f.Name, p.Name, fmt.Sprintf("%020d", future)),
+		KwalletdEnumTypePassword, []byte{}, appIdTest,
+	); err != nil {
+		t.Fatalf("failed to write HistoryVersion: %v", err)
+	}
+	if err = p.SetValue("v6"); err != nil {
+		t.Fatalf("failed to SetValue: %v", err)
+	}
+	if versions, err = p.History(); err != nil || len(versions) != 2 || versions[0].ID != fmt.Sprintf("%020d", future+1) {
+		t.Errorf("unexpected History after a version ID collision: %#v (err: %v)", versions, err)
+	}
+
+	// Removing a Folder replaces the history of its WalletItems with their last values.
+	if err = e.w.RemoveFolder(e.f.Name); err != nil {
+		t.Fatalf("failed to RemoveFolder: %v", err)
+	}
+	if versions, err = e.f.History(p.Name); err != nil || len(versions) != 1 || historyValue(versions[0]) != "v6" {
+		t.Errorf("unexpected History after RemoveFolder: %#v (err: %v)", versions, err)
+	}
+	if err = e.w.CreateFolder(e.f.Name); err != nil {
+		t.Fatalf("failed to CreateFolder: %v", err)
+	}
+	if err = e.f.RestoreVersion(p.Name, versions[0].ID); err != nil {
+		t.Fatalf("failed to RestoreVersion from a removed Folder: %v", err)
+	}
+
+	// A failed Folder.Rename doesn't leave the copied history behind.
+	if _, err = e.f.WritePassword(lastEntry, "z"); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	fb = newFailBackend(baseBackend(e.wm.backend))
+	fb.failAfter["WriteEntry"] = 2
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if err = wm.EnableHistory(2); err != nil {
+		t.Fatalf("failed to EnableHistory: %v", err)
+	}
+	if w, err = NewWallet(wm, e.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if f, err = NewFolder(w, renamedFolder, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Folder: %v", err)
+	}
+	if err = f.Rename(rollbackFolder); err == nil {
+		t.Errorf("expected an error from a failed Folder.Rename")
+	}
+	if f, err = NewFolder(e.w, rollbackFolder, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Folder: %v", err)
+	}
+	if versions, err = f.History(p.Name); err != nil || len(versions) != 0 {
+		t.Errorf("History left behind by a failed Folder.Rename: %#v (err: %v)", versions, err)
+	}
+	if versions, err = e.f.History(p.Name); err != nil || len(versions) != 1 {
+		t.Errorf("original History changed by a failed Folder.Rename: %#v (err: %v)", versions, err)
+	}
+
+	// Once the original Folder is gone, a failure removing its old history doesn't fail a Folder.Rename.
+	fb = newFailBackend(baseBackend(e.wm.backend))
+	fb.failAfter["RemoveEntry"] = 0
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if err = wm.EnableHistory(2); err != nil {
+		t.Fatalf("failed to EnableHistory: %v", err)
+	}
+	if w, err = NewWallet(wm, e.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if f, err = NewFolder(w, renamedFolder, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Folder: %v", err)
+	}
+	if err = f.Rename(folderTest.String()); err != nil {
+		t.Errorf("Folder.Rename failed after the point of no return: %v", err)
+	}
+	if exists, err = w.HasFolder(renamedFolder); err != nil || exists || f.Name != folderTest.String() {
+		t.Errorf("Folder not renamed (err: %v)", err)
+	}
+}
+
+// historyValue returns the value of a Password HistoryVersion.
+func historyValue(v *HistoryVersion) (s string) {
+
+	s, _ = qStringToString(v.Raw)
+
+	return
+}
@@ -0,0 +1,386 @@
+package gokwallet
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+/*
+	ParseItemPath parses a kwallet:// URI, or the same without the scheme, into an ItemPath. The syntax is:
+
+		kwallet://<wallet>/<folder>/<entry>[#<map key>]
+
+	<wallet> may be empty (e.g. kwallet:///Passwords/x) to refer to the local (default) Wallet.
+	Each part is percent-decoded, so a "/", "#", or "%" in a name must be escaped as %2F, %23, or %25 respectively
+	(spaces and other characters may be escaped but need not be).
+*/
+func ParseItemPath(uri string) (p *ItemPath, err error) {
+
+	var idx int
+	var parts []string
+	var rest string = uri
+	var prefix string = URIScheme + "://"
+
+	if strings.HasPrefix(rest, prefix) {
+		rest = rest[len(prefix):]
+	} else if strings.Contains(rest, "://") {
+		err = fmt.Errorf("%w: %#v: scheme must be %v", ErrInvalidPath, uri, URIScheme)
+		return
+	}
+
+	p = new(ItemPath)
+
+	if idx = strings.Index(rest, "#"); idx >= 0 {
+		if p.Key, err = url.PathUnescape(rest[idx+1:]); err != nil {
+			err = fmt.Errorf("%w: %#v: %v", ErrInvalidPath, uri, err)
+			p = nil
+			return
+		}
+		rest = rest[:idx]
+	}
+
+	if parts = strings.Split(rest, "/"); len(parts) != 3 {
+		err = fmt.Errorf("%w: %#v: expected <wallet>/<folder>/<entry>", ErrInvalidPath, uri)
+		p = nil
+		return
+	}
+
+	for i, s := range []*string{&p.Wallet, &p.Folder, &p.Entry} {
+		if *s, err = url.PathUnescape(parts[i]); err != nil {
+			err = fmt.Errorf("%w: %#v: %v", ErrInvalidPath, uri, err)
+			p = nil
+			return
+		}
+	}
+
+	if p.Folder == "" || p.Entry == "" {
+		err = fmt.Errorf("%w: %#v: the folder and entry must not be empty", ErrInvalidPath, uri)
+		p = nil
+		return
+	}
+
+	return
+}
+
+// String returns the canonical kwallet:// URI of an ItemPath.
+func (p *ItemPath) String() (uri string) {
+
+	uri = fmt.Sprintf(
+		"%v://%v/%v/%v", URIScheme, url.PathEscape(p.Wallet), url.PathEscape(p.Folder), url.PathEscape(p.Entry),
+	)
+
+	if p.Key != "" {
+		uri += "#" + url.PathEscape(p.Key)
+	}
+
+	return
+}
+
+/*
+	Resolve returns the WalletItem (a *Password, *Map, *Blob, or *UnknownItem, with its value fetched)
+	that a kwallet:// URI or item path refers to (see ParseItemPath).
+	If the URI has a Map key, the WalletItem must be a Map containing that key (ErrNoMapKey otherwise);
+	the *Map is returned (see WalletManager.ResolveString to get the key's value directly).
+	The Wallet is closed again before returning (it is reopened if the WalletItem is used further).
+*/
+func (wm *WalletManager) Resolve(uri string) (item WalletItem, err error) {
+
+	var p *ItemPath
+
+	if p, err = ParseItemPath(uri); err != nil {
+		return
+	}
+
+	if err = wm.withPathFolder(p, false, func(f *Folder) (err error) {
+		item, err = resolvePathItem(f, p)
+		return
+	}); err != nil {
+		item = nil
+		return
+	}
+
+	return
+}
+
+/*
+	ResolveString is like WalletManager.Resolve, but returns the value as a string, which is convenient for
+	referencing secrets from configuration: a Password's value, the value of the Map key, or a Blob's (or UnknownItem's) bytes.
+	A Map must be referenced with a key.
+*/
+func (wm *WalletManager) ResolveString(uri string) (value string, err error) {
+
+	var p *ItemPath
+
+	if p, err = ParseItemPath(uri); err != nil {
+		return
+	}
+
+	if err = wm.withPathFolder(p, false, func(f *Folder) (err error) {
+
+		var item WalletItem
+		var m map[string]string
+		var raw []byte
+
+		if item, err = resolvePathItem(f, p); err != nil {
+			return
+		}
+
+		switch i := item.(type) {
+		case *Password:
+			value, err = i.GetValue()
+		case *Map:
+			if p.Key == "" {
+				err = fmt.Errorf("%w: %v: a Map must be referenced with a #key", ErrInvalidPath, p.String())
+				return
+			}
+			if m, err = i.GetValue(); err == nil {
+				value = m[p.Key]
+			}
+		case *Blob:
+			if raw, err = i.GetValue(); err == nil {
+				value = string(raw)
+			}
+		case *UnknownItem:
+			if raw, err = i.GetValue(); err == nil {
+				value = string(raw)
+			}
+		}
+
+		return
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Put writes value to the WalletItem a kwallet:// URI or item path refers to (see ParseItemPath), creating the Folder
+	(and, as with opening any Wallet, the Wallet) if needed, and returns the WalletItem as Resolve would.
+	value may be:
+
+		string               a Password (or, with a Map key in the URI, that key of a Map, which is created if needed)
+		map[string]string    a Map
+		[]byte               a Blob
+
+	Any other type returns ErrPathValue.
+*/
+func (wm *WalletManager) Put(uri string, value interface{}) (item WalletItem, err error) {
+
+	var p *ItemPath
+
+	if p, err = ParseItemPath(uri); err != nil {
+		return
+	}
+
+	if err = wm.withPathFolder(p, true, func(f *Folder) (err error) {
+
+		var exists bool
+		var entryType kwalletdEnumType
+		var m map[string]string
+
+		switch v := value.(type) {
+		case string:
+			if p.Key == "" {
+				_, err = f.WritePassword(p.Entry, v)
+				break
+			}
+			m = make(map[string]string)
+			if exists, err = f.HasEntry(p.Entry); err != nil {
+				return
+			} else if exists {
+				if entryType, err = wm.Backend().EntryType(f.wallet.handle, f.Name, p.Entry, wm.AppID); err != nil {
+					return
+				} else if entryType != KwalletdEnumTypeMap {
+					err = fmt.Errorf("%w: %v is not a Map", ErrBackendEntryType, p.String())
+					return
+				}
+				if m, err = wm.Backend().ReadMap(f.wallet.handle, f.Name, p.Entry, wm.AppID); err != nil {
+					return
+				}
+			}
+			m[p.Key] = v
+			_, err = f.WriteMap(p.Entry, m)
+		case map[string]string:
+			if p.Key != "" {
+				err = fmt.Errorf("%w: %T with a Map key (%v)", ErrPathValue, value, p.String())
+				return
+			}
+			_, err = f.WriteMap(p.Entry, v)
+		case []byte:
+			if p.Key != "" {
+				err = fmt.Errorf("%w: %T with a Map key (%v)", ErrPathValue, value, p.String())
+				return
+			}
+			_, err = f.WriteBlob(p.Entry, v)
+		default:
+			err = fmt.Errorf("%w: %T", ErrPathValue, value)
+		}
+		if err != nil {
+			return
+		}
+
+		item, err = newPathItem(f, p.Entry)
+
+		return
+	}); err != nil {
+		item = nil
+		return
+	}
+
+	return
+}
+
+/*
+	withPathFolder calls fn with the Folder an ItemPath is in (without fetching its WalletItems),
+	closing the Wallet it opened for this once fn returns.
+	If create is true, the Folder is created if it does not exist; otherwise the Wallet and Folder must exist.
+*/
+func (wm *WalletManager) withPathFolder(p *ItemPath, create bool, fn func(f *Folder) (err error)) (err error) {
+
+	var exists bool
+	var walletNames []string
+	var walletName string = p.Wallet
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if walletName == "" {
+		if walletName, err = wm.Backend().LocalWallet(); err != nil {
+			return
+		}
+	}
+
+	if !create {
+		if walletNames, err = wm.WalletNames(); err != nil {
+			return
+		}
+		for _, wn := range walletNames {
+			if wn == walletName {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			err = fmt.Errorf("%w: %#v", ErrNoWallet, walletName)
+			return
+		}
+	}
+
+	if err = wm.withWallet(walletName, func(w *Wallet) (err error) {
+
+		var f *Folder
+
+		if f, err = w.pathFolder(p, create); err != nil {
+			return
+		}
+
+		err = fn(f)
+
+		return
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	pathFolder returns the Folder an ItemPath is in, in Wallet w (without fetching its WalletItems).
+	If create is true, the Folder is created if it does not exist; otherwise it must exist.
+*/
+func (w *Wallet) pathFolder(p *ItemPath, create bool) (f *Folder, err error) {
+
+	var exists bool
+
+	// Reserved Folders are gokwallet's own bookkeeping, not something to address.
+	if exists, err = w.HasFolder(p.Folder); err != nil {
+		return
+	} else if isReservedFolder(p.Folder) || (!exists && !create) {
+		err = fmt.Errorf("%w: %#v/%#v", ErrBackendNoFolder, w.Name, p.Folder)
+		return
+	} else if !exists {
+		if err = w.CreateFolder(p.Folder); err != nil {
+			return
+		}
+	}
+
+	if f, err = NewFolder(w, p.Folder, &RecurseOpts{}); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	resolvePathItem returns the WalletItem an ItemPath refers to in Folder f (see WalletManager.Resolve),
+	checking its Map key (if any).
+*/
+func resolvePathItem(f *Folder, p *ItemPath) (item WalletItem, err error) {
+
+	var m *Map
+	var mapValue map[string]string
+	var ok bool
+
+	if item, err = newPathItem(f, p.Entry); err != nil {
+		return
+	}
+
+	if p.Key != "" {
+		if m, ok = item.(*Map); !ok {
+			item = nil
+			err = fmt.Errorf("%w: %v is not a Map", ErrBackendEntryType, p.String())
+			return
+		}
+		if mapValue, err = m.GetValue(); err != nil {
+			item = nil
+			return
+		}
+		if _, ok = mapValue[p.Key]; !ok {
+			item = nil
+			err = fmt.Errorf("%w: %v", ErrNoMapKey, p.String())
+			return
+		}
+	}
+
+	return
+}
+
+// newPathItem returns WalletItem entryName in Folder f, of the appropriate type and with its value fetched.
+func newPathItem(f *Folder, entryName string) (item WalletItem, err error) {
+
+	var exists bool
+	var entryType kwalletdEnumType
+	var r *RecurseOpts = &RecurseOpts{AllWalletItems: true}
+
+	if exists, err = f.HasEntry(entryName); err != nil {
+		return
+	} else if !exists {
+		err = fmt.Errorf("%w: %#v/%#v/%#v", ErrBackendNoEntry, f.wallet.Name, f.Name, entryName)
+		return
+	}
+
+	if entryType, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	switch entryType {
+	case KwalletdEnumTypePassword:
+		item, err = NewPassword(f, entryName, r)
+	case KwalletdEnumTypeMap:
+		item, err = NewMap(f, entryName, r)
+	case KwalletdEnumTypeStream:
+		item, err = NewBlob(f, entryName, r)
+	default:
+		item, err = NewUnknownItem(f, entryName, r)
+	}
+	if err != nil {
+		item = nil
+		return
+	}
+
+	return
+}
@@ -0,0 +1,136 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestParseItemPath tests parsing and formatting kwallet:// URIs.
+func TestParseItemPath(t *testing.T) {
+
+	var err error
+	var p *ItemPath
+	var again *ItemPath
+
+	for uri, expected := range map[string]ItemPath{
+		"kwallet://kdewallet/Passwords/x":          {Wallet: "kdewallet", Folder: "Passwords", Entry: "x"},
+		"kwallet:///Network Management/wifi#psk":   {Folder: "Network Management", Entry: "wifi", Key: "psk"},
+		"kdewallet/Form%20Data/a%2Fb%23c#k%23ey":   {Wallet: "kdewallet", Folder: "Form Data", Entry: "a/b#c", Key: "k#ey"},
+		"kwallet://other/Passwords/100%25%20legit": {Wallet: "other", Folder: "Passwords", Entry: "100% legit"},
+	} {
+		if p, err = ParseItemPath(uri); err != nil {
+			t.Errorf("failed to parse %#v: %v", uri, err)
+			continue
+		}
+		if *p != expected {
+			t.Errorf("%#v: expected %#v, got %#v", uri, expected, *p)
+		}
+		if again, err = ParseItemPath(p.String()); err != nil || *again != *p {
+			t.Errorf("%#v: String() %#v does not round-trip: %#v (err: %v)", uri, p.String(), again, err)
+		}
+	}
+
+	for _, uri := range []string{
+		"https://kdewallet/Passwords/x",
+		"kwallet://kdewallet/Passwords",
+		"kwallet://kdewallet/Passwords/x/y",
+		"kwallet://kdewallet//x",
+		"kwallet://kdewallet/Passwords/%zz",
+	} {
+		if _, err = ParseItemPath(uri); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%#v: expected ErrInvalidPath, got %v", uri, err)
+		}
+	}
+}
+
+// TestResolve tests WalletManager.Resolve, WalletManager.ResolveString, and WalletManager.Put.
+func TestResolve(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var ok bool
+	var s string
+	var item WalletItem
+	var p *Password
+	var m *Map
+	var b *Blob
+	var base string
+	var mem *MemoryBackend
+	var handles int
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+
+	base = "kwallet://" + e.w.Name + "/" + e.f.Name + "/"
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+
+	if item, err = e.wm.Resolve(base + passwordTest.String()); err != nil {
+		t.Fatalf("failed to Resolve a Password: %v", err)
+	}
+	if p, ok = item.(*Password); !ok || p.Value != testPassword {
+		t.Errorf("unexpected Password: %#v", item)
+	}
+	if item, err = e.wm.Resolve(base + blobTest.String()); err != nil {
+		t.Fatalf("failed to Resolve a Blob: %v", err)
+	}
+	if b, ok = item.(*Blob); !ok || string(b.Value) != string(testBytes) {
+		t.Errorf("unexpected Blob: %#v", item)
+	}
+	for k, v := range testMap {
+		if s, err = e.wm.ResolveString(base + mapTest.String() + "#" + k); err != nil || s != v {
+			t.Errorf("expected %#v for Map key %#v, got %#v (err: %v)", v, k, s, err)
+		}
+	}
+
+	if _, err = e.wm.Resolve(base + mapTest.String() + "#nosuchkey"); !errors.Is(err, ErrNoMapKey) {
+		t.Errorf("expected ErrNoMapKey, got %v", err)
+	}
+	if _, err = e.wm.Resolve(base + passwordTest.String() + "#key"); !errors.Is(err, ErrBackendEntryType) {
+		t.Errorf("expected ErrBackendEntryType, got %v", err)
+	}
+	if _, err = e.wm.Resolve(base + "nosuchentry"); !errors.Is(err, ErrBackendNoEntry) {
+		t.Errorf("expected ErrBackendNoEntry, got %v", err)
+	}
+	if _, err = e.wm.Resolve("kwallet://nosuchwallet/f/e"); !errors.Is(err, ErrNoWallet) {
+		t.Errorf("expected ErrNoWallet, got %v", err)
+	}
+	if _, err = e.wm.Resolve("kwallet:///" + MetadataFolder + "/e"); !errors.Is(err, ErrBackendNoFolder) {
+		t.Errorf("expected ErrBackendNoFolder for a reserved Folder, got %v", err)
+	}
+
+	// Put, to the default Wallet and a new Folder.
+	if _, err = e.wm.Put("kwallet:///Network Management/wifi#psk", "hunter2"); err != nil {
+		t.Fatalf("failed to Put a Map key: %v", err)
+	}
+	if item, err = e.wm.Put("kwallet:///Network Management/wifi#ssid", "home"); err != nil {
+		t.Fatalf("failed to Put a second Map key: %v", err)
+	}
+	if m, ok = item.(*Map); !ok || len(m.Value) != 2 || m.Value["psk"] != "hunter2" || m.Value["ssid"] != "home" {
+		t.Errorf("unexpected Map after Put: %#v", item)
+	}
+	if _, err = e.wm.Put(base+"token", "abc"); err != nil {
+		t.Fatalf("failed to Put a Password: %v", err)
+	}
+	if s, err = e.wm.ResolveString(base + "token"); err != nil || s != "abc" {
+		t.Errorf("expected Put Password, got %#v (err: %v)", s, err)
+	}
+	if _, err = e.wm.Put(base+"blob", []byte("raw")); err != nil {
+		t.Fatalf("failed to Put a Blob: %v", err)
+	}
+	if _, err = e.wm.Put(base+"num", 42); !errors.Is(err, ErrPathValue) {
+		t.Errorf("expected ErrPathValue, got %v", err)
+	}
+	if _, err = e.wm.Put(base+"token#key", "v"); !errors.Is(err, ErrBackendEntryType) {
+		t.Errorf("expected ErrBackendEntryType putting a Map key into a Password, got %v", err)
+	}
+
+	// The Wallets opened to resolve paths are closed again.
+	if len(mem.handles) != handles {
+		t.Errorf("Resolve/Put leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+}
@@ -0,0 +1,473 @@
+package gokwallet
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+/*
+	ExportKDBX writes a Wallet to out as a KeePass (KDBX 4) database locked with password (which must not be empty),
+	which KeePassXC and KeePass 2.x can open. opts may be nil to use the defaults (see KDBXOpts).
+
+	The root group is named after the Wallet, and each Folder is a group in it. Each WalletItem is an entry titled with its name:
+	a Password is an entry with its password field set, a Map is an entry with a (protected) custom string field for each key,
+	and a Blob (or UnknownItem) is an entry with an attachment named after it.
+	The type of each WalletItem is also recorded in its entry's custom data (see KDBXTypeKey),
+	so that Wallet.ImportKDBX imports it as the same type. A Map with a "Title" key cannot be exported.
+*/
+func (w *Wallet) ExportKDBX(out io.Writer, password []byte, opts *KDBXOpts) (err error) {
+
+	var ws *WalletSnapshot
+	var ew *ExportWallet
+	var file *kdbxXMLFile
+	var binaries [][]byte
+	var doc []byte
+	var hdr *kdbxHeader
+	var realOpts KDBXOpts
+
+	if len(password) == 0 {
+		err = ErrKDBXPassword
+		return
+	}
+
+	if opts != nil {
+		realOpts = *opts
+	}
+	if realOpts.Argon2Memory == 0 {
+		realOpts.Argon2Memory = DefaultKDBXArgon2Memory
+	}
+	if realOpts.Argon2Iterations == 0 {
+		realOpts.Argon2Iterations = DefaultKDBXArgon2Iterations
+	}
+	if realOpts.Argon2Parallelism == 0 {
+		realOpts.Argon2Parallelism = DefaultKDBXArgon2Parallelism
+	}
+
+	if ws, err = w.Snapshot(); err != nil {
+		return
+	}
+	if ew, err = exportWallet(ws); err != nil {
+		return
+	}
+
+	if file, binaries, err = kdbxFromExport(ew); err != nil {
+		return
+	}
+
+	if doc, err = xml.MarshalIndent(file, "", "\t"); err != nil {
+		return
+	}
+	defer wipeBytes(doc)
+
+	if hdr, err = newKDBXHeader(&realOpts); err != nil {
+		return
+	}
+
+	if err = writeKDBX(out, password, hdr, doc, binaries); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ImportKDBX imports a KeePass (KDBX 4) database, unlocked with password, into Wallet w
+	(see ParseKDBX for how it is converted, and Wallet.Import for opts). Like Wallet.Import, it writes
+	each entry with Folder.WritePassword, Folder.WriteMap, or Folder.WriteBlob.
+*/
+func (w *Wallet) ImportKDBX(in io.Reader, password []byte, opts *ImportOpts) (results []*ImportResult, err error) {
+
+	var doc *ExportDocument
+
+	if doc, err = ParseKDBX(in, password); err != nil {
+		return
+	}
+
+	if results, err = w.Import(doc, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ParseKDBX reads a KeePass (KDBX 4) database from in, unlocking it with password, and returns it as an ExportDocument
+	with a single ExportWallet named after the database, which can be imported (e.g. with Wallet.Import).
+	Only password-protected databases are supported (not key files); the cipher may be AES-256, ChaCha20, or Twofish,
+	and the KDF Argon2d, Argon2id, or AES-KDF.
+
+	Each group becomes an ExportFolder named by its path below the root group (e.g. "Internet/Email"),
+	and entries directly in the root group go in an ExportFolder named after the root group.
+	The recycle bin and entry histories are skipped. Each entry becomes an ExportEntry named after its title
+	(made unique within its ExportFolder with a " (1)", " (2)", etc. suffix), with its type taken from its custom data
+	(see KDBXTypeKey) if it was exported by Wallet.ExportKDBX. Otherwise:
+
+		* an entry with a single attachment and no other (non-empty) fields is a Blob,
+		* an entry with no (non-empty) fields other than its password is a Password, and
+		* any other entry is a Map of its non-empty fields (other than its title);
+		  each of its attachments becomes a Blob named "<title>: <attachment name>".
+*/
+func ParseKDBX(in io.Reader, password []byte) (doc *ExportDocument, err error) {
+
+	var raw []byte
+	var binaries [][]byte
+	var file *kdbxXMLFile = new(kdbxXMLFile)
+	var ew *ExportWallet
+
+	if raw, binaries, err = readKDBX(in, password); err != nil {
+		return
+	}
+	defer wipeBytes(raw)
+
+	if err = xml.Unmarshal(raw, file); err != nil {
+		err = fmt.Errorf("%w: %v", ErrKDBXCorrupt, err)
+		return
+	}
+
+	if ew, err = kdbxToExport(file, binaries); err != nil {
+		return
+	}
+
+	doc = newExportDocument("")
+	doc.Wallets = append(doc.Wallets, ew)
+
+	if err = doc.validate(); err != nil {
+		doc = nil
+		return
+	}
+
+	return
+}
+
+// kdbxFromExport converts an ExportWallet to the XML document of a KeePass database and its attachments.
+func kdbxFromExport(ew *ExportWallet) (file *kdbxXMLFile, binaries [][]byte, err error) {
+
+	var g *kdbxXMLGroup
+	var e *kdbxXMLEntry
+	var keys []string
+	var now string = kdbxTime(time.Now())
+	var root *kdbxXMLGroup = newKDBXGroup(ew.Name, now)
+
+	binaries = make([][]byte, 0)
+
+	for _, ef := range ew.Folders {
+		g = newKDBXGroup(ef.Name, now)
+		for _, ee := range ef.Entries {
+			e = &kdbxXMLEntry{
+				UUID:  kdbxUUID(),
+				Times: newKDBXTimes(now),
+				Strings: []*kdbxXMLString{
+					{Key: kdbxFieldTitle, Value: kdbxXMLValue{Value: ee.Name}},
+				},
+				CustomData: &kdbxXMLCustomData{
+					Items: []*kdbxXMLItem{{Key: KDBXTypeKey, Value: ee.Type.String()}},
+				},
+			}
+			switch ee.Type {
+			case KwalletdEnumTypePassword:
+				e.Strings = append(e.Strings, &kdbxXMLString{
+					Key:   kdbxFieldPassword,
+					Value: kdbxXMLValue{Protected: "True", Value: ee.Password},
+				})
+			case KwalletdEnumTypeMap:
+				keys = make([]string, 0, len(ee.Map))
+				for k := range ee.Map {
+					if k == kdbxFieldTitle {
+						err = fmt.Errorf("%w: %#v/%#v/%#v: map key %#v", ErrKDBXUnsupported, ew.Name, ef.Name, ee.Name, k)
+						return
+					}
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					e.Strings = append(e.Strings, &kdbxXMLString{
+						Key:   k,
+						Value: kdbxXMLValue{Protected: "True", Value: ee.Map[k]},
+					})
+				}
+			default:
+				e.Binaries = append(e.Binaries, &kdbxXMLBinary{
+					Key:   ee.Name,
+					Value: kdbxXMLBinaryValue{Ref: len(binaries)},
+				})
+				binaries = append(binaries, ee.Data)
+			}
+			g.Entries = append(g.Entries, e)
+		}
+		root.Groups = append(root.Groups, g)
+	}
+
+	file = &kdbxXMLFile{
+		Meta: kdbxXMLMeta{
+			Generator:         "gokwallet",
+			DatabaseName:      ew.Name,
+			RecycleBinEnabled: "False",
+		},
+		Root: kdbxXMLRoot{
+			Group: root,
+		},
+	}
+
+	return
+}
+
+// kdbxToExport converts the XML document of a KeePass database and its attachments to an ExportWallet (see ParseKDBX).
+func kdbxToExport(file *kdbxXMLFile, binaries [][]byte) (ew *ExportWallet, err error) {
+
+	var root *kdbxXMLGroup = file.Root.Group
+	var folders map[string]*ExportFolder = make(map[string]*ExportFolder)
+	var names map[string]map[string]bool = make(map[string]map[string]bool)
+	var walk func(g *kdbxXMLGroup, folderName string) (err error)
+
+	if root == nil {
+		err = fmt.Errorf("%w: no root group", ErrKDBXCorrupt)
+		return
+	}
+
+	ew = &ExportWallet{
+		Name:    file.Meta.DatabaseName,
+		Folders: make([]*ExportFolder, 0),
+	}
+	if ew.Name == "" {
+		ew.Name = kdbxGroupName(root)
+	}
+
+	walk = func(g *kdbxXMLGroup, folderName string) (err error) {
+
+		var ef *ExportFolder
+		var found []*ExportEntry
+
+		if g.UUID != "" && g.UUID == file.Meta.RecycleBinUUID {
+			return
+		}
+
+		for _, e := range g.Entries {
+			if found, err = kdbxEntryToExport(e, binaries); err != nil {
+				err = fmt.Errorf("folder %#v: %w", folderName, err)
+				return
+			}
+			if len(found) == 0 {
+				continue
+			}
+			if ef = folders[folderName]; ef == nil {
+				ef = &ExportFolder{
+					Name:    folderName,
+					Entries: make([]*ExportEntry, 0),
+				}
+				folders[folderName] = ef
+				names[folderName] = make(map[string]bool)
+				ew.Folders = append(ew.Folders, ef)
+			}
+			for _, ee := range found {
+				ee.Name = uniqueName(ee.Name, names[folderName])
+				names[folderName][ee.Name] = true
+				ef.Entries = append(ef.Entries, ee)
+			}
+		}
+
+		for _, sub := range g.Groups {
+			// Groups directly in the root group aren't prefixed with its name.
+			if g == root {
+				err = walk(sub, kdbxGroupName(sub))
+			} else {
+				err = walk(sub, folderName+"/"+kdbxGroupName(sub))
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		return
+	}
+
+	if err = walk(root, kdbxGroupName(root)); err != nil {
+		ew = nil
+		return
+	}
+
+	return
+}
+
+// kdbxEntryToExport converts a KeePass entry to one or more ExportEntry (see ParseKDBX).
+func kdbxEntryToExport(e *kdbxXMLEntry, binaries [][]byte) (entries []*ExportEntry, err error) {
+
+	var ee *ExportEntry
+	var name string
+	var typed bool
+	var fields map[string]string = make(map[string]string)
+	var allFields map[string]string = make(map[string]string)
+	var attachments []*kdbxXMLBinary = make([]*kdbxXMLBinary, 0, len(e.Binaries))
+
+	for _, s := range e.Strings {
+		switch {
+		case s.Key == kdbxFieldTitle:
+			name = s.Value.Value
+		case s.Value.Value != "":
+			fields[s.Key] = s.Value.Value
+			allFields[s.Key] = s.Value.Value
+		case !kdbxStandardField(s.Key):
+			// KeePass adds the standard fields (empty) to every entry, but an empty custom field is a Map key.
+			allFields[s.Key] = s.Value.Value
+		}
+	}
+	if name == "" {
+		name = kdbxUntitled
+	}
+
+	for _, b := range e.Binaries {
+		if b.Value.Ref < 0 || b.Value.Ref >= len(binaries) {
+			err = fmt.Errorf("%w: entry %#v: attachment %#v has a bad reference", ErrKDBXCorrupt, name, b.Key)
+			return
+		}
+		attachments = append(attachments, b)
+	}
+
+	ee = &ExportEntry{
+		Name: name,
+	}
+
+	if e.CustomData != nil {
+		for _, item := range e.CustomData.Items {
+			if item.Key == KDBXTypeKey {
+				typed = ee.Type.UnmarshalText([]byte(item.Value)) == nil
+			}
+		}
+	}
+
+	if !typed {
+		switch {
+		case len(attachments) == 1 && len(fields) == 0:
+			ee.Type = KwalletdEnumTypeStream
+		case len(attachments) == 0 && (len(fields) == 0 || (len(fields) == 1 && fields[kdbxFieldPassword] != "")):
+			ee.Type = KwalletdEnumTypePassword
+		default:
+			ee.Type = KwalletdEnumTypeMap
+		}
+	}
+
+	switch ee.Type {
+	case KwalletdEnumTypePassword:
+		ee.Password = fields[kdbxFieldPassword]
+	case KwalletdEnumTypeMap:
+		ee.Map = fields
+		if typed {
+			ee.Map = allFields
+		} else {
+			for _, b := range attachments {
+				entries = append(entries, &ExportEntry{
+					Name: fmt.Sprintf("%v: %v", name, b.Key),
+					Type: KwalletdEnumTypeStream,
+					Data: copyBytes(binaries[b.Value.Ref]),
+				})
+			}
+		}
+	default:
+		if len(attachments) != 0 {
+			ee.Data = copyBytes(binaries[attachments[0].Value.Ref])
+		}
+	}
+
+	entries = append([]*ExportEntry{ee}, entries...)
+
+	return
+}
+
+// kdbxStandardField returns true if key is one of the standard KeePass entry string fields.
+func kdbxStandardField(key string) (isStandard bool) {
+
+	switch key {
+	case kdbxFieldTitle, kdbxFieldUserName, kdbxFieldPassword, kdbxFieldURL, kdbxFieldNotes:
+		isStandard = true
+	}
+
+	return
+}
+
+// kdbxGroupName returns the name of a group, or kdbxDefaultFolder if it has none.
+func kdbxGroupName(g *kdbxXMLGroup) (name string) {
+
+	if name = g.Name; name == "" {
+		name = kdbxDefaultFolder
+	}
+
+	return
+}
+
+// newKDBXGroup returns a new (empty) KeePass group named name, created at now (see kdbxTime).
+func newKDBXGroup(name, now string) (g *kdbxXMLGroup) {
+
+	g = &kdbxXMLGroup{
+		UUID:    kdbxUUID(),
+		Name:    name,
+		Times:   newKDBXTimes(now),
+		Entries: make([]*kdbxXMLEntry, 0),
+		Groups:  make([]*kdbxXMLGroup, 0),
+	}
+
+	return
+}
+
+// newKDBXTimes returns the times of a new KeePass group or entry created at now (see kdbxTime).
+func newKDBXTimes(now string) (t *kdbxXMLTimes) {
+
+	t = &kdbxXMLTimes{
+		CreationTime:         now,
+		LastModificationTime: now,
+		LastAccessTime:       now,
+		ExpiryTime:           now,
+		Expires:              "False",
+		LocationChanged:      now,
+	}
+
+	return
+}
+
+// kdbxUUID returns a new random (base64-encoded) KeePass UUID.
+func kdbxUUID() (uuid string) {
+
+	var b [16]byte
+
+	// crypto/rand.Read doesn't fail on supported platforms.
+	_, _ = rand.Read(b[:])
+	uuid = base64.StdEncoding.EncodeToString(b[:])
+
+	return
+}
+
+// kdbxTime returns t as a KDBX 4 time: base64-encoded little-endian seconds since 0001-01-01 UTC.
+func kdbxTime(t time.Time) (s string) {
+
+	var b [8]byte
+
+	binary.LittleEndian.PutUint64(b[:], uint64(t.Unix()+kdbxTimeEpoch))
+	s = base64.StdEncoding.EncodeToString(b[:])
+
+	return
+}
+
+// uniqueName returns name, or the first of "name (1)", "name (2)", etc. that is not in taken.
+func uniqueName(name string, taken map[string]bool) (unique string) {
+
+	unique = name
+
+	for idx := 1; taken[unique]; idx++ {
+		unique = fmt.Sprintf("%v (%d)", name, idx)
+	}
+
+	return
+}
+
+// copyBytes returns a copy of b.
+func copyBytes(b []byte) (c []byte) {
+
+	c = make([]byte, len(b))
+	copy(c, b)
+
+	return
+}
@@ -0,0 +1,336 @@
+package gokwallet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// kdbxTestOpts makes test databases quick to open.
+var kdbxTestOpts *KDBXOpts = &KDBXOpts{
+	Argon2Memory:      1 << 20,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+}
+
+// kdbxForeignDoc is a KeePass XML document like one KeePassXC writes (not exported by gokwallet).
+const kdbxForeignDoc string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<Generator>KeePassXC</Generator>
+		<DatabaseName>Personal</DatabaseName>
+		<RecycleBinEnabled>True</RecycleBinEnabled>
+		<RecycleBinUUID>cmVjeWNsZWJpbmdyb3VwMQ==</RecycleBinUUID>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdGdyb3VwMDAwMDAwMQ==</UUID>
+			<Name>Root</Name>
+			<Entry>
+				<UUID>ZW50cnkwMDAwMDAwMDAwMQ==</UUID>
+				<String><Key>Notes</Key><Value/></String>
+				<String><Key>Password</Key><Value Protected="True">hunter2</Value></String>
+				<String><Key>Title</Key><Value>Router</Value></String>
+				<String><Key>URL</Key><Value/></String>
+				<String><Key>UserName</Key><Value/></String>
+				<History>
+					<Entry>
+						<UUID>ZW50cnkwMDAwMDAwMDAwMQ==</UUID>
+						<String><Key>Password</Key><Value Protected="True">old password</Value></String>
+						<String><Key>Title</Key><Value>Router</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Entry>
+				<UUID>ZW50cnkwMDAwMDAwMDAwMg==</UUID>
+				<String><Key>Notes</Key><Value/></String>
+				<String><Key>PIN</Key><Value Protected="True">1234</Value></String>
+				<String><Key>Password</Key><Value Protected="True">pw &amp; more</Value></String>
+				<String><Key>Title</Key><Value>Router</Value></String>
+				<String><Key>UserName</Key><Value>admin</Value></String>
+				<Binary><Key>cert.pem</Key><Value Ref="0"/></Binary>
+			</Entry>
+			<Group>
+				<UUID>Z3JvdXAwMDAwMDAwMDAwMQ==</UUID>
+				<Name>Internet</Name>
+				<Entry>
+					<UUID>ZW50cnkwMDAwMDAwMDAwMw==</UUID>
+					<String><Key>Password</Key><Value Protected="True"/></String>
+					<String><Key>Title</Key><Value>Key file</Value></String>
+					<Binary><Key>id_ed25519</Key><Value Ref="1"/></Binary>
+				</Entry>
+				<Group>
+					<UUID>Z3JvdXAwMDAwMDAwMDAwMg==</UUID>
+					<Name>Email</Name>
+					<Entry>
+						<UUID>ZW50cnkwMDAwMDAwMDAwNA==</UUID>
+						<String><Key>Password</Key><Value Protected="True">mail</Value></String>
+						<String><Key>Title</Key><Value/></String>
+					</Entry>
+				</Group>
+			</Group>
+			<Group>
+				<UUID>cmVjeWNsZWJpbmdyb3VwMQ==</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<UUID>ZW50cnkwMDAwMDAwMDAwNQ==</UUID>
+					<String><Key>Password</Key><Value Protected="True">deleted</Value></String>
+					<String><Key>Title</Key><Value>Gone</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>
+`
+
+// TestKDBX tests exporting a Wallet to a KeePass database and importing it back.
+func TestKDBX(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var e2 *testEnv
+	var buf bytes.Buffer
+	var db []byte
+	var doc *ExportDocument
+	var doc2 *ExportDocument
+	var results []*ImportResult
+	var fb *failBackend
+	var wm *WalletManager
+	var w *Wallet
+	var password []byte = []byte("correct horse battery staple")
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+	if _, err = e.wm.Put("kwallet://"+e.w.Name+"/Other/entry", "other"); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+
+	if err = e.w.ExportKDBX(&buf, nil, kdbxTestOpts); !errors.Is(err, ErrKDBXPassword) {
+		t.Errorf("expected ErrKDBXPassword, got %v", err)
+	}
+	if err = e.w.ExportKDBX(&buf, password, kdbxTestOpts); err != nil {
+		t.Fatalf("failed to ExportKDBX: %v", err)
+	}
+	db = buf.Bytes()
+	if bytes.Contains(db, []byte(testPassword)) || bytes.Contains(db, testBytes) {
+		t.Errorf("KeePass database contains plaintext")
+	}
+
+	if _, err = ParseKDBX(bytes.NewReader(db), []byte("wrong")); !errors.Is(err, ErrKDBXCredentials) {
+		t.Errorf("expected ErrKDBXCredentials, got %v", err)
+	}
+	if _, err = ParseKDBX(bytes.NewReader([]byte("not a database")), password); !errors.Is(err, ErrKDBXFormat) {
+		t.Errorf("expected ErrKDBXFormat, got %v", err)
+	}
+	db[len(db)-40] ^= 0xff
+	if _, err = ParseKDBX(bytes.NewReader(db), password); !errors.Is(err, ErrKDBXCorrupt) {
+		t.Errorf("expected ErrKDBXCorrupt for a modified database, got %v", err)
+	}
+	db[len(db)-40] ^= 0xff
+
+	if e2, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting second test env: %v", err)
+	}
+	// Entries are written as typed WalletItems (e.g. Folder.WritePassword), not as raw values.
+	fb = newFailBackend(e2.wm.Backend())
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if w, err = NewWallet(wm, e2.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if results, err = w.ImportKDBX(bytes.NewReader(db), password, nil); err != nil {
+		t.Fatalf("failed to ImportKDBX: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("expected 5 import results, got %d", len(results))
+	}
+	if fb.calls["WritePassword"] != 2 || fb.calls["WriteMap"] != 1 {
+		t.Errorf("unexpected writes importing a KeePass database: %v", fb.calls)
+	}
+
+	// Every WalletItem comes back with the same type and value.
+	if doc, err = e.w.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if doc2, err = e2.w.Export(); err != nil {
+		t.Fatalf("failed to Export: %v", err)
+	}
+	if !reflect.DeepEqual(doc.Wallets[0].Folders, doc2.Wallets[0].Folders) {
+		t.Errorf("KeePass round trip mismatch")
+	}
+
+	// Conflicts are handled as with Wallet.Import.
+	if results, err = e2.w.ImportKDBX(bytes.NewReader(db), password, &ImportOpts{Policy: ConflictSkip}); err != nil {
+		t.Fatalf("failed to ImportKDBX again: %v", err)
+	}
+	for _, res := range results {
+		if !res.Skipped {
+			t.Errorf("expected %#v to be skipped", res.Entry)
+		}
+	}
+
+	if _, err = e.f.WriteMap("titled", map[string]string{"Title": "x"}); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if err = e.w.ExportKDBX(&buf, password, kdbxTestOpts); !errors.Is(err, ErrKDBXUnsupported) {
+		t.Errorf("expected ErrKDBXUnsupported for a Map with a Title key, got %v", err)
+	}
+}
+
+// TestKDBXForeign tests importing a KeePass database not written by gokwallet, with each supported cipher and KDF.
+func TestKDBXForeign(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var hdr *kdbxHeader
+	var buf bytes.Buffer
+	var doc *ExportDocument
+	var got map[string]*ExportEntry
+	var folders []string
+	var password []byte = []byte("password")
+	var binaries [][]byte = [][]byte{[]byte("-----BEGIN CERTIFICATE-----"), testBytes}
+	var expected map[string]*ExportEntry = map[string]*ExportEntry{
+		"Root/Router": {Name: "Router", Type: KwalletdEnumTypePassword, Password: "hunter2"},
+		"Root/Router (1)": {
+			Name: "Router (1)",
+			Type: KwalletdEnumTypeMap,
+			Map:  map[string]string{"PIN": "1234", "Password": "pw & more", "UserName": "admin"},
+		},
+		"Root/Router: cert.pem":   {Name: "Router: cert.pem", Type: KwalletdEnumTypeStream, Data: binaries[0]},
+		"Internet/Key file":       {Name: "Key file", Type: KwalletdEnumTypeStream, Data: binaries[1]},
+		"Internet/Email/Untitled": {Name: "Untitled", Type: KwalletdEnumTypePassword, Password: "mail"},
+	}
+
+	for name, setup := range map[string]func(h *kdbxHeader){
+		"AES-256/Argon2id": func(h *kdbxHeader) {
+			h.compression = kdbxCompressNone
+		},
+		"ChaCha20/Argon2d": func(h *kdbxHeader) {
+			h.cipherID = kdbxCipherChaCha20
+			h.iv = make([]byte, 12)
+			h.kdf[kdbxKDFUUID] = kdbxUUIDBytes(kdbxKDFArgon2d)
+		},
+		"Twofish/AES-KDF": func(h *kdbxHeader) {
+			h.cipherID = kdbxCipherTwofish
+			h.kdf = kdbxVariantDict{
+				kdbxKDFUUID:   kdbxUUIDBytes(kdbxKDFAES),
+				kdbxKDFRounds: uint64(1000),
+				kdbxKDFSeed:   bytes.Repeat([]byte{0x5a}, 32),
+			}
+		},
+	} {
+		buf.Reset()
+		if hdr, err = newKDBXHeader(kdbxTestOpts); err != nil {
+			t.Fatalf("%v: failed to create header: %v", name, err)
+		}
+		setup(hdr)
+		if err = writeKDBX(&buf, password, hdr, []byte(kdbxForeignDoc), binaries); err != nil {
+			t.Fatalf("%v: failed to write database: %v", name, err)
+		}
+		if bytes.Contains(buf.Bytes(), []byte("hunter2")) {
+			t.Errorf("%v: database contains plaintext", name)
+		}
+		if doc, err = ParseKDBX(bytes.NewReader(buf.Bytes()), password); err != nil {
+			t.Fatalf("%v: failed to ParseKDBX: %v", name, err)
+		}
+		if doc.Wallets[0].Name != "Personal" {
+			t.Errorf("%v: unexpected wallet name %#v", name, doc.Wallets[0].Name)
+		}
+		got = make(map[string]*ExportEntry)
+		for _, ef := range doc.Wallets[0].Folders {
+			for _, ee := range ef.Entries {
+				got[ef.Name+"/"+ee.Name] = ee
+			}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%v: unexpected entries:\n%#v\nexpected:\n%#v", name, got, expected)
+		}
+	}
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if _, err = e.w.ImportKDBX(bytes.NewReader(buf.Bytes()), password, nil); err != nil {
+		t.Fatalf("failed to ImportKDBX: %v", err)
+	}
+	if folders, err = e.w.ListFolders(); err != nil {
+		t.Fatalf("failed to ListFolders: %v", err)
+	}
+	sort.Strings(folders)
+	if !reflect.DeepEqual(folders, []string{"Internet", "Internet/Email", "Root"}) {
+		t.Errorf("unexpected Folders: %v", folders)
+	}
+
+	// A crafted AES-KDF round count is rejected rather than run.
+	if _, err = (kdbxVariantDict{
+		kdbxKDFRounds: kdbxMaxAESRounds + 1,
+		kdbxKDFSeed:   bytes.Repeat([]byte{0x5a}, 32),
+	}).aesKDF(make([]byte, 32)); !errors.Is(err, ErrKDBXUnsupported) {
+		t.Errorf("expected ErrKDBXUnsupported for too many AES-KDF rounds, got %v", err)
+	}
+	// And so is a crafted Argon2 iteration count.
+	if _, err = (kdbxVariantDict{
+		kdbxKDFSalt:        bytes.Repeat([]byte{0x5a}, 32),
+		kdbxKDFParallelism: uint32(1),
+		kdbxKDFMemory:      uint64(1 << 20),
+		kdbxKDFIterations:  kdbxMaxArgon2Iterations + 1,
+		kdbxKDFVersion:     argon2Version13,
+	}).argon2KDF(argon2ModeID, make([]byte, 32)); !errors.Is(err, ErrKDBXUnsupported) {
+		t.Errorf("expected ErrKDBXUnsupported for too many Argon2 iterations, got %v", err)
+	}
+}
+
+/*
+	TestKDBXKeePassXC tests importing testdata/keepassxc.kdbx, a database saved by KeePassXC (with its default KDBX 4
+	settings) rather than written by gokwallet. It is skipped if the fixture is missing. To (re)create it, make a new
+	database named "Personal" with the password "password" in KeePassXC, and add to it:
+
+		* in the root group, an entry titled "Router" with only the password "hunter2", and
+		* in a new group "Email", an entry titled "Mail" with the username "me" and the password "mail".
+*/
+func TestKDBXKeePassXC(t *testing.T) {
+
+	var err error
+	var db []byte
+	var doc *ExportDocument
+	var got map[string]*ExportEntry
+	var expected map[string]*ExportEntry = map[string]*ExportEntry{
+		"Root/Router": {Name: "Router", Type: KwalletdEnumTypePassword, Password: "hunter2"},
+		"Email/Mail": {
+			Name: "Mail",
+			Type: KwalletdEnumTypeMap,
+			Map:  map[string]string{"Password": "mail", "UserName": "me"},
+		},
+	}
+
+	if db, err = os.ReadFile(filepath.Join("testdata", "keepassxc.kdbx")); errors.Is(err, os.ErrNotExist) {
+		t.Skipf("no KeePassXC fixture: %v", err)
+	} else if err != nil {
+		t.Fatalf("failed to read KeePassXC fixture: %v", err)
+	}
+
+	if doc, err = ParseKDBX(bytes.NewReader(db), []byte("password")); err != nil {
+		t.Fatalf("failed to ParseKDBX: %v", err)
+	}
+	if doc.Wallets[0].Name != "Personal" {
+		t.Errorf("unexpected wallet name %#v", doc.Wallets[0].Name)
+	}
+	got = make(map[string]*ExportEntry)
+	for _, ef := range doc.Wallets[0].Folders {
+		for _, ee := range ef.Entries {
+			got[ef.Name+"/"+ee.Name] = ee
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected entries:\n%#v\nexpected:\n%#v", got, expected)
+	}
+}
@@ -0,0 +1,953 @@
+package gokwallet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/twofish"
+)
+
+/*
+	readKDBX reads a KDBX 4 database from in, unlocking it with password, and returns its XML document
+	(with protected values decrypted, see kdbxProtect) and the attachments from its inner header.
+*/
+func readKDBX(in io.Reader, password []byte) (doc []byte, binaries [][]byte, err error) {
+
+	var raw []byte
+	var hdr *kdbxHeader
+	var hdrEnd int
+	var keys *kdbxKeys
+	var sum [sha256.Size]byte
+	var sealed []byte
+	var plain []byte
+	var inner *kdbxInner
+	var rest []byte
+
+	if raw, err = io.ReadAll(in); err != nil {
+		return
+	}
+
+	if hdr, hdrEnd, err = parseKDBXHeader(raw); err != nil {
+		return
+	}
+
+	if len(raw) < hdrEnd+2*sha256.Size {
+		err = fmt.Errorf("%w: truncated header", ErrKDBXFormat)
+		return
+	}
+	if sum = sha256.Sum256(raw[:hdrEnd]); !bytes.Equal(sum[:], raw[hdrEnd:hdrEnd+sha256.Size]) {
+		err = fmt.Errorf("%w: header checksum mismatch", ErrKDBXCorrupt)
+		return
+	}
+
+	if keys, err = hdr.keys(password); err != nil {
+		return
+	}
+	defer keys.wipe()
+
+	// The header HMAC is the first thing that depends on the key, so a mismatch means the password is wrong.
+	if !hmac.Equal(keys.blockMAC(kdbxHeaderBlock, raw[:hdrEnd]), raw[hdrEnd+sha256.Size:hdrEnd+2*sha256.Size]) {
+		err = ErrKDBXCredentials
+		return
+	}
+
+	if sealed, err = keys.readBlocks(raw[hdrEnd+2*sha256.Size:]); err != nil {
+		return
+	}
+
+	if plain, err = hdr.decrypt(keys.cipherKey, sealed); err != nil {
+		return
+	}
+	defer wipeBytes(plain)
+
+	if hdr.compression == kdbxCompressGzip {
+		var gz *gzip.Reader
+		var unzipped []byte
+		if gz, err = gzip.NewReader(bytes.NewReader(plain)); err == nil {
+			unzipped, err = io.ReadAll(gz)
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrKDBXCorrupt, err)
+			return
+		}
+		wipeBytes(plain)
+		plain = unzipped
+	}
+
+	if inner, rest, err = parseKDBXInner(plain); err != nil {
+		return
+	}
+	defer wipeBytes(inner.streamKey)
+
+	if doc, err = kdbxProtect(rest, inner, false); err != nil {
+		return
+	}
+	binaries = inner.binaries
+
+	return
+}
+
+/*
+	writeKDBX writes a KDBX 4 database with header hdr (see newKDBXHeader), locked with password, to out.
+	doc is its XML document, with the values to protect (see kdbxProtect) in plaintext, and binaries are its attachments.
+*/
+func writeKDBX(out io.Writer, password []byte, hdr *kdbxHeader, doc []byte, binaries [][]byte) (err error) {
+
+	var keys *kdbxKeys
+	var hdrRaw []byte
+	var sum [sha256.Size]byte
+	var payload bytes.Buffer
+	var gz *gzip.Writer
+	var sealed []byte
+	var protected []byte
+	var buf bytes.Buffer
+	var inner *kdbxInner = &kdbxInner{
+		streamID:  kdbxStreamChaCha20,
+		streamKey: make([]byte, 64),
+		binaries:  binaries,
+	}
+
+	defer wipeBytes(inner.streamKey)
+
+	if _, err = rand.Read(inner.streamKey); err != nil {
+		return
+	}
+
+	if protected, err = kdbxProtect(doc, inner, true); err != nil {
+		return
+	}
+
+	if keys, err = hdr.keys(password); err != nil {
+		return
+	}
+	defer keys.wipe()
+
+	if hdrRaw, err = hdr.marshal(); err != nil {
+		return
+	}
+
+	if hdr.compression == kdbxCompressGzip {
+		gz = gzip.NewWriter(&payload)
+		if _, err = gz.Write(inner.marshal()); err == nil {
+			if _, err = gz.Write(protected); err == nil {
+				err = gz.Close()
+			}
+		}
+		if err != nil {
+			return
+		}
+	} else {
+		payload.Write(inner.marshal())
+		payload.Write(protected)
+	}
+	defer wipeBytes(payload.Bytes())
+
+	if sealed, err = hdr.encrypt(keys.cipherKey, payload.Bytes()); err != nil {
+		return
+	}
+
+	sum = sha256.Sum256(hdrRaw)
+	buf.Write(hdrRaw)
+	buf.Write(sum[:])
+	buf.Write(keys.blockMAC(kdbxHeaderBlock, hdrRaw))
+	keys.writeBlocks(&buf, sealed)
+
+	if _, err = buf.WriteTo(out); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	newKDBXHeader returns the header of a new KDBX 4 database (with new random seeds):
+	AES-256 and gzip, with Argon2id as configured by opts (which must not have zero fields; see KDBXOpts).
+*/
+func newKDBXHeader(opts *KDBXOpts) (hdr *kdbxHeader, err error) {
+
+	hdr = &kdbxHeader{
+		cipherID:    kdbxCipherAES256,
+		compression: kdbxCompressGzip,
+		masterSeed:  make([]byte, 32),
+		iv:          make([]byte, aes.BlockSize),
+		kdf: kdbxVariantDict{
+			kdbxKDFUUID:        kdbxUUIDBytes(kdbxKDFArgon2id),
+			kdbxKDFSalt:        make([]byte, 32),
+			kdbxKDFParallelism: opts.Argon2Parallelism,
+			kdbxKDFMemory:      opts.Argon2Memory,
+			kdbxKDFIterations:  opts.Argon2Iterations,
+			kdbxKDFVersion:     argon2Version13,
+		},
+	}
+
+	for _, b := range [][]byte{hdr.masterSeed, hdr.iv, hdr.kdf[kdbxKDFSalt].([]byte)} {
+		if _, err = rand.Read(b); err != nil {
+			hdr = nil
+			return
+		}
+	}
+
+	return
+}
+
+// parseKDBXHeader parses the (outer) header of a KDBX 4 database, returning it and where it ends in raw.
+func parseKDBXHeader(raw []byte) (hdr *kdbxHeader, end int, err error) {
+
+	var version uint32
+	var id byte
+	var size uint32
+	var data []byte
+
+	if len(raw) < 12 ||
+		binary.LittleEndian.Uint32(raw[0:4]) != kdbxSig1 || binary.LittleEndian.Uint32(raw[4:8]) != kdbxSig2 {
+		err = ErrKDBXFormat
+		return
+	}
+
+	if version = binary.LittleEndian.Uint32(raw[8:12]); version>>16 != kdbxMajorVersion {
+		err = fmt.Errorf("%w: KDBX version %d.%d (only 4.x is supported)", ErrKDBXUnsupported, version>>16, version&0xffff)
+		return
+	}
+
+	hdr = new(kdbxHeader)
+	end = 12
+
+	for id = 0xff; id != kdbxHdrEnd; {
+		if len(raw) < end+5 {
+			err = fmt.Errorf("%w: truncated header", ErrKDBXFormat)
+			hdr = nil
+			return
+		}
+		id = raw[end]
+		size = binary.LittleEndian.Uint32(raw[end+1 : end+5])
+		if uint64(size) > uint64(len(raw)-end-5) {
+			err = fmt.Errorf("%w: truncated header", ErrKDBXFormat)
+			hdr = nil
+			return
+		}
+		data = raw[end+5 : end+5+int(size)]
+		end += 5 + int(size)
+		switch id {
+		case kdbxHdrCipherID:
+			hdr.cipherID = hex.EncodeToString(data)
+		case kdbxHdrCompression:
+			if len(data) != 4 {
+				err = fmt.Errorf("%w: bad compression flags", ErrKDBXFormat)
+			} else {
+				hdr.compression = binary.LittleEndian.Uint32(data)
+			}
+		case kdbxHdrMasterSeed:
+			hdr.masterSeed = data
+		case kdbxHdrIV:
+			hdr.iv = data
+		case kdbxHdrKDF:
+			hdr.kdf, err = parseKDBXVariantDict(data)
+		}
+		if err != nil {
+			hdr = nil
+			return
+		}
+	}
+
+	switch {
+	case len(hdr.masterSeed) != 32:
+		err = fmt.Errorf("%w: bad master seed", ErrKDBXFormat)
+	case hdr.kdf == nil:
+		err = fmt.Errorf("%w: no KDF parameters", ErrKDBXFormat)
+	case hdr.compression > kdbxCompressGzip:
+		err = fmt.Errorf("%w: compression %d", ErrKDBXUnsupported, hdr.compression)
+	}
+	if err != nil {
+		hdr = nil
+		return
+	}
+
+	return
+}
+
+// marshal returns the serialized form of a kdbxHeader (including the signature and version).
+func (h *kdbxHeader) marshal() (raw []byte, err error) {
+
+	var buf bytes.Buffer
+	var kdf []byte
+	var compression [4]byte
+
+	if kdf, err = h.kdf.marshal(); err != nil {
+		return
+	}
+
+	binary.LittleEndian.PutUint32(compression[:], h.compression)
+
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{kdbxSig1, kdbxSig2, kdbxMajorVersion << 16})
+	for _, field := range []struct {
+		id   byte
+		data []byte
+	}{
+		{kdbxHdrCipherID, kdbxUUIDBytes(h.cipherID)},
+		{kdbxHdrCompression, compression[:]},
+		{kdbxHdrMasterSeed, h.masterSeed},
+		{kdbxHdrIV, h.iv},
+		{kdbxHdrKDF, kdf},
+		{kdbxHdrEnd, []byte("\r\n\r\n")},
+	} {
+		buf.WriteByte(field.id)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(field.data)))
+		buf.Write(field.data)
+	}
+
+	raw = buf.Bytes()
+
+	return
+}
+
+// keys derives the keys of a KDBX 4 database from password and its kdbxHeader.
+func (h *kdbxHeader) keys(password []byte) (keys *kdbxKeys, err error) {
+
+	var pwHash [sha256.Size]byte
+	var composite [sha256.Size]byte
+	var transformed []byte
+	var cipherKey [sha256.Size]byte
+	var macKey [sha512.Size]byte
+
+	// The composite key of a password-only database is SHA-256(SHA-256(password)); key files are not supported.
+	pwHash = sha256.Sum256(password)
+	composite = sha256.Sum256(pwHash[:])
+	wipeBytes(pwHash[:])
+	defer wipeBytes(composite[:])
+
+	if transformed, err = h.kdf.transform(composite[:]); err != nil {
+		return
+	}
+	defer wipeBytes(transformed)
+
+	cipherKey = sha256.Sum256(bytes.Join([][]byte{h.masterSeed, transformed}, nil))
+	macKey = sha512.Sum512(bytes.Join([][]byte{h.masterSeed, transformed, {0x01}}, nil))
+
+	keys = &kdbxKeys{
+		cipherKey: cipherKey[:],
+		macKey:    macKey[:],
+	}
+
+	return
+}
+
+// decrypt decrypts the payload of a KDBX 4 database with key, using the cipher in its kdbxHeader.
+func (h *kdbxHeader) decrypt(key, sealed []byte) (plain []byte, err error) {
+
+	var block cipher.Block
+	var stream cipher.Stream
+	var pad int
+
+	if block, stream, err = h.newCipher(key); err != nil {
+		return
+	}
+
+	if stream != nil {
+		plain = make([]byte, len(sealed))
+		stream.XORKeyStream(plain, sealed)
+		return
+	}
+
+	if len(sealed) == 0 || len(sealed)%block.BlockSize() != 0 {
+		err = fmt.Errorf("%w: bad payload length", ErrKDBXCorrupt)
+		return
+	}
+
+	plain = make([]byte, len(sealed))
+	cipher.NewCBCDecrypter(block, h.iv).CryptBlocks(plain, sealed)
+
+	// PKCS #7 padding.
+	if pad = int(plain[len(plain)-1]); pad == 0 || pad > block.BlockSize() ||
+		!bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		wipeBytes(plain)
+		plain = nil
+		err = fmt.Errorf("%w: bad padding", ErrKDBXCorrupt)
+		return
+	}
+	plain = plain[:len(plain)-pad]
+
+	return
+}
+
+// encrypt encrypts the payload of a KDBX 4 database with key, using the cipher in its kdbxHeader.
+func (h *kdbxHeader) encrypt(key, plain []byte) (sealed []byte, err error) {
+
+	var block cipher.Block
+	var stream cipher.Stream
+	var pad int
+
+	if block, stream, err = h.newCipher(key); err != nil {
+		return
+	}
+
+	if stream != nil {
+		sealed = make([]byte, len(plain))
+		stream.XORKeyStream(sealed, plain)
+		return
+	}
+
+	pad = block.BlockSize() - len(plain)%block.BlockSize()
+	sealed = make([]byte, len(plain)+pad)
+	copy(sealed, plain)
+	copy(sealed[len(plain):], bytes.Repeat([]byte{byte(pad)}, pad))
+
+	cipher.NewCBCEncrypter(block, h.iv).CryptBlocks(sealed, sealed)
+
+	return
+}
+
+/*
+	newCipher returns the payload cipher of a KDBX 4 database for key: a block cipher (AES-256 or Twofish, used in CBC mode)
+	or a stream cipher (ChaCha20).
+*/
+func (h *kdbxHeader) newCipher(key []byte) (block cipher.Block, stream cipher.Stream, err error) {
+
+	switch h.cipherID {
+	case kdbxCipherAES256:
+		block, err = aes.NewCipher(key)
+	case kdbxCipherTwofish:
+		block, err = twofish.NewCipher(key)
+	case kdbxCipherChaCha20:
+		stream, err = chacha20.NewUnauthenticatedCipher(key, h.iv)
+	default:
+		err = fmt.Errorf("%w: cipher %v", ErrKDBXUnsupported, h.cipherID)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrKDBXFormat, err)
+		return
+	}
+
+	if block != nil && len(h.iv) != block.BlockSize() {
+		err = fmt.Errorf("%w: bad IV", ErrKDBXFormat)
+		block = nil
+		return
+	}
+
+	return
+}
+
+// blockMAC returns the HMAC-SHA-256 of data for block index of a KDBX 4 database (kdbxHeaderBlock for the header).
+func (k *kdbxKeys) blockMAC(index uint64, data []byte) (mac []byte) {
+
+	var idx [8]byte
+	var blockKey [sha512.Size]byte
+	var h hash.Hash
+
+	binary.LittleEndian.PutUint64(idx[:], index)
+	blockKey = sha512.Sum512(bytes.Join([][]byte{idx[:], k.macKey}, nil))
+	defer wipeBytes(blockKey[:])
+
+	h = hmac.New(sha256.New, blockKey[:])
+	h.Write(data)
+	mac = h.Sum(nil)
+
+	return
+}
+
+/*
+	readBlocks reads and verifies the HMAC block stream of a KDBX 4 database (after its header),
+	returning the (still encrypted) payload.
+*/
+func (k *kdbxKeys) readBlocks(raw []byte) (sealed []byte, err error) {
+
+	var mac []byte
+	var size uint32
+	var data []byte
+	var buf bytes.Buffer
+	var sizeData [12]byte
+
+	for index := uint64(0); ; index++ {
+		if len(raw) < sha256.Size+4 {
+			err = fmt.Errorf("%w: truncated block %d", ErrKDBXCorrupt, index)
+			return
+		}
+		mac = raw[:sha256.Size]
+		size = binary.LittleEndian.Uint32(raw[sha256.Size : sha256.Size+4])
+		raw = raw[sha256.Size+4:]
+		if uint64(size) > uint64(len(raw)) {
+			err = fmt.Errorf("%w: truncated block %d", ErrKDBXCorrupt, index)
+			return
+		}
+		data = raw[:size]
+		raw = raw[size:]
+		// The MAC covers the block index, its size, and its data.
+		binary.LittleEndian.PutUint64(sizeData[:8], index)
+		binary.LittleEndian.PutUint32(sizeData[8:], size)
+		if !hmac.Equal(mac, k.blockMAC(index, bytes.Join([][]byte{sizeData[:], data}, nil))) {
+			err = fmt.Errorf("%w: block %d fails authentication", ErrKDBXCorrupt, index)
+			return
+		}
+		if size == 0 {
+			break
+		}
+		buf.Write(data)
+	}
+
+	sealed = buf.Bytes()
+
+	return
+}
+
+// writeBlocks writes sealed (an encrypted payload) to buf as the HMAC block stream of a KDBX 4 database.
+func (k *kdbxKeys) writeBlocks(buf *bytes.Buffer, sealed []byte) {
+
+	var data []byte
+	var sizeData [12]byte
+
+	for index := uint64(0); ; index++ {
+		data = sealed
+		if len(data) > kdbxBlockSize {
+			data = data[:kdbxBlockSize]
+		}
+		sealed = sealed[len(data):]
+		binary.LittleEndian.PutUint64(sizeData[:8], index)
+		binary.LittleEndian.PutUint32(sizeData[8:], uint32(len(data)))
+		buf.Write(k.blockMAC(index, bytes.Join([][]byte{sizeData[:], data}, nil)))
+		buf.Write(sizeData[8:])
+		buf.Write(data)
+		// The stream ends with an empty block.
+		if len(data) == 0 {
+			break
+		}
+	}
+
+	return
+}
+
+// wipe zeroes the keys in a kdbxKeys.
+func (k *kdbxKeys) wipe() {
+
+	wipeBytes(k.cipherKey)
+	wipeBytes(k.macKey)
+
+	return
+}
+
+// parseKDBXInner parses the inner header at the start of the decrypted payload of a KDBX 4 database, returning it and the rest (the XML document).
+func parseKDBXInner(plain []byte) (inner *kdbxInner, rest []byte, err error) {
+
+	var id byte
+	var size uint32
+	var data []byte
+	var bin []byte
+
+	inner = &kdbxInner{
+		binaries: make([][]byte, 0),
+	}
+
+	for id = 0xff; id != kdbxInnerEnd; {
+		if len(plain) < 5 {
+			err = fmt.Errorf("%w: truncated inner header", ErrKDBXCorrupt)
+			inner = nil
+			return
+		}
+		id = plain[0]
+		size = binary.LittleEndian.Uint32(plain[1:5])
+		if uint64(size) > uint64(len(plain)-5) {
+			err = fmt.Errorf("%w: truncated inner header", ErrKDBXCorrupt)
+			inner = nil
+			return
+		}
+		data = plain[5 : 5+size]
+		plain = plain[5+size:]
+		switch id {
+		case kdbxInnerStreamID:
+			if len(data) == 4 {
+				inner.streamID = binary.LittleEndian.Uint32(data)
+			}
+		case kdbxInnerStreamKey:
+			inner.streamKey = make([]byte, len(data))
+			copy(inner.streamKey, data)
+		case kdbxInnerBinary:
+			// The first byte holds flags (e.g. "protect in memory"), which don't apply here.
+			if len(data) == 0 {
+				err = fmt.Errorf("%w: bad attachment", ErrKDBXCorrupt)
+				inner = nil
+				return
+			}
+			bin = make([]byte, len(data)-1)
+			copy(bin, data[1:])
+			inner.binaries = append(inner.binaries, bin)
+		}
+	}
+
+	rest = plain
+
+	return
+}
+
+// marshal returns the serialized form of a kdbxInner.
+func (i *kdbxInner) marshal() (raw []byte) {
+
+	var buf bytes.Buffer
+	var streamID [4]byte
+
+	binary.LittleEndian.PutUint32(streamID[:], i.streamID)
+
+	writeField := func(id byte, data ...[]byte) {
+		var size int
+		for _, d := range data {
+			size += len(d)
+		}
+		buf.WriteByte(id)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(size))
+		for _, d := range data {
+			buf.Write(d)
+		}
+	}
+
+	writeField(kdbxInnerStreamID, streamID[:])
+	writeField(kdbxInnerStreamKey, i.streamKey)
+	for _, b := range i.binaries {
+		writeField(kdbxInnerBinary, []byte{kdbxBinaryProtected}, b)
+	}
+	writeField(kdbxInnerEnd)
+
+	raw = buf.Bytes()
+
+	return
+}
+
+/*
+	kdbxProtect encrypts (if encrypt is true) or decrypts the values of the elements in doc (a KDBX XML document)
+	with a Protected="True" attribute, in document order, with the inner random stream of inner.
+	Encrypted values are base64-encoded; decrypted ones are plaintext (and keep the attribute).
+*/
+func kdbxProtect(doc []byte, inner *kdbxInner, encrypt bool) (out []byte, err error) {
+
+	var h [sha512.Size]byte
+	var stream cipher.Stream
+	var tok xml.Token
+	var protected bool
+	var val []byte
+	var buf bytes.Buffer
+	var dec *xml.Decoder = xml.NewDecoder(bytes.NewReader(doc))
+	var enc *xml.Encoder = xml.NewEncoder(&buf)
+
+	switch inner.streamID {
+	case kdbxStreamNone:
+	case kdbxStreamChaCha20:
+		h = sha512.Sum512(inner.streamKey)
+		defer wipeBytes(h[:])
+		if stream, err = chacha20.NewUnauthenticatedCipher(h[:32], h[32:44]); err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("%w: inner random stream %d", ErrKDBXUnsupported, inner.streamID)
+		return
+	}
+
+	buf.WriteString(xml.Header)
+
+	for {
+		if tok, err = dec.Token(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = fmt.Errorf("%w: %v", ErrKDBXCorrupt, err)
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			protected = false
+			for _, a := range t.Attr {
+				if a.Name.Local == "Protected" && strings.EqualFold(a.Value, "True") {
+					protected = stream != nil
+				}
+			}
+		case xml.EndElement:
+			protected = false
+		case xml.CharData:
+			if !protected {
+				break
+			}
+			if encrypt {
+				val = []byte(t)
+				stream.XORKeyStream(val, val)
+				tok = xml.CharData(base64.StdEncoding.EncodeToString(val))
+			} else {
+				if val, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(t))); err != nil {
+					err = fmt.Errorf("%w: bad protected value: %v", ErrKDBXCorrupt, err)
+					return
+				}
+				stream.XORKeyStream(val, val)
+				tok = xml.CharData(val)
+			}
+		case xml.ProcInst, xml.Comment, xml.Directive:
+			continue
+		}
+		if err = enc.EncodeToken(tok); err != nil {
+			return
+		}
+	}
+
+	if err = enc.Flush(); err != nil {
+		return
+	}
+
+	out = buf.Bytes()
+
+	return
+}
+
+// parseKDBXVariantDict parses a KDBX VariantDictionary (e.g. the KDF parameters).
+func parseKDBXVariantDict(raw []byte) (vd kdbxVariantDict, err error) {
+
+	var typ byte
+	var keyLen uint32
+	var valLen uint32
+	var key string
+	var val []byte
+
+	if len(raw) < 2 || binary.LittleEndian.Uint16(raw)>>8 != kdbxVariantDictVersion>>8 {
+		err = fmt.Errorf("%w: bad KDF parameters", ErrKDBXFormat)
+		return
+	}
+	raw = raw[2:]
+
+	vd = make(kdbxVariantDict)
+
+	for {
+		if len(raw) < 1 {
+			err = fmt.Errorf("%w: truncated KDF parameters", ErrKDBXFormat)
+			vd = nil
+			return
+		}
+		if typ = raw[0]; typ == kdbxVariantEnd {
+			break
+		}
+		if len(raw) < 5 {
+			err = fmt.Errorf("%w: truncated KDF parameters", ErrKDBXFormat)
+			vd = nil
+			return
+		}
+		if keyLen = binary.LittleEndian.Uint32(raw[1:5]); uint64(keyLen)+4 > uint64(len(raw)-5) {
+			err = fmt.Errorf("%w: truncated KDF parameters", ErrKDBXFormat)
+			vd = nil
+			return
+		}
+		key = string(raw[5 : 5+keyLen])
+		raw = raw[5+keyLen:]
+		if valLen = binary.LittleEndian.Uint32(raw[:4]); uint64(valLen) > uint64(len(raw)-4) {
+			err = fmt.Errorf("%w: truncated KDF parameters", ErrKDBXFormat)
+			vd = nil
+			return
+		}
+		val = raw[4 : 4+valLen]
+		raw = raw[4+valLen:]
+		switch {
+		case typ == kdbxVariantUint32 && valLen == 4:
+			vd[key] = binary.LittleEndian.Uint32(val)
+		case typ == kdbxVariantUint64 && valLen == 8:
+			vd[key] = binary.LittleEndian.Uint64(val)
+		case typ == kdbxVariantBool && valLen == 1:
+			vd[key] = val[0] != 0
+		case typ == kdbxVariantInt32 && valLen == 4:
+			vd[key] = int32(binary.LittleEndian.Uint32(val))
+		case typ == kdbxVariantInt64 && valLen == 8:
+			vd[key] = int64(binary.LittleEndian.Uint64(val))
+		case typ == kdbxVariantString:
+			vd[key] = string(val)
+		case typ == kdbxVariantBytes:
+			vd[key] = append([]byte{}, val...)
+		default:
+			err = fmt.Errorf("%w: KDF parameter %#v has a bad type or size", ErrKDBXFormat, key)
+			vd = nil
+			return
+		}
+	}
+
+	return
+}
+
+// marshal returns the serialized form of a kdbxVariantDict (with its keys sorted).
+func (v kdbxVariantDict) marshal() (raw []byte, err error) {
+
+	var typ byte
+	var val []byte
+	var keys []string = make([]string, 0, len(v))
+	var buf bytes.Buffer
+
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	_ = binary.Write(&buf, binary.LittleEndian, kdbxVariantDictVersion)
+
+	for _, k := range keys {
+		switch t := v[k].(type) {
+		case uint32:
+			typ, val = kdbxVariantUint32, make([]byte, 4)
+			binary.LittleEndian.PutUint32(val, t)
+		case uint64:
+			typ, val = kdbxVariantUint64, make([]byte, 8)
+			binary.LittleEndian.PutUint64(val, t)
+		case bool:
+			typ, val = kdbxVariantBool, []byte{0}
+			if t {
+				val[0] = 1
+			}
+		case int32:
+			typ, val = kdbxVariantInt32, make([]byte, 4)
+			binary.LittleEndian.PutUint32(val, uint32(t))
+		case int64:
+			typ, val = kdbxVariantInt64, make([]byte, 8)
+			binary.LittleEndian.PutUint64(val, uint64(t))
+		case string:
+			typ, val = kdbxVariantString, []byte(t)
+		case []byte:
+			typ, val = kdbxVariantBytes, t
+		default:
+			err = fmt.Errorf("unsupported KDF parameter type %T", t)
+			return
+		}
+		buf.WriteByte(typ)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(k)))
+		buf.WriteString(k)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(val)))
+		buf.Write(val)
+	}
+	buf.WriteByte(kdbxVariantEnd)
+
+	raw = buf.Bytes()
+
+	return
+}
+
+// transform derives the transformed key of a KDBX 4 database from its composite key, with the KDF a kdbxVariantDict describes.
+func (v kdbxVariantDict) transform(composite []byte) (key []byte, err error) {
+
+	var uuid []byte
+	var ok bool
+
+	if uuid, ok = v[kdbxKDFUUID].([]byte); !ok {
+		err = fmt.Errorf("%w: no KDF", ErrKDBXFormat)
+		return
+	}
+
+	switch hex.EncodeToString(uuid) {
+	case kdbxKDFAES:
+		key, err = v.aesKDF(composite)
+	case kdbxKDFArgon2d:
+		key, err = v.argon2KDF(argon2ModeD, composite)
+	case kdbxKDFArgon2id:
+		key, err = v.argon2KDF(argon2ModeID, composite)
+	default:
+		err = fmt.Errorf("%w: KDF %v", ErrKDBXUnsupported, hex.EncodeToString(uuid))
+	}
+
+	return
+}
+
+// aesKDF is the AES-KDF: composite is encrypted with AES-256 (in ECB mode) keyed with the seed, a number of rounds.
+func (v kdbxVariantDict) aesKDF(composite []byte) (key []byte, err error) {
+
+	var ok bool
+	var rounds uint64
+	var seed []byte
+	var block cipher.Block
+	var buf []byte = make([]byte, len(composite))
+	var sum [sha256.Size]byte
+
+	defer wipeBytes(buf)
+
+	rounds, ok = v[kdbxKDFRounds].(uint64)
+	if seed, _ = v[kdbxKDFSeed].([]byte); !ok || len(seed) != 32 || len(composite) != 32 {
+		err = fmt.Errorf("%w: bad AES-KDF parameters", ErrKDBXFormat)
+		return
+	}
+	if rounds > kdbxMaxAESRounds {
+		// A crafted database could otherwise keep us busy for as long as it likes before the password is checked.
+		err = fmt.Errorf("%w: AES-KDF rounds %d", ErrKDBXUnsupported, rounds)
+		return
+	}
+
+	if block, err = aes.NewCipher(seed); err != nil {
+		return
+	}
+
+	copy(buf, composite)
+	for r := uint64(0); r < rounds; r++ {
+		block.Encrypt(buf[:16], buf[:16])
+		block.Encrypt(buf[16:], buf[16:])
+	}
+
+	sum = sha256.Sum256(buf)
+	key = sum[:]
+
+	return
+}
+
+// argon2KDF is the Argon2d or Argon2id KDF (see argon2Key).
+func (v kdbxVariantDict) argon2KDF(mode uint32, composite []byte) (key []byte, err error) {
+
+	var salt []byte
+	var secret []byte
+	var ad []byte
+	var parallelism uint32
+	var memory uint64
+	var iterations uint64
+	var version uint32
+	var ok [5]bool
+
+	salt, ok[0] = v[kdbxKDFSalt].([]byte)
+	parallelism, ok[1] = v[kdbxKDFParallelism].(uint32)
+	memory, ok[2] = v[kdbxKDFMemory].(uint64)
+	iterations, ok[3] = v[kdbxKDFIterations].(uint64)
+	version, ok[4] = v[kdbxKDFVersion].(uint32)
+	secret, _ = v[kdbxKDFSecret].([]byte)
+	ad, _ = v[kdbxKDFAssocData].([]byte)
+
+	for _, o := range ok {
+		if !o {
+			err = fmt.Errorf("%w: missing Argon2 parameters", ErrKDBXFormat)
+			return
+		}
+	}
+
+	switch {
+	case version != argon2Version10 && version != argon2Version13:
+		err = fmt.Errorf("%w: Argon2 version %#x", ErrKDBXUnsupported, version)
+	case parallelism < 1 || parallelism > kdbxMaxArgon2Parallelism:
+		err = fmt.Errorf("%w: Argon2 parallelism %d", ErrKDBXUnsupported, parallelism)
+	case memory < 1024 || memory > kdbxMaxArgon2Memory:
+		// A crafted database could otherwise make us allocate any amount of memory before the password is checked.
+		err = fmt.Errorf("%w: Argon2 memory %d bytes", ErrKDBXUnsupported, memory)
+	case iterations < 1 || iterations > kdbxMaxArgon2Iterations:
+		err = fmt.Errorf("%w: Argon2 iterations %d", ErrKDBXUnsupported, iterations)
+	}
+	if err != nil {
+		return
+	}
+
+	key = argon2Key(mode, version, composite, salt, secret, ad, uint32(iterations), uint32(memory/1024), parallelism, 32)
+
+	return
+}
+
+// kdbxUUIDBytes returns the bytes of a KDBX UUID constant (e.g. kdbxCipherAES256).
+func kdbxUUIDBytes(uuid string) (b []byte) {
+
+	// The constants are all valid hex.
+	b, _ = hex.DecodeString(uuid)
+
+	return
+}
@@ -0,0 +1,52 @@
+package gokwallet
+
+import (
+	"fmt"
+)
+
+// String returns the name of a WalletItem type ("password", "map", "blob", or "unknown").
+func (k kwalletdEnumType) String() (s string) {
+
+	switch k {
+	case KwalletdEnumTypePassword:
+		s = "password"
+	case KwalletdEnumTypeMap:
+		s = "map"
+	case KwalletdEnumTypeStream:
+		s = "blob"
+	case KwalletdEnumTypeUnknown:
+		s = "unknown"
+	default:
+		s = fmt.Sprintf("type(%d)", int32(k))
+	}
+
+	return
+}
+
+// MarshalText lets a WalletItem type be serialized (e.g. to JSON) by its name (see kwalletdEnumType.String).
+func (k kwalletdEnumType) MarshalText() (b []byte, err error) {
+
+	b = []byte(k.String())
+
+	return
+}
+
+// UnmarshalText performs the inverse of kwalletdEnumType.MarshalText.
+func (k *kwalletdEnumType) UnmarshalText(b []byte) (err error) {
+
+	switch string(b) {
+	case "password":
+		*k = KwalletdEnumTypePassword
+	case "map":
+		*k = KwalletdEnumTypeMap
+	case "blob", "stream":
+		*k = KwalletdEnumTypeStream
+	case "unknown":
+		*k = KwalletdEnumTypeUnknown
+	default:
+		err = fmt.Errorf("%w: %#v", ErrUnknownEntryType, string(b))
+		return
+	}
+
+	return
+}
@@ -1,7 +1,10 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 )
 
 /*
@@ -29,7 +32,7 @@ func NewMap(f *Folder, keyName string, recursion *RecurseOpts) (m *Map, err erro
 
 	m.isInit = true
 
-	if m.Recurse.AllWalletItems || m.Recurse.Maps {
+	if !m.Recurse.Lazy && (m.Recurse.AllWalletItems || m.Recurse.Maps) {
 		if err = m.Update(); err != nil {
 			return
 		}
@@ -74,42 +77,204 @@ func (m *Map) Rename(newName string) (err error) {
 	return
 }
 
-// SetValue will replace this Map's Map.Value.
+// SetValue will replace this Map's Map.Value (or, in secure mode, Map.Secrets).
 func (m *Map) SetValue(newValue map[string]string) (err error) {
 
 	if _, err = m.folder.WriteMap(m.Name, newValue); err != nil {
 		return
 	}
 
-	m.Value = newValue
+	if err = m.setValue(newValue); err != nil {
+		return
+	}
 
 	return
 }
 
-// Update fetches a Map's Map.Value.
+/*
+	SetValueIf replaces this Map's value with newValue, but only if its stored value is still expectedOld.
+	Otherwise (or if the Map was removed or replaced by another type) a *ConflictError is returned, nothing is written,
+	and Map.Value is refreshed with the stored value so the caller can retry.
+*/
+func (m *Map) SetValueIf(expectedOld, newValue map[string]string) (err error) {
+
+	var cur []byte
+	var raw []byte
+	var curMap map[string]string
+
+	if newValue == nil {
+		err = ErrInvalidMap
+		return
+	}
+
+	if raw, err = mapToBytes(newValue); err != nil {
+		return
+	}
+
+	if cur, err = m.folder.casEntry(
+		m.Name,
+		KwalletdEnumTypeMap,
+		func(cur []byte) (isMatch bool, err error) {
+			curMap = make(map[string]string)
+			if len(cur) != 0 {
+				if curMap, _, err = bytesToMap(cur); err != nil {
+					return
+				}
+			}
+			isMatch = mapsEqual(curMap, expectedOld)
+			return
+		},
+		raw,
+	); err != nil {
+		if cur != nil && errors.Is(err, ErrConflict) {
+			// The *ConflictError is what matters to the caller.
+			_ = m.setValue(curMap)
+		}
+		return
+	}
+
+	if err = m.setValue(newValue); err != nil {
+		return
+	}
+
+	return
+}
+
+// Update fetches a Map's Map.Value (or, in secure mode, Map.Secrets).
 func (m *Map) Update() (err error) {
 
-	var call *dbus.Call
-	var b []byte
+	var value map[string]string
+	var secrets map[string]*SecretBytes
+
+	if m.folder.wallet.wm.secure {
+		if secrets, err = m.readSecrets(); err != nil {
+			return
+		}
+		_ = m.wipeSecrets()
+		m.Secrets = secrets
+		m.loaded = true
+		return
+	}
+
+	if value, err = m.read(); err != nil {
+		return
+	}
+
+	m.Value = value
+	m.loaded = true
+
+	return
+}
+
+/*
+	GetValue returns a Map's value, fetching it if it has not been fetched (or set) yet,
+	e.g. if the Map was created with RecurseOpts.Lazy.
+	The fetched value is only kept (in Map.Value, or Map.Secrets in secure mode) if RecurseOpts.CacheValues is true;
+	otherwise it is fetched on every call.
+	Unlike reading Map.Value directly, a failed fetch (e.g. an *ExpiredError) is returned rather than an empty value.
+*/
+func (m *Map) GetValue() (value map[string]string, err error) {
+
+	if !m.loaded && m.Recurse != nil && m.Recurse.CacheValues {
+		if err = m.Update(); err != nil {
+			return
+		}
+	}
+
+	if m.loaded {
+		value = m.value()
+		return
+	}
+
+	if value, err = m.read(); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Wipe wipes this Map's values from memory: Map.Secrets (in secure mode) are zeroed in place (see SecretBytes.Wipe),
+	so they will be fetched again if needed. Map.Value holds strings, which can't be zeroed, so outside of secure mode
+	it is only dropped (see WalletManager.EnableSecureMode).
+*/
+func (m *Map) Wipe() (err error) {
+
+	err = m.wipeSecrets()
+
+	for k := range m.Value {
+		delete(m.Value, k)
+	}
+	m.Value = nil
+	m.loaded = false
+
+	return
+}
+
+// read fetches a Map's value from the Backend.
+func (m *Map) read() (value map[string]string, err error) {
 
 	if err = m.folder.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	m.Value = make(map[string]string, 0)
+	if err = m.folder.expiryCheck(m.Name); err != nil {
+		return
+	}
 
-	if call = m.Dbus.Call(
-		DbusWMReadMap, 0, m.folder.wallet.handle, m.folder.Name, m.Name, m.folder.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if value, err = m.folder.wallet.wm.Backend().ReadMap(
+		m.folder.wallet.handle, m.folder.Name, m.Name, m.folder.wallet.wm.AppID,
+	); err != nil {
 		return
 	}
-	if err = call.Store(&b); err != nil {
+
+	return
+}
+
+// readSecrets fetches a Map's values from the Backend into SecretBytes, without them ever being strings.
+func (m *Map) readSecrets() (secrets map[string]*SecretBytes, err error) {
+
+	var raw []byte
+	var values map[string][]byte
+
+	if err = m.folder.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if err = m.folder.expiryCheck(m.Name); err != nil {
+		return
+	}
+
+	if raw, err = m.folder.wallet.wm.Backend().ReadEntry(
+		m.folder.wallet.handle, m.folder.Name, m.Name, m.folder.wallet.wm.AppID,
+	); err != nil {
+		return
+	}
+	defer wipeBytes(raw)
+
+	secrets = make(map[string]*SecretBytes)
+
+	if len(raw) == 0 {
 		return
 	}
 
-	if len(b) != 0 {
-		if m.Value, _, err = bytesToMap(b); err != nil {
+	if values, _, err = bytesToByteMap(raw); err != nil {
+		secrets = nil
+		return
+	}
+
+	defer func() {
+		for _, v := range values {
+			wipeBytes(v)
+		}
+	}()
+
+	for k, v := range values {
+		if secrets[k], err = m.folder.wallet.wm.newSecret(v); err != nil {
+			for _, s := range secrets {
+				_ = s.Wipe()
+			}
+			secrets = nil
 			return
 		}
 	}
@@ -117,6 +282,229 @@ func (m *Map) Update() (err error) {
 	return
 }
 
+// setValue sets a Map's known value (in Map.Secrets in secure mode, otherwise Map.Value).
+func (m *Map) setValue(value map[string]string) (err error) {
+
+	var secrets map[string]*SecretBytes
+
+	if m.folder.wallet.wm.secure {
+		secrets = make(map[string]*SecretBytes, len(value))
+		for k, v := range value {
+			if secrets[k], err = m.folder.wallet.wm.newSecret([]byte(v)); err != nil {
+				for _, s := range secrets {
+					_ = s.Wipe()
+				}
+				return
+			}
+		}
+		_ = m.wipeSecrets()
+		m.Secrets = secrets
+		m.Value = nil
+	} else {
+		m.Value = value
+	}
+
+	m.loaded = true
+
+	return
+}
+
+// value returns a Map's known value (from Map.Secrets in secure mode, otherwise Map.Value).
+func (m *Map) value() (value map[string]string) {
+
+	if m.Secrets != nil {
+		value = make(map[string]string, len(m.Secrets))
+		for k, s := range m.Secrets {
+			value[k] = string(s.Bytes())
+		}
+		return
+	}
+
+	value = m.Value
+
+	return
+}
+
+// wipeSecrets wipes and drops Map.Secrets.
+func (m *Map) wipeSecrets() (err error) {
+
+	var errs []error = make([]error, 0)
+
+	for _, s := range m.Secrets {
+		if err = s.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	m.Secrets = nil
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	return
+}
+
+/*
+	UpdateKeys calls fn with a copy of Map.Value, which fn may modify (add, change, or delete keys) in place,
+	and then writes the result, but only if the stored Map still matches Map.Value (see Map.SetValueIf).
+	If Map.Value has not been fetched (or set) yet, it is fetched first.
+	If fn returns an error, nothing is written and that error is returned.
+	On a *ConflictError, Map.Value is refreshed so UpdateKeys can simply be called again to retry.
+*/
+func (m *Map) UpdateKeys(fn func(value map[string]string) (err error)) (err error) {
+
+	var curValue map[string]string
+	var newValue map[string]string
+
+	if !m.loaded {
+		if err = m.Update(); err != nil {
+			return
+		}
+	}
+
+	curValue = m.value()
+
+	newValue = make(map[string]string, len(curValue))
+	for k, v := range curValue {
+		newValue[k] = v
+	}
+
+	if err = fn(newValue); err != nil {
+		return
+	}
+
+	if err = m.SetValueIf(curValue, newValue); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Metadata returns the EntryMetadata of this Map (see Folder.EntryMetadata).
+	md is nil if no metadata has been recorded for it yet.
+*/
+func (m *Map) Metadata() (md *EntryMetadata, err error) {
+
+	if md, err = m.folder.EntryMetadata(m.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetNote sets the EntryMetadata.Note of this Map.
+func (m *Map) SetNote(note string) (err error) {
+
+	if err = m.folder.SetEntryNote(m.Name, note); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetTags replaces the EntryMetadata.Tags of this Map.
+func (m *Map) SetTags(tags ...string) (err error) {
+
+	if err = m.folder.SetEntryTags(m.Name, tags...); err != nil {
+		return
+	}
+
+	return
+}
+
+// History returns the retained previous values of this Map, newest first (see Folder.History).
+func (m *Map) History() (versions []*HistoryVersion, err error) {
+
+	if versions, err = m.folder.History(m.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// RestoreVersion restores HistoryVersion versionID of this Map (see Folder.RestoreVersion) and updates Map.Value.
+func (m *Map) RestoreVersion(versionID string) (err error) {
+
+	if err = m.folder.RestoreVersion(m.Name, versionID); err != nil {
+		return
+	}
+
+	if err = m.Update(); err != nil {
+		return
+	}
+
+	return
+}
+
+// Expiry returns when this Map expires (see Folder.EntryExpiry). expires is zero if it does not expire.
+func (m *Map) Expiry() (expires time.Time, err error) {
+
+	if expires, err = m.folder.EntryExpiry(m.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetExpiry sets when this Map expires (see Folder.SetEntryExpiry). A zero expires removes its expiry.
+func (m *Map) SetExpiry(expires time.Time) (err error) {
+
+	if err = m.folder.SetEntryExpiry(m.Name, expires); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	String returns a representation of a Map with its value replaced by RedactedValue (its keys are shown),
+	unless WalletManager.SetShowValues(true) was called.
+*/
+func (m Map) String() (str string) {
+
+	var value string = mapString(m.value(), false)
+
+	if m.wm.ShowValuesEnabled() {
+		value = mapString(m.value(), true)
+	}
+
+	str = fmt.Sprintf("Map{Name: %q, Value: %v}", m.Name, value)
+
+	return
+}
+
+// Format prints Map.String for every verb (see formatRedacted).
+func (m Map) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, m.String())
+
+	return
+}
+
+// MarshalJSON encodes a Map with its value replaced by RedactedValue (its keys are shown), unless WalletManager.SetShowValues(true) was called.
+func (m Map) MarshalJSON() (b []byte, err error) {
+
+	var value interface{} = redactedMap(m.value())
+
+	if m.wm.ShowValuesEnabled() {
+		value = m.value()
+	}
+
+	if b, err = json.Marshal(&struct {
+		*jsonMap
+		Value interface{} `json:"value"`
+	}{
+		jsonMap: (*jsonMap)(&m),
+		Value:   value,
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
 // isWalletItem is needed for interface membership.
 func (m *Map) isWalletItem() (isWalletItem bool) {
 
@@ -1,6 +1,7 @@
 package gokwallet
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -53,3 +54,68 @@ func TestMap(t *testing.T) {
 	}
 
 }
+
+// TestMapUpdateKeys tests conditional updates of a Map.
+func TestMapUpdateKeys(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var m *Map
+	var other *Map
+	var fnErr error = errors.New("test error")
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if m, err = e.f.WriteMap(mapTest.String(), testMap); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if other, err = NewMap(e.f, mapTest.String(), e.r); err != nil {
+		t.Fatalf("failed to get Map: %v", err)
+	}
+
+	if err = m.UpdateKeys(func(value map[string]string) (err error) {
+		value["added"] = "yes"
+		return
+	}); err != nil {
+		t.Fatalf("failed to UpdateKeys: %v", err)
+	}
+	if m.Value["added"] != "yes" {
+		t.Errorf("Map.Value not updated by UpdateKeys")
+	}
+
+	// other is stale, so its update must not clobber the one above.
+	if err = other.UpdateKeys(func(value map[string]string) (err error) {
+		value["other"] = "yes"
+		return
+	}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if !reflect.DeepEqual(other.Value, m.Value) {
+		t.Errorf("Map.Value not refreshed after a conflict")
+	}
+	// Retrying after a conflict works.
+	if err = other.UpdateKeys(func(value map[string]string) (err error) {
+		value["other"] = "yes"
+		return
+	}); err != nil {
+		t.Fatalf("failed to UpdateKeys after a conflict: %v", err)
+	}
+	if err = m.Update(); err != nil {
+		t.Fatalf("failed to Update: %v", err)
+	}
+	if m.Value["added"] != "yes" || m.Value["other"] != "yes" {
+		t.Errorf("lost update: %#v", m.Value)
+	}
+
+	if err = m.UpdateKeys(func(value map[string]string) (err error) {
+		delete(value, "added")
+		err = fnErr
+		return
+	}); err != fnErr {
+		t.Errorf("expected the UpdateKeys func's error, got %v", err)
+	}
+	if _, ok := m.Value["added"]; !ok {
+		t.Errorf("Map.Value changed by a failed UpdateKeys")
+	}
+}
@@ -0,0 +1,768 @@
+package gokwallet
+
+import (
+	"sort"
+)
+
+// NewMemoryBackend returns an empty MemoryBackend.
+func NewMemoryBackend() (backend *MemoryBackend) {
+
+	backend = &MemoryBackend{
+		wallets:    make(map[string]*memWallet),
+		handles:    make(map[int32]string),
+		nextHandle: 1,
+	}
+
+	return
+}
+
+// IsEnabled always returns true for a MemoryBackend.
+func (m *MemoryBackend) IsEnabled() (enabled bool, err error) {
+
+	enabled = true
+
+	return
+}
+
+// Wallets returns the names of all wallets.
+func (m *MemoryBackend) Wallets() (walletNames []string, err error) {
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	walletNames = make([]string, 0, len(m.wallets))
+
+	for wn := range m.wallets {
+		walletNames = append(walletNames, wn)
+	}
+
+	sort.Strings(walletNames)
+
+	return
+}
+
+// LocalWallet returns DefaultWalletName.
+func (m *MemoryBackend) LocalWallet() (walletName string, err error) {
+
+	walletName = DefaultWalletName
+
+	return
+}
+
+// NetworkWallet returns DefaultWalletName.
+func (m *MemoryBackend) NetworkWallet() (walletName string, err error) {
+
+	walletName = DefaultWalletName
+
+	return
+}
+
+// Open opens a wallet (creating it if it doesn't exist) and returns a handle for it.
+func (m *MemoryBackend) Open(walletName, appID string) (handle int32, err error) {
+
+	var w *memWallet
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// A new wallet isn't reported as changed until something is actually written to it.
+	w, _ = m.getOrCreateWallet(walletName)
+
+	w.isOpen = true
+	w.users[appID]++
+
+	handle = m.nextHandle
+	m.nextHandle++
+	m.handles[handle] = walletName
+
+	return
+}
+
+// IsOpen returns whether a wallet is open or not.
+func (m *MemoryBackend) IsOpen(walletName string) (isOpen bool, err error) {
+
+	var w *memWallet
+	var ok bool
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if w, ok = m.wallets[walletName]; !ok {
+		return
+	}
+
+	isOpen = w.isOpen
+
+	return
+}
+
+// Close invalidates a handle. The wallet is closed once no applications are using it (or immediately if force is true).
+func (m *MemoryBackend) Close(handle int32, force bool, appID string) (err error) {
+
+	var w *memWallet
+	var walletName string
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		return
+	}
+
+	// As with kwalletd, the wallet stays open until its last handle is closed.
+	delete(m.handles, handle)
+	if w.users[appID]--; w.users[appID] <= 0 {
+		delete(w.users, appID)
+	}
+
+	if force || len(w.users) == 0 {
+		m.closeWallet(walletName)
+	}
+
+	return
+}
+
+// CloseWallet closes a wallet by name for all applications.
+func (m *MemoryBackend) CloseWallet(walletName string, force bool) (err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.wallets[walletName]; !ok {
+		err = ErrOperationFailed
+		return
+	}
+
+	m.closeWallet(walletName)
+
+	return
+}
+
+// CloseAllWallets closes all wallets.
+func (m *MemoryBackend) CloseAllWallets() (err error) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for wn := range m.wallets {
+		m.closeWallet(wn)
+	}
+
+	return
+}
+
+// DeleteWallet deletes a wallet.
+func (m *MemoryBackend) DeleteWallet(walletName string) (err error) {
+
+	m.lock.Lock()
+
+	if _, ok := m.wallets[walletName]; !ok {
+		m.lock.Unlock()
+		err = ErrOperationFailed
+		return
+	}
+
+	m.closeWallet(walletName)
+	delete(m.wallets, walletName)
+
+	m.lock.Unlock()
+
+	if m.onDelete != nil {
+		err = m.onDelete(walletName)
+	}
+
+	return
+}
+
+// DisconnectApplication disconnects application appID from a wallet.
+func (m *MemoryBackend) DisconnectApplication(walletName, appID string) (err error) {
+
+	var w *memWallet
+	var ok bool
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if w, ok = m.wallets[walletName]; !ok || w.users[appID] == 0 {
+		err = ErrNoDisconnect
+		return
+	}
+
+	delete(w.users, appID)
+
+	for h, wn := range m.handles {
+		if wn == walletName {
+			delete(m.handles, h)
+		}
+	}
+
+	return
+}
+
+// Users returns the application IDs that have a wallet open.
+func (m *MemoryBackend) Users(walletName string) (appIDs []string, err error) {
+
+	var w *memWallet
+	var ok bool
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	appIDs = make([]string, 0)
+
+	if w, ok = m.wallets[walletName]; !ok {
+		return
+	}
+
+	for a := range w.users {
+		appIDs = append(appIDs, a)
+	}
+
+	sort.Strings(appIDs)
+
+	return
+}
+
+// ChangePassword is not supported by a MemoryBackend, as its wallets have no password.
+func (m *MemoryBackend) ChangePassword(walletName, appID string) (err error) {
+
+	err = ErrBackendUnsupported
+
+	return
+}
+
+// FolderList returns the names of all folders in a wallet.
+func (m *MemoryBackend) FolderList(handle int32, appID string) (folderNames []string, err error) {
+
+	var w *memWallet
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if _, w, err = m.getWallet(handle); err != nil {
+		return
+	}
+
+	folderNames = make([]string, 0, len(w.folders))
+
+	for fn := range w.folders {
+		folderNames = append(folderNames, fn)
+	}
+
+	sort.Strings(folderNames)
+
+	return
+}
+
+// HasFolder returns whether a wallet contains a folder.
+func (m *MemoryBackend) HasFolder(handle int32, folderName, appID string) (hasFolder bool, err error) {
+
+	var w *memWallet
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if _, w, err = m.getWallet(handle); err != nil {
+		return
+	}
+
+	_, hasFolder = w.folders[folderName]
+
+	return
+}
+
+// FolderDoesNotExist returns whether a folder does *not* exist.
+func (m *MemoryBackend) FolderDoesNotExist(walletName, folderName string) (notExist bool, err error) {
+
+	var w *memWallet
+	var ok bool
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if w, ok = m.wallets[walletName]; !ok {
+		notExist = true
+		return
+	}
+
+	_, ok = w.folders[folderName]
+	notExist = !ok
+
+	return
+}
+
+// CreateFolder creates a folder in a wallet. It is not an error if the folder already exists.
+func (m *MemoryBackend) CreateFolder(handle int32, folderName, appID string) (err error) {
+
+	var w *memWallet
+	var walletName string
+
+	m.lock.Lock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		m.lock.Unlock()
+		return
+	}
+
+	if _, ok := w.folders[folderName]; ok {
+		m.lock.Unlock()
+		return
+	}
+
+	w.folders[folderName] = &memFolder{
+		entries: make(map[string]*memEntry),
+	}
+
+	m.lock.Unlock()
+
+	err = m.changed(walletName)
+
+	return
+}
+
+// RemoveFolder removes a folder, and all of its entries, from a wallet.
+func (m *MemoryBackend) RemoveFolder(handle int32, folderName, appID string) (err error) {
+
+	var w *memWallet
+	var walletName string
+
+	m.lock.Lock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		m.lock.Unlock()
+		return
+	}
+
+	if _, ok := w.folders[folderName]; !ok {
+		m.lock.Unlock()
+		err = ErrDbusOpfailRemoveFolder
+		return
+	}
+
+	delete(w.folders, folderName)
+
+	m.lock.Unlock()
+
+	err = m.changed(walletName)
+
+	return
+}
+
+// EntryList returns the names of all entries in a folder.
+func (m *MemoryBackend) EntryList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = m.listEntries(handle, folderName, KwalletdEnumTypeUnused)
+
+	return
+}
+
+// PasswordList returns the names of all Password entries in a folder.
+func (m *MemoryBackend) PasswordList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = m.listEntries(handle, folderName, KwalletdEnumTypePassword)
+
+	return
+}
+
+// MapList returns the names of all Map entries in a folder.
+func (m *MemoryBackend) MapList(handle int32, folderName, appID string) (entryNames []string, err error) {
+
+	entryNames, err = m.listEntries(handle, folderName, KwalletdEnumTypeMap)
+
+	return
+}
+
+// EntryType returns the type of an entry.
+func (m *MemoryBackend) EntryType(handle int32, folderName, entryName, appID string) (entryType kwalletdEnumType, err error) {
+
+	var e *memEntry
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if e, err = m.getEntry(handle, folderName, entryName); err != nil {
+		return
+	}
+
+	entryType = e.entryType
+
+	return
+}
+
+// HasEntry returns whether a folder contains an entry.
+func (m *MemoryBackend) HasEntry(handle int32, folderName, entryName, appID string) (hasEntry bool, err error) {
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if _, err = m.getEntry(handle, folderName, entryName); err != nil {
+		if err == ErrBackendNoFolder || err == ErrBackendNoEntry {
+			err = nil
+		}
+		return
+	}
+
+	hasEntry = true
+
+	return
+}
+
+// KeyDoesNotExist returns whether an entry does *not* exist.
+func (m *MemoryBackend) KeyDoesNotExist(walletName, folderName, entryName string) (notExist bool, err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var ok bool
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	notExist = true
+
+	if w, ok = m.wallets[walletName]; !ok {
+		return
+	}
+	if f, ok = w.folders[folderName]; !ok {
+		return
+	}
+
+	_, ok = f.entries[entryName]
+	notExist = !ok
+
+	return
+}
+
+// ReadEntry returns the raw (serialized) value of an entry.
+func (m *MemoryBackend) ReadEntry(handle int32, folderName, entryName, appID string) (value []byte, err error) {
+
+	var e *memEntry
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if e, err = m.getEntry(handle, folderName, entryName); err != nil {
+		return
+	}
+
+	value = make([]byte, len(e.value))
+	copy(value, e.value)
+
+	return
+}
+
+// ReadPassword returns the value of a Password entry.
+func (m *MemoryBackend) ReadPassword(handle int32, folderName, entryName, appID string) (value string, err error) {
+
+	var e *memEntry
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if e, err = m.getEntry(handle, folderName, entryName); err != nil {
+		return
+	}
+
+	if e.entryType != KwalletdEnumTypePassword {
+		err = ErrBackendEntryType
+		return
+	}
+
+	if value, err = qStringToString(e.value); err != nil {
+		return
+	}
+
+	return
+}
+
+// ReadMap returns the value of a Map entry.
+func (m *MemoryBackend) ReadMap(handle int32, folderName, entryName, appID string) (value map[string]string, err error) {
+
+	var e *memEntry
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if e, err = m.getEntry(handle, folderName, entryName); err != nil {
+		return
+	}
+
+	if e.entryType != KwalletdEnumTypeMap {
+		err = ErrBackendEntryType
+		return
+	}
+
+	value = make(map[string]string, 0)
+
+	if len(e.value) != 0 {
+		if value, _, err = bytesToMap(e.value); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+	WriteEntry writes a raw (serialized) value as an entry of type entryType.
+	Like kwalletd, the folder is created if it does not exist.
+*/
+func (m *MemoryBackend) WriteEntry(
+	handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string,
+) (err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var walletName string
+	var ok bool
+
+	if entryType == KwalletdEnumTypeUnused {
+		err = ErrNoCreate
+		return
+	}
+
+	m.lock.Lock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		m.lock.Unlock()
+		return
+	}
+
+	if f, ok = w.folders[folderName]; !ok {
+		f = &memFolder{
+			entries: make(map[string]*memEntry),
+		}
+		w.folders[folderName] = f
+	}
+
+	f.entries[entryName] = &memEntry{
+		entryType: entryType,
+		value:     make([]byte, len(value)),
+	}
+	copy(f.entries[entryName].value, value)
+
+	m.lock.Unlock()
+
+	err = m.changed(walletName)
+
+	return
+}
+
+// WritePassword writes a Password entry.
+func (m *MemoryBackend) WritePassword(handle int32, folderName, entryName, value, appID string) (err error) {
+
+	err = m.WriteEntry(handle, folderName, entryName, KwalletdEnumTypePassword, stringToQString(value), appID)
+
+	return
+}
+
+// WriteMap writes a Map entry.
+func (m *MemoryBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	var b []byte
+
+	if b, err = mapToBytes(value); err != nil {
+		return
+	}
+
+	err = m.WriteEntry(handle, folderName, entryName, KwalletdEnumTypeMap, b, appID)
+
+	return
+}
+
+// RemoveEntry removes an entry from a folder.
+func (m *MemoryBackend) RemoveEntry(handle int32, folderName, entryName, appID string) (err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var walletName string
+	var ok bool
+
+	m.lock.Lock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		m.lock.Unlock()
+		return
+	}
+
+	if f, ok = w.folders[folderName]; ok {
+		delete(f.entries, entryName)
+	}
+
+	m.lock.Unlock()
+
+	err = m.changed(walletName)
+
+	return
+}
+
+// RenameEntry renames an entry in a folder. Any existing entry named newEntryName is replaced.
+func (m *MemoryBackend) RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var e *memEntry
+	var walletName string
+	var ok bool
+
+	m.lock.Lock()
+
+	if walletName, w, err = m.getWallet(handle); err != nil {
+		m.lock.Unlock()
+		return
+	}
+
+	if f, ok = w.folders[folderName]; !ok {
+		m.lock.Unlock()
+		err = ErrOperationFailed
+		return
+	}
+	if e, ok = f.entries[entryName]; !ok {
+		m.lock.Unlock()
+		err = ErrOperationFailed
+		return
+	}
+
+	delete(f.entries, entryName)
+	f.entries[newEntryName] = e
+
+	m.lock.Unlock()
+
+	err = m.changed(walletName)
+
+	return
+}
+
+// Release is a no-op for a MemoryBackend.
+func (m *MemoryBackend) Release() (err error) {
+
+	return
+}
+
+// changed runs MemoryBackend.onChange (if set) for walletName.
+func (m *MemoryBackend) changed(walletName string) (err error) {
+
+	if m.onChange == nil {
+		return
+	}
+
+	err = m.onChange(walletName)
+
+	return
+}
+
+// closeWallet closes a wallet and invalidates all of its handles. The caller must hold MemoryBackend.lock.
+func (m *MemoryBackend) closeWallet(walletName string) {
+
+	var w *memWallet
+	var ok bool
+
+	if w, ok = m.wallets[walletName]; !ok {
+		return
+	}
+
+	w.isOpen = false
+	w.users = make(map[string]int)
+
+	for h, wn := range m.handles {
+		if wn == walletName {
+			delete(m.handles, h)
+		}
+	}
+
+	return
+}
+
+// getEntry returns the memEntry for a handle, folderName, and entryName. The caller must hold MemoryBackend.lock.
+func (m *MemoryBackend) getEntry(handle int32, folderName, entryName string) (e *memEntry, err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var ok bool
+
+	if _, w, err = m.getWallet(handle); err != nil {
+		return
+	}
+
+	if f, ok = w.folders[folderName]; !ok {
+		err = ErrBackendNoFolder
+		return
+	}
+	if e, ok = f.entries[entryName]; !ok {
+		err = ErrBackendNoEntry
+		return
+	}
+
+	return
+}
+
+// getOrCreateWallet returns the memWallet named walletName, creating it if needed. The caller must hold MemoryBackend.lock.
+func (m *MemoryBackend) getOrCreateWallet(walletName string) (w *memWallet, created bool) {
+
+	var ok bool
+
+	if w, ok = m.wallets[walletName]; ok {
+		return
+	}
+
+	w = &memWallet{
+		folders: make(map[string]*memFolder),
+		users:   make(map[string]int),
+		isOpen:  false,
+	}
+	m.wallets[walletName] = w
+	created = true
+
+	return
+}
+
+// getWallet returns the wallet name and memWallet for a handle. The caller must hold MemoryBackend.lock.
+func (m *MemoryBackend) getWallet(handle int32) (walletName string, w *memWallet, err error) {
+
+	var ok bool
+
+	if walletName, ok = m.handles[handle]; !ok {
+		err = ErrBackendHandle
+		return
+	}
+	if w, ok = m.wallets[walletName]; !ok {
+		err = ErrBackendHandle
+		return
+	}
+
+	return
+}
+
+/*
+	listEntries returns the (sorted) names of entries in a folder of type entryType.
+	If entryType is KwalletdEnumTypeUnused, entries of all types are returned.
+*/
+func (m *MemoryBackend) listEntries(handle int32, folderName string, entryType kwalletdEnumType) (entryNames []string, err error) {
+
+	var w *memWallet
+	var f *memFolder
+	var ok bool
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if _, w, err = m.getWallet(handle); err != nil {
+		return
+	}
+
+	entryNames = make([]string, 0)
+
+	if f, ok = w.folders[folderName]; !ok {
+		return
+	}
+
+	for en, e := range f.entries {
+		if entryType != KwalletdEnumTypeUnused && e.entryType != entryType {
+			continue
+		}
+		entryNames = append(entryNames, en)
+	}
+
+	sort.Strings(entryNames)
+
+	return
+}
@@ -0,0 +1,11 @@
+package gokwallet
+
+import (
+	"testing"
+)
+
+// TestMemoryBackend runs the Backend conformance suite against a MemoryBackend.
+func TestMemoryBackend(t *testing.T) {
+
+	testBackendConformance(t, NewMemoryBackend())
+}
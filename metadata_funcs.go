@@ -0,0 +1,320 @@
+package gokwallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+/*
+	EnableMetadata turns on EntryMetadata tracking for a WalletManager.
+	From then on, every write, rename, and removal of a WalletItem made through this WalletManager
+	(via Folder.WritePassword, Password.SetValue, Folder.RenameEntry, Wallet.RemoveFolder, Tx.Commit, etc.)
+	also updates that WalletItem's EntryMetadata, which is kept in the reserved Folder MetadataFolder of each Wallet.
+
+	If a write succeeds but its EntryMetadata cannot be updated, the returned error wraps ErrBookkeeping.
+
+	Changes made by other applications (or by a WalletManager without metadata enabled) are not tracked.
+*/
+func (wm *WalletManager) EnableMetadata() (err error) {
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	wm.swapBackend(func(cur Backend) (next Backend) {
+
+		var t *trackingBackend = newTrackingBackend(cur)
+
+		t.metadata = true
+		next = t
+
+		return
+	})
+
+	return
+}
+
+// MetadataEnabled returns true if WalletManager.EnableMetadata has been called.
+func (wm *WalletManager) MetadataEnabled() (enabled bool) {
+
+	var t *trackingBackend
+	var ok bool
+
+	if t, ok = wm.Backend().(*trackingBackend); ok {
+		enabled = t.metadata
+	}
+
+	return
+}
+
+/*
+	EntryMetadata returns the EntryMetadata of WalletItem entryName in a Folder.
+	md is nil (with a nil err) if no metadata has been recorded for the WalletItem yet
+	(e.g. it was last written before metadata was enabled, or by another application).
+*/
+func (f *Folder) EntryMetadata(entryName string) (md *EntryMetadata, err error) {
+
+	if err = f.metadataCheck(entryName); err != nil {
+		return
+	}
+
+	if md, err = readMetadata(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetEntryNote sets the EntryMetadata.Note of WalletItem entryName in a Folder. An empty note removes it.
+func (f *Folder) SetEntryNote(entryName, note string) (err error) {
+
+	var md *EntryMetadata
+
+	if md, err = f.metadataForUpdate(entryName); err != nil {
+		return
+	}
+
+	md.Note = note
+
+	if err = writeMetadata(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, md, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetEntryTags replaces the EntryMetadata.Tags of WalletItem entryName in a Folder. No tags removes them.
+func (f *Folder) SetEntryTags(entryName string, tags ...string) (err error) {
+
+	var md *EntryMetadata
+
+	if md, err = f.metadataForUpdate(entryName); err != nil {
+		return
+	}
+
+	md.Tags = tags
+
+	if err = writeMetadata(f.wallet.wm.Backend(), f.wallet.handle, f.Name, entryName, md, f.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// metadataCheck returns an error if EntryMetadata is not enabled or WalletItem entryName does not exist.
+func (f *Folder) metadataCheck(entryName string) (err error) {
+
+	var exists bool
+
+	if !f.wallet.wm.MetadataEnabled() {
+		err = ErrMetadataDisabled
+		return
+	}
+
+	if exists, err = f.HasEntry(entryName); err != nil {
+		return
+	} else if !exists {
+		err = fmt.Errorf("%w: %#v/%#v/%#v", ErrBackendNoEntry, f.wallet.Name, f.Name, entryName)
+		return
+	}
+
+	return
+}
+
+// metadataForUpdate returns the current (or a new, empty) EntryMetadata for WalletItem entryName.
+func (f *Folder) metadataForUpdate(entryName string) (md *EntryMetadata, err error) {
+
+	if md, err = f.EntryMetadata(entryName); err != nil {
+		return
+	}
+
+	if md == nil {
+		md = new(EntryMetadata)
+	}
+
+	return
+}
+
+/*
+	transferMetadata carries the EntryMetadata.Created, EntryMetadata.Tags, and EntryMetadata.Note of WalletItem srcEntry
+	in Folder src over to WalletItem dstEntry in Folder dst (e.g. when moving a WalletItem).
+	It is a no-op unless both have metadata enabled and src has EntryMetadata.
+*/
+func transferMetadata(src *Folder, srcEntry string, dst *Folder, dstEntry string) (err error) {
+
+	var srcMd *EntryMetadata
+	var dstMd *EntryMetadata
+
+	if !src.wallet.wm.MetadataEnabled() || !dst.wallet.wm.MetadataEnabled() {
+		return
+	}
+
+	if srcMd, err = readMetadata(src.wallet.wm.Backend(), src.wallet.handle, src.Name, srcEntry, src.wallet.wm.AppID); err != nil {
+		return
+	} else if srcMd == nil {
+		return
+	}
+
+	if dstMd, err = readMetadata(dst.wallet.wm.Backend(), dst.wallet.handle, dst.Name, dstEntry, dst.wallet.wm.AppID); err != nil {
+		return
+	} else if dstMd == nil {
+		dstMd = srcMd
+	}
+
+	dstMd.Created = srcMd.Created
+	dstMd.Tags = srcMd.Tags
+	dstMd.Note = srcMd.Note
+
+	if err = writeMetadata(dst.wallet.wm.Backend(), dst.wallet.handle, dst.Name, dstEntry, dstMd, dst.wallet.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// metadataKey returns the name of the Map in MetadataFolder that holds the EntryMetadata for entryName in folderName.
+func metadataKey(folderName, entryName string) (key string) {
+
+	key = url.PathEscape(folderName) + "/" + url.PathEscape(entryName)
+
+	return
+}
+
+// readMetadata reads the EntryMetadata for entryName in folderName. md is nil if there is none.
+func readMetadata(backend Backend, handle int32, folderName, entryName, appID string) (md *EntryMetadata, err error) {
+
+	var exists bool
+	var m map[string]string
+	var key string = metadataKey(folderName, entryName)
+
+	if exists, err = backend.HasEntry(handle, MetadataFolder, key, appID); err != nil || !exists {
+		return
+	}
+
+	if m, err = backend.ReadMap(handle, MetadataFolder, key, appID); err != nil {
+		return
+	}
+
+	if md, err = mapToMetadata(m); err != nil {
+		return
+	}
+
+	return
+}
+
+// removeMetadata removes the EntryMetadata for entryName in folderName (if any).
+func removeMetadata(backend Backend, handle int32, folderName, entryName, appID string) (err error) {
+
+	var exists bool
+	var key string = metadataKey(folderName, entryName)
+
+	if exists, err = backend.HasEntry(handle, MetadataFolder, key, appID); err != nil || !exists {
+		return
+	}
+
+	if err = backend.RemoveEntry(handle, MetadataFolder, key, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// touchMetadata updates (or creates) the EntryMetadata for entryName in folderName after it has been written by appID.
+func touchMetadata(backend Backend, handle int32, folderName, entryName, appID string) (err error) {
+
+	var md *EntryMetadata
+	var now time.Time = time.Now()
+
+	if md, err = readMetadata(backend, handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if md == nil {
+		md = &EntryMetadata{
+			Created: now,
+		}
+	}
+
+	md.Modified = now
+	md.ModifiedBy = appID
+
+	if err = writeMetadata(backend, handle, folderName, entryName, md, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// writeMetadata writes the EntryMetadata for entryName in folderName.
+func writeMetadata(backend Backend, handle int32, folderName, entryName string, md *EntryMetadata, appID string) (err error) {
+
+	var m map[string]string
+
+	if m, err = metadataToMap(md); err != nil {
+		return
+	}
+
+	if err = backend.WriteMap(handle, MetadataFolder, metadataKey(folderName, entryName), m, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// mapToMetadata converts a Map value (as stored in MetadataFolder) to an EntryMetadata.
+func mapToMetadata(m map[string]string) (md *EntryMetadata, err error) {
+
+	md = &EntryMetadata{
+		ModifiedBy: m[metadataKeyModifiedBy],
+		Note:       m[metadataKeyNote],
+	}
+
+	if m[metadataKeyCreated] != "" {
+		if md.Created, err = time.Parse(time.RFC3339Nano, m[metadataKeyCreated]); err != nil {
+			return
+		}
+	}
+	if m[metadataKeyModified] != "" {
+		if md.Modified, err = time.Parse(time.RFC3339Nano, m[metadataKeyModified]); err != nil {
+			return
+		}
+	}
+	if m[metadataKeyTags] != "" {
+		if err = json.Unmarshal([]byte(m[metadataKeyTags]), &md.Tags); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// metadataToMap converts an EntryMetadata to a Map value (as stored in MetadataFolder).
+func metadataToMap(md *EntryMetadata) (m map[string]string, err error) {
+
+	var b []byte
+
+	m = map[string]string{
+		metadataKeyModifiedBy: md.ModifiedBy,
+	}
+
+	if !md.Created.IsZero() {
+		m[metadataKeyCreated] = md.Created.Format(time.RFC3339Nano)
+	}
+	if !md.Modified.IsZero() {
+		m[metadataKeyModified] = md.Modified.Format(time.RFC3339Nano)
+	}
+	if len(md.Tags) != 0 {
+		if b, err = json.Marshal(md.Tags); err != nil {
+			return
+		}
+		m[metadataKeyTags] = string(b)
+	}
+	if md.Note != "" {
+		m[metadataKeyNote] = md.Note
+	}
+
+	return
+}
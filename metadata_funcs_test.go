@@ -0,0 +1,152 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMetadata tests EntryMetadata tracking.
+func TestMetadata(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var p *Password
+	var md *EntryMetadata
+	var created *EntryMetadata
+	var folders []string
+	var exists bool
+	var found bool
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var renamedFolder string = folderTest.String() + "_md"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+
+	if p, err = e.f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if _, err = p.Metadata(); !errors.Is(err, ErrMetadataDisabled) {
+		t.Errorf("expected ErrMetadataDisabled, got %v", err)
+	}
+
+	if err = e.wm.EnableMetadata(); err != nil {
+		t.Fatalf("failed to EnableMetadata: %v", err)
+	}
+	if !e.wm.MetadataEnabled() {
+		t.Fatalf("MetadataEnabled is false after EnableMetadata")
+	}
+
+	// Written before metadata was enabled.
+	if md, err = p.Metadata(); err != nil || md != nil {
+		t.Errorf("expected no EntryMetadata for an untracked Password, got %#v (err: %v)", md, err)
+	}
+
+	if err = p.SetValue(testPasswordReplace); err != nil {
+		t.Fatalf("failed to SetValue: %v", err)
+	}
+	if created, err = p.Metadata(); err != nil || created == nil {
+		t.Fatalf("expected EntryMetadata after SetValue, got %#v (err: %v)", created, err)
+	}
+	if created.Created.IsZero() || !created.Modified.Equal(created.Created) || created.ModifiedBy != appIdTest {
+		t.Errorf("unexpected EntryMetadata after first write: %#v", created)
+	}
+
+	if err = p.SetTags("rotated", "db"); err != nil {
+		t.Fatalf("failed to SetTags: %v", err)
+	}
+	if err = p.SetNote("rotate quarterly"); err != nil {
+		t.Fatalf("failed to SetNote: %v", err)
+	}
+	if err = p.SetValue(testPassword); err != nil {
+		t.Fatalf("failed to SetValue: %v", err)
+	}
+	if md, err = p.Metadata(); err != nil {
+		t.Fatalf("failed to get Metadata: %v", err)
+	}
+	if !md.Created.Equal(created.Created) || md.Modified.Before(created.Modified) {
+		t.Errorf("unexpected timestamps after rewrite: %#v", md)
+	}
+	if len(md.Tags) != 2 || md.Tags[0] != "rotated" || md.Note != "rotate quarterly" {
+		t.Errorf("tags/note not kept across a write: %#v", md)
+	}
+
+	// Renames carry metadata along.
+	if err = p.Rename(passwordTestRename.String()); err != nil {
+		t.Fatalf("failed to Rename: %v", err)
+	}
+	if md, err = p.Metadata(); err != nil || md == nil || md.Note != "rotate quarterly" {
+		t.Errorf("EntryMetadata not carried over by Rename: %#v (err: %v)", md, err)
+	}
+	if exists, err = e.f.wallet.wm.backend.HasEntry(
+		e.w.handle, MetadataFolder, metadataKey(e.f.Name, passwordTest.String()), appIdTest,
+	); err != nil || exists {
+		t.Errorf("old EntryMetadata not removed by Rename (err: %v)", err)
+	}
+
+	if err = e.f.Rename(renamedFolder); err != nil {
+		t.Fatalf("failed to Folder.Rename: %v", err)
+	}
+	if md, err = p.Metadata(); err != nil || md == nil || !md.Created.Equal(created.Created) || md.Note != "rotate quarterly" {
+		t.Errorf("EntryMetadata not carried over by Folder.Rename: %#v (err: %v)", md, err)
+	}
+
+	// The metadata Folder is hidden.
+	if folders, err = e.w.ListFolders(); err != nil {
+		t.Fatalf("failed to ListFolders: %v", err)
+	}
+	for _, fn := range folders {
+		if fn == MetadataFolder {
+			t.Errorf("%v returned by ListFolders", MetadataFolder)
+		}
+	}
+	// ...but only while metadata is enabled.
+	if wm, err = NewWalletManagerBackend(baseBackend(e.wm.backend), &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if w, err = NewWallet(wm, e.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if folders, err = w.ListFolders(); err != nil {
+		t.Fatalf("failed to ListFolders: %v", err)
+	}
+	for _, fn := range folders {
+		if fn == MetadataFolder {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("%v not returned by ListFolders with metadata disabled", MetadataFolder)
+	}
+
+	if err = p.Delete(); err != nil {
+		t.Fatalf("failed to Delete: %v", err)
+	}
+	if exists, err = e.f.wallet.wm.backend.HasEntry(
+		e.w.handle, MetadataFolder, metadataKey(renamedFolder, passwordTestRename.String()), appIdTest,
+	); err != nil || exists {
+		t.Errorf("EntryMetadata not removed with its WalletItem (err: %v)", err)
+	}
+
+	// A metadata write failure after a successful write is reported as ErrBookkeeping.
+	if wm, err = NewWalletManagerBackend(&metadataFailBackend{Backend: baseBackend(e.wm.backend)}, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if err = wm.EnableMetadata(); err != nil {
+		t.Fatalf("failed to EnableMetadata: %v", err)
+	}
+	if w, err = NewWallet(wm, e.w.Name, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if f, err = NewFolder(w, renamedFolder, &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Folder: %v", err)
+	}
+	if _, err = f.WritePassword(passwordTest.String(), testPassword); !errors.Is(err, ErrBookkeeping) {
+		t.Errorf("expected ErrBookkeeping, got %v", err)
+	}
+	if p, err = NewPassword(f, passwordTest.String(), e.r); err != nil || p.Value != testPassword {
+		t.Errorf("Password not written despite a bookkeeping failure (err: %v)", err)
+	}
+}
@@ -1,7 +1,11 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 )
 
 /*
@@ -29,7 +33,7 @@ func NewPassword(f *Folder, keyName string, recursion *RecurseOpts) (password *P
 
 	password.isInit = true
 
-	if password.Recurse.AllWalletItems || password.Recurse.Passwords {
+	if !password.Recurse.Lazy && (password.Recurse.AllWalletItems || password.Recurse.Passwords) {
 		if err = password.Update(); err != nil {
 			return
 		}
@@ -74,39 +78,332 @@ func (p *Password) Rename(newName string) (err error) {
 	return
 }
 
-// SetValue will replace this Password's Password.Value.
+// SetValue will replace this Password's Password.Value (or, in secure mode, Password.Secret).
 func (p *Password) SetValue(newValue string) (err error) {
 
 	if _, err = p.folder.WritePassword(p.Name, newValue); err != nil {
 		return
 	}
 
-	p.Value = newValue
+	if err = p.setValue(newValue); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	SetValueIf replaces this Password's value with newValue, but only if its stored value is still expectedOld.
+	Otherwise (or if the Password was removed or replaced by another type) a *ConflictError is returned, nothing is written,
+	and Password.Value is refreshed with the stored value so the caller can retry.
+*/
+func (p *Password) SetValueIf(expectedOld, newValue string) (err error) {
+
+	var cur []byte
+	var s string
+
+	if cur, err = p.folder.casEntry(
+		p.Name,
+		KwalletdEnumTypePassword,
+		func(cur []byte) (isMatch bool, err error) {
+			if s, err = qStringToString(cur); err != nil {
+				return
+			}
+			isMatch = s == expectedOld
+			return
+		},
+		stringToQString(newValue),
+	); err != nil {
+		if cur != nil && errors.Is(err, ErrConflict) {
+			// The *ConflictError is what matters to the caller.
+			_ = p.setValue(s)
+		}
+		return
+	}
+
+	if err = p.setValue(newValue); err != nil {
+		return
+	}
 
 	return
 }
 
-// Update fetches a Password's Password.Value.
+// Update fetches a Password's Password.Value (or, in secure mode, Password.Secret).
 func (p *Password) Update() (err error) {
 
-	var call *dbus.Call
+	var value string
+	var secret *SecretBytes
+
+	if p.folder.wallet.wm.secure {
+		if secret, err = p.readSecret(); err != nil {
+			return
+		}
+		_ = p.Secret.Wipe()
+		p.Secret = secret
+		p.loaded = true
+		return
+	}
+
+	if value, err = p.read(); err != nil {
+		return
+	}
+
+	p.Value = value
+	p.loaded = true
+
+	return
+}
+
+/*
+	GetValue returns a Password's value, fetching it if it has not been fetched (or set) yet,
+	e.g. if the Password was created with RecurseOpts.Lazy.
+	The fetched value is only kept (in Password.Value, or Password.Secret in secure mode) if RecurseOpts.CacheValues is true;
+	otherwise it is fetched on every call.
+	Unlike reading Password.Value directly, a failed fetch (e.g. an *ExpiredError) is returned rather than an empty value.
+*/
+func (p *Password) GetValue() (value string, err error) {
+
+	if !p.loaded && p.Recurse != nil && p.Recurse.CacheValues {
+		if err = p.Update(); err != nil {
+			return
+		}
+	}
+
+	if p.loaded {
+		value = p.value()
+		return
+	}
+
+	if value, err = p.read(); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Wipe wipes this Password's value from memory: Password.Secret (in secure mode) is zeroed in place (see SecretBytes.Wipe),
+	so it will be fetched again if needed. Password.Value is a string, which can't be zeroed, so outside of secure mode
+	it is only dropped (see WalletManager.EnableSecureMode).
+*/
+func (p *Password) Wipe() (err error) {
+
+	err = p.Secret.Wipe()
+
+	p.Secret = nil
+	p.Value = ""
+	p.loaded = false
+
+	return
+}
+
+// read fetches a Password's value from the Backend.
+func (p *Password) read() (value string, err error) {
+
+	if err = p.folder.wallet.walletCheck(); err != nil {
+		return
+	}
+
+	if err = p.folder.expiryCheck(p.Name); err != nil {
+		return
+	}
+
+	if value, err = p.folder.wallet.wm.Backend().ReadPassword(
+		p.folder.wallet.handle, p.folder.Name, p.Name, p.folder.wallet.wm.AppID,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// readSecret fetches a Password's value from the Backend into a SecretBytes, without it ever being a string.
+func (p *Password) readSecret() (secret *SecretBytes, err error) {
+
+	var raw []byte
 	var b []byte
 
 	if err = p.folder.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = p.Dbus.Call(
-		DbusWMReadPassword, 0, p.folder.wallet.handle, p.folder.Name, p.Name, p.folder.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = p.folder.expiryCheck(p.Name); err != nil {
+		return
+	}
+
+	if raw, err = p.folder.wallet.wm.Backend().ReadEntry(
+		p.folder.wallet.handle, p.folder.Name, p.Name, p.folder.wallet.wm.AppID,
+	); err != nil {
+		return
+	}
+	defer wipeBytes(raw)
+
+	if b, err = qStringToBytes(raw); err != nil {
+		return
+	}
+	defer wipeBytes(b)
+
+	if secret, err = p.folder.wallet.wm.newSecret(b); err != nil {
+		return
+	}
+
+	return
+}
+
+// setValue sets a Password's known value (in Password.Secret in secure mode, otherwise Password.Value).
+func (p *Password) setValue(value string) (err error) {
+
+	var secret *SecretBytes
+
+	if p.folder.wallet.wm.secure {
+		if secret, err = p.folder.wallet.wm.newSecret([]byte(value)); err != nil {
+			return
+		}
+		_ = p.Secret.Wipe()
+		p.Secret = secret
+		p.Value = ""
+	} else {
+		p.Value = value
+	}
+
+	p.loaded = true
+
+	return
+}
+
+// value returns a Password's known value (from Password.Secret in secure mode, otherwise Password.Value).
+func (p *Password) value() (value string) {
+
+	if p.Secret != nil {
+		value = string(p.Secret.Bytes())
+		return
+	}
+
+	value = p.Value
+
+	return
+}
+
+/*
+	Metadata returns the EntryMetadata of this Password (see Folder.EntryMetadata).
+	md is nil if no metadata has been recorded for it yet.
+*/
+func (p *Password) Metadata() (md *EntryMetadata, err error) {
+
+	if md, err = p.folder.EntryMetadata(p.Name); err != nil {
 		return
 	}
-	if err = call.Store(&b); err != nil {
+
+	return
+}
+
+// SetNote sets the EntryMetadata.Note of this Password.
+func (p *Password) SetNote(note string) (err error) {
+
+	if err = p.folder.SetEntryNote(p.Name, note); err != nil {
 		return
 	}
 
-	p.Value = string(b)
+	return
+}
+
+// SetTags replaces the EntryMetadata.Tags of this Password.
+func (p *Password) SetTags(tags ...string) (err error) {
+
+	if err = p.folder.SetEntryTags(p.Name, tags...); err != nil {
+		return
+	}
+
+	return
+}
+
+// History returns the retained previous values of this Password, newest first (see Folder.History).
+func (p *Password) History() (versions []*HistoryVersion, err error) {
+
+	if versions, err = p.folder.History(p.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// RestoreVersion restores HistoryVersion versionID of this Password (see Folder.RestoreVersion) and updates Password.Value.
+func (p *Password) RestoreVersion(versionID string) (err error) {
+
+	if err = p.folder.RestoreVersion(p.Name, versionID); err != nil {
+		return
+	}
+
+	if err = p.Update(); err != nil {
+		return
+	}
+
+	return
+}
+
+// Expiry returns when this Password expires (see Folder.EntryExpiry). expires is zero if it does not expire.
+func (p *Password) Expiry() (expires time.Time, err error) {
+
+	if expires, err = p.folder.EntryExpiry(p.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetExpiry sets when this Password expires (see Folder.SetEntryExpiry). A zero expires removes its expiry.
+func (p *Password) SetExpiry(expires time.Time) (err error) {
+
+	if err = p.folder.SetEntryExpiry(p.Name, expires); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	String returns a representation of a Password with its value replaced by RedactedValue,
+	unless WalletManager.SetShowValues(true) was called.
+*/
+func (p Password) String() (str string) {
+
+	var value string = RedactedValue
+
+	if p.wm.ShowValuesEnabled() {
+		value = strconv.Quote(p.value())
+	}
+
+	str = fmt.Sprintf("Password{Name: %q, Value: %v}", p.Name, value)
+
+	return
+}
+
+// Format prints Password.String for every verb (see formatRedacted).
+func (p Password) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, p.String())
+
+	return
+}
+
+// MarshalJSON encodes a Password with its value replaced by RedactedValue, unless WalletManager.SetShowValues(true) was called.
+func (p Password) MarshalJSON() (b []byte, err error) {
+
+	var value interface{} = RedactedValue
+
+	if p.wm.ShowValuesEnabled() {
+		value = p.value()
+	}
+
+	if b, err = json.Marshal(&struct {
+		*jsonPassword
+		Value interface{} `json:"value"`
+	}{
+		jsonPassword: (*jsonPassword)(&p),
+		Value:        value,
+	}); err != nil {
+		return
+	}
 
 	return
 }
@@ -1,6 +1,7 @@
 package gokwallet
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -51,3 +52,48 @@ func TestPassword(t *testing.T) {
 	}
 
 }
+
+// TestPasswordSetValueIf tests conditional writes of a Password.
+func TestPasswordSetValueIf(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var p *Password
+	var other *Password
+	var ce *ConflictError
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if p, err = e.f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if other, err = NewPassword(e.f, passwordTest.String(), e.r); err != nil {
+		t.Fatalf("failed to get Password: %v", err)
+	}
+
+	if err = p.SetValueIf(testPassword, testPasswordReplace); err != nil {
+		t.Fatalf("failed to SetValueIf: %v", err)
+	}
+
+	// other still thinks the value is testPassword.
+	if err = other.SetValueIf(other.Value, testPassword); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if !errors.As(err, &ce) || ce.Entry != passwordTest.String() {
+		t.Errorf("expected a *ConflictError for %v, got %#v", passwordTest.String(), err)
+	}
+	if other.Value != testPasswordReplace {
+		t.Errorf("Password.Value not refreshed after a conflict")
+	}
+	if err = other.Update(); err != nil || other.Value != testPasswordReplace {
+		t.Errorf("conflicting SetValueIf overwrote the Password (err: %v)", err)
+	}
+
+	if err = p.Delete(); err != nil {
+		t.Fatalf("failed to Delete: %v", err)
+	}
+	if err = other.SetValueIf(testPasswordReplace, testPassword); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict for a removed Password, got %v", err)
+	}
+}
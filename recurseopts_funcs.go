@@ -0,0 +1,180 @@
+package gokwallet
+
+import (
+	"fmt"
+	"regexp"
+)
+
+/*
+	checkFilters returns an ErrRecurseFilter error if any of the name filters of a RecurseOpts is not a valid glob.
+	It is called when a WalletManager, Wallet, or Folder is created with the RecurseOpts, so bad globs are reported then
+	rather than on the first name they are matched against.
+*/
+func (r *RecurseOpts) checkFilters() (err error) {
+
+	if _, err = r.compiledFilters(); err != nil {
+		return
+	}
+
+	return
+}
+
+// wantWallet returns true if Wallet walletName passes RecurseOpts.IncludeWallets and RecurseOpts.ExcludeWallets.
+func (r *RecurseOpts) wantWallet(walletName string) (wanted bool, err error) {
+
+	var c *recurseFilters
+
+	if c, err = r.compiledFilters(); err != nil {
+		return
+	}
+
+	wanted = c.wallets.want(walletName)
+
+	return
+}
+
+// wantFolder returns true if Folder folderName passes RecurseOpts.IncludeFolders and RecurseOpts.ExcludeFolders.
+func (r *RecurseOpts) wantFolder(folderName string) (wanted bool, err error) {
+
+	var c *recurseFilters
+
+	if c, err = r.compiledFilters(); err != nil {
+		return
+	}
+
+	wanted = c.folders.want(folderName)
+
+	return
+}
+
+// wantEntry returns true if WalletItem entryName passes RecurseOpts.IncludeEntries and RecurseOpts.ExcludeEntries.
+func (r *RecurseOpts) wantEntry(entryName string) (wanted bool, err error) {
+
+	var c *recurseFilters
+
+	if c, err = r.compiledFilters(); err != nil {
+		return
+	}
+
+	wanted = c.entries.want(entryName)
+
+	return
+}
+
+/*
+	compiledFilters returns the compiled name filters of a RecurseOpts, compiling them only if they haven't been yet
+	(or the globs have been changed since).
+*/
+func (r *RecurseOpts) compiledFilters() (c *recurseFilters, err error) {
+
+	var globs [][]string = r.filterGlobs()
+	var filters []*globFilter = make([]*globFilter, 3)
+
+	recurseFiltersLock.Lock()
+	defer recurseFiltersLock.Unlock()
+
+	if r.filters != nil && r.filters.compiledFrom(globs) {
+		c = r.filters
+		return
+	}
+
+	c = &recurseFilters{
+		globs: make([][]string, len(globs)),
+	}
+
+	for idx := range globs {
+		c.globs[idx] = append([]string(nil), globs[idx]...)
+	}
+
+	for idx := range filters {
+		filters[idx] = new(globFilter)
+		if filters[idx].include, err = compileGlobs(globs[idx*2]); err != nil {
+			c = nil
+			return
+		}
+		if filters[idx].exclude, err = compileGlobs(globs[idx*2+1]); err != nil {
+			c = nil
+			return
+		}
+	}
+	c.wallets, c.folders, c.entries = filters[0], filters[1], filters[2]
+
+	r.filters = c
+
+	return
+}
+
+// filterGlobs returns the name filters of a RecurseOpts, as include/exclude pairs for Wallets, Folders, and WalletItems.
+func (r *RecurseOpts) filterGlobs() (globs [][]string) {
+
+	globs = [][]string{
+		r.IncludeWallets, r.ExcludeWallets, r.IncludeFolders, r.ExcludeFolders, r.IncludeEntries, r.ExcludeEntries,
+	}
+
+	return
+}
+
+// compiledFrom returns true if recurseFilters were compiled from globs.
+func (c *recurseFilters) compiledFrom(globs [][]string) (isSame bool) {
+
+	if len(c.globs) != len(globs) {
+		return
+	}
+
+	for idx := range globs {
+		if len(c.globs[idx]) != len(globs[idx]) {
+			return
+		}
+		for gIdx := range globs[idx] {
+			if c.globs[idx][gIdx] != globs[idx][gIdx] {
+				return
+			}
+		}
+	}
+
+	isSame = true
+
+	return
+}
+
+// want returns true if name matches one of the include globs (or there are none) and none of the exclude globs.
+func (g *globFilter) want(name string) (wanted bool) {
+
+	wanted = len(g.include) == 0
+
+	for _, re := range g.include {
+		if re.MatchString(name) {
+			wanted = true
+			break
+		}
+	}
+
+	if !wanted {
+		return
+	}
+
+	for _, re := range g.exclude {
+		if re.MatchString(name) {
+			wanted = false
+			return
+		}
+	}
+
+	return
+}
+
+// compileGlobs compiles each of globs (see globToRegexp).
+func compileGlobs(globs []string) (res []*regexp.Regexp, err error) {
+
+	res = make([]*regexp.Regexp, len(globs))
+
+	for idx, g := range globs {
+		if res[idx], err = regexp.Compile(globToRegexp(g)); err != nil {
+			res = nil
+			err = fmt.Errorf("%w: pattern %#v: %v", ErrRecurseFilter, g, err)
+			return
+		}
+	}
+
+	return
+}
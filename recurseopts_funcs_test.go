@@ -0,0 +1,88 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestRecurseOptsFilters tests the RecurseOpts name filters and RecurseOpts.MaxItems.
+func TestRecurseOptsFilters(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var wm *WalletManager
+	var w *Wallet
+	var f *Folder
+	var r *RecurseOpts
+	var compiled *recurseFilters
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	for _, uri := range []string{
+		"kwallet://kdewallet/Passwords/a", "kwallet://kdewallet/Passwords/b", "kwallet://kdewallet/Passwords/skip-me",
+		"kwallet://kdewallet/Form Data/x", "kwallet://kdewallet/Chromium Keys/y", "kwallet://other/Passwords/c",
+	} {
+		if _, err = e.wm.Put(uri, "v"); err != nil {
+			t.Fatalf("failed to Put %v: %v", uri, err)
+		}
+	}
+
+	r = &RecurseOpts{
+		Wallets:        true,
+		Folders:        true,
+		AllWalletItems: true,
+		IncludeWallets: []string{"kde*"},
+		ExcludeFolders: []string{"Form Data", "Chromium*"},
+		ExcludeEntries: []string{"skip-*"},
+	}
+	if wm, err = NewWalletManagerBackend(e.wm.backend, r, appIdTest); err != nil {
+		t.Fatalf("failed to get WalletManager: %v", err)
+	}
+	if len(wm.Wallets) != 1 || wm.Wallets["kdewallet"] == nil {
+		t.Fatalf("unexpected Wallets: %#v", wm.Wallets)
+	}
+	w = wm.Wallets["kdewallet"]
+	if len(w.Folders) != 1 || w.Folders["Passwords"] == nil {
+		t.Fatalf("unexpected Folders: %#v", w.Folders)
+	}
+	f = w.Folders["Passwords"]
+	if len(f.Passwords) != 2 || f.Passwords["a"] == nil || f.Passwords["b"] == nil || f.Truncated {
+		t.Errorf("unexpected Passwords: %#v", f.Passwords)
+	}
+
+	r.MaxItems = 1
+	if err = f.Update(); err != nil {
+		t.Fatalf("failed to Update Folder: %v", err)
+	}
+	if len(f.Passwords) != 1 || f.Passwords["a"] == nil || !f.Truncated {
+		t.Errorf("unexpected Passwords with MaxItems: %#v", f.Passwords)
+	}
+
+	r.IncludeEntries = []string{"[a"}
+	if err = f.Update(); !errors.Is(err, ErrRecurseFilter) {
+		t.Errorf("expected ErrRecurseFilter, got %v", err)
+	}
+
+	// Bad globs are reported when the RecurseOpts is first used.
+	if _, err = NewWalletManagerBackend(e.wm.backend, &RecurseOpts{ExcludeFolders: []string{"[x"}}, appIdTest); !errors.Is(err, ErrRecurseFilter) {
+		t.Errorf("expected ErrRecurseFilter from NewWalletManagerBackend, got %v", err)
+	}
+	if _, err = NewFolder(e.w, e.f.Name, &RecurseOpts{ExcludeEntries: []string{"[x"}}); !errors.Is(err, ErrRecurseFilter) {
+		t.Errorf("expected ErrRecurseFilter from NewFolder, got %v", err)
+	}
+
+	// The globs are compiled once, and again only when changed.
+	r.IncludeEntries = []string{"a"}
+	if err = f.Update(); err != nil {
+		t.Fatalf("failed to Update Folder: %v", err)
+	}
+	compiled = r.filters
+	if err = f.Update(); err != nil || r.filters != compiled {
+		t.Errorf("filters recompiled without a change (err: %v)", err)
+	}
+	r.IncludeEntries[0] = "b"
+	if err = f.Update(); err != nil || r.filters == compiled || len(f.Passwords) != 1 || f.Passwords["b"] == nil {
+		t.Errorf("changed filter not applied: %#v (err: %v)", f.Passwords, err)
+	}
+}
@@ -0,0 +1,100 @@
+package gokwallet
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	SetShowValues controls whether the values of a WalletManager's WalletItems are included in fmt and JSON output.
+	By default they are not: the String, Format, and MarshalJSON methods (e.g. Password.String, Password.MarshalJSON)
+	replace them with RedactedValue, so logging (or JSON-encoding) a WalletManager, Wallet, Folder, or WalletItem
+	does not leak secrets.
+	Call SetShowValues(true) to include the plaintext values (e.g. for an export), and SetShowValues(false) to redact them again.
+	A SecretBytes is never shown. EntrySnapshot and HistoryVersion values are always redacted in fmt output;
+	a Snapshot only includes its raw values in JSON if it was taken with values shown (see EntrySnapshot.MarshalJSON).
+*/
+func (wm *WalletManager) SetShowValues(show bool) {
+
+	wm.showValues = show
+
+	return
+}
+
+// ShowValuesEnabled returns true if WalletItem values are included in fmt and JSON output (see WalletManager.SetShowValues).
+func (wm *WalletManager) ShowValuesEnabled() (show bool) {
+
+	show = wm != nil && wm.showValues
+
+	return
+}
+
+/*
+	formatRedacted writes str, the (possibly redacted) representation of a value, to s for any fmt verb.
+	It backs the Format methods of types holding secrets (e.g. Password.Format): without them, verbs like %+v and %#v
+	would print the struct fields (values included) instead of calling String. Those methods (and String and MarshalJSON)
+	have value receivers, so a dereferenced value (e.g. *p) is covered as well as a pointer.
+*/
+func formatRedacted(s fmt.State, verb rune, str string) {
+
+	if verb == 'q' {
+		str = strconv.Quote(str)
+	}
+
+	_, _ = s.Write([]byte(str))
+
+	return
+}
+
+// redactedMap returns a copy of m with every value replaced by RedactedValue (the keys are kept).
+func redactedMap(m map[string]string) (redacted map[string]string) {
+
+	if m == nil {
+		return
+	}
+
+	redacted = make(map[string]string, len(m))
+	for k := range m {
+		redacted[k] = RedactedValue
+	}
+
+	return
+}
+
+// mapString returns a representation of m with sorted keys, with its values redacted unless show is true.
+func mapString(m map[string]string, show bool) (str string) {
+
+	var keys []string = make([]string, 0, len(m))
+	var parts []string = make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if show {
+			parts = append(parts, fmt.Sprintf("%q: %q", k, m[k]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%q: %v", k, RedactedValue))
+		}
+	}
+
+	str = "{" + strings.Join(parts, ", ") + "}"
+
+	return
+}
+
+// bytesString returns a representation of b, redacted unless show is true.
+func bytesString(b []byte, show bool) (str string) {
+
+	str = RedactedValue
+
+	if show {
+		str = strconv.Quote(string(b))
+	}
+
+	return
+}
@@ -0,0 +1,108 @@
+package gokwallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestRedaction tests that WalletItem values are redacted in fmt and JSON output unless WalletManager.SetShowValues is used.
+func TestRedaction(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var f *Folder
+	var b []byte
+	var out string
+	var secrets []string
+	var snap *Snapshot
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+	if f, err = NewFolder(e.w, e.f.Name, e.r); err != nil {
+		t.Fatalf("failed to get Folder: %v", err)
+	}
+	if err = e.wm.Update(); err != nil {
+		t.Fatalf("failed to Update WalletManager: %v", err)
+	}
+
+	secrets = []string{testPassword, string(testBytes), base64.StdEncoding.EncodeToString(testBytes)}
+	for _, v := range testMap {
+		secrets = append(secrets, v)
+	}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		for _, v := range []interface{}{
+			f, f.Passwords[passwordTest.String()], f.Maps[mapTest.String()], f.BinaryData[blobTest.String()],
+			f.Unknown[unknownItemTest.String()], *f,
+			*f.Passwords[passwordTest.String()], *f.Maps[mapTest.String()], *f.BinaryData[blobTest.String()],
+			*f.Unknown[unknownItemTest.String()],
+		} {
+			out = fmt.Sprintf(verb, v)
+			for _, s := range secrets {
+				if strings.Contains(out, s) {
+					t.Errorf("%v of %T contains a value: %v", verb, v, out)
+				}
+			}
+		}
+	}
+	if out = fmt.Sprint(f.Passwords[passwordTest.String()]); !strings.Contains(out, RedactedValue) {
+		t.Errorf("Password not redacted: %v", out)
+	}
+
+	for _, v := range []interface{}{
+		e.wm, f, *f.Passwords[passwordTest.String()], *f.Maps[mapTest.String()], *f.BinaryData[blobTest.String()],
+		*f.Unknown[unknownItemTest.String()],
+	} {
+		if b, err = json.Marshal(v); err != nil {
+			t.Fatalf("failed to marshal %T: %v", v, err)
+		}
+		for _, s := range secrets {
+			if strings.Contains(string(b), s) {
+				t.Errorf("JSON of %T contains a value: %v", v, string(b))
+			}
+		}
+	}
+
+	if snap, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot: %v", err)
+	}
+	if out = fmt.Sprintf("%+v", snap.Wallets[0].Folders[0].Entries); strings.Contains(out, testPassword) {
+		t.Errorf("EntrySnapshot printed a value: %v", out)
+	}
+	if out = fmt.Sprintf("%+v", *snap.Wallets[0].Folders[0].Entries[0]); strings.Contains(out, "raw:") {
+		t.Errorf("EntrySnapshot printed a value: %v", out)
+	}
+	if b, err = json.Marshal(snap); err != nil {
+		t.Fatalf("failed to marshal Snapshot: %v", err)
+	}
+	for _, s := range secrets {
+		if strings.Contains(string(b), s) {
+			t.Errorf("JSON of Snapshot contains a value: %v", string(b))
+		}
+	}
+
+	e.wm.SetShowValues(true)
+	defer e.wm.SetShowValues(false)
+
+	if out = fmt.Sprint(f.Passwords[passwordTest.String()]); !strings.Contains(out, testPassword) {
+		t.Errorf("Password not shown with SetShowValues: %v", out)
+	}
+	if b, err = json.Marshal(f); err != nil {
+		t.Fatalf("failed to marshal Folder: %v", err)
+	}
+	if !strings.Contains(string(b), testPassword) || !strings.Contains(string(b), base64.StdEncoding.EncodeToString(testBytes)) {
+		t.Errorf("JSON does not contain values with SetShowValues: %v", string(b))
+	}
+	for _, v := range testMap {
+		if !strings.Contains(string(b), v) {
+			t.Errorf("JSON does not contain Map value %#v with SetShowValues", v)
+		}
+	}
+}
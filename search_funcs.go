@@ -0,0 +1,332 @@
+package gokwallet
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+	Search finds the WalletItems (and Map keys) matching q across all Wallets of a WalletManager.
+	Names are matched using the Backend's name listings (kwalletd's entryList etc.); values are only read
+	when q.ValueContains or q.MapKey requires it, and only for WalletItems that otherwise match.
+	Errors for individual Wallets do not stop the search; err is then a MultiError of them.
+*/
+func (wm *WalletManager) Search(q *SearchQuery) (results []*SearchResult, err error) {
+
+	var m *searchMatcher
+	var walletNames []string
+	var found []*SearchResult
+	var errs []error = make([]error, 0)
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if m, err = q.compile(); err != nil {
+		return
+	}
+
+	if walletNames, err = wm.WalletNames(); err != nil {
+		return
+	}
+	sort.Strings(walletNames)
+
+	results = make([]*SearchResult, 0)
+
+	for _, wn := range walletNames {
+		if m.full(len(results)) {
+			break
+		}
+		if m.wallet != nil && !m.wallet.MatchString(wn) {
+			continue
+		}
+		found = nil
+		if err = wm.withWallet(wn, func(w *Wallet) (err error) {
+			found, err = w.search(m, len(results))
+			return
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("wallet %#v: %w", wn, err))
+			err = nil
+		}
+		results = append(results, found...)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// Search is like WalletManager.Search, but only searches Wallet w (SearchQuery.Wallet is ignored).
+func (w *Wallet) Search(q *SearchQuery) (results []*SearchResult, err error) {
+
+	var m *searchMatcher
+
+	if m, err = q.compile(); err != nil {
+		return
+	}
+
+	if results, err = w.search(m, 0); err != nil {
+		return
+	}
+
+	return
+}
+
+// search searches Wallet w with m. already is the number of results found so far (for SearchQuery.Limit).
+func (w *Wallet) search(m *searchMatcher, already int) (results []*SearchResult, err error) {
+
+	var folderNames []string
+	var found []*SearchResult
+
+	if err = w.walletCheck(); err != nil {
+		return
+	}
+
+	if folderNames, err = w.listUserFolders(); err != nil {
+		return
+	}
+	sort.Strings(folderNames)
+
+	results = make([]*SearchResult, 0)
+
+	for _, fn := range folderNames {
+		if m.full(already + len(results)) {
+			break
+		}
+		if m.folder != nil && !m.folder.MatchString(fn) {
+			continue
+		}
+		if found, err = w.searchFolder(m, fn, already+len(results)); err != nil {
+			return
+		}
+		results = append(results, found...)
+	}
+
+	return
+}
+
+// searchFolder searches Folder folderName of Wallet w with m. already is the number of results found so far.
+func (w *Wallet) searchFolder(m *searchMatcher, folderName string, already int) (results []*SearchResult, err error) {
+
+	var entryNames []string
+	var entryType kwalletdEnumType
+	var types map[string]kwalletdEnumType
+	var mapValue map[string]string
+	var raw []byte
+	var pw string
+	var keys []string
+
+	if entryNames, types, err = w.listEntryTypes(folderName); err != nil {
+		return
+	}
+
+	results = make([]*SearchResult, 0)
+
+	for _, en := range entryNames {
+		if m.full(already + len(results)) {
+			return
+		}
+		if m.entry != nil && !m.entry.MatchString(en) {
+			continue
+		}
+		if entryType, err = w.entryType(folderName, en, types); err != nil {
+			return
+		}
+		if m.types != nil && !m.types[entryType] {
+			continue
+		}
+		if m.mapKey != nil && entryType != KwalletdEnumTypeMap {
+			continue
+		}
+
+		switch {
+		case entryType == KwalletdEnumTypeMap && (m.mapKey != nil || m.value != nil):
+			if mapValue, err = w.wm.Backend().ReadMap(w.handle, folderName, en, w.wm.AppID); err != nil {
+				return
+			}
+			keys = make([]string, 0, len(mapValue))
+			for k, v := range mapValue {
+				if m.mapKey != nil && !m.mapKey.MatchString(k) {
+					continue
+				}
+				if m.value != nil && !m.valueMatches([]byte(v)) {
+					continue
+				}
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if m.full(already + len(results)) {
+					return
+				}
+				results = append(results, &SearchResult{
+					Path: &ItemPath{Wallet: w.Name, Folder: folderName, Entry: en, Key: k},
+					Type: entryType,
+				})
+			}
+			continue
+		case m.value != nil && entryType == KwalletdEnumTypePassword:
+			if pw, err = w.wm.Backend().ReadPassword(w.handle, folderName, en, w.wm.AppID); err != nil {
+				return
+			}
+			if !m.valueMatches([]byte(pw)) {
+				continue
+			}
+		case m.value != nil:
+			if raw, err = w.wm.Backend().ReadEntry(w.handle, folderName, en, w.wm.AppID); err != nil {
+				return
+			}
+			if !m.valueMatches(raw) {
+				continue
+			}
+		}
+
+		results = append(results, &SearchResult{
+			Path: &ItemPath{Wallet: w.Name, Folder: folderName, Entry: en},
+			Type: entryType,
+		})
+	}
+
+	return
+}
+
+// compile compiles a SearchQuery into a searchMatcher.
+func (q *SearchQuery) compile() (m *searchMatcher, err error) {
+
+	m = &searchMatcher{
+		ignoreCase: q.IgnoreCase,
+		limit:      q.Limit,
+	}
+
+	if q.Limit < 0 {
+		err = fmt.Errorf("%w: negative Limit", ErrSearchQuery)
+		m = nil
+		return
+	}
+
+	for _, p := range []struct {
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{q.Wallet, &m.wallet},
+		{q.Folder, &m.folder},
+		{q.Entry, &m.entry},
+		{q.MapKey, &m.mapKey},
+	} {
+		if p.pattern == "" {
+			continue
+		}
+		if *p.re, err = q.compilePattern(p.pattern); err != nil {
+			m = nil
+			return
+		}
+	}
+
+	if len(q.Types) != 0 {
+		m.types = make(map[kwalletdEnumType]bool, len(q.Types))
+		for _, t := range q.Types {
+			m.types[t] = true
+		}
+	}
+
+	if q.ValueContains != "" {
+		m.value = []byte(q.ValueContains)
+		if q.IgnoreCase {
+			m.value = bytes.ToLower(m.value)
+		}
+	}
+
+	return
+}
+
+// compilePattern compiles a glob (or, if SearchQuery.Regex, regular expression) pattern.
+func (q *SearchQuery) compilePattern(pattern string) (re *regexp.Regexp, err error) {
+
+	var expr string = pattern
+
+	if !q.Regex {
+		expr = globToRegexp(pattern)
+	}
+
+	if q.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+
+	if re, err = regexp.Compile(expr); err != nil {
+		err = fmt.Errorf("%w: pattern %#v: %v", ErrSearchQuery, pattern, err)
+		return
+	}
+
+	return
+}
+
+// full returns true if n results satisfy SearchQuery.Limit.
+func (m *searchMatcher) full(n int) (isFull bool) {
+
+	isFull = m.limit > 0 && n >= m.limit
+
+	return
+}
+
+// valueMatches returns true if value contains SearchQuery.ValueContains.
+func (m *searchMatcher) valueMatches(value []byte) (matches bool) {
+
+	if m.ignoreCase {
+		value = bytes.ToLower(value)
+	}
+
+	matches = bytes.Contains(value, m.value)
+
+	return
+}
+
+// globToRegexp converts a glob (*, ?, and [...] or [!...] character classes) to an anchored regular expression.
+func globToRegexp(glob string) (expr string) {
+
+	var inClass bool
+	var classStart bool
+	var sb strings.Builder
+
+	sb.WriteString("(?s)^")
+
+	for _, r := range glob {
+		switch {
+		case classStart && r == '!':
+			sb.WriteRune('^')
+			classStart = false
+		case inClass:
+			classStart = false
+			if r == ']' {
+				inClass = false
+			}
+			if r == '\\' {
+				sb.WriteString(`\\`)
+				continue
+			}
+			sb.WriteRune(r)
+		case r == '*':
+			sb.WriteString(".*")
+		case r == '?':
+			sb.WriteString(".")
+		case r == '[':
+			inClass = true
+			classStart = true
+			sb.WriteRune(r)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	expr = sb.String()
+
+	return
+}
@@ -0,0 +1,128 @@
+package gokwallet
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestSearch tests WalletManager.Search and Wallet.Search.
+func TestSearch(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var results []*SearchResult
+	var k string
+	var v string
+	var mem *MemoryBackend
+	var handles int
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+	if _, err = e.wm.Put("kwallet://other/Tokens/GitHub Token", "ghp_Secret"); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+	if _, err = e.wm.Put("kwallet://other/Tokens/gitlab", "glpat-x"); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+	for k, v = range testMap {
+		break
+	}
+
+	for name, c := range map[string]struct {
+		q        *SearchQuery
+		expected []string
+	}{
+		"everything": {
+			q: &SearchQuery{},
+			expected: []string{
+				"kwallet://other/Tokens/GitHub%20Token", "kwallet://other/Tokens/gitlab",
+				(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: blobTest.String()}).String(),
+				(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: mapTest.String()}).String(),
+				(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: passwordTest.String()}).String(),
+				(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: unknownItemTest.String()}).String(),
+			},
+		},
+		"glob": {
+			q:        &SearchQuery{Wallet: "oth*", Entry: "Git?ub*"},
+			expected: []string{"kwallet://other/Tokens/GitHub%20Token"},
+		},
+		"glob ignore case": {
+			q:        &SearchQuery{Entry: "git*", IgnoreCase: true},
+			expected: []string{"kwallet://other/Tokens/GitHub%20Token", "kwallet://other/Tokens/gitlab"},
+		},
+		"regex with limit": {
+			q:        &SearchQuery{Folder: "^Tok", Regex: true, Limit: 1},
+			expected: []string{"kwallet://other/Tokens/GitHub%20Token"},
+		},
+		"type": {
+			q:        &SearchQuery{Types: []kwalletdEnumType{KwalletdEnumTypeStream}},
+			expected: []string{(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: blobTest.String()}).String()},
+		},
+		"value": {
+			q:        &SearchQuery{ValueContains: "SECRET", IgnoreCase: true},
+			expected: []string{"kwallet://other/Tokens/GitHub%20Token"},
+		},
+		"map key": {
+			q:        &SearchQuery{MapKey: k},
+			expected: []string{(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: mapTest.String(), Key: k}).String()},
+		},
+		"map value": {
+			q:        &SearchQuery{ValueContains: v[2:10]},
+			expected: []string{(&ItemPath{Wallet: e.w.Name, Folder: e.f.Name, Entry: mapTest.String(), Key: k}).String()},
+		},
+	} {
+		if results, err = e.wm.Search(c.q); err != nil {
+			t.Errorf("%v: failed to Search: %v", name, err)
+			continue
+		}
+		if len(results) != len(c.expected) {
+			t.Errorf("%v: expected %d results, got %d: %v", name, len(c.expected), len(results), searchURIs(results))
+			continue
+		}
+		// The test names are random, so the order is compared sorted.
+		sort.Strings(c.expected)
+		if searchURIs(results) != strings.Join(c.expected, ", ") {
+			t.Errorf("%v: expected %v, got %v", name, c.expected, searchURIs(results))
+		}
+	}
+
+	if results, err = e.w.Search(&SearchQuery{Wallet: "other", Types: []kwalletdEnumType{KwalletdEnumTypePassword}}); err != nil ||
+		len(results) != 1 || results[0].Path.Entry != passwordTest.String() {
+		t.Errorf("unexpected Wallet.Search results: %v (err: %v)", searchURIs(results), err)
+	}
+
+	if _, err = e.wm.Search(&SearchQuery{Entry: "(", Regex: true}); !errors.Is(err, ErrSearchQuery) {
+		t.Errorf("expected ErrSearchQuery, got %v", err)
+	}
+
+	// The Wallets opened for a search are closed again.
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if _, err = e.wm.Search(&SearchQuery{}); err != nil {
+		t.Fatalf("failed to Search: %v", err)
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("Search leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+}
+
+// searchURIs returns the URIs of results (for test output).
+func searchURIs(results []*SearchResult) (uris string) {
+
+	var s []string = make([]string, 0, len(results))
+
+	for _, r := range results {
+		s = append(s, r.Path.String())
+	}
+	sort.Strings(s)
+
+	uris = strings.Join(s, ", ")
+
+	return
+}
@@ -0,0 +1,120 @@
+package gokwallet
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+	NewSecretBytes returns a SecretBytes holding a copy of b, in locked memory if lock is true.
+	b itself is left as-is; wipe it (if it is no longer needed) yourself.
+*/
+func NewSecretBytes(b []byte, lock bool) (s *SecretBytes, err error) {
+
+	s = new(SecretBytes)
+
+	if lock {
+		if s.b, err = lockedAlloc(len(b)); err != nil {
+			s = nil
+			return
+		}
+		s.locked = true
+	} else {
+		s.b = make([]byte, len(b))
+	}
+
+	copy(s.b, b)
+
+	return
+}
+
+/*
+	Bytes returns the secret held by a SecretBytes. It is not a copy: it is only valid until SecretBytes.Wipe is called
+	(and must not be used afterwards if the SecretBytes is locked). It is nil once wiped.
+*/
+func (s *SecretBytes) Bytes() (b []byte) {
+
+	if s == nil || s.wiped {
+		return
+	}
+
+	b = s.b
+
+	return
+}
+
+// Len returns the length of the secret held by a SecretBytes (0 once wiped).
+func (s *SecretBytes) Len() (n int) {
+
+	if s == nil || s.wiped {
+		return
+	}
+
+	n = len(s.b)
+
+	return
+}
+
+// Locked returns true if the secret held by a SecretBytes is in locked memory.
+func (s *SecretBytes) Locked() (locked bool) {
+
+	locked = s != nil && s.locked && !s.wiped
+
+	return
+}
+
+// String returns RedactedValue, so a SecretBytes is never printed (use SecretBytes.Bytes to get the secret).
+func (s SecretBytes) String() (str string) {
+
+	str = RedactedValue
+
+	return
+}
+
+// Format prints SecretBytes.String for every verb (see formatRedacted).
+func (s SecretBytes) Format(st fmt.State, verb rune) {
+
+	formatRedacted(st, verb, s.String())
+
+	return
+}
+
+// MarshalJSON encodes a SecretBytes as RedactedValue, so a SecretBytes is never included in JSON output.
+func (s SecretBytes) MarshalJSON() (b []byte, err error) {
+
+	if b, err = json.Marshal(RedactedValue); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Wipe zeroes the secret held by a SecretBytes and releases its locked memory (if any).
+	It is safe to call more than once.
+*/
+func (s *SecretBytes) Wipe() (err error) {
+
+	if s == nil || s.wiped {
+		return
+	}
+
+	s.wiped = true
+
+	if s.locked {
+		err = lockedFree(s.b)
+	} else {
+		wipeBytes(s.b)
+	}
+	s.b = nil
+
+	return
+}
+
+// Wiped returns true once SecretBytes.Wipe has been called.
+func (s *SecretBytes) Wiped() (wiped bool) {
+
+	wiped = s == nil || s.wiped
+
+	return
+}
@@ -0,0 +1,163 @@
+//go:build linux
+// +build linux
+
+package gokwallet
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+/*
+	lockedAlloc returns n bytes of memory outside of the Go heap that is locked (mlock(2)) so it is never swapped out.
+	Up to half a page is handed out from a slot of a shared locked slab (see lockedPool), so that small secrets
+	don't each lock a page of their own; anything larger gets its own locked mapping.
+*/
+func lockedAlloc(n int) (b []byte, err error) {
+
+	var slot int = lockedSlotMin
+	var page int = os.Getpagesize()
+
+	if n > page/2 {
+		b, err = lockedMap(n)
+		return
+	}
+
+	for slot < n {
+		slot *= 2
+	}
+
+	if b, err = lockedMemory.alloc(n, slot, page); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	lockedFree wipes memory returned by lockedAlloc and gives it back: a slot is returned to its slab
+	(see lockedPool), and its own mapping is unlocked and unmapped. b must not be used afterwards.
+*/
+func lockedFree(b []byte) (err error) {
+
+	b = b[:cap(b)]
+
+	wipeBytes(b)
+
+	if cap(b) > os.Getpagesize()/2 {
+		err = lockedUnmap(b)
+		return
+	}
+
+	if err = lockedMemory.release(b); err != nil {
+		return
+	}
+
+	return
+}
+
+// lockedMap maps size bytes of memory and locks it.
+func lockedMap(size int) (b []byte, err error) {
+
+	if b, err = syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON); err != nil {
+		return
+	}
+
+	if err = syscall.Mlock(b); err != nil {
+		_ = syscall.Munmap(b)
+		b = nil
+		return
+	}
+
+	return
+}
+
+// lockedUnmap unlocks and unmaps memory mapped by lockedMap.
+func lockedUnmap(b []byte) (err error) {
+
+	if err = syscall.Munlock(b); err != nil {
+		return
+	}
+
+	if err = syscall.Munmap(b); err != nil {
+		return
+	}
+
+	return
+}
+
+// alloc hands out n bytes from a free slot of size slot, locking a new slab (of size page) if there are none.
+func (p *lockedPool) alloc(n, slot, page int) (b []byte, err error) {
+
+	var off int
+	var s *lockedSlab
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, candidate := range p.slabs[slot] {
+		if len(candidate.free) != 0 {
+			s = candidate
+			break
+		}
+	}
+
+	if s == nil {
+		s = &lockedSlab{
+			slotSize: slot,
+			free:     make([]int, 0, page/slot),
+		}
+		if s.mem, err = lockedMap(page); err != nil {
+			return
+		}
+		for off = page - slot; off >= 0; off -= slot {
+			s.free = append(s.free, off)
+		}
+		if p.slabs == nil {
+			p.slabs = make(map[int][]*lockedSlab)
+		}
+		p.slabs[slot] = append(p.slabs[slot], s)
+	}
+
+	off = s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+
+	b = s.mem[off : off+n : off+s.slotSize]
+
+	return
+}
+
+/*
+	release returns the (already wiped) slot b to its slab.
+	A slab left with no slots in use is unlocked and unmapped, unless it is the last one of its slot size.
+*/
+func (p *lockedPool) release(b []byte) (err error) {
+
+	var start uintptr
+	var slabs []*lockedSlab
+	var addr uintptr = uintptr(unsafe.Pointer(&b[0]))
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	slabs = p.slabs[cap(b)]
+
+	for idx, s := range slabs {
+		start = uintptr(unsafe.Pointer(&s.mem[0]))
+		if addr < start || addr >= start+uintptr(len(s.mem)) {
+			continue
+		}
+		s.free = append(s.free, int(addr-start))
+		if len(s.free)*s.slotSize == len(s.mem) && len(slabs) > 1 {
+			p.slabs[cap(b)] = append(slabs[:idx], slabs[idx+1:]...)
+			err = lockedUnmap(s.mem)
+		}
+		return
+	}
+
+	err = fmt.Errorf("%w: %d bytes at %#x", ErrLockedMemory, cap(b), addr)
+
+	return
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package gokwallet
+
+import (
+	"os"
+	"testing"
+)
+
+// TestLockedPool tests that locked SecretBytes share locked pages, and that wiped ones give them back.
+func TestLockedPool(t *testing.T) {
+
+	var err error
+	var s *SecretBytes
+	var slabs int
+	var secrets []*SecretBytes = make([]*SecretBytes, 0, 100)
+	var big []byte = make([]byte, os.Getpagesize())
+	var secret string = "0123456789abcdef"
+
+	for idx := 0; idx < cap(secrets); idx++ {
+		if s, err = NewSecretBytes([]byte(secret), true); err != nil {
+			t.Skipf("locked memory unavailable: %v", err)
+		}
+		secrets = append(secrets, s)
+	}
+
+	lockedMemory.lock.Lock()
+	slabs = len(lockedMemory.slabs[lockedSlotMin])
+	lockedMemory.lock.Unlock()
+	// 100 slots of 32 bytes fit in one page (or a few, on exotic page sizes); not one page each.
+	if slabs == 0 || slabs > (cap(secrets)*lockedSlotMin)/os.Getpagesize()+1 {
+		t.Errorf("%d secrets used %d locked slabs", len(secrets), slabs)
+	}
+	for idx, s := range secrets {
+		if !s.Locked() || string(s.Bytes()) != secret {
+			t.Errorf("unexpected locked secret %d", idx)
+		}
+	}
+
+	// Secrets don't spill into each other's slots.
+	secrets[0].Bytes()[0] ^= 0xff
+	if string(secrets[1].Bytes()) != secret {
+		t.Errorf("locked secrets overlap")
+	}
+
+	for _, s := range secrets {
+		if err = s.Wipe(); err != nil {
+			t.Errorf("failed to Wipe: %v", err)
+		}
+	}
+	lockedMemory.lock.Lock()
+	if len(lockedMemory.slabs[lockedSlotMin]) != 1 || len(lockedMemory.slabs[lockedSlotMin][0].free)*lockedSlotMin != os.Getpagesize() {
+		t.Errorf("wiped secrets not returned to the pool")
+	}
+	lockedMemory.lock.Unlock()
+
+	// Larger secrets get their own mapping.
+	if s, err = NewSecretBytes(big, true); err != nil {
+		t.Fatalf("failed to get a large locked secret: %v", err)
+	}
+	if len(s.Bytes()) != len(big) {
+		t.Errorf("unexpected large locked secret length %d", len(s.Bytes()))
+	}
+	if err = s.Wipe(); err != nil {
+		t.Errorf("failed to Wipe a large locked secret: %v", err)
+	}
+}
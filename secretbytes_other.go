@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package gokwallet
+
+// lockedAlloc is not supported on this platform; it always returns ErrNoMlock.
+func lockedAlloc(n int) (b []byte, err error) {
+
+	err = ErrNoMlock
+
+	return
+}
+
+// lockedFree is not supported on this platform; it always returns ErrNoMlock.
+func lockedFree(b []byte) (err error) {
+
+	err = ErrNoMlock
+
+	return
+}
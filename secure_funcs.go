@@ -0,0 +1,127 @@
+package gokwallet
+
+/*
+	EnableSecureMode makes a WalletManager hold the values of the WalletItems it reads or sets in SecretBytes
+	(Password.Secret, Map.Secrets, Blob.Secret, and UnknownItem.Secret) instead of their Value fields, which are left empty.
+	Those can then be wiped when no longer needed: by the Wipe methods (e.g. Password.Wipe, Folder.Wipe, Wallet.Wipe,
+	or WalletManager.Wipe for everything), and by WalletManager.Close.
+	If mlock is true, the SecretBytes are also kept in locked memory so they are never swapped out
+	(ErrNoMlock is returned if that's not supported; mlock may also fail if RLIMIT_MEMLOCK is too low).
+
+	The Value fields (e.g. Password.Value) stay empty in secure mode; use the Secret fields, or the GetValue methods
+	(e.g. Password.GetValue), which still work but return copies that can't be wiped.
+	Small secrets share locked pages, so even a low RLIMIT_MEMLOCK holds many of them.
+	Secure mode should be enabled before anything is read.
+*/
+func (wm *WalletManager) EnableSecureMode(mlock bool) (err error) {
+
+	var b []byte
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if mlock {
+		if b, err = lockedAlloc(1); err != nil {
+			return
+		}
+		if err = lockedFree(b); err != nil {
+			return
+		}
+	}
+
+	wm.secure = true
+	wm.mlock = mlock
+
+	return
+}
+
+// SecureModeEnabled returns true if WalletManager.EnableSecureMode has been called.
+func (wm *WalletManager) SecureModeEnabled() (enabled bool) {
+
+	enabled = wm.secure
+
+	return
+}
+
+/*
+	Wipe wipes every WalletItem value a WalletManager has loaded: those in WalletManager.Wallets (and WalletManager.Local
+	and WalletManager.Network), every SecretBytes created in secure mode (see WalletManager.EnableSecureMode),
+	and its read cache (see WalletManager.EnableCache).
+	Values not held in SecretBytes (i.e. outside of secure mode) are zeroed where possible and otherwise dropped.
+*/
+func (wm *WalletManager) Wipe() (err error) {
+
+	var errs []error = make([]error, 0)
+
+	for _, w := range wm.Wallets {
+		if err = w.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	for _, w := range []*Wallet{wm.Local, wm.Network} {
+		if w == nil {
+			continue
+		}
+		if err = w.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	wm.secretsLock.Lock()
+	for _, s := range wm.secrets {
+		if err = s.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+	wm.secrets = nil
+	wm.secretsLock.Unlock()
+
+	if wm.CacheEnabled() {
+		if err = wm.FlushCache(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	return
+}
+
+/*
+	newSecret returns a SecretBytes (locked, if so configured) holding a copy of b.
+	It is tracked so that WalletManager.Wipe wipes it.
+*/
+func (wm *WalletManager) newSecret(b []byte) (s *SecretBytes, err error) {
+
+	var live []*SecretBytes
+
+	if s, err = NewSecretBytes(b, wm.mlock); err != nil {
+		return
+	}
+
+	wm.secretsLock.Lock()
+	defer wm.secretsLock.Unlock()
+
+	// Forget the ones that were already wiped rather than growing forever.
+	if len(wm.secrets) == cap(wm.secrets) && len(wm.secrets) != 0 {
+		live = make([]*SecretBytes, 0, len(wm.secrets))
+		for _, ws := range wm.secrets {
+			if !ws.Wiped() {
+				live = append(live, ws)
+			}
+		}
+		wm.secrets = live
+	}
+
+	wm.secrets = append(wm.secrets, s)
+
+	return
+}
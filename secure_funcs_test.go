@@ -0,0 +1,123 @@
+package gokwallet
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestSecureMode tests WalletItem values held in SecretBytes, and wiping them.
+func TestSecureMode(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var f *Folder
+	var p *Password
+	var m *Map
+	var b *Blob
+	var held []byte
+	var pw string
+	var secret *SecretBytes
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.wm.EnableSecureMode(false); err != nil {
+		t.Fatalf("failed to EnableSecureMode: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+	if f, err = NewFolder(e.w, e.f.Name, e.r); err != nil {
+		t.Fatalf("failed to get Folder: %v", err)
+	}
+
+	p = f.Passwords[passwordTest.String()]
+	m = f.Maps[mapTest.String()]
+	b = f.BinaryData[blobTest.String()]
+	if p.Value != "" || string(p.Secret.Bytes()) != testPassword {
+		t.Errorf("unexpected Password in secure mode: %#v / %#v", p.Value, p.Secret.Bytes())
+	}
+	if m.Value != nil || len(m.Secrets) != len(testMap) {
+		t.Errorf("unexpected Map in secure mode: %#v / %#v", m.Value, m.Secrets)
+	}
+	for k, v := range testMap {
+		if string(m.Secrets[k].Bytes()) != v {
+			t.Errorf("unexpected Map key %#v in secure mode", k)
+		}
+	}
+	if b.Value != nil || !bytes.Equal(b.Secret.Bytes(), testBytes) {
+		t.Errorf("unexpected Blob in secure mode: %#v / %#v", b.Value, b.Secret.Bytes())
+	}
+	if pw, err = p.GetValue(); err != nil || pw != testPassword {
+		t.Errorf("unexpected Password.GetValue in secure mode: %#v (err: %v)", pw, err)
+	}
+	if pw = fmt.Sprintf("%v", p.Secret); pw != RedactedValue {
+		t.Errorf("SecretBytes printed as %#v", pw)
+	}
+
+	if err = p.SetValue(testPasswordReplace); err != nil {
+		t.Fatalf("failed to SetValue: %v", err)
+	}
+	if p.Value != "" || string(p.Secret.Bytes()) != testPasswordReplace {
+		t.Errorf("unexpected Password after SetValue in secure mode")
+	}
+	if err = m.UpdateKeys(func(value map[string]string) (err error) {
+		value["added"] = "yes"
+		return
+	}); err != nil {
+		t.Fatalf("failed to UpdateKeys: %v", err)
+	}
+	if m.Value != nil || string(m.Secrets["added"].Bytes()) != "yes" {
+		t.Errorf("unexpected Map after UpdateKeys in secure mode")
+	}
+
+	// Wiping zeroes the values in place.
+	held = b.Secret.Bytes()
+	secret = p.Secret
+	if err = f.Wipe(); err != nil {
+		t.Fatalf("failed to Wipe Folder: %v", err)
+	}
+	if !bytes.Equal(held, make([]byte, len(held))) || b.Secret != nil || !secret.Wiped() || p.Secret != nil || m.Secrets != nil {
+		t.Errorf("Folder was not wiped")
+	}
+	if pw, err = p.GetValue(); err != nil || pw != testPasswordReplace {
+		t.Errorf("unexpected Password.GetValue after Wipe: %#v (err: %v)", pw, err)
+	}
+
+	// Close wipes everything the WalletManager loaded, wherever it is.
+	if err = p.Update(); err != nil {
+		t.Fatalf("failed to Update: %v", err)
+	}
+	secret = p.Secret
+	if err = e.wm.Close(); err != nil {
+		t.Fatalf("failed to Close: %v", err)
+	}
+	if !secret.Wiped() || secret.Bytes() != nil {
+		t.Errorf("Close did not wipe a loaded secret")
+	}
+}
+
+// TestSecretBytesLocked tests SecretBytes in locked memory (if the platform and RLIMIT_MEMLOCK allow it).
+func TestSecretBytesLocked(t *testing.T) {
+
+	var err error
+	var s *SecretBytes
+
+	if s, err = NewSecretBytes(testBytes, true); err != nil {
+		t.Skipf("cannot lock memory here: %v", err)
+	}
+
+	if !s.Locked() || !bytes.Equal(s.Bytes(), testBytes) {
+		t.Errorf("unexpected locked SecretBytes")
+	}
+	if err = s.Wipe(); err != nil {
+		t.Errorf("failed to Wipe locked SecretBytes: %v", err)
+	}
+	if s.Locked() || s.Bytes() != nil || s.Len() != 0 {
+		t.Errorf("locked SecretBytes not wiped")
+	}
+	if err = s.Wipe(); err != nil {
+		t.Errorf("second Wipe failed: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package gokwallet
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+/*
+	shamirSplit splits secret into count Shamir shares, any threshold of which recover it (see shamirCombine).
+	Each byte of secret is split separately, with a random polynomial of degree threshold-1 over GF(2^8);
+	share i (from 0) is the polynomials evaluated at x = i+1.
+*/
+func shamirSplit(secret []byte, threshold, count int) (shares [][]byte, err error) {
+
+	var coeffs []byte = make([]byte, threshold)
+
+	defer wipeBytes(coeffs)
+
+	if threshold < 2 || threshold > count || count > BackupShareMax {
+		err = ErrBackupShareCount
+		return
+	}
+
+	shares = make([][]byte, count)
+	for idx := range shares {
+		shares[idx] = make([]byte, len(secret))
+	}
+
+	for bIdx, b := range secret {
+		coeffs[0] = b
+		if _, err = rand.Read(coeffs[1:]); err != nil {
+			shares = nil
+			return
+		}
+		for idx := range shares {
+			shares[idx][bIdx] = gfEval(coeffs, byte(idx+1))
+		}
+	}
+
+	return
+}
+
+/*
+	shamirCombine recovers a secret from Shamir shares (see shamirSplit) by Lagrange interpolation at x = 0.
+	xs are the (distinct, non-zero) x values of the shares in ys, which must all be the same length.
+	At least as many shares as the threshold they were split with must be given; with fewer, the result is garbage.
+*/
+func shamirCombine(xs []byte, ys [][]byte) (secret []byte, err error) {
+
+	var basis byte
+	var seen map[byte]bool = make(map[byte]bool, len(xs))
+
+	if len(xs) == 0 || len(xs) != len(ys) {
+		err = fmt.Errorf("%w: no shares", ErrBackupShares)
+		return
+	}
+
+	for idx, x := range xs {
+		if x == 0 || seen[x] || len(ys[idx]) != len(ys[0]) {
+			err = fmt.Errorf("%w: duplicate or invalid share", ErrBackupShares)
+			return
+		}
+		seen[x] = true
+	}
+
+	secret = make([]byte, len(ys[0]))
+
+	for i, xi := range xs {
+		// The Lagrange basis polynomial for share i, at 0. In GF(2^8), subtraction is addition (XOR).
+		basis = 1
+		for j, xj := range xs {
+			if i != j {
+				basis = gfMul(basis, gfMul(xj, gfInv(xj^xi)))
+			}
+		}
+		for bIdx := range secret {
+			secret[bIdx] ^= gfMul(ys[i][bIdx], basis)
+		}
+	}
+
+	return
+}
+
+// gfEval evaluates the polynomial with coefficients coeffs (lowest degree first) at x over GF(2^8).
+func gfEval(coeffs []byte, x byte) (y byte) {
+
+	for idx := len(coeffs) - 1; idx >= 0; idx-- {
+		y = gfMul(y, x) ^ coeffs[idx]
+	}
+
+	return
+}
+
+/*
+	gfMul multiplies a and b in GF(2^8) (with the AES polynomial, x^8 + x^4 + x^3 + x + 1).
+	It doesn't use lookup tables or branch on its inputs, so its timing doesn't depend on them.
+*/
+func gfMul(a, b byte) (p byte) {
+
+	for i := 0; i < 8; i++ {
+		p ^= a & -(b & 1)
+		a = (a << 1) ^ (0x1b & -(a >> 7))
+		b >>= 1
+	}
+
+	return
+}
+
+// gfInv returns the multiplicative inverse of a (which must not be 0) in GF(2^8), as a^254.
+func gfInv(a byte) (inv byte) {
+
+	var sq byte = a
+
+	inv = 1
+	// 254 is 0b11111110.
+	for i := 0; i < 7; i++ {
+		sq = gfMul(sq, sq)
+		inv = gfMul(inv, sq)
+	}
+
+	return
+}
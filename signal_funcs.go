@@ -0,0 +1,103 @@
+package gokwallet
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+/*
+	watchSignals subscribes to the kwalletd signals named by names (see the DbusWMSignal* constants).
+	Each one received is sent to sigs until stop is called. It requires a DbusBackend (ErrNoSignals otherwise).
+*/
+func (wm *WalletManager) watchSignals(names ...string) (sigs chan *WalletSignal, stop func(), err error) {
+
+	var ok bool
+	var d *DbusBackend
+	var raw chan *dbus.Signal
+	var quit chan bool
+	var wanted map[string]bool = make(map[string]bool, len(names))
+
+	if d, ok = baseBackend(wm.Backend()).(*DbusBackend); !ok || d.Conn == nil {
+		err = ErrNoSignals
+		return
+	}
+
+	for _, n := range names {
+		if err = d.Conn.AddMatchSignal(
+			dbus.WithMatchInterface(DbusInterfaceWM), dbus.WithMatchMember(n), dbus.WithMatchObjectPath(dbus.ObjectPath(DbusPath)),
+		); err != nil {
+			return
+		}
+		wanted[DbusInterfaceWM+"."+n] = true
+	}
+
+	raw = make(chan *dbus.Signal, 16)
+	sigs = make(chan *WalletSignal, 16)
+	quit = make(chan bool)
+	d.Conn.Signal(raw)
+
+	go func() {
+		var s *dbus.Signal
+		var ws *WalletSignal
+		for {
+			select {
+			case <-quit:
+				return
+			case s = <-raw:
+				if s == nil || !wanted[s.Name] {
+					continue
+				}
+				if ws = toWalletSignal(s); ws == nil {
+					continue
+				}
+				select {
+				case sigs <- ws:
+				case <-quit:
+					return
+				}
+			}
+		}
+	}()
+
+	stop = func() {
+		d.Conn.RemoveSignal(raw)
+		for _, n := range names {
+			_ = d.Conn.RemoveMatchSignal(
+				dbus.WithMatchInterface(DbusInterfaceWM), dbus.WithMatchMember(n), dbus.WithMatchObjectPath(dbus.ObjectPath(DbusPath)),
+			)
+		}
+		close(quit)
+	}
+
+	return
+}
+
+// toWalletSignal converts a kwalletd Dbus signal to a WalletSignal. ws is nil if it is not about a Wallet.
+func toWalletSignal(s *dbus.Signal) (ws *WalletSignal) {
+
+	var ok bool
+	var walletName string
+	var handle int32
+
+	if len(s.Body) == 0 {
+		return
+	}
+
+	// walletClosed is also emitted with just a handle (an int).
+	if walletName, ok = s.Body[0].(string); !ok {
+		if handle, ok = s.Body[0].(int32); !ok {
+			return
+		}
+	}
+
+	ws = &WalletSignal{
+		Name:   s.Name[len(DbusInterfaceWM)+1:],
+		Wallet: walletName,
+		Handle: handle,
+	}
+
+	if len(s.Body) > 1 {
+		ws.Folder, _ = s.Body[1].(string)
+	}
+
+	return
+}
@@ -0,0 +1,405 @@
+package gokwallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+/*
+	NewMemoryBackendSnapshot returns a MemoryBackend populated from a Snapshot,
+	allowing the full Wallet/Folder/WalletItem API to be used on a Snapshot offline.
+	The MemoryBackend does not share memory with snap.
+	A Snapshot decoded from JSON saved without its values (see EntrySnapshot.MarshalJSON) can't be used (ErrSnapshotRedacted).
+*/
+func NewMemoryBackendSnapshot(snap *Snapshot) (backend *MemoryBackend, err error) {
+
+	var w *memWallet
+	var f *memFolder
+
+	backend = NewMemoryBackend()
+
+	if snap == nil {
+		return
+	}
+
+	for _, ws := range snap.Wallets {
+		w, _ = backend.getOrCreateWallet(ws.Name)
+		for _, fs := range ws.Folders {
+			f = &memFolder{
+				entries: make(map[string]*memEntry, len(fs.Entries)),
+			}
+			for _, es := range fs.Entries {
+				if es.redacted {
+					err = fmt.Errorf("%w: %#v/%#v/%#v", ErrSnapshotRedacted, ws.Name, fs.Name, es.Name)
+					backend = nil
+					return
+				}
+				f.entries[es.Name] = &memEntry{
+					entryType: es.Type,
+					value:     es.Bytes(),
+				}
+			}
+			w.folders[fs.Name] = f
+		}
+	}
+
+	return
+}
+
+/*
+	Snapshot returns a detached Snapshot of every Wallet (and all of their Folders and WalletItems) in a WalletManager.
+	Each Wallet is opened for the Snapshot and closed again afterwards. WalletManager.Wallets is not modified.
+*/
+func (wm *WalletManager) Snapshot() (snap *Snapshot, err error) {
+
+	var walletNames []string
+	var ws *WalletSnapshot
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if walletNames, err = wm.WalletNames(); err != nil {
+		return
+	}
+
+	sort.Strings(walletNames)
+
+	snap = &Snapshot{
+		AppID:   wm.AppID,
+		Taken:   time.Now(),
+		Wallets: make([]*WalletSnapshot, 0, len(walletNames)),
+	}
+
+	for _, wn := range walletNames {
+		if err = wm.withWallet(wn, func(w *Wallet) (err error) {
+			ws, err = w.Snapshot()
+			return
+		}); err != nil {
+			return
+		}
+		snap.Wallets = append(snap.Wallets, ws)
+	}
+
+	return
+}
+
+// Snapshot returns a detached WalletSnapshot of a Wallet and all of its Folders and WalletItems.
+func (w *Wallet) Snapshot() (snap *WalletSnapshot, err error) {
+
+	var folderNames []string
+	var f *Folder
+	var fs *FolderSnapshot
+
+	if folderNames, err = w.listUserFolders(); err != nil {
+		return
+	}
+
+	sort.Strings(folderNames)
+
+	snap = &WalletSnapshot{
+		Name:    w.Name,
+		Taken:   time.Now(),
+		Folders: make([]*FolderSnapshot, 0, len(folderNames)),
+	}
+
+	for _, fn := range folderNames {
+		if f, err = NewFolder(w, fn, &RecurseOpts{}); err != nil {
+			return
+		}
+		if fs, err = f.Snapshot(); err != nil {
+			return
+		}
+		snap.Folders = append(snap.Folders, fs)
+	}
+
+	return
+}
+
+/*
+	Snapshot returns a detached FolderSnapshot of a Folder and all of its WalletItems.
+	Expired WalletItems (see WalletManager.EnableExpiry) are left out, as they can no longer be read.
+*/
+func (f *Folder) Snapshot() (snap *FolderSnapshot, err error) {
+
+	var entryNames []string
+	var es *EntrySnapshot
+
+	if entryNames, err = f.ListEntries(); err != nil {
+		return
+	}
+
+	sort.Strings(entryNames)
+
+	snap = &FolderSnapshot{
+		Name:    f.Name,
+		Entries: make([]*EntrySnapshot, 0, len(entryNames)),
+	}
+
+	for _, en := range entryNames {
+		if err = f.expiryCheck(en); err != nil {
+			if errors.Is(err, ErrExpired) {
+				err = nil
+				continue
+			}
+			return
+		}
+		es = &EntrySnapshot{
+			Name:    en,
+			showRaw: f.wallet.wm.ShowValuesEnabled(),
+		}
+		if es.Type, err = f.wallet.wm.Backend().EntryType(f.wallet.handle, f.Name, en, f.wallet.wm.AppID); err != nil {
+			return
+		}
+		if es.raw, err = f.wallet.wm.Backend().ReadEntry(f.wallet.handle, f.Name, en, f.wallet.wm.AppID); err != nil {
+			return
+		}
+		snap.Entries = append(snap.Entries, es)
+	}
+
+	return
+}
+
+/*
+	Wallet returns the WalletSnapshot named walletName from a Snapshot (or nil if it doesn't exist).
+	Snapshot.Wallets need not be sorted (e.g. if the Snapshot was decoded from JSON written elsewhere).
+*/
+func (s *Snapshot) Wallet(walletName string) (ws *WalletSnapshot) {
+
+	for _, w := range s.Wallets {
+		if w != nil && w.Name == walletName {
+			ws = w
+			return
+		}
+	}
+
+	return
+}
+
+// Folder returns the FolderSnapshot named folderName from a WalletSnapshot (or nil if it doesn't exist), as Snapshot.Wallet.
+func (w *WalletSnapshot) Folder(folderName string) (fs *FolderSnapshot) {
+
+	for _, f := range w.Folders {
+		if f != nil && f.Name == folderName {
+			fs = f
+			return
+		}
+	}
+
+	return
+}
+
+// Entry returns the EntrySnapshot named entryName from a FolderSnapshot (or nil if it doesn't exist), as Snapshot.Wallet.
+func (f *FolderSnapshot) Entry(entryName string) (es *EntrySnapshot) {
+
+	for _, e := range f.Entries {
+		if e != nil && e.Name == entryName {
+			es = e
+			return
+		}
+	}
+
+	return
+}
+
+// Bytes returns a copy of the raw (serialized) value of an EntrySnapshot.
+func (e *EntrySnapshot) Bytes() (b []byte) {
+
+	b = make([]byte, len(e.raw))
+	copy(b, e.raw)
+
+	return
+}
+
+/*
+	MarshalJSON encodes an EntrySnapshot. Its raw value is left out (and "redacted" set) unless the Snapshot was taken
+	with WalletManager.SetShowValues(true), or was itself decoded from JSON that included it; it is then base64-encoded as "raw".
+	A redacted EntrySnapshot has the SHA-256 digest of its raw value instead (hex-encoded as "sha256"), so it can still be diffed.
+*/
+func (e EntrySnapshot) MarshalJSON() (b []byte, err error) {
+
+	var sum []byte
+	var j *jsonEntrySnapshot = &jsonEntrySnapshot{
+		Name:     e.Name,
+		Type:     e.Type,
+		Redacted: !e.showRaw,
+	}
+
+	if e.showRaw {
+		j.Raw = e.raw
+	} else if sum = e.sum(); sum != nil {
+		j.SHA256 = hex.EncodeToString(sum)
+	}
+
+	if b, err = json.Marshal(j); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	UnmarshalJSON decodes an EntrySnapshot encoded by EntrySnapshot.MarshalJSON.
+	A redacted EntrySnapshot decodes without a value: its decoded values (e.g. EntrySnapshot.Password) return ErrSnapshotRedacted.
+*/
+func (e *EntrySnapshot) UnmarshalJSON(b []byte) (err error) {
+
+	var j jsonEntrySnapshot
+
+	if err = json.Unmarshal(b, &j); err != nil {
+		return
+	}
+
+	e.Name = j.Name
+	e.Type = j.Type
+	e.raw = j.Raw
+	e.showRaw = !j.Redacted
+	e.redacted = j.Redacted
+	e.digest = nil
+
+	if j.Redacted && j.SHA256 != "" {
+		if e.digest, err = hex.DecodeString(j.SHA256); err != nil || len(e.digest) != sha256.Size {
+			err = fmt.Errorf("%w: bad digest for %#v", ErrSnapshotRedacted, j.Name)
+			e.digest = nil
+			return
+		}
+	}
+
+	return
+}
+
+// Map returns the decoded value of an EntrySnapshot for a Map.
+func (e *EntrySnapshot) Map() (m map[string]string, err error) {
+
+	if e.Type != KwalletdEnumTypeMap {
+		err = ErrBackendEntryType
+		return
+	}
+	if e.redacted {
+		err = ErrSnapshotRedacted
+		return
+	}
+
+	m = make(map[string]string, 0)
+
+	if len(e.raw) != 0 {
+		if m, _, err = bytesToMap(e.raw); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Password returns the decoded value of an EntrySnapshot for a Password.
+func (e *EntrySnapshot) Password() (s string, err error) {
+
+	if e.Type != KwalletdEnumTypePassword {
+		err = ErrBackendEntryType
+		return
+	}
+	if e.redacted {
+		err = ErrSnapshotRedacted
+		return
+	}
+
+	if s, err = qStringToString(e.raw); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	String returns a representation of an EntrySnapshot with its value replaced by RedactedValue
+	(use EntrySnapshot.Password, EntrySnapshot.Map, etc. to get the value).
+*/
+func (e EntrySnapshot) String() (str string) {
+
+	str = fmt.Sprintf("EntrySnapshot{Name: %q, Type: %v, Raw: %v}", e.Name, e.Type, RedactedValue)
+
+	return
+}
+
+// Format prints EntrySnapshot.String for every verb (see formatRedacted).
+func (e EntrySnapshot) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, e.String())
+
+	return
+}
+
+/*
+	sum returns the SHA-256 digest of the raw value of an EntrySnapshot.
+	For a redacted EntrySnapshot, that is the digest its JSON had (nil if it had none).
+*/
+func (e *EntrySnapshot) sum() (sum []byte) {
+
+	var digest [sha256.Size]byte
+
+	if e.redacted {
+		sum = e.digest
+		return
+	}
+
+	digest = sha256.Sum256(e.raw)
+	sum = digest[:]
+
+	return
+}
+
+// digestCheck returns ErrSnapshotRedacted if any EntrySnapshot in a FolderSnapshot can't be compared (see EntrySnapshot.sum).
+func (f *FolderSnapshot) digestCheck() (err error) {
+
+	if f == nil {
+		return
+	}
+
+	for _, e := range f.Entries {
+		if e != nil && e.sum() == nil {
+			err = fmt.Errorf("%w: no digest for %#v/%#v", ErrSnapshotRedacted, f.Name, e.Name)
+			return
+		}
+	}
+
+	return
+}
+
+// digestCheck is like FolderSnapshot.digestCheck, for every FolderSnapshot in a WalletSnapshot.
+func (w *WalletSnapshot) digestCheck() (err error) {
+
+	if w == nil {
+		return
+	}
+
+	for _, f := range w.Folders {
+		if err = f.digestCheck(); err != nil {
+			err = fmt.Errorf("wallet %#v: %w", w.Name, err)
+			return
+		}
+	}
+
+	return
+}
+
+// digestCheck is like FolderSnapshot.digestCheck, for every WalletSnapshot in a Snapshot.
+func (s *Snapshot) digestCheck() (err error) {
+
+	if s == nil {
+		return
+	}
+
+	for _, w := range s.Wallets {
+		if err = w.digestCheck(); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,153 @@
+package gokwallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSnapshot tests taking, serializing, and restoring a Snapshot.
+func TestSnapshot(t *testing.T) {
+
+	var err error
+	var b []byte
+	var e *testEnv
+	var snap *Snapshot
+	var snap2 *Snapshot
+	var es *EntrySnapshot
+	var s string
+	var m map[string]string
+	var wm *WalletManager
+	var p *Password
+	var mem *MemoryBackend
+	var handles int
+	var raw []byte
+	var unsorted *FolderSnapshot
+	var redacted *Snapshot
+	var cur *Snapshot
+	var d *Diff
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	if snap, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+
+	if es = snap.Wallet(e.w.Name).Folder(e.f.Name).Entry(passwordTest.String()); es == nil {
+		t.Fatalf("Password '%v' not in Snapshot", passwordTest.String())
+	}
+	if s, err = es.Password(); err != nil {
+		t.Errorf("failed to decode Password from Snapshot: %v", err)
+	} else if s != testPassword {
+		t.Errorf("Snapshot Password '%v' does not match expected '%v'", s, testPassword)
+	}
+	if _, err = es.Map(); err != ErrBackendEntryType {
+		t.Errorf("expected ErrBackendEntryType decoding a Password as a Map, got %v", err)
+	}
+	if m, err = snap.Wallet(e.w.Name).Folder(e.f.Name).Entry(mapTest.String()).Map(); err != nil {
+		t.Errorf("failed to decode Map from Snapshot: %v", err)
+	} else if !reflect.DeepEqual(m, testMap) {
+		t.Errorf("Snapshot Map '%#v' does not match expected '%#v'", m, testMap)
+	}
+
+	// Snapshots must be detached from the live wallet.
+	if _, err = e.f.WritePassword(passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to WritePassword: %v", err)
+	}
+	if s, _ = es.Password(); s != testPassword {
+		t.Errorf("Snapshot changed after live write: '%v'", s)
+	}
+
+	// Values are only handed out as copies.
+	raw = es.Bytes()
+	raw[len(raw)-1] ^= 0xff
+	if s, _ = es.Password(); s != testPassword {
+		t.Errorf("Snapshot changed after modifying EntrySnapshot.Bytes: '%v'", s)
+	}
+
+	// Lookups don't depend on the order of the snapshots.
+	unsorted = &FolderSnapshot{
+		Entries: []*EntrySnapshot{{Name: "z"}, {Name: "a"}, {Name: "m"}},
+	}
+	if unsorted.Entry("a") != unsorted.Entries[1] || unsorted.Entry("b") != nil {
+		t.Errorf("lookup in unsorted FolderSnapshot failed")
+	}
+
+	// The Wallets opened for a Snapshot are closed again.
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if _, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("Snapshot leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+
+	// Values are left out of JSON unless they're shown.
+	if b, err = json.Marshal(snap); err != nil {
+		t.Fatalf("failed to marshal Snapshot: %v", err)
+	}
+	if strings.Contains(string(b), base64.StdEncoding.EncodeToString(es.Bytes())) || !strings.Contains(string(b), `"redacted":true`) {
+		t.Errorf("Snapshot JSON not redacted: %v", string(b))
+	}
+
+	// A redacted Snapshot can still be diffed (by digest), but not used for its values.
+	redacted = new(Snapshot)
+	if err = json.Unmarshal(b, redacted); err != nil {
+		t.Fatalf("failed to unmarshal redacted Snapshot: %v", err)
+	}
+	if _, err = NewMemoryBackendSnapshot(redacted); !errors.Is(err, ErrSnapshotRedacted) {
+		t.Errorf("expected ErrSnapshotRedacted for a MemoryBackend from a redacted Snapshot, got %v", err)
+	}
+	if _, err = redacted.Wallet(e.w.Name).Folder(e.f.Name).Entry(passwordTest.String()).Password(); !errors.Is(err, ErrSnapshotRedacted) {
+		t.Errorf("expected ErrSnapshotRedacted decoding a redacted Password, got %v", err)
+	}
+	if d, err = DiffSnapshots(snap, redacted, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("redacted Snapshot differs from the original (err: %v):\n%v", err, d.Text())
+	}
+	if cur, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+	if d, err = DiffSnapshots(redacted, cur, nil); err != nil || len(d.Changes) != 1 || d.Changes[0].Op != DiffModified {
+		t.Errorf("unexpected Diff from a redacted Snapshot (err: %v):\n%v", err, d.Text())
+	}
+	redacted.Wallets[0].Folders[0].Entries[0].digest = nil
+	if _, err = DiffSnapshots(redacted, cur, nil); !errors.Is(err, ErrSnapshotRedacted) {
+		t.Errorf("expected ErrSnapshotRedacted diffing a redacted Snapshot without digests, got %v", err)
+	}
+
+	e.wm.SetShowValues(true)
+	if snap, err = e.wm.Snapshot(); err != nil {
+		t.Fatalf("failed to take Snapshot: %v", err)
+	}
+	e.wm.SetShowValues(false)
+	if b, err = json.Marshal(snap); err != nil {
+		t.Fatalf("failed to marshal Snapshot: %v", err)
+	}
+	snap2 = new(Snapshot)
+	if err = json.Unmarshal(b, snap2); err != nil {
+		t.Fatalf("failed to unmarshal Snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(snap.Wallets[0].Folders, snap2.Wallets[0].Folders) {
+		t.Errorf("Snapshot did not survive a JSON round trip:\n%#v\n%#v", snap.Wallets[0].Folders, snap2.Wallets[0].Folders)
+	}
+
+	// And it should be usable offline.
+	if mem, err = NewMemoryBackendSnapshot(snap2); err != nil {
+		t.Fatalf("failed to get MemoryBackend from Snapshot: %v", err)
+	}
+	if wm, err = NewWalletManagerBackend(mem, e.r, appIdTest); err != nil {
+		t.Fatalf("failed to get WalletManager from Snapshot: %v", err)
+	}
+	if p = wm.Wallets[e.w.Name].Folders[e.f.Name].Passwords[passwordTest.String()]; p == nil || p.Value != testPasswordReplace {
+		t.Errorf("Password not restored from Snapshot correctly: %#v", p)
+	}
+}
@@ -0,0 +1,646 @@
+package gokwallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	ParseStateSpec parses a StateSpec from YAML or JSON (JSON being a subset of YAML).
+	For example:
+
+		wallets:
+		  kdewallet:
+		    folders:
+		      Passwords:
+		        prune: true
+		        passwords:
+		          db_admin: "hunter2"
+		        maps:
+		          smtp:
+		            user: alice
+		            host: mail.example.com
+		        blobs:
+		          cert: "MIIB..."
+*/
+func ParseStateSpec(b []byte) (spec *StateSpec, err error) {
+
+	spec = new(StateSpec)
+
+	if err = yaml.Unmarshal(b, spec); err != nil {
+		spec = nil
+		err = fmt.Errorf("%w: %v", ErrStateSpec, err)
+		return
+	}
+
+	if err = spec.validate(); err != nil {
+		spec = nil
+		return
+	}
+
+	return
+}
+
+// ReadStateSpec is like ParseStateSpec, but reads the YAML or JSON from r.
+func ReadStateSpec(r io.Reader) (spec *StateSpec, err error) {
+
+	var b []byte
+
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+
+	if spec, err = ParseStateSpec(b); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	PlanState compares the Wallets in a WalletManager against spec and returns the StatePlan needed to make them match.
+	Nothing is changed; use WalletManager.ApplyState to perform the StatePlan.
+	If opts is nil, entries not in spec are left alone (i.e. no pruning).
+
+	Wallets in spec that do not exist yet are not opened (which would create them); they are created by ApplyState instead.
+*/
+func (wm *WalletManager) PlanState(spec *StateSpec, opts *StateOpts) (plan *StatePlan, err error) {
+
+	var walletNames []string
+	var exists map[string]bool = make(map[string]bool)
+	var ops []*StateOp
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if spec == nil {
+		err = ErrStateSpec
+		return
+	}
+
+	if err = spec.validate(); err != nil {
+		return
+	}
+
+	if opts == nil {
+		opts = new(StateOpts)
+	}
+
+	if walletNames, err = wm.WalletNames(); err != nil {
+		return
+	}
+	for _, wn := range walletNames {
+		exists[wn] = true
+	}
+
+	plan = &StatePlan{
+		Ops: make([]*StateOp, 0),
+	}
+
+	for _, wn := range walletStateKeys(spec.Wallets) {
+		if spec.Wallets[wn] == nil {
+			continue
+		}
+		if exists[wn] {
+			if err = wm.withWallet(wn, func(w *Wallet) (err error) {
+				ops, err = planWallet(wn, w, spec.Wallets[wn], opts)
+				return
+			}); err != nil {
+				return
+			}
+		} else if ops, err = planWallet(wn, nil, spec.Wallets[wn], opts); err != nil {
+			return
+		}
+		plan.Ops = append(plan.Ops, ops...)
+	}
+
+	return
+}
+
+/*
+	PlanState is like WalletManager.PlanState, but for a single Wallet.
+	The resulting StatePlan can be applied with WalletManager.ApplyState (or Wallet.ApplyState).
+*/
+func (w *Wallet) PlanState(spec *WalletStateSpec, opts *StateOpts) (plan *StatePlan, err error) {
+
+	if spec == nil {
+		err = ErrStateSpec
+		return
+	}
+
+	if err = spec.validate(); err != nil {
+		return
+	}
+
+	if opts == nil {
+		opts = new(StateOpts)
+	}
+
+	plan = new(StatePlan)
+
+	if plan.Ops, err = planWallet(w.Name, w, spec, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	ApplyState performs the operations in a StatePlan (as returned by WalletManager.PlanState), in order,
+	and returns a StateResult for every StateOp in plan.
+	If opts is nil (or opts.ContinueOnError is false), the operations after the first failure are skipped.
+	err is a MultiError of all failed operations, if any.
+
+	Wallets referenced by plan that do not exist are created (by opening them).
+	The Wallets opened by ApplyState are closed again before it returns.
+
+	plan must come from PlanState in the same process: values to be written are not serialized (see StatePlan.JSON),
+	so a StateWriteEntry StateOp from a decoded plan fails with ErrStateValue instead of writing an empty value.
+*/
+func (wm *WalletManager) ApplyState(plan *StatePlan, opts *StateOpts) (results []*StateResult, err error) {
+
+	var ok bool
+	var w *Wallet
+	var closeErr error
+	var wallets map[string]*Wallet = make(map[string]*Wallet)
+	var opened []string
+	var failed bool
+	var res *StateResult
+	var errs []error = make([]error, 0)
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if plan == nil {
+		return
+	}
+
+	if opts == nil {
+		opts = new(StateOpts)
+	}
+
+	results = make([]*StateResult, 0, len(plan.Ops))
+
+	for _, op := range plan.Ops {
+		res = &StateResult{
+			Op: op,
+		}
+		results = append(results, res)
+		if failed && !opts.ContinueOnError {
+			res.Skipped = true
+			continue
+		}
+		if w, ok = wallets[op.Wallet]; !ok {
+			if w, res.Err = NewWallet(wm, op.Wallet, &RecurseOpts{}); res.Err == nil {
+				wallets[op.Wallet] = w
+				opened = append(opened, op.Wallet)
+			}
+		}
+		if res.Err == nil {
+			res.Err = op.apply(w)
+		}
+		if res.Err != nil {
+			res.Err = fmt.Errorf("%v: %w", op.String(), res.Err)
+			res.Error = res.Err.Error()
+			errs = append(errs, res.Err)
+			failed = true
+		}
+	}
+
+	for _, wn := range opened {
+		if closeErr = wallets[wn].Close(); closeErr != nil {
+			errs = append(errs, fmt.Errorf("wallet %#v: %w", wn, closeErr))
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// ApplyState is like WalletManager.ApplyState, but only applies the StateOp objects in plan that are for this Wallet.
+func (w *Wallet) ApplyState(plan *StatePlan, opts *StateOpts) (results []*StateResult, err error) {
+
+	var filtered *StatePlan
+
+	if plan == nil {
+		return
+	}
+
+	filtered = &StatePlan{
+		Ops: make([]*StateOp, 0, len(plan.Ops)),
+	}
+	for _, op := range plan.Ops {
+		if op.Wallet == w.Name {
+			filtered.Ops = append(filtered.Ops, op)
+		}
+	}
+
+	if results, err = w.wm.ApplyState(filtered, opts); err != nil {
+		return
+	}
+
+	return
+}
+
+// IsEmpty returns true if a StatePlan has no operations (i.e. everything already matches the StateSpec).
+func (p *StatePlan) IsEmpty() (isEmpty bool) {
+
+	isEmpty = p == nil || len(p.Ops) == 0
+
+	return
+}
+
+/*
+	JSON returns an indented JSON representation of a StatePlan for review. Values to be written are never included,
+	so a StatePlan decoded from it cannot be applied (see WalletManager.ApplyState).
+*/
+func (p *StatePlan) JSON() (b []byte, err error) {
+
+	if b, err = json.MarshalIndent(p, "", "  "); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Text returns a human-readable ("dry-run") representation of a StatePlan, one StateOp per line.
+	Values to be written are never included.
+*/
+func (p *StatePlan) Text() (s string) {
+
+	var buf *strings.Builder = new(strings.Builder)
+
+	if p.IsEmpty() {
+		return
+	}
+
+	for _, op := range p.Ops {
+		buf.WriteString(op.String())
+		if op.Reason != "" {
+			fmt.Fprintf(buf, " (%v)", op.Reason)
+		}
+		buf.WriteString("\n")
+	}
+
+	s = buf.String()
+
+	return
+}
+
+// String returns a short, single-line description of a StateOp.
+func (o *StateOp) String() (s string) {
+
+	var p string = fmt.Sprintf("%#v/%#v", o.Wallet, o.Folder)
+
+	if o.Entry != "" {
+		p += fmt.Sprintf("/%#v", o.Entry)
+	}
+
+	switch o.Action {
+	case StateCreateFolder:
+		s = fmt.Sprintf("+ %v %v", o.Action, p)
+	case StateWriteEntry:
+		s = fmt.Sprintf("~ %v %v", o.Action, p)
+		if o.Type != nil {
+			s += fmt.Sprintf(" [%v]", o.Type)
+		}
+	case StateRenameEntry:
+		s = fmt.Sprintf("> %v %v -> %#v", o.Action, p, o.NewEntry)
+	case StateRemoveEntry:
+		s = fmt.Sprintf("- %v %v", o.Action, p)
+	default:
+		s = fmt.Sprintf("? %v %v", o.Action, p)
+	}
+
+	return
+}
+
+// apply performs a single StateOp against Wallet w.
+func (o *StateOp) apply(w *Wallet) (err error) {
+
+	var f *Folder
+
+	if o.Action == StateWriteEntry && o.value == nil {
+		err = ErrStateValue
+		return
+	}
+
+	if o.Action != StateCreateFolder {
+		if f, err = NewFolder(w, o.Folder, &RecurseOpts{}); err != nil {
+			return
+		}
+	}
+
+	switch o.Action {
+	case StateCreateFolder:
+		err = w.CreateFolder(o.Folder)
+	case StateWriteEntry:
+		if o.Type == nil {
+			err = ErrUnknownEntryType
+			return
+		}
+		err = f.WriteEntry(o.Entry, *o.Type, o.value)
+	case StateRenameEntry:
+		err = f.RenameEntry(o.Entry, o.NewEntry)
+	case StateRemoveEntry:
+		err = f.RemoveEntry(o.Entry)
+	default:
+		err = ErrStateAction
+	}
+
+	return
+}
+
+// validate checks a StateSpec for errors, returning the first one found.
+func (s *StateSpec) validate() (err error) {
+
+	for _, wn := range walletStateKeys(s.Wallets) {
+		if s.Wallets[wn] == nil {
+			continue
+		}
+		if err = s.Wallets[wn].validate(); err != nil {
+			err = fmt.Errorf("wallet %#v: %w", wn, err)
+			return
+		}
+	}
+
+	return
+}
+
+// validate checks a WalletStateSpec for errors, returning the first one found.
+func (s *WalletStateSpec) validate() (err error) {
+
+	for _, fn := range folderStateKeys(s.Folders) {
+		if s.Folders[fn] == nil {
+			continue
+		}
+		if _, err = s.Folders[fn].entries(); err != nil {
+			err = fmt.Errorf("folder %#v: %w", fn, err)
+			return
+		}
+	}
+
+	return
+}
+
+// entries returns the desired WalletItems of a FolderStateSpec as EntrySnapshot objects (keyed by name).
+func (s *FolderStateSpec) entries() (entries map[string]*EntrySnapshot, err error) {
+
+	var b []byte
+
+	entries = make(map[string]*EntrySnapshot)
+
+	for k, v := range s.Passwords {
+		if err = addStateEntry(entries, k, KwalletdEnumTypePassword, stringToQString(v)); err != nil {
+			return
+		}
+	}
+	for k, v := range s.Maps {
+		if v == nil {
+			v = make(map[string]string)
+		}
+		if b, err = mapToBytes(v); err != nil {
+			return
+		}
+		if err = addStateEntry(entries, k, KwalletdEnumTypeMap, b); err != nil {
+			return
+		}
+	}
+	for k, v := range s.Blobs {
+		if b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v)); err != nil {
+			err = fmt.Errorf("%w: blob %#v: %v", ErrStateSpec, k, err)
+			return
+		}
+		if err = addStateEntry(entries, k, KwalletdEnumTypeStream, b); err != nil {
+			return
+		}
+	}
+	for k, v := range s.Unknown {
+		if b, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v)); err != nil {
+			err = fmt.Errorf("%w: unknown item %#v: %v", ErrStateSpec, k, err)
+			return
+		}
+		if err = addStateEntry(entries, k, KwalletdEnumTypeUnknown, b); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// addStateEntry adds a desired WalletItem to entries, returning an error if its name is already used.
+func addStateEntry(entries map[string]*EntrySnapshot, name string, entryType kwalletdEnumType, raw []byte) (err error) {
+
+	if _, exists := entries[name]; exists {
+		err = fmt.Errorf("%w: entry %#v is declared more than once", ErrStateSpec, name)
+		return
+	}
+
+	entries[name] = &EntrySnapshot{
+		Name: name,
+		Type: entryType,
+		raw:  raw,
+	}
+
+	return
+}
+
+// planWallet returns the StateOp objects needed for Wallet w (which is nil if it does not exist yet) to match spec.
+func planWallet(walletName string, w *Wallet, spec *WalletStateSpec, opts *StateOpts) (ops []*StateOp, err error) {
+
+	var hasFolder bool
+	var f *Folder
+	var fs *FolderSnapshot
+	var folderOps []*StateOp
+
+	ops = make([]*StateOp, 0)
+
+	for _, fn := range folderStateKeys(spec.Folders) {
+		if spec.Folders[fn] == nil {
+			continue
+		}
+		fs = nil
+		if w != nil {
+			if hasFolder, err = w.HasFolder(fn); err != nil {
+				return
+			}
+			if hasFolder {
+				if f, err = NewFolder(w, fn, &RecurseOpts{}); err != nil {
+					return
+				}
+				if fs, err = f.Snapshot(); err != nil {
+					return
+				}
+			}
+		}
+		if folderOps, err = planFolder(walletName, fn, fs, spec.Folders[fn], opts); err != nil {
+			return
+		}
+		ops = append(ops, folderOps...)
+	}
+
+	return
+}
+
+/*
+	planFolder returns the StateOp objects needed for a Folder (with current contents cur, which is nil if the Folder
+	does not exist) to match spec. Renames come first, then writes, then removals.
+*/
+func planFolder(walletName, folderName string, cur *FolderSnapshot, spec *FolderStateSpec, opts *StateOpts) (ops []*StateOp, err error) {
+
+	var ok bool
+	var prune bool = opts.Prune
+	var want map[string]*EntrySnapshot
+	var have map[string]*EntrySnapshot = make(map[string]*EntrySnapshot)
+	var missing []string = make([]string, 0)
+	var extra []string = make([]string, 0)
+	var renamed map[string]bool = make(map[string]bool)
+	var writes []*StateOp = make([]*StateOp, 0)
+	var removes []*StateOp = make([]*StateOp, 0)
+	var e *EntrySnapshot
+	var reason string
+
+	if spec.Prune != nil {
+		prune = *spec.Prune
+	}
+
+	if want, err = spec.entries(); err != nil {
+		return
+	}
+
+	ops = make([]*StateOp, 0)
+
+	if cur == nil {
+		ops = append(ops, &StateOp{
+			Action: StateCreateFolder,
+			Wallet: walletName,
+			Folder: folderName,
+			Reason: "folder does not exist",
+		})
+	} else {
+		for _, es := range cur.Entries {
+			have[es.Name] = es
+		}
+	}
+
+	for _, en := range entrySnapshotKeys(want, have) {
+		if _, ok = want[en]; !ok {
+			extra = append(extra, en)
+			continue
+		}
+		if e, ok = have[en]; !ok {
+			missing = append(missing, en)
+			continue
+		}
+		if e.Type != want[en].Type {
+			writes = append(writes, newStateWrite(walletName, folderName, want[en], fmt.Sprintf("type is %v", e.Type)))
+		} else if !stateEntryEqual(e, want[en]) {
+			writes = append(writes, newStateWrite(walletName, folderName, want[en], "value differs"))
+		}
+	}
+
+	for _, en := range missing {
+		reason = "entry does not exist"
+		// If we'd be removing an identical entry anyway, just rename it instead.
+		if prune {
+			for _, xn := range extra {
+				if renamed[xn] || have[xn].Type != want[en].Type || !stateEntryEqual(have[xn], want[en]) {
+					continue
+				}
+				renamed[xn] = true
+				ops = append(ops, &StateOp{
+					Action:   StateRenameEntry,
+					Wallet:   walletName,
+					Folder:   folderName,
+					Entry:    xn,
+					NewEntry: en,
+					Reason:   "identical entry exists under another name",
+				})
+				reason = ""
+				break
+			}
+		}
+		if reason != "" {
+			writes = append(writes, newStateWrite(walletName, folderName, want[en], reason))
+		}
+	}
+
+	if prune {
+		for _, xn := range extra {
+			if renamed[xn] {
+				continue
+			}
+			removes = append(removes, &StateOp{
+				Action: StateRemoveEntry,
+				Wallet: walletName,
+				Folder: folderName,
+				Entry:  xn,
+				Reason: "not in spec",
+			})
+		}
+	}
+
+	ops = append(ops, writes...)
+	ops = append(ops, removes...)
+
+	return
+}
+
+// newStateWrite returns a StateWriteEntry StateOp for EntrySnapshot e.
+func newStateWrite(walletName, folderName string, e *EntrySnapshot, reason string) (op *StateOp) {
+
+	op = &StateOp{
+		Action: StateWriteEntry,
+		Wallet: walletName,
+		Folder: folderName,
+		Entry:  e.Name,
+		Type:   entryTypePtr(e.Type),
+		Reason: reason,
+		value:  e.Bytes(),
+	}
+
+	return
+}
+
+/*
+	stateEntryEqual returns true if two EntrySnapshot objects of the same type have the same value.
+	Maps are compared by their decoded contents, since their serialized key order is not stable.
+*/
+func stateEntryEqual(a, b *EntrySnapshot) (isEqual bool) {
+
+	var err error
+	var am map[string]string
+	var bm map[string]string
+
+	if a.Type != KwalletdEnumTypeMap {
+		isEqual = bytes.Equal(a.raw, b.raw)
+		return
+	}
+
+	if am, err = a.Map(); err != nil {
+		return
+	}
+	if bm, err = b.Map(); err != nil {
+		return
+	}
+
+	isEqual = mapsEqual(am, bm)
+
+	return
+}
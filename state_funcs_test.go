@@ -0,0 +1,178 @@
+package gokwallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestState tests planning and applying a StateSpec.
+func TestState(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var spec *StateSpec
+	var specYaml string
+	var plan *StatePlan
+	var results []*StateResult
+	var actions map[string]StateAction = make(map[string]StateAction)
+	var b []byte
+	var p *Password
+	var m map[string]string
+	var hasEntry bool
+	var mem *MemoryBackend
+	var handles int
+	var newFolder string = folderTest.String() + "_new"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+
+	// passwordTest is "renamed" to passwordTestRename, blobTest is pruned, and unknownItemTest is left as-is.
+	specYaml = fmt.Sprintf(
+		"wallets:\n"+
+			"  %q:\n"+
+			"    folders:\n"+
+			"      %q:\n"+
+			"        passwords:\n"+
+			"          %q: %q\n"+
+			"        maps:\n"+
+			"          %q: {foo: bar}\n"+
+			"        unknown:\n"+
+			"          %q: %q\n"+
+			"      %q:\n"+
+			"        passwords:\n"+
+			"          %q: %q\n",
+		walletTest.String(),
+		folderTest.String(),
+		passwordTestRename.String(), testPassword,
+		mapTest.String(),
+		unknownItemTest.String(), base64.StdEncoding.EncodeToString(testBytes),
+		newFolder,
+		passwordTest.String(), testPasswordReplace,
+	)
+
+	if spec, err = ParseStateSpec([]byte(specYaml)); err != nil {
+		t.Fatalf("failed to parse StateSpec: %v", err)
+	}
+
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+
+	if plan, err = e.wm.PlanState(spec, &StateOpts{Prune: true}); err != nil {
+		t.Fatalf("failed to PlanState: %v", err)
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("PlanState leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+	t.Logf("plan:\n%v", plan.Text())
+
+	for _, op := range plan.Ops {
+		actions[op.Folder+"/"+op.Entry] = op.Action
+		if op.Action == StateRenameEntry && op.NewEntry != passwordTestRename.String() {
+			t.Errorf("unexpected rename target %#v", op.NewEntry)
+		}
+	}
+	for k, v := range map[string]StateAction{
+		folderTest.String() + "/" + passwordTest.String(): StateRenameEntry,
+		folderTest.String() + "/" + mapTest.String():      StateWriteEntry,
+		folderTest.String() + "/" + blobTest.String():     StateRemoveEntry,
+		newFolder + "/":                         StateCreateFolder,
+		newFolder + "/" + passwordTest.String(): StateWriteEntry,
+	} {
+		if actions[k] != v {
+			t.Errorf("expected %v for %v, got %#v", v, k, actions[k])
+		}
+	}
+	if len(plan.Ops) != 5 {
+		t.Errorf("expected 5 operations, got %v", len(plan.Ops))
+	}
+
+	if b, err = plan.JSON(); err != nil {
+		t.Fatalf("failed to marshal StatePlan: %v", err)
+	}
+	if json.Valid(b) == false {
+		t.Errorf("StatePlan.JSON is not valid JSON")
+	}
+
+	if results, err = e.wm.ApplyState(plan, nil); err != nil {
+		t.Fatalf("failed to ApplyState: %v", err)
+	}
+	if len(results) != len(plan.Ops) {
+		t.Errorf("expected %v results, got %v", len(plan.Ops), len(results))
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("ApplyState leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+
+	if p, err = NewPassword(e.f, passwordTestRename.String(), e.r); err != nil {
+		t.Fatalf("failed to get renamed Password: %v", err)
+	}
+	if p.Value != testPassword {
+		t.Errorf("renamed Password has value %#v, expected %#v", p.Value, testPassword)
+	}
+	if m, err = e.wm.backend.ReadMap(e.w.handle, folderTest.String(), mapTest.String(), appIdTest); err != nil {
+		t.Fatalf("failed to ReadMap: %v", err)
+	}
+	if len(m) != 1 || m["foo"] != "bar" {
+		t.Errorf("unexpected Map value after apply: %#v", m)
+	}
+	if hasEntry, err = e.f.HasEntry(blobTest.String()); err != nil {
+		t.Fatalf("failed to HasEntry: %v", err)
+	} else if hasEntry {
+		t.Errorf("pruned Blob still exists")
+	}
+
+	// Applying should converge.
+	if plan, err = e.wm.PlanState(spec, &StateOpts{Prune: true}); err != nil {
+		t.Fatalf("failed to PlanState: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("expected an empty plan after apply, got:\n%v", plan.Text())
+	}
+
+	// Without pruning, extra entries are left alone and nothing is renamed.
+	if _, err = e.f.WriteBlob(blobTest.String(), testBytes); err != nil {
+		t.Fatalf("failed to WriteBlob: %v", err)
+	}
+	if plan, err = e.wm.PlanState(spec, nil); err != nil {
+		t.Fatalf("failed to PlanState: %v", err)
+	}
+	if !plan.IsEmpty() {
+		t.Errorf("expected an empty plan without pruning, got:\n%v", plan.Text())
+	}
+
+	// Duplicate names across types are rejected.
+	if _, err = ParseStateSpec([]byte(`{"wallets": {"w": {"folders": {"f": {"passwords": {"x": "y"}, "blobs": {"x": ""}}}}}}`)); !errors.Is(err, ErrStateSpec) {
+		t.Errorf("expected ErrStateSpec for a duplicate entry, got %v", err)
+	}
+
+	// A plan decoded from JSON has no values, so its writes fail instead of blanking entries.
+	if _, err = e.f.WriteMap(mapTest.String(), map[string]string{"foo": "baz"}); err != nil {
+		t.Fatalf("failed to WriteMap: %v", err)
+	}
+	if plan, err = e.wm.PlanState(spec, nil); err != nil {
+		t.Fatalf("failed to PlanState: %v", err)
+	}
+	if b, err = plan.JSON(); err != nil {
+		t.Fatalf("failed to marshal StatePlan: %v", err)
+	}
+	plan = new(StatePlan)
+	if err = json.Unmarshal(b, plan); err != nil {
+		t.Fatalf("failed to unmarshal StatePlan: %v", err)
+	}
+	if results, err = e.wm.ApplyState(plan, nil); err == nil || len(results) != 1 || !errors.Is(results[0].Err, ErrStateValue) {
+		t.Errorf("expected ErrStateValue applying a decoded plan, got %v", err)
+	}
+	if m, err = e.wm.backend.ReadMap(e.w.handle, folderTest.String(), mapTest.String(), appIdTest); err != nil {
+		t.Fatalf("failed to ReadMap: %v", err)
+	}
+	if m["foo"] != "baz" {
+		t.Errorf("applying a decoded plan changed the Map: %#v", m)
+	}
+}
@@ -0,0 +1,325 @@
+package gokwallet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+	NewSweeper returns a Sweeper for a WalletManager, which must have expiry enabled (see WalletManager.EnableExpiry).
+	interval is the Sweeper.Interval; the other fields may be set before calling Sweeper.Start.
+*/
+func NewSweeper(wm *WalletManager, interval time.Duration) (s *Sweeper, err error) {
+
+	if !wm.ExpiryEnabled() {
+		err = ErrExpiryDisabled
+		return
+	}
+
+	s = &Sweeper{
+		Interval: interval,
+		wm:       wm,
+	}
+
+	return
+}
+
+// Sweep removes every expired WalletItem from the Wallets of a Sweeper (see Sweeper.Wallets).
+func (s *Sweeper) Sweep() (report *SweepReport, err error) {
+
+	var walletNames []string
+	var errs []error = make([]error, 0)
+
+	report = &SweepReport{
+		Started: time.Now(),
+		Removed: make([]*SweptEntry, 0),
+	}
+
+	if walletNames, err = s.walletNames(); err != nil {
+		s.finish(report, err)
+		return
+	}
+
+	for _, wn := range walletNames {
+		if err = s.sweep(report, wn, ""); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	s.finish(report, err)
+
+	return
+}
+
+// SweepFolder removes every expired WalletItem from Folder folderName in Wallet walletName.
+func (s *Sweeper) SweepFolder(walletName, folderName string) (report *SweepReport, err error) {
+
+	report = &SweepReport{
+		Started: time.Now(),
+		Removed: make([]*SweptEntry, 0),
+	}
+
+	err = s.sweep(report, walletName, folderName)
+
+	s.finish(report, err)
+
+	return
+}
+
+/*
+	Start runs a Sweeper in the background until Sweeper.Stop is called:
+	all Wallets are swept every Sweeper.Interval, and individual Folders when signalled (see Sweeper.Signals and Sweeper.Notify).
+	Each SweepReport is passed to Sweeper.OnSweep.
+*/
+func (s *Sweeper) Start() (err error) {
+
+	var sigs chan *WalletSignal
+	var stopSigs func()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running {
+		err = ErrSweeperRunning
+		return
+	}
+
+	if s.Signals {
+		if sigs, stopSigs, err = s.wm.watchSignals(DbusWMSignalFolderUpdated); err != nil {
+			return
+		}
+	}
+
+	s.running = true
+	s.stop = make(chan bool)
+	s.done = make(chan bool)
+	s.notify = make(chan *WalletSignal, 16)
+
+	go s.run(sigs, stopSigs)
+
+	return
+}
+
+// Stop stops a running Sweeper (see Sweeper.Start), waiting for any sweep in progress to finish.
+func (s *Sweeper) Stop() {
+
+	var done chan bool
+
+	s.lock.Lock()
+
+	if !s.running {
+		s.lock.Unlock()
+		return
+	}
+
+	// The lock isn't held while waiting, as Sweeper.OnSweep may call Sweeper.Notify.
+	s.running = false
+	close(s.stop)
+	done = s.done
+	s.lock.Unlock()
+
+	<-done
+
+	return
+}
+
+/*
+	Notify asks a running Sweeper to sweep Folder folderName in Wallet walletName (or all of its Folders, if folderName is empty).
+	This is useful for Backends without signals. It does nothing if the Sweeper is not running.
+*/
+func (s *Sweeper) Notify(walletName, folderName string) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	select {
+	case s.notify <- &WalletSignal{Name: DbusWMSignalFolderUpdated, Wallet: walletName, Folder: folderName}:
+	default:
+		// A sweep is already pending; it'll get picked up by the next periodic sweep at the latest.
+	}
+
+	return
+}
+
+// finish completes report.
+func (s *Sweeper) finish(report *SweepReport, err error) {
+
+	report.Finished = time.Now()
+	report.Err = err
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return
+}
+
+// run is the loop of a running Sweeper.
+func (s *Sweeper) run(sigs chan *WalletSignal, stopSigs func()) {
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	var sig *WalletSignal
+	var report *SweepReport
+
+	defer close(s.done)
+
+	if stopSigs != nil {
+		defer stopSigs()
+	}
+
+	if s.Interval > 0 {
+		ticker = time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick:
+			report, _ = s.Sweep()
+		case sig = <-sigs:
+			if sig.Wallet == "" || !s.wanted(sig.Wallet) || isReservedFolder(sig.Folder) {
+				continue
+			}
+			report, _ = s.SweepFolder(sig.Wallet, sig.Folder)
+		case sig = <-s.notify:
+			report, _ = s.SweepFolder(sig.Wallet, sig.Folder)
+		}
+		if s.OnSweep != nil {
+			s.OnSweep(report)
+		}
+	}
+}
+
+// sweep removes the expired WalletItems in Folder folderName (or every Folder, if empty) of Wallet walletName, adding them to report.
+func (s *Sweeper) sweep(report *SweepReport, walletName, folderName string) (err error) {
+
+	if err = s.wm.withWallet(walletName, func(w *Wallet) (err error) {
+		err = s.sweepWallet(report, w, folderName)
+		return
+	}); err != nil {
+		return
+	}
+
+	return
+}
+
+// sweepWallet does the work of Sweeper.sweep once Wallet w is open.
+func (s *Sweeper) sweepWallet(report *SweepReport, w *Wallet, folderName string) (err error) {
+
+	var ok bool
+	var f *Folder
+	var keys []string
+	var fn string
+	var en string
+	var exists bool
+	var se *SweptEntry
+	var expires time.Time
+	var now time.Time = time.Now()
+	var folders map[string]*Folder = make(map[string]*Folder)
+	var errs []error = make([]error, 0)
+
+	if keys, err = s.wm.Backend().PasswordList(w.handle, ExpiryFolder, s.wm.AppID); err != nil {
+		return
+	}
+
+	for _, k := range keys {
+		if folderName != "" && !strings.HasPrefix(k, metadataKey(folderName, "")) {
+			continue
+		}
+		if fn, en, ok = splitMetadataKey(k); !ok {
+			continue
+		}
+		if expires, err = readExpiry(s.wm.Backend(), w.handle, fn, en, s.wm.AppID); err != nil {
+			errs = append(errs, err)
+			err = nil
+			continue
+		}
+		if expires.IsZero() || expires.After(now) {
+			continue
+		}
+		if f, ok = folders[fn]; !ok {
+			if f, err = NewFolder(w, fn, &RecurseOpts{}); err != nil {
+				errs = append(errs, err)
+				err = nil
+				continue
+			}
+			folders[fn] = f
+		}
+		// Stale expiry for a WalletItem that was removed behind our back; just clean it up.
+		if exists, err = f.HasEntry(en); err != nil {
+			errs = append(errs, err)
+			err = nil
+			continue
+		} else if !exists {
+			if err = removeExpiry(s.wm.Backend(), w.handle, fn, en, s.wm.AppID); err != nil {
+				errs = append(errs, err)
+				err = nil
+			}
+			continue
+		}
+		se = &SweptEntry{
+			Wallet:  w.Name,
+			Folder:  fn,
+			Entry:   en,
+			Expired: expires,
+		}
+		if se.Err = f.RemoveEntry(en); se.Err != nil {
+			se.Error = se.Err.Error()
+			errs = append(errs, fmt.Errorf("remove %#v/%#v/%#v: %w", w.Name, fn, en, se.Err))
+		}
+		report.Removed = append(report.Removed, se)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+// walletNames returns the names of the Wallets to sweep.
+func (s *Sweeper) walletNames() (walletNames []string, err error) {
+
+	if len(s.Wallets) != 0 {
+		walletNames = s.Wallets
+		return
+	}
+
+	if walletNames, err = s.wm.WalletNames(); err != nil {
+		return
+	}
+
+	return
+}
+
+// wanted returns true if Wallet walletName is to be swept.
+func (s *Sweeper) wanted(walletName string) (isWanted bool) {
+
+	if len(s.Wallets) == 0 {
+		isWanted = true
+		return
+	}
+
+	for _, wn := range s.Wallets {
+		if wn == walletName {
+			isWanted = true
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,104 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSweeper tests sweeping expired WalletItems.
+func TestSweeper(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var s *Sweeper
+	var exists bool
+	var report *SweepReport
+	var mem *MemoryBackend
+	var handles int
+	var reports chan *SweepReport = make(chan *SweepReport, 1)
+	var past time.Time = time.Now().Add(-time.Minute)
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if _, err = NewSweeper(e.wm, 0); !errors.Is(err, ErrExpiryDisabled) {
+		t.Errorf("expected ErrExpiryDisabled, got %v", err)
+	}
+
+	if err = e.wm.EnableExpiry(); err != nil {
+		t.Fatalf("failed to EnableExpiry: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+	for en, expires := range map[string]time.Time{
+		passwordTest.String(): past,
+		mapTest.String():      past,
+		blobTest.String():     time.Now().Add(time.Hour),
+	} {
+		if err = e.f.SetEntryExpiry(en, expires); err != nil {
+			t.Fatalf("failed to SetEntryExpiry for %v: %v", en, err)
+		}
+	}
+
+	if s, err = NewSweeper(e.wm, 0); err != nil {
+		t.Fatalf("failed to NewSweeper: %v", err)
+	}
+
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if report, err = s.Sweep(); err != nil {
+		t.Fatalf("failed to Sweep: %v", err)
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("Sweep leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+	if len(report.Removed) != 2 {
+		t.Errorf("expected 2 WalletItems swept, got %#v", report.Removed)
+	}
+	for en, expected := range map[string]bool{
+		passwordTest.String():    false,
+		mapTest.String():         false,
+		blobTest.String():        true,
+		unknownItemTest.String(): true,
+	} {
+		if exists, err = e.f.HasEntry(en); err != nil || exists != expected {
+			t.Errorf("%v: expected exists %v after Sweep, got %v (err: %v)", en, expected, exists, err)
+		}
+	}
+
+	// Running, triggered by Sweeper.Notify.
+	s.Signals = true
+	if err = s.Start(); !errors.Is(err, ErrNoSignals) {
+		t.Errorf("expected ErrNoSignals from a MemoryBackend, got %v", err)
+	}
+	s.Signals = false
+	s.OnSweep = func(report *SweepReport) {
+		reports <- report
+	}
+	if err = s.Start(); err != nil {
+		t.Fatalf("failed to Start: %v", err)
+	}
+	if err = s.Start(); !errors.Is(err, ErrSweeperRunning) {
+		t.Errorf("expected ErrSweeperRunning, got %v", err)
+	}
+	if err = e.f.SetEntryExpiry(unknownItemTest.String(), past); err != nil {
+		t.Fatalf("failed to SetEntryExpiry: %v", err)
+	}
+	s.Notify(e.w.Name, e.f.Name)
+	select {
+	case report = <-reports:
+		if report.Err != nil || len(report.Removed) != 1 || report.Removed[0].Entry != unknownItemTest.String() {
+			t.Errorf("unexpected SweepReport: %#v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out waiting for a sweep")
+	}
+	s.Stop()
+	s.Stop()
+
+	if exists, err = e.f.HasEntry(unknownItemTest.String()); err != nil || exists {
+		t.Errorf("expired UnknownItem not swept (err: %v)", err)
+	}
+}
@@ -0,0 +1,497 @@
+package gokwallet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+	newTrackingBackend wraps backend in a trackingBackend, or returns a copy of it if it already is one
+	(so its settings can be changed without affecting operations already using it; see WalletManager.swapBackend).
+*/
+func newTrackingBackend(backend Backend) (t *trackingBackend) {
+
+	var ok bool
+	var cur *trackingBackend
+
+	if cur, ok = backend.(*trackingBackend); ok {
+		t = new(trackingBackend)
+		*t = *cur
+		return
+	}
+
+	t = &trackingBackend{
+		Backend: backend,
+	}
+
+	return
+}
+
+// RemoveEntry removes an entry from a folder, along with its bookkeeping.
+func (t *trackingBackend) RemoveEntry(handle int32, folderName, entryName, appID string) (err error) {
+
+	var prior *EntrySnapshot
+
+	if prior, err = t.prior(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if err = t.Backend.RemoveEntry(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if err = t.removed(handle, folderName, entryName, prior, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+/*
+	RemoveFolder removes a folder, along with the bookkeeping of all of its entries.
+	If history is retained for the folder, the history of each entry is replaced by a single version of its last value.
+*/
+func (t *trackingBackend) RemoveFolder(handle int32, folderName, appID string) (err error) {
+
+	var priors []*EntrySnapshot
+
+	if priors, err = t.folderPriors(handle, folderName, appID); err != nil {
+		return
+	}
+
+	if err = t.Backend.RemoveFolder(handle, folderName, appID); err != nil {
+		return
+	}
+
+	if err = t.folderRemoved(handle, folderName, priors, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+// RenameEntry renames an entry in a folder, along with its bookkeeping.
+func (t *trackingBackend) RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	if err = t.Backend.RenameEntry(handle, folderName, entryName, newEntryName, appID); err != nil {
+		return
+	}
+
+	if err = t.renamed(handle, folderName, entryName, newEntryName, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+// WriteEntry writes a raw (serialized) value as an entry of type entryType and updates its bookkeeping.
+func (t *trackingBackend) WriteEntry(
+	handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string,
+) (err error) {
+
+	var prior *EntrySnapshot
+
+	if prior, err = t.prior(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if err = t.Backend.WriteEntry(handle, folderName, entryName, entryType, value, appID); err != nil {
+		return
+	}
+
+	if err = t.written(handle, folderName, entryName, prior, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+// WriteMap writes a Map entry and updates its bookkeeping.
+func (t *trackingBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	var prior *EntrySnapshot
+
+	if prior, err = t.prior(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if err = t.Backend.WriteMap(handle, folderName, entryName, value, appID); err != nil {
+		return
+	}
+
+	if err = t.written(handle, folderName, entryName, prior, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+// WritePassword writes a Password entry and updates its bookkeeping.
+func (t *trackingBackend) WritePassword(handle int32, folderName, entryName, value, appID string) (err error) {
+
+	var prior *EntrySnapshot
+
+	if prior, err = t.prior(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	if err = t.Backend.WritePassword(handle, folderName, entryName, value, appID); err != nil {
+		return
+	}
+
+	if err = t.written(handle, folderName, entryName, prior, appID); err != nil {
+		err = fmt.Errorf("%w: %v", ErrBookkeeping, err)
+		return
+	}
+
+	return
+}
+
+/*
+	folderPriors returns the prior (as returned by trackingBackend.prior) of each entry in folderName
+	before the folder is removed.
+*/
+func (t *trackingBackend) folderPriors(handle int32, folderName, appID string) (priors []*EntrySnapshot, err error) {
+
+	var exists bool
+	var entryNames []string
+	var prior *EntrySnapshot
+
+	if !t.history || isReservedFolder(folderName) {
+		return
+	}
+
+	if exists, err = t.Backend.HasFolder(handle, folderName, appID); err != nil || !exists {
+		return
+	}
+
+	if entryNames, err = t.Backend.EntryList(handle, folderName, appID); err != nil {
+		return
+	}
+
+	priors = make([]*EntrySnapshot, 0, len(entryNames))
+
+	for _, en := range entryNames {
+		if prior, err = t.prior(handle, folderName, en, appID); err != nil {
+			return
+		}
+		if prior != nil {
+			priors = append(priors, prior)
+		}
+	}
+
+	return
+}
+
+/*
+	folderRemoved is called after folderName has been removed. priors are as returned by trackingBackend.folderPriors;
+	they replace any versions retained for the folder so none are left behind for a later folder of the same name.
+*/
+func (t *trackingBackend) folderRemoved(handle int32, folderName string, priors []*EntrySnapshot, appID string) (err error) {
+
+	var keys []string
+	var prefix string = metadataKey(folderName, "")
+
+	if isReservedFolder(folderName) {
+		return
+	}
+
+	if t.metadata {
+		if keys, err = t.Backend.MapList(handle, MetadataFolder, appID); err != nil {
+			return
+		}
+		for _, k := range keys {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			if err = t.Backend.RemoveEntry(handle, MetadataFolder, k, appID); err != nil {
+				return
+			}
+		}
+	}
+
+	if t.expiry {
+		if keys, err = t.Backend.PasswordList(handle, ExpiryFolder, appID); err != nil {
+			return
+		}
+		for _, k := range keys {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			if err = t.Backend.RemoveEntry(handle, ExpiryFolder, k, appID); err != nil {
+				return
+			}
+		}
+	}
+
+	if t.history {
+		if keys, err = t.Backend.EntryList(handle, HistoryFolder, appID); err != nil {
+			return
+		}
+		for _, k := range keys {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			if err = t.Backend.RemoveEntry(handle, HistoryFolder, k, appID); err != nil {
+				return
+			}
+		}
+		for _, prior := range priors {
+			if err = retainVersion(t, handle, folderName, prior.Name, prior, appID); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+/*
+	prior returns the current type and value of entryName in folderName before it is replaced or removed,
+	if it is to be retained as a HistoryVersion. prior is nil otherwise.
+*/
+func (t *trackingBackend) prior(handle int32, folderName, entryName, appID string) (prior *EntrySnapshot, err error) {
+
+	var depth int
+	var exists bool
+	var entryType kwalletdEnumType
+
+	if !t.history || isReservedFolder(folderName) {
+		return
+	}
+
+	// Nothing would be retained, so don't read the old value at all.
+	if depth, err = folderHistoryDepth(t, handle, folderName, appID); err != nil || depth <= 0 {
+		return
+	}
+
+	if exists, err = t.Backend.HasEntry(handle, folderName, entryName, appID); err != nil || !exists {
+		return
+	}
+
+	if entryType, err = t.Backend.EntryType(handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	switch entryType {
+	case KwalletdEnumTypePassword, KwalletdEnumTypeMap, KwalletdEnumTypeStream:
+	default:
+		return
+	}
+
+	prior = &EntrySnapshot{
+		Name: entryName,
+		Type: entryType,
+	}
+
+	if prior.raw, err = t.Backend.ReadEntry(handle, folderName, entryName, appID); err != nil {
+		prior = nil
+		return
+	}
+
+	return
+}
+
+// removed is called after entryName has been removed from folderName. prior is as returned by trackingBackend.prior.
+func (t *trackingBackend) removed(handle int32, folderName, entryName string, prior *EntrySnapshot, appID string) (err error) {
+
+	if isReservedFolder(folderName) {
+		return
+	}
+
+	if t.metadata {
+		if err = removeMetadata(t.Backend, handle, folderName, entryName, appID); err != nil {
+			return
+		}
+	}
+
+	if t.expiry {
+		if err = removeExpiry(t.Backend, handle, folderName, entryName, appID); err != nil {
+			return
+		}
+	}
+
+	if prior != nil {
+		if err = retainVersion(t, handle, folderName, entryName, prior, appID); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// renamed is called after entryName in folderName has been renamed to newEntryName.
+func (t *trackingBackend) renamed(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	var md *EntryMetadata
+	var ids []string
+	var expires time.Time
+
+	if isReservedFolder(folderName) {
+		return
+	}
+
+	if t.expiry {
+		if expires, err = readExpiry(t.Backend, handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		if !expires.IsZero() {
+			if err = writeExpiry(t.Backend, handle, folderName, newEntryName, expires, appID); err != nil {
+				return
+			}
+			if err = removeExpiry(t.Backend, handle, folderName, entryName, appID); err != nil {
+				return
+			}
+		}
+	}
+
+	if t.history {
+		if ids, err = listVersions(t.Backend, handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		for _, id := range ids {
+			if err = t.Backend.RenameEntry(
+				handle, HistoryFolder, historyKey(folderName, entryName, id), historyKey(folderName, newEntryName, id), appID,
+			); err != nil {
+				return
+			}
+		}
+	}
+
+	if !t.metadata {
+		return
+	}
+
+	if md, err = readMetadata(t.Backend, handle, folderName, entryName, appID); err != nil || md == nil {
+		return
+	}
+
+	if err = writeMetadata(t.Backend, handle, folderName, newEntryName, md, appID); err != nil {
+		return
+	}
+
+	if err = removeMetadata(t.Backend, handle, folderName, entryName, appID); err != nil {
+		return
+	}
+
+	return
+}
+
+// unwrap returns the Backend wrapped by a trackingBackend.
+func (t *trackingBackend) unwrap() (backend Backend) {
+
+	backend = t.Backend
+
+	return
+}
+
+// written is called after entryName in folderName has been written by appID. prior is as returned by trackingBackend.prior.
+func (t *trackingBackend) written(handle int32, folderName, entryName string, prior *EntrySnapshot, appID string) (err error) {
+
+	var cur *EntrySnapshot
+
+	if isReservedFolder(folderName) {
+		return
+	}
+
+	if t.metadata {
+		if err = touchMetadata(t.Backend, handle, folderName, entryName, appID); err != nil {
+			return
+		}
+	}
+
+	if prior != nil {
+		// Rewriting the same value doesn't need a new version.
+		cur = &EntrySnapshot{
+			Name: entryName,
+		}
+		if cur.Type, err = t.Backend.EntryType(handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		if cur.raw, err = t.Backend.ReadEntry(handle, folderName, entryName, appID); err != nil {
+			return
+		}
+		if cur.Type == prior.Type && stateEntryEqual(cur, prior) {
+			return
+		}
+		if err = retainVersion(t, handle, folderName, entryName, prior, appID); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// baseBackend returns the innermost Backend of backend (i.e. with any wrappers such as trackingBackend removed).
+func baseBackend(backend Backend) (base Backend) {
+
+	var ok bool
+	var w backendWrapper
+
+	base = backend
+
+	for {
+		if w, ok = base.(backendWrapper); !ok {
+			return
+		}
+		base = w.unwrap()
+	}
+}
+
+/*
+	untrackedBackend returns backend without its trackingBackend (if any), to write without bookkeeping.
+	Unlike baseBackend, any cachingBackend is kept so the write still invalidates the cache.
+*/
+func untrackedBackend(backend Backend) (untracked Backend) {
+
+	var ok bool
+	var t *trackingBackend
+
+	if t, ok = backend.(*trackingBackend); ok {
+		untracked = t.Backend
+		return
+	}
+
+	untracked = backend
+
+	return
+}
+
+/*
+	isTrackedFolder returns true if folderName is the reserved Folder of a bookkeeping feature
+	(EntryMetadata, history, or expiry) that is enabled on backend.
+*/
+func isTrackedFolder(backend Backend, folderName string) (isTracked bool) {
+
+	var ok bool
+	var t *trackingBackend
+
+	if t, ok = backend.(*trackingBackend); !ok {
+		return
+	}
+
+	switch folderName {
+	case MetadataFolder:
+		isTracked = t.metadata
+	case HistoryFolder:
+		isTracked = t.history
+	case ExpiryFolder:
+		isTracked = t.expiry
+	}
+
+	return
+}
+
+// isReservedFolder returns true if folderName is reserved for gokwallet's own use (see ReservedFolderPrefix).
+func isReservedFolder(folderName string) (isReserved bool) {
+
+	isReserved = strings.HasPrefix(folderName, ReservedFolderPrefix)
+
+	return
+}
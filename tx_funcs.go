@@ -0,0 +1,435 @@
+package gokwallet
+
+import (
+	"fmt"
+)
+
+/*
+	Begin starts a new Tx on a Wallet.
+	Nothing is written to the Wallet until Tx.Commit is called.
+*/
+func (w *Wallet) Begin() (tx *Tx, err error) {
+
+	if err = w.walletCheck(); err != nil {
+		return
+	}
+
+	tx = &Tx{
+		wallet:        w,
+		ops:           make([]*TxOp, 0),
+		folders:       make(map[string]*Folder),
+		folderExisted: make(map[string]bool),
+		prior:         make([]*txPrior, 0),
+		priorIdx:      make(map[string]*txPrior),
+	}
+
+	return
+}
+
+/*
+	WriteEntry queues adding/replacing a WalletItem of type entryType with a raw (serialized) value.
+	As with Folder.WriteEntry, entryType must not be KwalletdEnumTypeUnused.
+*/
+func (t *Tx) WriteEntry(folderName, entryName string, entryType kwalletdEnumType, entryValue []byte) (err error) {
+
+	var b []byte
+
+	if entryType == KwalletdEnumTypeUnused {
+		err = ErrNoCreate
+		return
+	}
+
+	if entryValue != nil {
+		b = make([]byte, len(entryValue))
+		copy(b, entryValue)
+	}
+
+	err = t.queue(&TxOp{
+		Action: TxWriteEntry,
+		Folder: folderName,
+		Entry:  entryName,
+		Type:   entryTypePtr(entryType),
+		value:  b,
+	})
+
+	return
+}
+
+// WriteBlob queues adding/replacing a Blob.
+func (t *Tx) WriteBlob(folderName, entryName string, entryValue []byte) (err error) {
+
+	err = t.WriteEntry(folderName, entryName, KwalletdEnumTypeStream, entryValue)
+
+	return
+}
+
+// WriteMap queues adding/replacing a Map.
+func (t *Tx) WriteMap(folderName, entryName string, entryValue map[string]string) (err error) {
+
+	var b []byte
+
+	if entryValue == nil {
+		err = ErrInvalidMap
+		return
+	}
+
+	if b, err = mapToBytes(entryValue); err != nil {
+		return
+	}
+
+	err = t.WriteEntry(folderName, entryName, KwalletdEnumTypeMap, b)
+
+	return
+}
+
+// WritePassword queues adding/replacing a Password.
+func (t *Tx) WritePassword(folderName, entryName, entryValue string) (err error) {
+
+	err = t.WriteEntry(folderName, entryName, KwalletdEnumTypePassword, stringToQString(entryValue))
+
+	return
+}
+
+// WriteUnknown queues adding/replacing an UnknownItem.
+func (t *Tx) WriteUnknown(folderName, entryName string, entryValue []byte) (err error) {
+
+	err = t.WriteEntry(folderName, entryName, KwalletdEnumTypeUnknown, entryValue)
+
+	return
+}
+
+// RemoveEntry queues removing a WalletItem.
+func (t *Tx) RemoveEntry(folderName, entryName string) (err error) {
+
+	err = t.queue(&TxOp{
+		Action: TxRemoveEntry,
+		Folder: folderName,
+		Entry:  entryName,
+	})
+
+	return
+}
+
+// RenameEntry queues renaming a WalletItem from entryName to newEntryName.
+func (t *Tx) RenameEntry(folderName, entryName, newEntryName string) (err error) {
+
+	err = t.queue(&TxOp{
+		Action:   TxRenameEntry,
+		Folder:   folderName,
+		Entry:    entryName,
+		NewEntry: newEntryName,
+	})
+
+	return
+}
+
+// Ops returns the queued operations of a Tx.
+func (t *Tx) Ops() (ops []*TxOp) {
+
+	ops = make([]*TxOp, len(t.ops))
+	copy(ops, t.ops)
+
+	return
+}
+
+/*
+	Commit applies the queued operations of a Tx, in order.
+	If an operation fails, the remaining operations are not attempted and every WalletItem the Tx touched
+	is restored to its prior state (see Tx); err then contains both the failure and any errors from the rollback.
+	The TxReport details what was applied and restored either way.
+
+	A successfully committed Tx may still be undone with Tx.Rollback.
+*/
+func (t *Tx) Commit() (report *TxReport, err error) {
+
+	var res *TxOpResult
+	var rbErr error
+
+	if t.committed || t.done {
+		err = ErrTxDone
+		return
+	}
+
+	report = &TxReport{
+		Ops: make([]*TxOpResult, 0, len(t.ops)),
+	}
+
+	for _, op := range t.ops {
+		res = &TxOpResult{
+			Op: op,
+		}
+		report.Ops = append(report.Ops, res)
+		if err != nil {
+			continue
+		}
+		if res.Err = t.apply(op); res.Err != nil {
+			res.Error = res.Err.Error()
+			err = fmt.Errorf("%v %#v/%#v: %w", op.Action, op.Folder, op.Entry, res.Err)
+			continue
+		}
+		res.Applied = true
+	}
+
+	if err != nil {
+		t.done = true
+		if rbErr = t.restore(report); rbErr != nil {
+			err = NewErrors(err, rbErr)
+		}
+		return
+	}
+
+	t.committed = true
+	report.Committed = true
+
+	return
+}
+
+/*
+	Rollback ends a Tx.
+	If the Tx has not been committed, its queued operations are simply discarded.
+	If it has been (successfully) committed, every WalletItem it touched is restored to its prior state.
+*/
+func (t *Tx) Rollback() (report *TxReport, err error) {
+
+	if t.done {
+		err = ErrTxDone
+		return
+	}
+
+	t.done = true
+
+	report = &TxReport{
+		Ops: make([]*TxOpResult, 0, len(t.ops)),
+	}
+
+	for _, op := range t.ops {
+		report.Ops = append(report.Ops, &TxOpResult{
+			Op:      op,
+			Applied: t.committed,
+		})
+	}
+
+	if !t.committed {
+		return
+	}
+
+	if err = t.restore(report); err != nil {
+		return
+	}
+
+	return
+}
+
+// apply records the prior state of the WalletItems touched by op and then performs it.
+func (t *Tx) apply(op *TxOp) (err error) {
+
+	var f *Folder
+
+	if f, err = t.folder(op.Folder); err != nil {
+		return
+	}
+
+	if err = t.record(f, op.Entry); err != nil {
+		return
+	}
+	if op.Action == TxRenameEntry {
+		if err = t.record(f, op.NewEntry); err != nil {
+			return
+		}
+	}
+
+	switch op.Action {
+	case TxWriteEntry:
+		err = f.WriteEntry(op.Entry, *op.Type, op.value)
+	case TxRemoveEntry:
+		err = f.RemoveEntry(op.Entry)
+	case TxRenameEntry:
+		err = f.RenameEntry(op.Entry, op.NewEntry)
+	}
+
+	return
+}
+
+// folder returns the (cached) Folder folderName, recording whether it existed before the Tx touched it.
+func (t *Tx) folder(folderName string) (f *Folder, err error) {
+
+	var ok bool
+	var exists bool
+
+	if f, ok = t.folders[folderName]; ok {
+		return
+	}
+
+	if exists, err = t.wallet.HasFolder(folderName); err != nil {
+		return
+	}
+
+	if f, err = NewFolder(t.wallet, folderName, &RecurseOpts{}); err != nil {
+		return
+	}
+
+	t.folders[folderName] = f
+	t.folderExisted[folderName] = exists
+
+	return
+}
+
+// queue adds op to a Tx.
+func (t *Tx) queue(op *TxOp) (err error) {
+
+	if t.committed || t.done {
+		err = ErrTxDone
+		return
+	}
+
+	t.ops = append(t.ops, op)
+
+	return
+}
+
+// record saves the state of WalletItem entryName in Folder f, if it hasn't been recorded already.
+func (t *Tx) record(f *Folder, entryName string) (err error) {
+
+	var ok bool
+	var p *txPrior
+	var key string = f.Name + "\x00" + entryName
+	var backend Backend = f.wallet.wm.Backend()
+
+	if _, ok = t.priorIdx[key]; ok {
+		return
+	}
+
+	p = &txPrior{
+		folder: f.Name,
+		entry:  entryName,
+	}
+
+	if t.folderExisted[f.Name] {
+		if p.existed, err = f.HasEntry(entryName); err != nil {
+			return
+		}
+	}
+	if p.existed {
+		if p.entryType, p.raw, err = f.readStored(entryName); err != nil {
+			return
+		}
+		if isTrackedFolder(backend, MetadataFolder) {
+			if p.metadata, err = readMetadata(backend, f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+				return
+			}
+		}
+		if isTrackedFolder(backend, ExpiryFolder) {
+			if p.expires, err = readExpiry(backend, f.wallet.handle, f.Name, entryName, f.wallet.wm.AppID); err != nil {
+				return
+			}
+		}
+	}
+
+	t.prior = append(t.prior, p)
+	t.priorIdx[key] = p
+
+	return
+}
+
+// restore returns every WalletItem (and Folder) touched by a Tx to its recorded prior state, adding the details to report.
+func (t *Tx) restore(report *TxReport) (err error) {
+
+	var p *txPrior
+	var f *Folder
+	var exists bool
+	var entries []string
+	var rs *TxRestore
+	var errs []error = make([]error, 0)
+
+	report.RolledBack = true
+	report.Restores = make([]*TxRestore, 0, len(t.prior))
+
+	for idx := len(t.prior) - 1; idx >= 0; idx-- {
+		p = t.prior[idx]
+		f = t.folders[p.folder]
+		rs = &TxRestore{
+			Folder:  p.folder,
+			Entry:   p.entry,
+			Existed: p.existed,
+		}
+		if p.existed {
+			rs.Type = entryTypePtr(p.entryType)
+			if rs.Err = f.WriteEntry(p.entry, p.entryType, p.raw); rs.Err == nil {
+				if rs.Err = t.restoreBookkeeping(f, p); rs.Err != nil {
+					rs.Err = fmt.Errorf("%w: %v", ErrBookkeeping, rs.Err)
+				}
+			}
+		} else if exists, rs.Err = f.HasEntry(p.entry); rs.Err == nil && exists {
+			rs.Err = f.RemoveEntry(p.entry)
+		}
+		if rs.Err != nil {
+			rs.Error = rs.Err.Error()
+			errs = append(errs, fmt.Errorf("restore %#v/%#v: %w", p.folder, p.entry, rs.Err))
+		}
+		report.Restores = append(report.Restores, rs)
+	}
+
+	// Folders created by the Tx are removed again, but only if they're empty (i.e. nothing else was put in them).
+	for _, fn := range sortedKeys(t.folderExisted) {
+		if t.folderExisted[fn] {
+			continue
+		}
+		if exists, err = t.wallet.HasFolder(fn); err != nil || !exists {
+			err = nil
+			continue
+		}
+		if entries, err = t.folders[fn].ListEntries(); err != nil || len(entries) != 0 {
+			err = nil
+			continue
+		}
+		rs = &TxRestore{
+			Folder: fn,
+		}
+		if rs.Err = t.wallet.RemoveFolder(fn); rs.Err != nil {
+			rs.Error = rs.Err.Error()
+			errs = append(errs, fmt.Errorf("remove folder %#v: %w", fn, rs.Err))
+		}
+		report.Restores = append(report.Restores, rs)
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+		return
+	}
+
+	return
+}
+
+/*
+	restoreBookkeeping writes back the EntryMetadata and expiry recorded in p for a restored WalletItem,
+	replacing what the restoring write itself recorded.
+*/
+func (t *Tx) restoreBookkeeping(f *Folder, p *txPrior) (err error) {
+
+	var backend Backend = f.wallet.wm.Backend()
+	var untracked Backend = untrackedBackend(backend)
+
+	if isTrackedFolder(backend, MetadataFolder) {
+		if p.metadata != nil {
+			err = writeMetadata(untracked, f.wallet.handle, f.Name, p.entry, p.metadata, f.wallet.wm.AppID)
+		} else {
+			err = removeMetadata(untracked, f.wallet.handle, f.Name, p.entry, f.wallet.wm.AppID)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if isTrackedFolder(backend, ExpiryFolder) {
+		if !p.expires.IsZero() {
+			err = writeExpiry(untracked, f.wallet.handle, f.Name, p.entry, p.expires, f.wallet.wm.AppID)
+		} else {
+			err = removeExpiry(untracked, f.wallet.handle, f.Name, p.entry, f.wallet.wm.AppID)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
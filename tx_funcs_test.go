@@ -0,0 +1,165 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestTx tests committing and rolling back a Tx.
+func TestTx(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var fb *onceFailBackend
+	var wm *WalletManager
+	var w *Wallet
+	var tx *Tx
+	var report *TxReport
+	var before *WalletSnapshot
+	var after *WalletSnapshot
+	var d *Diff
+	var p *Password
+	var md *EntryMetadata
+	var mdBefore *EntryMetadata
+	var exp time.Time
+	var expires time.Time = time.Now().Add(time.Hour).UTC()
+	var newFolder string = folderTest.String() + "_tx"
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failure populating test env: %v", err)
+	}
+	if before, err = e.w.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Wallet: %v", err)
+	}
+
+	// A failing Tx restores everything it touched.
+	fb = &onceFailBackend{Backend: e.wm.Backend(), failAfter: 2}
+	if wm, err = NewWalletManagerBackend(fb, &RecurseOpts{}, appIdTest); err != nil {
+		t.Fatalf("failure getting WalletManager: %v", err)
+	}
+	if w, err = NewWallet(wm, walletTest.String(), &RecurseOpts{}); err != nil {
+		t.Fatalf("failure getting Wallet: %v", err)
+	}
+	if tx, err = w.Begin(); err != nil {
+		t.Fatalf("failed to Begin: %v", err)
+	}
+	txQueueTest(t, tx, newFolder)
+	if report, err = tx.Commit(); !errors.Is(err, ErrOperationFailed) {
+		t.Fatalf("expected ErrOperationFailed from Commit, got %v", err)
+	}
+	if report.Committed || !report.RolledBack {
+		t.Errorf("unexpected TxReport state: committed %v, rolled back %v", report.Committed, report.RolledBack)
+	}
+	if len(report.Ops) != 5 || !report.Ops[3].Applied || report.Ops[4].Applied || report.Ops[4].Err == nil {
+		t.Errorf("unexpected TxReport.Ops")
+	}
+	if after, err = e.w.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Wallet: %v", err)
+	}
+	if d, err = DiffWalletSnapshots(before, after, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("Wallet not restored after a failed Tx:\n%v", d.Text())
+	}
+	if _, err = tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+
+	// A successful Tx can be committed and then rolled back.
+	if tx, err = e.w.Begin(); err != nil {
+		t.Fatalf("failed to Begin: %v", err)
+	}
+	txQueueTest(t, tx, newFolder)
+	if report, err = tx.Commit(); err != nil {
+		t.Fatalf("failed to Commit: %v", err)
+	}
+	if !report.Committed || report.RolledBack {
+		t.Errorf("unexpected TxReport state: committed %v, rolled back %v", report.Committed, report.RolledBack)
+	}
+	if p, err = NewPassword(e.f, passwordTest.String(), e.r); err != nil {
+		t.Fatalf("failed to get Password: %v", err)
+	} else if p.Value != testPasswordReplace {
+		t.Errorf("Password not written by Tx")
+	}
+	if report, err = tx.Rollback(); err != nil {
+		t.Fatalf("failed to Rollback: %v", err)
+	}
+	if !report.RolledBack || len(report.Restores) == 0 {
+		t.Errorf("Rollback did not restore anything")
+	}
+	if after, err = e.w.Snapshot(); err != nil {
+		t.Fatalf("failed to Snapshot Wallet: %v", err)
+	}
+	if d, err = DiffWalletSnapshots(before, after, nil); err != nil || !d.IsEmpty() {
+		t.Errorf("Wallet not restored after Rollback:\n%v", d.Text())
+	}
+	if _, err = tx.Rollback(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+	if err = tx.RemoveEntry(folderTest.String(), passwordTest.String()); !errors.Is(err, ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+
+	// Rollback restores the EntryMetadata and expiry of replaced and removed WalletItems as well.
+	if err = e.wm.EnableMetadata(); err != nil {
+		t.Fatalf("failed to EnableMetadata: %v", err)
+	}
+	if err = e.wm.EnableExpiry(); err != nil {
+		t.Fatalf("failed to EnableExpiry: %v", err)
+	}
+	for _, en := range []string{passwordTest.String(), mapTest.String()} {
+		if err = e.f.SetEntryNote(en, "note"); err != nil {
+			t.Fatalf("failed to SetEntryNote: %v", err)
+		}
+		if err = e.f.SetEntryExpiry(en, expires); err != nil {
+			t.Fatalf("failed to SetEntryExpiry: %v", err)
+		}
+	}
+	if mdBefore, err = e.f.EntryMetadata(passwordTest.String()); err != nil {
+		t.Fatalf("failed to get EntryMetadata: %v", err)
+	}
+	if tx, err = e.w.Begin(); err != nil {
+		t.Fatalf("failed to Begin: %v", err)
+	}
+	if err = tx.WritePassword(folderTest.String(), passwordTest.String(), testPasswordReplace); err != nil {
+		t.Fatalf("failed to queue Tx operation: %v", err)
+	}
+	if err = tx.RemoveEntry(folderTest.String(), mapTest.String()); err != nil {
+		t.Fatalf("failed to queue Tx operation: %v", err)
+	}
+	if _, err = tx.Commit(); err != nil {
+		t.Fatalf("failed to Commit: %v", err)
+	}
+	if _, err = tx.Rollback(); err != nil {
+		t.Fatalf("failed to Rollback: %v", err)
+	}
+	for _, en := range []string{passwordTest.String(), mapTest.String()} {
+		if md, err = e.f.EntryMetadata(en); err != nil || md == nil || md.Note != "note" {
+			t.Errorf("EntryMetadata of %#v not restored by Rollback: %#v (err: %v)", en, md, err)
+		}
+		if exp, err = e.f.EntryExpiry(en); err != nil || !exp.Equal(expires) {
+			t.Errorf("expiry of %#v not restored by Rollback: %v (err: %v)", en, exp, err)
+		}
+	}
+	if md, err = e.f.EntryMetadata(passwordTest.String()); err == nil && md != nil && !md.Modified.Equal(mdBefore.Modified) {
+		t.Errorf("EntryMetadata.Modified not restored by Rollback: %v, expected %v", md.Modified, mdBefore.Modified)
+	}
+}
+
+// txQueueTest queues the same set of test operations on a Tx.
+func txQueueTest(t *testing.T, tx *Tx, newFolder string) {
+
+	for _, err := range []error{
+		tx.WritePassword(folderTest.String(), passwordTest.String(), testPasswordReplace),
+		tx.WriteMap(folderTest.String(), mapTest.String()+"_tx", testMap),
+		tx.RenameEntry(folderTest.String(), blobTest.String(), blobTest.String()+"_tx"),
+		tx.RemoveEntry(folderTest.String(), unknownItemTest.String()),
+		tx.WriteBlob(newFolder, blobTest.String(), testBytesReplace),
+	} {
+		if err != nil {
+			t.Fatalf("failed to queue Tx operation: %v", err)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package gokwallet
 
 import (
+	"encoding/xml"
+	"hash"
+	"regexp"
+	"sync"
+	"time"
+
 	"github.com/godbus/dbus/v5"
 )
 
@@ -31,7 +37,189 @@ type DbusObject struct {
 }
 
 /*
-	WalletManager is a general KWallet interface, sort of a handler for Dbus.
+	Backend is the storage layer behind a WalletManager (and thus its Wallet, Folder, and WalletItem objects).
+	Its methods mirror the kwalletd Dbus API (see consts.go) so that the same Folder.WritePassword, Map.Update,
+	Wallet.ListFolders, etc. work the same regardless of where the wallet actually lives.
+
+	Wallet operations are performed on a handle as returned by Backend.Open, exactly like kwalletd.
+	appID is passed through to every call that kwalletd would take it on.
+
+	Provided implementations are DbusBackend (the default; kwalletd via Dbus), FileBackend (a directory of
+	kwalletmanager-compatible XML wallet files), and MemoryBackend (non-persistent; useful for testing).
+*/
+type Backend interface {
+	// IsEnabled returns whether the Backend is enabled/usable.
+	IsEnabled() (enabled bool, err error)
+	// Wallets returns the names of all wallets in the Backend.
+	Wallets() (walletNames []string, err error)
+	// LocalWallet returns the name of the "local" wallet.
+	LocalWallet() (walletName string, err error)
+	// NetworkWallet returns the name of the "network" wallet.
+	NetworkWallet() (walletName string, err error)
+	// Open opens (unlocks) a wallet, creating it if it does not exist, and returns a handle for it.
+	Open(walletName, appID string) (handle int32, err error)
+	// IsOpen returns whether a wallet is open (unlocked) or not.
+	IsOpen(walletName string) (isOpen bool, err error)
+	// Close closes the wallet for a handle.
+	Close(handle int32, force bool, appID string) (err error)
+	// CloseWallet closes a wallet by name for all applications.
+	CloseWallet(walletName string, force bool) (err error)
+	// CloseAllWallets closes all wallets.
+	CloseAllWallets() (err error)
+	// DeleteWallet deletes a wallet.
+	DeleteWallet(walletName string) (err error)
+	// DisconnectApplication disconnects application appID from a wallet.
+	DisconnectApplication(walletName, appID string) (err error)
+	// Users returns the application IDs that have a wallet open.
+	Users(walletName string) (appIDs []string, err error)
+	// ChangePassword changes (or sets) the password for a wallet.
+	ChangePassword(walletName, appID string) (err error)
+	// FolderList returns the names of all folders in a wallet.
+	FolderList(handle int32, appID string) (folderNames []string, err error)
+	// HasFolder returns whether a wallet contains a folder.
+	HasFolder(handle int32, folderName, appID string) (hasFolder bool, err error)
+	// FolderDoesNotExist returns whether a folder does *not* exist. It does not require an open wallet.
+	FolderDoesNotExist(walletName, folderName string) (notExist bool, err error)
+	// CreateFolder creates a folder in a wallet.
+	CreateFolder(handle int32, folderName, appID string) (err error)
+	// RemoveFolder removes a folder, and all of its entries, from a wallet.
+	RemoveFolder(handle int32, folderName, appID string) (err error)
+	// EntryList returns the names of all entries in a folder.
+	EntryList(handle int32, folderName, appID string) (entryNames []string, err error)
+	// PasswordList returns the names of all Password entries in a folder.
+	PasswordList(handle int32, folderName, appID string) (entryNames []string, err error)
+	// MapList returns the names of all Map entries in a folder.
+	MapList(handle int32, folderName, appID string) (entryNames []string, err error)
+	// EntryType returns the type of an entry.
+	EntryType(handle int32, folderName, entryName, appID string) (entryType kwalletdEnumType, err error)
+	// HasEntry returns whether a folder contains an entry.
+	HasEntry(handle int32, folderName, entryName, appID string) (hasEntry bool, err error)
+	// KeyDoesNotExist returns whether an entry does *not* exist. It does not require an open wallet.
+	KeyDoesNotExist(walletName, folderName, entryName string) (notExist bool, err error)
+	// ReadEntry returns the raw (serialized) value of an entry, regardless of type.
+	ReadEntry(handle int32, folderName, entryName, appID string) (value []byte, err error)
+	// ReadPassword returns the value of a Password entry.
+	ReadPassword(handle int32, folderName, entryName, appID string) (value string, err error)
+	// ReadMap returns the value of a Map entry.
+	ReadMap(handle int32, folderName, entryName, appID string) (value map[string]string, err error)
+	// WriteEntry writes a raw (serialized) value as an entry of type entryType.
+	WriteEntry(handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string) (err error)
+	// WritePassword writes a Password entry.
+	WritePassword(handle int32, folderName, entryName, value, appID string) (err error)
+	// WriteMap writes a Map entry.
+	WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error)
+	// RemoveEntry removes an entry from a folder.
+	RemoveEntry(handle int32, folderName, entryName, appID string) (err error)
+	// RenameEntry renames an entry in a folder.
+	RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error)
+	// Release releases any resources (connections, etc.) held by the Backend.
+	Release() (err error)
+}
+
+// DbusBackend is a Backend that talks to kwalletd via Dbus.
+type DbusBackend struct {
+	*DbusObject
+}
+
+/*
+	MemoryBackend is a Backend that keeps all wallets in memory.
+	Nothing is persisted; it is mostly useful for testing and offline processing.
+	Values are stored serialized the same way kwalletd stores them, so raw values are interchangeable with DbusBackend.
+*/
+type MemoryBackend struct {
+	// wallets are the wallets in this MemoryBackend, keyed by name.
+	wallets map[string]*memWallet
+	// handles maps open handles to a wallet name.
+	handles map[int32]string
+	// nextHandle is the next handle to be issued.
+	nextHandle int32
+	// onChange, if not nil, is called with the wallet name after every successful modification.
+	onChange func(walletName string) (err error)
+	// onDelete, if not nil, is called with the wallet name after a wallet is deleted.
+	onDelete func(walletName string) (err error)
+	lock     sync.RWMutex
+}
+
+/*
+	FileBackend is a Backend that keeps each wallet as an (unencrypted) kwalletmanager-compatible XML file
+	in a directory. The wallet name is the filename without the extension (see WalletFileExt).
+
+	Note that these files are *not* encrypted; protect them accordingly.
+*/
+type FileBackend struct {
+	*MemoryBackend
+	// Dir is the directory the wallet files live in.
+	Dir string
+}
+
+// memWallet is a wallet in a MemoryBackend.
+type memWallet struct {
+	// folders are the folders in this wallet, keyed by name.
+	folders map[string]*memFolder
+	// users are the application IDs that have this wallet open, with the number of handles each has open.
+	users map[string]int
+	// isOpen is true if the wallet is open.
+	isOpen bool
+}
+
+// memFolder is a folder in a memWallet.
+type memFolder struct {
+	// entries are the entries in this folder, keyed by name.
+	entries map[string]*memEntry
+}
+
+// memEntry is an entry in a memFolder.
+type memEntry struct {
+	// entryType is the type of this entry.
+	entryType kwalletdEnumType
+	// value is the serialized value of this entry.
+	value []byte
+}
+
+/*
+	xmlWallet is a wallet as exported by kwalletmanager ("Export as XML...").
+	It is the on-disk format used by FileBackend.
+*/
+type xmlWallet struct {
+	XMLName xml.Name `xml:"wallet"`
+	// Name is the name of the wallet.
+	Name string `xml:"name,attr"`
+	// Folders are the folders in the wallet.
+	Folders []xmlFolder `xml:"folder"`
+}
+
+// xmlFolder is a folder in an xmlWallet.
+type xmlFolder struct {
+	// Name is the name of the folder.
+	Name string `xml:"name,attr"`
+	// Passwords are the Password entries in the folder.
+	Passwords []xmlEntry `xml:"password"`
+	// Maps are the Map entries in the folder.
+	Maps []xmlMap `xml:"map"`
+	// Streams are the Blob entries in the folder (base64-encoded).
+	Streams []xmlEntry `xml:"stream"`
+	// Unknown are the UnknownItem entries in the folder (base64-encoded).
+	Unknown []xmlEntry `xml:"unknown"`
+}
+
+// xmlEntry is a single-value entry in an xmlFolder (or a key/value pair in an xmlMap).
+type xmlEntry struct {
+	// Name is the name of the entry (or the key of the map entry).
+	Name string `xml:"name,attr"`
+	// Value is the value of the entry.
+	Value string `xml:",chardata"`
+}
+
+// xmlMap is a Map entry in an xmlFolder.
+type xmlMap struct {
+	// Name is the name of the entry.
+	Name string `xml:"name,attr"`
+	// Entries are the key/value pairs of the Map.
+	Entries []xmlEntry `xml:"mapentry"`
+}
+
+/*
+	WalletManager is a general KWallet interface, sort of a handler for Dbus (or another Backend).
 	It's used for fetching Wallet objects.
 */
 type WalletManager struct {
@@ -44,8 +232,8 @@ type WalletManager struct {
 	/*
 		Wallets is the collection of Wallets accessible in/to this WalletManager.
 		Wallet.Name is the map key.
-		(TODO: When wallet file support is added, the *filename* will be the map key.
-			   This is to mitigate namespace conflicts between Dbus and file wallets.)
+		(For a FileBackend, Wallet.Name is the wallet filename without its extension.
+		Dbus and file wallets never share a WalletManager, so their names cannot conflict.)
 	*/
 	Wallets map[string]*Wallet `json:"wallets"`
 	// Recurse contains the relevant RecurseOpts.
@@ -56,10 +244,22 @@ type WalletManager struct {
 	Local *Wallet `json:"local_wallet"`
 	// Network is the "network" wallet.
 	Network *Wallet `json:"network_wallet"`
+	// backend is the Backend all operations are performed through (see WalletManager.Backend).
+	backend Backend
+	// backendLock guards backend, which is swapped when e.g. the read cache is enabled (see WalletManager.swapBackend).
+	backendLock sync.RWMutex
+	// secure, if true, holds WalletItem values in SecretBytes (see WalletManager.EnableSecureMode).
+	secure bool
+	// mlock, if true, locks the memory of SecretBytes in secure mode.
+	mlock bool
+	// secrets are the SecretBytes created in secure mode, so WalletManager.Wipe can wipe them all.
+	secrets []*SecretBytes
+	// secretsLock protects secrets.
+	secretsLock sync.Mutex
+	// showValues, if true, includes WalletItem values in fmt and JSON output (see WalletManager.SetShowValues).
+	showValues bool
 	// isInit flags whether this is "properly" set up (i.e. was initialized via NewWalletManager).
 	isInit bool
-	// walletFiles are (resolved and vetted) wallet files (kwl, xml).
-	walletFiles []string
 }
 
 // Wallet contains one or more (or none) Folder objects.
@@ -119,6 +319,14 @@ type Folder struct {
 	Unknown map[string]*UnknownItem `json:"unknown"`
 	// Recurse contains the relevant RecurseOpts.
 	Recurse *RecurseOpts `json:"recurse_opts"`
+	/*
+		TTL, if non-zero, gives every WalletItem written via this Folder's Write* methods an expiry of TTL from the time it is written
+		(see Folder.SetEntryExpiry). It requires WalletManager.EnableExpiry; otherwise the Write* methods fail with ErrExpiryDisabled
+		without writing anything.
+	*/
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Truncated is true if the last Folder.Update skipped WalletItems because of RecurseOpts.MaxItems.
+	Truncated bool `json:"truncated,omitempty"`
 	// wm is the parent WalletManager that Folder.wallet was fetched from.
 	wm *WalletManager
 	// wallet is the parent Wallet this Folder was fetched from.
@@ -132,8 +340,10 @@ type Password struct {
 	*DbusObject
 	// Name is the name of this Password.
 	Name string `json:"name"`
-	// Value is this Password's value.
+	// Value is this Password's value. It is left empty in secure mode (see Password.Secret).
 	Value string `json:"value"`
+	// Secret is this Password's value (as UTF-8) in secure mode, instead of Password.Value (see WalletManager.EnableSecureMode).
+	Secret *SecretBytes `json:"-"`
 	// Recurse contains the relevant RecurseOpts.
 	Recurse *RecurseOpts `json:"recurse_opts"`
 	// wm is the parent WalletManager that Password.folder.wallet was fetched from.
@@ -142,6 +352,8 @@ type Password struct {
 	wallet *Wallet
 	// folder is the parent Folder this Password was fetched from.
 	folder *Folder
+	// loaded flags whether Password.Value has been fetched (or set).
+	loaded bool
 	// isInit flags whether this is "properly" set up (i.e. has a handle).
 	isInit bool
 }
@@ -151,8 +363,10 @@ type Map struct {
 	*DbusObject
 	// Name is the name of this Map.
 	Name string `json:"name"`
-	// Value is this Map's value.
+	// Value is this Map's value. It is left empty in secure mode (see Map.Secrets).
 	Value map[string]string `json:"value"`
+	// Secrets are this Map's values in secure mode, instead of Map.Value (see WalletManager.EnableSecureMode).
+	Secrets map[string]*SecretBytes `json:"-"`
 	// Recurse contains the relevant RecurseOpts.
 	Recurse *RecurseOpts `json:"recurse_opts"`
 	// wm is the parent WalletManager that Map.folder.wallet was fetched from.
@@ -161,6 +375,8 @@ type Map struct {
 	wallet *Wallet
 	// folder is the parent Folder this Map was fetched from.
 	folder *Folder
+	// loaded flags whether Map.Value has been fetched (or set).
+	loaded bool
 	// isInit flags whether this is "properly" set up (i.e. has a handle).
 	isInit bool
 }
@@ -170,8 +386,10 @@ type Blob struct {
 	*DbusObject
 	// Name is the name of this Blob.
 	Name string `json:"name"`
-	// Value is this Blob's value.
+	// Value is this Blob's value. It is left empty in secure mode (see Blob.Secret).
 	Value []byte `json:"value"`
+	// Secret is this Blob's value in secure mode, instead of Blob.Value (see WalletManager.EnableSecureMode).
+	Secret *SecretBytes `json:"-"`
 	// Recurse contains the relevant RecurseOpts.
 	Recurse *RecurseOpts `json:"recurse_opts"`
 	// wm is the parent WalletManager that Blob.folder.wallet was fetched from.
@@ -180,6 +398,8 @@ type Blob struct {
 	wallet *Wallet
 	// folder is the parent Folder this Blob was fetched from.
 	folder *Folder
+	// loaded flags whether Blob.Value has been fetched (or set).
+	loaded bool
 	// isInit flags whether this is "properly" set up (i.e. has a handle).
 	isInit bool
 }
@@ -192,8 +412,10 @@ type UnknownItem struct {
 	*DbusObject
 	// Name is the name of this UnknownItem.
 	Name string `json:"name"`
-	// Value is the Dbus path of this UnknownItem.
+	// Value is the Dbus path of this UnknownItem. It is left empty in secure mode (see UnknownItem.Secret).
 	Value []byte `json:"value"`
+	// Secret is this UnknownItem's value in secure mode, instead of UnknownItem.Value (see WalletManager.EnableSecureMode).
+	Secret *SecretBytes `json:"-"`
 	// Recurse contains the relevant RecurseOpts.
 	Recurse *RecurseOpts `json:"recurse_opts"`
 	// wm is the parent WalletManager that UnknownItem.folder.wallet was fetched from.
@@ -202,12 +424,27 @@ type UnknownItem struct {
 	wallet *Wallet
 	// folder is the parent Folder this UnknownItem was fetched from.
 	folder *Folder
+	// loaded flags whether UnknownItem.Value has been fetched (or set).
+	loaded bool
 	// isInit flags whether this is "properly" set up (i.e. has a handle).
 	isInit bool
 }
 
+/*
+	jsonPassword, jsonMap, jsonBlob, and jsonUnknownItem have the fields of Password, Map, Blob, and UnknownItem respectively,
+	but none of their methods, so their MarshalJSON methods can encode them without recursing.
+*/
+type (
+	jsonPassword    Password
+	jsonMap         Map
+	jsonBlob        Blob
+	jsonUnknownItem UnknownItem
+)
+
 // WalletItem is an interface to manage wallet objects: Password, Map, Blob, or UnknownItem.
 type WalletItem interface {
+	// Metadata returns the EntryMetadata for the WalletItem (see WalletManager.EnableMetadata).
+	Metadata() (md *EntryMetadata, err error)
 	isWalletItem() (isWalletItem bool)
 }
 
@@ -301,4 +538,1258 @@ type RecurseOpts struct {
 		Wallet
 	*/
 	UnknownItems bool `json:"unknown_item"`
+	/*
+		Lazy, if true, indicates that WalletItems are created from the Folder's name listings only;
+		their values are not fetched when created (RecurseOpts.AllWalletItems, RecurseOpts.Passwords, etc.
+		then only control which WalletItems are created) but on first access via their GetValue method
+		(e.g. Password.GetValue), so only the secrets actually used are read into memory.
+
+		Performed in/from:
+		(WalletItem)
+	*/
+	Lazy bool `json:"lazy"`
+	/*
+		CacheValues, if true, indicates that a value fetched by a WalletItem's GetValue method is kept in its Value field
+		and returned by later calls without fetching it again. Otherwise it is fetched on every call and not kept.
+
+		Performed in/from:
+		(WalletItem)
+	*/
+	CacheValues bool `json:"cache_values"`
+	/*
+		IncludeWallets, if not empty, restricts the Wallets created to those whose name matches at least one of these globs
+		(*, ?, and [...] or [!...] character classes; see SearchQuery). The globs are compiled once, when first used;
+		an invalid glob in any of the name filters makes creating a WalletManager, Wallet, or Folder fail with ErrRecurseFilter.
+
+		Performed in/from:
+		WalletManager
+	*/
+	IncludeWallets []string `json:"include_wallets,omitempty"`
+	// ExcludeWallets skips the Wallets whose name matches any of its globs (see RecurseOpts.IncludeWallets).
+	ExcludeWallets []string `json:"exclude_wallets,omitempty"`
+	/*
+		IncludeFolders is like RecurseOpts.IncludeWallets, but for Folder names.
+		E.g. to skip the browsers' Folders, use RecurseOpts.ExcludeFolders: []string{"Form Data", "Chromium Keys"}.
+
+		Performed in/from:
+		Wallet
+	*/
+	IncludeFolders []string `json:"include_folders,omitempty"`
+	// ExcludeFolders skips the Folders whose name matches any of its globs (see RecurseOpts.IncludeFolders).
+	ExcludeFolders []string `json:"exclude_folders,omitempty"`
+	/*
+		IncludeEntries is like RecurseOpts.IncludeWallets, but for WalletItem names.
+
+		Performed in/from:
+		Folder
+	*/
+	IncludeEntries []string `json:"include_entries,omitempty"`
+	// ExcludeEntries skips the WalletItems whose name matches any of its globs (see RecurseOpts.IncludeEntries).
+	ExcludeEntries []string `json:"exclude_entries,omitempty"`
+	/*
+		MaxItems, if greater than zero, is the most WalletItems a Folder holds
+		(across Folder.Passwords, Folder.Maps, Folder.BinaryData, and Folder.Unknown, filled in that order and by name);
+		further entries are skipped and Folder.Truncated is set.
+
+		Performed in/from:
+		Folder
+	*/
+	MaxItems int `json:"max_items,omitempty"`
+	// filters are the compiled name filters (see RecurseOpts.compiledFilters), guarded by recurseFiltersLock.
+	filters *recurseFilters
+}
+
+/*
+	recurseFilters are the compiled name filters of a RecurseOpts.
+	They are recompiled if the RecurseOpts' globs have changed since (see recurseFilters.compiledFrom).
+*/
+type recurseFilters struct {
+	// globs are the globs the filters were compiled from, in the order of RecurseOpts.filterGlobs.
+	globs [][]string
+	// wallets, folders, and entries are the compiled RecurseOpts.IncludeWallets/ExcludeWallets, etc.
+	wallets *globFilter
+	folders *globFilter
+	entries *globFilter
+}
+
+// globFilter is a compiled pair of include and exclude globs (see RecurseOpts.IncludeWallets).
+type globFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+/*
+	Snapshot is a detached, plain-data copy of the Wallet objects in a WalletManager at a point in time.
+	It holds no connection or parent pointers, so it can be copied, compared, serialized (e.g. to JSON),
+	and used offline. Nothing in this library modifies a Snapshot after it is taken,
+	and the values of its WalletItems are only returned as copies (see EntrySnapshot.Bytes).
+	Those values are only included in its JSON if it was taken with WalletManager.SetShowValues(true).
+*/
+type Snapshot struct {
+	// AppID is the WalletManager.AppID the Snapshot was taken with.
+	AppID string `json:"app_id"`
+	// Taken is when the Snapshot was taken.
+	Taken time.Time `json:"taken"`
+	// Wallets are the snapshots of each Wallet, sorted by WalletSnapshot.Name.
+	Wallets []*WalletSnapshot `json:"wallets"`
+}
+
+// WalletSnapshot is a detached, plain-data copy of a Wallet. See Snapshot.
+type WalletSnapshot struct {
+	// Name is the Wallet.Name.
+	Name string `json:"name"`
+	// Taken is when the WalletSnapshot was taken.
+	Taken time.Time `json:"taken"`
+	// Folders are the snapshots of each Folder, sorted by FolderSnapshot.Name.
+	Folders []*FolderSnapshot `json:"folders"`
+}
+
+// FolderSnapshot is a detached, plain-data copy of a Folder. See Snapshot.
+type FolderSnapshot struct {
+	// Name is the Folder.Name.
+	Name string `json:"name"`
+	// Entries are the snapshots of each WalletItem in the Folder, sorted by EntrySnapshot.Name.
+	Entries []*EntrySnapshot `json:"entries"`
+}
+
+/*
+	EntrySnapshot is a detached, plain-data copy of a WalletItem. See Snapshot.
+	EntrySnapshot.Bytes returns (a copy of) the value as stored by kwalletd (i.e. as returned by Backend.ReadEntry);
+	use EntrySnapshot.Password, EntrySnapshot.Map, etc. to get a decoded value.
+*/
+type EntrySnapshot struct {
+	// Name is the name of the WalletItem.
+	Name string `json:"name"`
+	// Type is the type of the WalletItem.
+	Type kwalletdEnumType `json:"type"`
+	// raw is the raw (serialized) value of the WalletItem.
+	raw []byte
+	// showRaw, if true, includes raw in JSON (see EntrySnapshot.MarshalJSON).
+	showRaw bool
+	// redacted is true if the EntrySnapshot was decoded from JSON without its raw value; raw is then nil.
+	redacted bool
+	// digest is the SHA-256 digest of the raw value of a redacted EntrySnapshot, if its JSON had one.
+	digest []byte
+}
+
+/*
+	jsonEntrySnapshot is the JSON form of an EntrySnapshot: with its raw value base64-encoded,
+	or (if Redacted) with only the hex-encoded SHA-256 digest of it.
+*/
+type jsonEntrySnapshot struct {
+	Name     string           `json:"name"`
+	Type     kwalletdEnumType `json:"type"`
+	Raw      []byte           `json:"raw,omitempty"`
+	Redacted bool             `json:"redacted,omitempty"`
+	SHA256   string           `json:"sha256,omitempty"`
+}
+
+// DiffOpts controls how a Diff is computed.
+type DiffOpts struct {
+	/*
+		ShowValues, if true, includes the actual (old and new) values of changed WalletItems in the Diff.
+		By default, values are replaced by RedactedValue.
+	*/
+	ShowValues bool `json:"show_values"`
+}
+
+// Diff is a structured set of differences between two Snapshot/WalletSnapshot/FolderSnapshot (or Wallet/Folder) trees.
+type Diff struct {
+	// Changes are the individual changes, ordered by wallet, folder, and entry name.
+	Changes []*DiffChange `json:"changes"`
+}
+
+/*
+	DiffChange is a single change in a Diff.
+	Which fields are populated depends on the level of the change: a Wallet-level change only has DiffChange.Wallet,
+	a Folder-level change also has DiffChange.Folder, and an entry (WalletItem) change also has DiffChange.Entry.
+*/
+type DiffChange struct {
+	// Op is the type of change.
+	Op DiffOp `json:"op"`
+	// Wallet is the name of the Wallet the change is in.
+	Wallet string `json:"wallet,omitempty"`
+	// Folder is the name of the Folder the change is in (if any).
+	Folder string `json:"folder,omitempty"`
+	// Entry is the name of the WalletItem that changed (if any).
+	Entry string `json:"entry,omitempty"`
+	// OldType is the type of the WalletItem before the change (for DiffModified, DiffRemoved, and DiffTypeChanged).
+	OldType *kwalletdEnumType `json:"old_type,omitempty"`
+	// NewType is the type of the WalletItem after the change (for DiffModified, DiffAdded, and DiffTypeChanged).
+	NewType *kwalletdEnumType `json:"new_type,omitempty"`
+	/*
+		OldValue is the value before the change (RedactedValue unless DiffOpts.ShowValues).
+		It is nil if there was no value (e.g. for DiffAdded), and an empty string if the value was empty.
+	*/
+	OldValue *string `json:"old_value,omitempty"`
+	// NewValue is the value after the change, as DiffChange.OldValue.
+	NewValue *string `json:"new_value,omitempty"`
+	// MapKeys are the per-key changes if the WalletItem is a Map on both sides.
+	MapKeys []*DiffMapKey `json:"map_keys,omitempty"`
+}
+
+// DiffMapKey is a change to a single key in a Map.
+type DiffMapKey struct {
+	// Op is the type of change (DiffAdded, DiffRemoved, or DiffModified).
+	Op DiffOp `json:"op"`
+	// Key is the Map key.
+	Key string `json:"key"`
+	// OldValue is the value before the change (RedactedValue unless DiffOpts.ShowValues; nil if the key was added).
+	OldValue *string `json:"old_value,omitempty"`
+	// NewValue is the value after the change (RedactedValue unless DiffOpts.ShowValues; nil if the key was removed).
+	NewValue *string `json:"new_value,omitempty"`
+}
+
+// DiffOp is the type of a DiffChange or DiffMapKey.
+type DiffOp string
+
+/*
+	StateSpec is a declarative description of what one or more Wallets should contain
+	(e.g. "Folder X must contain these Passwords and Maps"). See ParseStateSpec and WalletManager.PlanState.
+	Wallets and Folders not named in a StateSpec are never touched.
+*/
+type StateSpec struct {
+	// Wallets are the desired Wallet states. The map key is the Wallet.Name.
+	Wallets map[string]*WalletStateSpec `json:"wallets" yaml:"wallets"`
+}
+
+// WalletStateSpec is the desired state of a single Wallet. See StateSpec.
+type WalletStateSpec struct {
+	// Folders are the desired Folder states. The map key is the Folder.Name.
+	Folders map[string]*FolderStateSpec `json:"folders" yaml:"folders"`
+}
+
+/*
+	FolderStateSpec is the desired state of a single Folder. See StateSpec.
+	An entry name may only be used once across all of the WalletItem types.
+*/
+type FolderStateSpec struct {
+	// Passwords are the Password objects the Folder must contain, as name: value.
+	Passwords map[string]string `json:"passwords,omitempty" yaml:"passwords,omitempty"`
+	// Maps are the Map objects the Folder must contain, as name: {key: value}.
+	Maps map[string]map[string]string `json:"maps,omitempty" yaml:"maps,omitempty"`
+	// Blobs are the Blob objects the Folder must contain, as name: base64-encoded value.
+	Blobs map[string]string `json:"blobs,omitempty" yaml:"blobs,omitempty"`
+	// Unknown are the UnknownItem objects the Folder must contain, as name: base64-encoded value.
+	Unknown map[string]string `json:"unknown,omitempty" yaml:"unknown,omitempty"`
+	// Prune, if non-nil, overrides StateOpts.Prune for this Folder.
+	Prune *bool `json:"prune,omitempty" yaml:"prune,omitempty"`
+}
+
+// StateOpts controls how a StatePlan is computed and applied.
+type StateOpts struct {
+	/*
+		Prune, if true, removes WalletItems from the Folders in a StateSpec if they are not in the StateSpec.
+		(Folders that are not in the StateSpec are never pruned.)
+		If an extraneous WalletItem has the same type and value as a missing one, it is renamed instead.
+	*/
+	Prune bool `json:"prune"`
+	/*
+		ContinueOnError, if true, continues applying a StatePlan after an operation fails.
+		By default, the remaining operations are skipped.
+	*/
+	ContinueOnError bool `json:"continue_on_error"`
+}
+
+// StatePlan is the ordered set of operations needed to bring one or more Wallets to the state in a StateSpec.
+type StatePlan struct {
+	// Ops are the operations to perform, in order.
+	Ops []*StateOp `json:"ops"`
+}
+
+/*
+	StateOp is a single operation in a StatePlan.
+	The value to write (for StateWriteEntry) is intentionally not exported so that plans can be printed or serialized safely;
+	a StateOp decoded from JSON therefore cannot be applied (see WalletManager.ApplyState).
+*/
+type StateOp struct {
+	// Action is the operation to perform.
+	Action StateAction `json:"action"`
+	// Wallet is the name of the Wallet the operation is for.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the operation is for.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem the operation is for (if any).
+	Entry string `json:"entry,omitempty"`
+	// NewEntry is the new name of the WalletItem (for StateRenameEntry).
+	NewEntry string `json:"new_entry,omitempty"`
+	// Type is the type of the WalletItem being written (for StateWriteEntry).
+	Type *kwalletdEnumType `json:"type,omitempty"`
+	// Reason is a short, human-readable explanation of why the operation is needed.
+	Reason string `json:"reason,omitempty"`
+	// value is the raw value to write (for StateWriteEntry).
+	value []byte
+}
+
+// StateResult is the result of applying a single StateOp.
+type StateResult struct {
+	// Op is the StateOp that was applied.
+	Op *StateOp `json:"op"`
+	// Skipped is true if the StateOp was not attempted (because an earlier one failed).
+	Skipped bool `json:"skipped"`
+	// Err is the error returned by the StateOp, if any.
+	Err error `json:"-"`
+	// Error is the string form of StateResult.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// StateAction is the type of a StateOp.
+type StateAction string
+
+/*
+	ConflictPolicy controls what happens when copying or moving a WalletItem to a Folder that already has an entry by that name.
+	See the Conflict* constants.
+*/
+type ConflictPolicy string
+
+// CopyResult is the result of copying (or moving) a single WalletItem.
+type CopyResult struct {
+	// Entry is the name of the source WalletItem.
+	Entry string `json:"entry"`
+	// DestEntry is the name of the destination WalletItem (which differs from CopyResult.Entry if it was renamed).
+	DestEntry string `json:"dest_entry,omitempty"`
+	// Type is the type of the WalletItem.
+	Type kwalletdEnumType `json:"type"`
+	// Skipped is true if the WalletItem was not copied because of a conflict (see ConflictSkip).
+	Skipped bool `json:"skipped"`
+	// Overwritten is true if an existing destination WalletItem was replaced (see ConflictOverwrite).
+	Overwritten bool `json:"overwritten"`
+	// Err is the error encountered copying the WalletItem, if any.
+	Err error `json:"-"`
+}
+
+/*
+	ExportDocument is a versioned, self-describing JSON document of the contents of one or more Wallets,
+	as created by WalletManager.Export (or Wallet.Export or Folder.Export) and read back by ParseExport and WalletManager.Import.
+	Unlike Snapshot, which holds values as kwalletd serializes them, each ExportEntry holds its value in a typed,
+	human-readable field. Values are included in plaintext; treat an ExportDocument (and its JSON) as secret.
+*/
+type ExportDocument struct {
+	// Format is always ExportFormat.
+	Format string `json:"format"`
+	// Version is the version of the document format (see ExportVersion).
+	Version int `json:"version"`
+	// AppID is the WalletManager.AppID the document was exported with.
+	AppID string `json:"app_id"`
+	// Exported is when the document was exported.
+	Exported time.Time `json:"exported"`
+	// Wallets are the exported Wallets, sorted by ExportWallet.Name.
+	Wallets []*ExportWallet `json:"wallets"`
+}
+
+// ExportWallet is an exported Wallet. See ExportDocument.
+type ExportWallet struct {
+	// Name is the Wallet.Name.
+	Name string `json:"name"`
+	// Folders are the exported Folders, sorted by ExportFolder.Name.
+	Folders []*ExportFolder `json:"folders"`
+}
+
+// ExportFolder is an exported Folder. See ExportDocument.
+type ExportFolder struct {
+	// Name is the Folder.Name.
+	Name string `json:"name"`
+	// Entries are the exported WalletItems, sorted by ExportEntry.Name.
+	Entries []*ExportEntry `json:"entries"`
+}
+
+/*
+	ExportEntry is an exported WalletItem. See ExportDocument.
+	Which value field is used depends on ExportEntry.Type; the others are empty.
+*/
+type ExportEntry struct {
+	// Name is the name of the WalletItem.
+	Name string `json:"name"`
+	// Type is the type of the WalletItem.
+	Type kwalletdEnumType `json:"type"`
+	// Password is the value of a Password.
+	Password string `json:"password,omitempty"`
+	// Map is the value of a Map.
+	Map map[string]string `json:"map,omitempty"`
+	// Data is the value of a Blob or UnknownItem (base64-encoded in JSON).
+	Data []byte `json:"data,omitempty"`
+}
+
+// ImportOpts controls how an ExportDocument is imported (see WalletManager.Import).
+type ImportOpts struct {
+	// Policy determines what happens if a WalletItem being imported already exists.
+	Policy ConflictPolicy `json:"policy"`
+	/*
+		MergeMaps, if true, merges an imported Map into an existing Map of the same name
+		(keys from the ExportDocument replace existing keys; other existing keys are kept) instead of applying ImportOpts.Policy.
+	*/
+	MergeMaps bool `json:"merge_maps"`
+	/*
+		Wallet, if not empty, imports every ExportWallet into the Wallet by this name
+		instead of the one named by ExportWallet.Name. It is only used by WalletManager.Import.
+	*/
+	Wallet string `json:"wallet,omitempty"`
+}
+
+// ImportResult is the result of importing a single ExportEntry (or restoring a WalletItem from a backup archive; see Wallet.Restore).
+type ImportResult struct {
+	// Wallet is the name of the Wallet the WalletItem was imported into.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the WalletItem was imported into.
+	Folder string `json:"folder"`
+	// CopyResult describes the WalletItem written (see ImportOpts.Policy).
+	*CopyResult
+	// Merged is true if the WalletItem was merged into an existing Map (see ImportOpts.MergeMaps).
+	Merged bool `json:"merged"`
+}
+
+/*
+	BackupManifest describes the contents of a backup archive (see Wallet.Backup): every Folder and WalletItem in it,
+	with the type, size, and SHA-256 checksum of each WalletItem's raw value. It is stored (encrypted) in the archive,
+	and is returned by Wallet.Restore, including for a dry run (see RestoreOpts.DryRun).
+*/
+type BackupManifest struct {
+	// Version is the version of the backup archive format (see BackupVersion).
+	Version int `json:"version"`
+	// Wallet is the name of the Wallet that was backed up.
+	Wallet string `json:"wallet"`
+	// AppID is the WalletManager.AppID the backup was made with.
+	AppID string `json:"app_id"`
+	// Created is when the backup was made.
+	Created time.Time `json:"created"`
+	// Folders are the Folders in the backup, sorted by BackupFolder.Name.
+	Folders []*BackupFolder `json:"folders"`
+}
+
+// BackupFolder describes a Folder in a backup archive. See BackupManifest.
+type BackupFolder struct {
+	// Name is the Folder.Name.
+	Name string `json:"name"`
+	// Entries are the WalletItems in the Folder, sorted by BackupEntry.Name.
+	Entries []*BackupEntry `json:"entries"`
+}
+
+// BackupEntry describes a WalletItem in a backup archive. See BackupManifest.
+type BackupEntry struct {
+	// Name is the name of the WalletItem.
+	Name string `json:"name"`
+	// Type is the type of the WalletItem.
+	Type kwalletdEnumType `json:"type"`
+	// Size is the length of the raw (serialized) value of the WalletItem.
+	Size int `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the raw (serialized) value of the WalletItem.
+	SHA256 string `json:"sha256"`
+}
+
+// RestoreOpts controls how a backup archive is restored (see Wallet.Restore).
+type RestoreOpts struct {
+	// Folders, if not empty, restores only these Folders (each must be in the archive; see ErrBackupNoFolder).
+	Folders []string `json:"folders,omitempty"`
+	/*
+		DryRun, if true, decrypts and verifies the archive and reports what would be restored (including conflicts),
+		but does not write anything.
+	*/
+	DryRun bool `json:"dry_run"`
+	// Policy determines what happens if a WalletItem being restored already exists.
+	Policy ConflictPolicy `json:"policy"`
+}
+
+/*
+	BackupShare is a parsed key share of a backup archive written by Wallet.BackupShares (see ParseBackupShare).
+	It describes the share without its secret part, so it is safe to print (e.g. to check which share a custodian holds).
+*/
+type BackupShare struct {
+	// ArchiveID identifies the backup archive (and so the set of shares) the share belongs to, hex-encoded.
+	ArchiveID string `json:"archive_id"`
+	// Threshold is the number of shares needed to recover the archive key.
+	Threshold int `json:"threshold"`
+	// Index is the number of this share (from 1 to the number of shares).
+	Index int `json:"index"`
+	// value is the secret part of the share.
+	value []byte
+}
+
+// backupHeader is the unencrypted header of a backup archive; it (and backupMagic) is authenticated as the AEAD's additional data.
+type backupHeader struct {
+	// Version is the version of the backup archive format (see BackupVersion).
+	Version int `json:"version"`
+	// KDF is how the encryption key is derived (see backupKDFScrypt).
+	KDF string `json:"kdf"`
+	// Salt is the salt for the KDF.
+	Salt []byte `json:"salt,omitempty"`
+	// ScryptN is the scrypt CPU/memory cost parameter.
+	ScryptN int `json:"scrypt_n,omitempty"`
+	// ScryptR is the scrypt block size parameter.
+	ScryptR int `json:"scrypt_r,omitempty"`
+	// ScryptP is the scrypt parallelization parameter.
+	ScryptP int `json:"scrypt_p,omitempty"`
+	// ShareID identifies the key shares of an archive whose key is split (see backupKDFShamir and Wallet.BackupShares).
+	ShareID []byte `json:"share_id,omitempty"`
+	// ShareThreshold is the number of key shares needed to recover the key.
+	ShareThreshold int `json:"share_threshold,omitempty"`
+	// ShareCount is the number of key shares the key was split into.
+	ShareCount int `json:"share_count,omitempty"`
+	// Cipher is the AEAD the payload is encrypted with (see backupCipher).
+	Cipher string `json:"cipher"`
+	// Nonce is the AEAD nonce.
+	Nonce []byte `json:"nonce"`
+}
+
+// backupPayload is the (encrypted) contents of a backup archive.
+type backupPayload struct {
+	// Manifest describes Wallet.
+	Manifest *BackupManifest `json:"manifest"`
+	// Wallet holds every Folder and WalletItem (as its raw value).
+	Wallet *WalletSnapshot `json:"wallet"`
+}
+
+/*
+	Tx is a set of WalletItem operations (writes, removals, and renames) on a single Wallet that are applied together.
+	Operations are queued with Tx.WriteEntry, Tx.RemoveEntry, etc. and applied, in order, by Tx.Commit.
+	Before each WalletItem is first touched, its prior type and raw value (and its EntryMetadata and expiry, if tracked)
+	are recorded; if any operation fails, everything the Tx touched is restored to that recorded state (on a best-effort basis).
+	Create one with Wallet.Begin.
+*/
+type Tx struct {
+	// wallet is the Wallet the Tx operates on.
+	wallet *Wallet
+	// ops are the queued operations.
+	ops []*TxOp
+	// folders are the Folder objects used by the Tx, by name.
+	folders map[string]*Folder
+	// folderExisted records whether each Folder touched existed before the Tx touched it.
+	folderExisted map[string]bool
+	// prior records the state of each WalletItem before the Tx first touched it, in the order they were first touched.
+	prior []*txPrior
+	// priorIdx indexes prior by Folder and WalletItem name.
+	priorIdx map[string]*txPrior
+	// committed is true once Tx.Commit has been called.
+	committed bool
+	// done is true once the Tx has failed or been rolled back; no further operations are possible.
+	done bool
+}
+
+// TxOp is a single queued operation in a Tx.
+type TxOp struct {
+	// Action is the operation to perform.
+	Action TxAction `json:"action"`
+	// Folder is the name of the Folder the operation is for.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem the operation is for.
+	Entry string `json:"entry"`
+	// NewEntry is the new name of the WalletItem (for TxRenameEntry).
+	NewEntry string `json:"new_entry,omitempty"`
+	// Type is the type of the WalletItem being written (for TxWriteEntry).
+	Type *kwalletdEnumType `json:"type,omitempty"`
+	// value is the raw value to write (for TxWriteEntry).
+	value []byte
+}
+
+// TxAction is the type of a TxOp.
+type TxAction string
+
+// TxReport details what a Tx.Commit or Tx.Rollback did.
+type TxReport struct {
+	// Committed is true if every TxOp was applied successfully.
+	Committed bool `json:"committed"`
+	// RolledBack is true if the Tx's changes were (or were attempted to be) undone.
+	RolledBack bool `json:"rolled_back"`
+	// Ops contains a TxOpResult for each TxOp, in order.
+	Ops []*TxOpResult `json:"ops"`
+	// Restores contains a TxRestore for each WalletItem (and Folder) that was restored during a rollback.
+	Restores []*TxRestore `json:"restores,omitempty"`
+}
+
+// TxOpResult is the result of applying a single TxOp.
+type TxOpResult struct {
+	// Op is the TxOp.
+	Op *TxOp `json:"op"`
+	// Applied is true if the TxOp was applied successfully.
+	Applied bool `json:"applied"`
+	// Err is the error returned by the TxOp, if any.
+	Err error `json:"-"`
+	// Error is the string form of TxOpResult.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// TxRestore is the result of restoring a single WalletItem (or removing a Folder created by the Tx) during a rollback.
+type TxRestore struct {
+	// Folder is the name of the Folder.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem (empty if the Folder itself was removed).
+	Entry string `json:"entry,omitempty"`
+	// Existed is true if the WalletItem (or Folder) existed before the Tx; if false, it was removed.
+	Existed bool `json:"existed"`
+	// Type is the restored type of the WalletItem (if TxRestore.Existed).
+	Type *kwalletdEnumType `json:"type,omitempty"`
+	// Err is the error encountered restoring the WalletItem, if any.
+	Err error `json:"-"`
+	// Error is the string form of TxRestore.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// txPrior is the state of a WalletItem before a Tx first touched it.
+type txPrior struct {
+	folder    string
+	entry     string
+	existed   bool
+	entryType kwalletdEnumType
+	raw       []byte
+	// metadata is the EntryMetadata of the WalletItem, if EntryMetadata is tracked (see WalletManager.EnableMetadata).
+	metadata *EntryMetadata
+	// expires is the expiry of the WalletItem, if expiry is tracked and it has one (see WalletManager.EnableExpiry).
+	expires time.Time
+}
+
+/*
+	ConflictError is returned by conditional ("compare-and-swap") writes such as Password.SetValueIf and Map.UpdateKeys
+	if the stored WalletItem no longer matches what the caller expected. Nothing is written in that case.
+	errors.Is(err, ErrConflict) is true for a ConflictError.
+*/
+type ConflictError struct {
+	// Wallet is the name of the Wallet the WalletItem is in.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the WalletItem is in.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem.
+	Entry string `json:"entry"`
+	// Reason describes the conflict (e.g. the value changed, or the WalletItem no longer exists). It never contains values.
+	Reason string `json:"reason"`
+}
+
+/*
+	EntryMetadata is the metadata gokwallet keeps for a WalletItem if WalletManager.EnableMetadata has been called.
+	kwalletd itself only stores a name, type, and value for each WalletItem,
+	so this is kept in a reserved (hidden) Folder, MetadataFolder, in each Wallet.
+*/
+type EntryMetadata struct {
+	// Created is when the WalletItem was first written (with metadata enabled).
+	Created time.Time `json:"created"`
+	// Modified is when the WalletItem's value was last written.
+	Modified time.Time `json:"modified"`
+	// ModifiedBy is the WalletManager.AppID that last wrote the WalletItem's value.
+	ModifiedBy string `json:"modified_by"`
+	// Tags are arbitrary, user-defined tags.
+	Tags []string `json:"tags,omitempty"`
+	// Note is an arbitrary, user-defined note.
+	Note string `json:"note,omitempty"`
+}
+
+/*
+	trackingBackend wraps a Backend so that gokwallet's own bookkeeping (e.g. EntryMetadata)
+	is maintained on every write, rename, and removal regardless of which method made it.
+	Bookkeeping is stored via the wrapped Backend in reserved Folders (see isReservedFolder), using the same handle.
+*/
+type trackingBackend struct {
+	Backend
+	// metadata, if true, maintains EntryMetadata.
+	metadata bool
+	// history, if true, retains previous values of WalletItems (see WalletManager.EnableHistory).
+	history bool
+	// historyDepth is the default number of previous values to retain for Folders without their own setting.
+	historyDepth int
+	// expiry, if true, maintains WalletItem expiry times (see WalletManager.EnableExpiry).
+	expiry bool
+}
+
+/*
+	SecretBytes holds a secret value in memory that can be wiped (zeroed) once it is no longer needed,
+	unlike a string (see WalletManager.EnableSecureMode). If locked, its memory is also locked (mlock(2)) so it is never swapped out.
+	A SecretBytes is not safe for concurrent use while being wiped.
+*/
+type SecretBytes struct {
+	// b is the secret.
+	b []byte
+	// locked is true if b was allocated in locked memory (see lockedAlloc).
+	locked bool
+	// wiped is true once SecretBytes.Wipe has been called.
+	wiped bool
+}
+
+/*
+	lockedPool hands out locked memory (see lockedAlloc) in slots carved from shared locked slabs, so that each SecretBytes
+	doesn't lock pages of its own; RLIMIT_MEMLOCK is often only 64 KiB, which would otherwise only allow 16 secrets.
+*/
+type lockedPool struct {
+	// lock guards slabs.
+	lock sync.Mutex
+	// slabs are the locked slabs, by their slot size.
+	slabs map[int][]*lockedSlab
+}
+
+// lockedSlab is a page of locked memory divided into slots of the same size.
+type lockedSlab struct {
+	// mem is the locked memory.
+	mem []byte
+	// slotSize is the size of each slot.
+	slotSize int
+	// free are the offsets of the slots in mem that are not in use.
+	free []int
+}
+
+/*
+	cachingBackend wraps a Backend to cache Folder listings and WalletItem values (see WalletManager.EnableCache).
+	It always sits directly above the base Backend (i.e. under any trackingBackend) so that every write,
+	including gokwallet's own bookkeeping, goes through it and invalidates what it affects.
+*/
+type cachingBackend struct {
+	Backend
+	// ttl is how long a cached listing or value is used before it is evicted.
+	ttl time.Duration
+	// lock protects the fields below.
+	lock sync.Mutex
+	// entries are the cached listings and values.
+	entries map[cacheKey]*cacheEntry
+	// wallets maps the handles seen (via Backend.Open) to their Wallet names, to share the cache between handles and apply signals.
+	wallets map[int32]string
+	// stats are the running CacheStats.
+	stats CacheStats
+	// gen is incremented on every invalidation, so a value read from the Backend during one is not cached.
+	gen uint64
+	// stop stops the background eviction (and signal handling) goroutine.
+	stop chan bool
+	// done is closed when the background goroutine has stopped.
+	done chan bool
+}
+
+// cacheKey identifies a cached listing or value of a cachingBackend.
+type cacheKey struct {
+	// wallet is the Wallet name, if known.
+	wallet string
+	// handle is the Wallet handle, if the Wallet name is not known.
+	handle int32
+	// kind is what is cached (see the cacheKind* constants).
+	kind string
+	// folder is the Folder name (empty for cacheKindFolders).
+	folder string
+	// entry is the WalletItem name (empty for listings).
+	entry string
+}
+
+/*
+	cacheEntry is a cached listing or value of a cachingBackend.
+	Values are kept serialized in raw (never as a string) so they can be wiped on eviction.
+*/
+type cacheEntry struct {
+	// expires is when this cacheEntry is evicted.
+	expires time.Time
+	// names is a cached listing.
+	names []string
+	// raw is a cached (serialized) value.
+	raw []byte
+	// entryType is a cached kwalletdEnumType.
+	entryType kwalletdEnumType
+}
+
+// CacheStats are the statistics of a WalletManager's read cache (see WalletManager.EnableCache).
+type CacheStats struct {
+	// Hits is the number of reads answered from the cache.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of reads that went to the Backend.
+	Misses uint64 `json:"misses"`
+	// Evictions is the number of cached listings and values removed because their TTL passed.
+	Evictions uint64 `json:"evictions"`
+	// Invalidations is the number of cached listings and values removed because of a write or a kwalletd signal.
+	Invalidations uint64 `json:"invalidations"`
+	// Signals is the number of kwalletd signals handled (only a DbusBackend provides them).
+	Signals uint64 `json:"signals"`
+	// Entries is the number of listings and values currently cached.
+	Entries int `json:"entries"`
+}
+
+// backendWrapper is implemented by Backend objects that wrap another Backend (e.g. trackingBackend).
+type backendWrapper interface {
+	unwrap() (backend Backend)
+}
+
+/*
+	HistoryVersion is a previous value of a Password, Map, or Blob, as retained if history is enabled
+	(see WalletManager.EnableHistory and Folder.SetHistoryDepth).
+*/
+type HistoryVersion struct {
+	// ID identifies the version (e.g. for Folder.RestoreVersion).
+	ID string `json:"id"`
+	// Replaced is when this value was replaced (or removed).
+	Replaced time.Time `json:"replaced"`
+	// Type is the type of the WalletItem when it had this value.
+	Type kwalletdEnumType `json:"type"`
+	// Raw is the raw (serialized) value; see EntrySnapshot for decoding it.
+	Raw []byte `json:"-"`
+}
+
+/*
+	ExpiredError is returned when reading a WalletItem (e.g. via Password.Update or Folder.Update) whose expiry
+	(see Folder.SetEntryExpiry) has passed but which has not been removed by a Sweeper yet.
+	errors.Is(err, ErrExpired) is true for an ExpiredError.
+*/
+type ExpiredError struct {
+	// Wallet is the name of the Wallet the WalletItem is in.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the WalletItem is in.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem.
+	Entry string `json:"entry"`
+	// Expired is when the WalletItem expired.
+	Expired time.Time `json:"expired"`
+}
+
+/*
+	Sweeper removes expired WalletItems (see Folder.SetEntryExpiry) from the Wallets of a WalletManager.
+	Use Sweeper.Sweep for a one-off sweep, or Sweeper.Start to sweep every Sweeper.Interval
+	and/or whenever kwalletd reports a Folder as updated (Sweeper.Signals).
+	The exported fields must not be changed while a Sweeper is running.
+*/
+type Sweeper struct {
+	// Interval is how often all Wallets are swept while running. 0 disables periodic sweeps.
+	Interval time.Duration
+	// Wallets, if not empty, limits sweeping to the named Wallets.
+	Wallets []string
+	/*
+		Signals, if true, also sweeps a Folder as soon as kwalletd emits DbusWMSignalFolderUpdated for it.
+		This requires a DbusBackend; Sweeper.Start returns ErrNoSignals otherwise.
+	*/
+	Signals bool
+	// OnSweep, if not nil, is called with the SweepReport of every sweep made while running.
+	OnSweep func(report *SweepReport)
+	// wm is the WalletManager to sweep.
+	wm *WalletManager
+	// lock protects running and stop.
+	lock sync.Mutex
+	// running is true between Sweeper.Start and Sweeper.Stop.
+	running bool
+	// stop is closed to stop a running Sweeper.
+	stop chan bool
+	// done is closed once a running Sweeper has stopped.
+	done chan bool
+	// notify receives Folders to sweep (from signals or Sweeper.Notify).
+	notify chan *WalletSignal
+}
+
+/*
+	BackupScheduler periodically writes encrypted backup archives (see Wallet.Backup) of the Wallets of a WalletManager
+	to a directory, and prunes old archives according to its BackupRetention.
+	A Wallet whose contents have not changed since its newest archive in BackupScheduler.Dir is skipped.
+	Use BackupScheduler.Run for a one-off run (e.g. from cron), or BackupScheduler.Start to run every BackupScheduler.Interval.
+	The exported fields must not be changed while a BackupScheduler is running.
+*/
+type BackupScheduler struct {
+	// Interval is how often backups are made while running.
+	Interval time.Duration
+	// Dir is the directory backup archives are written to (and pruned from). It is created if it does not exist.
+	Dir string
+	// Wallets, if not empty, limits backups to the named Wallets.
+	Wallets []string
+	// Retention determines which old backup archives are kept.
+	Retention BackupRetention
+	// OnRun, if not nil, is called with the BackupReport of every run made while running.
+	OnRun func(report *BackupReport)
+	// OnFailure, if not nil, is called with the BackupResult of every Wallet that could not be backed up (or pruned).
+	OnFailure func(result *BackupResult)
+	// wm is the WalletManager to back up.
+	wm *WalletManager
+	// passphrase is the backup archive passphrase (a copy).
+	passphrase []byte
+	// lock protects running and stop.
+	lock sync.Mutex
+	// running is true between BackupScheduler.Start and BackupScheduler.Stop.
+	running bool
+	// stop is closed to stop a running BackupScheduler.
+	stop chan bool
+	// done is closed once a running BackupScheduler has stopped.
+	done chan bool
+}
+
+/*
+	BackupRetention determines which backup archives of a Wallet a BackupScheduler keeps.
+	The newest archive is always kept. If both fields are 0, nothing is pruned.
+*/
+type BackupRetention struct {
+	// Daily keeps the newest archive of each of the last Daily days that have archives.
+	Daily int `json:"daily"`
+	// Weekly keeps the newest archive of each of the last Weekly (ISO) weeks that have archives.
+	Weekly int `json:"weekly"`
+}
+
+// BackupReport details a run made by a BackupScheduler.
+type BackupReport struct {
+	// Started is when the run started.
+	Started time.Time `json:"started"`
+	// Finished is when the run finished.
+	Finished time.Time `json:"finished"`
+	// Wallets contains a BackupResult for each Wallet.
+	Wallets []*BackupResult `json:"wallets"`
+	// Err is the error of the run as a whole (including those of Wallets), if any.
+	Err error `json:"-"`
+	// Error is the string form of BackupReport.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// BackupResult is the result of backing up a single Wallet in a BackupScheduler run.
+type BackupResult struct {
+	// Wallet is the name of the Wallet.
+	Wallet string `json:"wallet"`
+	// Path is the path of the backup archive written (empty if none was written).
+	Path string `json:"path,omitempty"`
+	// Fingerprint identifies the contents of the Wallet (see BackupFileExt).
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Unchanged is true if no backup archive was written because the newest one already has the same Fingerprint.
+	Unchanged bool `json:"unchanged"`
+	// Pruned are the paths of old backup archives that were removed (see BackupRetention).
+	Pruned []string `json:"pruned,omitempty"`
+	// Err is the error backing up (or pruning) the Wallet, if any.
+	Err error `json:"-"`
+	// Error is the string form of BackupResult.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// backupFile is a backup archive file found in a BackupScheduler.Dir.
+type backupFile struct {
+	// path is the path of the file.
+	path string
+	// wallet is the name of the Wallet backed up.
+	wallet string
+	// taken is when the backup was made.
+	taken time.Time
+	// fingerprint is the BackupResult.Fingerprint of the backup.
+	fingerprint string
+}
+
+/*
+	KDBXOpts controls the key derivation of a KeePass (KDBX 4) database written by Wallet.ExportKDBX,
+	which uses Argon2id. Zero fields use the defaults (DefaultKDBXArgon2Memory, etc.).
+	Larger values make the password harder to brute-force, but the database slower to open.
+*/
+type KDBXOpts struct {
+	// Argon2Memory is the memory (in bytes) Argon2id uses.
+	Argon2Memory uint64 `json:"argon2_memory"`
+	// Argon2Iterations is the number of Argon2id passes over memory.
+	Argon2Iterations uint64 `json:"argon2_iterations"`
+	// Argon2Parallelism is the number of Argon2id lanes (threads).
+	Argon2Parallelism uint32 `json:"argon2_parallelism"`
+}
+
+// kdbxHeader is the (outer, unencrypted) header of a KDBX 4 database.
+type kdbxHeader struct {
+	// cipherID is the (hex-encoded) UUID of the payload cipher (e.g. kdbxCipherAES256).
+	cipherID string
+	// compression is the compression of the payload (kdbxCompressNone or kdbxCompressGzip).
+	compression uint32
+	// masterSeed is hashed with the transformed key to get the keys (see kdbxKeys).
+	masterSeed []byte
+	// iv is the IV (or nonce) of the payload cipher.
+	iv []byte
+	// kdf holds the KDF parameters (see kdbxKDFUUID, etc.).
+	kdf kdbxVariantDict
+}
+
+// kdbxKeys are the keys of a KDBX 4 database, derived from its password and kdbxHeader.
+type kdbxKeys struct {
+	// cipherKey is the key of the payload cipher.
+	cipherKey []byte
+	// macKey is the key the HMAC key of each block is derived from.
+	macKey []byte
+}
+
+// kdbxInner is the inner (encrypted) header of a KDBX 4 database.
+type kdbxInner struct {
+	// streamID is the inner random stream that protected values are encrypted with (e.g. kdbxStreamChaCha20).
+	streamID uint32
+	// streamKey is the key of the inner random stream.
+	streamKey []byte
+	// binaries are the attachments, referenced by index (see kdbxXMLBinary).
+	binaries [][]byte
+}
+
+// kdbxVariantDict is a KDBX VariantDictionary; values are uint32, uint64, bool, int32, int64, string, or []byte.
+type kdbxVariantDict map[string]interface{}
+
+// kdbxXMLFile is the XML document of a KeePass database (only the parts used here).
+type kdbxXMLFile struct {
+	XMLName xml.Name    `xml:"KeePassFile"`
+	Meta    kdbxXMLMeta `xml:"Meta"`
+	Root    kdbxXMLRoot `xml:"Root"`
+}
+
+// kdbxXMLMeta is the metadata of a KeePass database.
+type kdbxXMLMeta struct {
+	Generator         string `xml:"Generator"`
+	DatabaseName      string `xml:"DatabaseName"`
+	RecycleBinEnabled string `xml:"RecycleBinEnabled"`
+	RecycleBinUUID    string `xml:"RecycleBinUUID,omitempty"`
+}
+
+// kdbxXMLRoot holds the root group of a KeePass database.
+type kdbxXMLRoot struct {
+	Group *kdbxXMLGroup `xml:"Group"`
+}
+
+// kdbxXMLGroup is a group in a KeePass database.
+type kdbxXMLGroup struct {
+	UUID    string          `xml:"UUID"`
+	Name    string          `xml:"Name"`
+	Times   *kdbxXMLTimes   `xml:"Times"`
+	Entries []*kdbxXMLEntry `xml:"Entry"`
+	Groups  []*kdbxXMLGroup `xml:"Group"`
+}
+
+// kdbxXMLEntry is an entry in a KeePass database. Its history is ignored.
+type kdbxXMLEntry struct {
+	UUID       string             `xml:"UUID"`
+	Times      *kdbxXMLTimes      `xml:"Times"`
+	Strings    []*kdbxXMLString   `xml:"String"`
+	Binaries   []*kdbxXMLBinary   `xml:"Binary"`
+	CustomData *kdbxXMLCustomData `xml:"CustomData,omitempty"`
+}
+
+// kdbxXMLString is a string field of a kdbxXMLEntry (e.g. kdbxFieldTitle, or a custom one).
+type kdbxXMLString struct {
+	Key   string       `xml:"Key"`
+	Value kdbxXMLValue `xml:"Value"`
+}
+
+// kdbxXMLValue is the value of a kdbxXMLString; if Protected is "True", it is encrypted in the database (see kdbxProtect).
+type kdbxXMLValue struct {
+	Protected string `xml:"Protected,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+// kdbxXMLBinary is an attachment of a kdbxXMLEntry, referencing kdbxInner.binaries.
+type kdbxXMLBinary struct {
+	Key   string             `xml:"Key"`
+	Value kdbxXMLBinaryValue `xml:"Value"`
+}
+
+// kdbxXMLBinaryValue is the value of a kdbxXMLBinary: the index of the attachment in kdbxInner.binaries.
+type kdbxXMLBinaryValue struct {
+	Ref int `xml:"Ref,attr"`
+}
+
+// kdbxXMLCustomData holds the custom data of a kdbxXMLEntry (e.g. kdbxCustomType).
+type kdbxXMLCustomData struct {
+	Items []*kdbxXMLItem `xml:"Item"`
+}
+
+// kdbxXMLItem is a custom data item.
+type kdbxXMLItem struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// kdbxXMLTimes are the timestamps of a kdbxXMLGroup or kdbxXMLEntry (as KDBX 4 base64-encoded seconds; see kdbxTime).
+type kdbxXMLTimes struct {
+	CreationTime         string `xml:"CreationTime"`
+	LastModificationTime string `xml:"LastModificationTime"`
+	LastAccessTime       string `xml:"LastAccessTime"`
+	ExpiryTime           string `xml:"ExpiryTime"`
+	Expires              string `xml:"Expires"`
+	UsageCount           int    `xml:"UsageCount"`
+	LocationChanged      string `xml:"LocationChanged"`
+}
+
+// bitwardenFile is a Bitwarden (unencrypted) JSON export (only the parts used here).
+type bitwardenFile struct {
+	Encrypted bool               `json:"encrypted"`
+	Folders   []*bitwardenFolder `json:"folders"`
+	Items     []*bitwardenItem   `json:"items"`
+}
+
+// bitwardenFolder is a folder in a Bitwarden export.
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// bitwardenItem is an item (login, secure note, card, or identity) in a Bitwarden export.
+type bitwardenItem struct {
+	ID             string                 `json:"id"`
+	OrganizationID *string                `json:"organizationId"`
+	FolderID       *string                `json:"folderId"`
+	Type           int                    `json:"type"`
+	Reprompt       int                    `json:"reprompt"`
+	Name           string                 `json:"name"`
+	Notes          *string                `json:"notes"`
+	Favorite       bool                   `json:"favorite"`
+	Fields         []*bitwardenField      `json:"fields,omitempty"`
+	Login          *bitwardenLogin        `json:"login,omitempty"`
+	SecureNote     *bitwardenSecureNote   `json:"secureNote,omitempty"`
+	Card           map[string]interface{} `json:"card,omitempty"`
+	Identity       map[string]interface{} `json:"identity,omitempty"`
+	CollectionIDs  []string               `json:"collectionIds"`
+	DeletedDate    *string                `json:"deletedDate,omitempty"`
+}
+
+// bitwardenField is a custom field of a bitwardenItem. Value is nil for a linked field.
+type bitwardenField struct {
+	Name     string  `json:"name"`
+	Value    *string `json:"value"`
+	Type     int     `json:"type"`
+	LinkedID *int    `json:"linkedId"`
+}
+
+// bitwardenLogin holds the login fields of a bitwardenItem.
+type bitwardenLogin struct {
+	URIs     []*bitwardenURI `json:"uris"`
+	Username *string         `json:"username"`
+	Password *string         `json:"password"`
+	TOTP     *string         `json:"totp"`
+}
+
+// bitwardenURI is a URI of a bitwardenLogin.
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// bitwardenSecureNote holds the secure note fields of a bitwardenItem.
+type bitwardenSecureNote struct {
+	Type int `json:"type"`
+}
+
+// argon2Block is an Argon2 memory block (see argon2Key).
+type argon2Block [argon2BlockLen]uint64
+
+// hashWriter is a hash.Hash with a helper for writing little-endian integers (see argon2Key).
+type hashWriter struct {
+	hash.Hash
+}
+
+// SweepReport details a sweep made by a Sweeper.
+type SweepReport struct {
+	// Started is when the sweep started.
+	Started time.Time `json:"started"`
+	// Finished is when the sweep finished.
+	Finished time.Time `json:"finished"`
+	// Removed contains a SweptEntry for each expired WalletItem that was found.
+	Removed []*SweptEntry `json:"removed"`
+	// Err is the error of the sweep as a whole (including those of Removed), if any.
+	Err error `json:"-"`
+	// Error is the string form of SweepReport.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+// SweptEntry is an expired WalletItem found by a Sweeper.
+type SweptEntry struct {
+	// Wallet is the name of the Wallet the WalletItem was in.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the WalletItem was in.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem.
+	Entry string `json:"entry"`
+	// Expired is when the WalletItem expired.
+	Expired time.Time `json:"expired"`
+	// Err is the error removing the WalletItem, if any.
+	Err error `json:"-"`
+	// Error is the string form of SweptEntry.Err (for serialization).
+	Error string `json:"error,omitempty"`
+}
+
+/*
+	WalletSignal is a signal emitted by kwalletd (see the DbusWMSignal* constants).
+	Folder is only set for DbusWMSignalFolderUpdated.
+*/
+type WalletSignal struct {
+	// Name is the name (Dbus member) of the signal, e.g. DbusWMSignalFolderUpdated.
+	Name string `json:"name"`
+	// Wallet is the name of the Wallet the signal is about.
+	Wallet string `json:"wallet"`
+	// Folder is the name of the Folder the signal is about, if any.
+	Folder string `json:"folder,omitempty"`
+	// Handle is the Wallet handle the signal is about, if only that is given (e.g. for some DbusWMSignalWalletClosed); Wallet is then empty.
+	Handle int32 `json:"handle,omitempty"`
+}
+
+/*
+	ItemPath addresses a WalletItem (and optionally a key in a Map) by name, as parsed from (and formatted as) a URI such as
+	kwallet://kdewallet/Passwords/x or kwallet:///Network%20Management/wifi#psk (see ParseItemPath).
+*/
+type ItemPath struct {
+	// Wallet is the name of the Wallet. If empty, the WalletManager's local (default) Wallet is used.
+	Wallet string `json:"wallet,omitempty"`
+	// Folder is the name of the Folder.
+	Folder string `json:"folder"`
+	// Entry is the name of the WalletItem.
+	Entry string `json:"entry"`
+	// Key, if not empty, is a key in the Map named by Entry.
+	Key string `json:"key,omitempty"`
+}
+
+/*
+	SearchQuery describes what WalletManager.Search (or Wallet.Search) looks for. All of the set matchers must match.
+	Name patterns are globs (* matching any run of characters, including "/", and ? any single character)
+	unless SearchQuery.Regex is true. An empty pattern matches everything.
+*/
+type SearchQuery struct {
+	// Wallet matches Wallet names.
+	Wallet string `json:"wallet,omitempty" yaml:"wallet,omitempty"`
+	// Folder matches Folder names.
+	Folder string `json:"folder,omitempty" yaml:"folder,omitempty"`
+	// Entry matches WalletItem names.
+	Entry string `json:"entry,omitempty" yaml:"entry,omitempty"`
+	/*
+		MapKey, if set, matches the key names of Maps; only Maps with a matching key are found,
+		and a SearchResult is returned for each matching key (with ItemPath.Key set).
+	*/
+	MapKey string `json:"map_key,omitempty" yaml:"map_key,omitempty"`
+	// Regex, if true, makes Wallet, Folder, Entry, and MapKey regular expressions (see regexp) instead of globs.
+	Regex bool `json:"regex,omitempty" yaml:"regex,omitempty"`
+	// Types, if not empty, limits the search to WalletItems of these types.
+	Types []kwalletdEnumType `json:"types,omitempty" yaml:"types,omitempty"`
+	/*
+		ValueContains, if set, matches WalletItems whose value contains it: a Password's value,
+		the value of a Map key (a SearchResult is returned for each matching key), or a Blob's (or UnknownItem's) bytes.
+		This is the only matcher that requires reading values; they are read only for WalletItems that match everything else.
+	*/
+	ValueContains string `json:"value_contains,omitempty" yaml:"value_contains,omitempty"`
+	// IgnoreCase, if true, makes all of the matchers case-insensitive.
+	IgnoreCase bool `json:"ignore_case,omitempty" yaml:"ignore_case,omitempty"`
+	// Limit, if non-zero, is the maximum number of SearchResults to return.
+	Limit int `json:"limit,omitempty" yaml:"limit,omitempty"`
+}
+
+// SearchResult is a WalletItem (or Map key) found by a search.
+type SearchResult struct {
+	// Path is the ItemPath of the WalletItem (see ItemPath.String and WalletManager.Resolve).
+	Path *ItemPath `json:"path"`
+	// Type is the type of the WalletItem.
+	Type kwalletdEnumType `json:"type"`
+}
+
+// searchMatcher is a compiled SearchQuery.
+type searchMatcher struct {
+	// wallet, folder, entry, and mapKey are nil if they match everything.
+	wallet *regexp.Regexp
+	folder *regexp.Regexp
+	entry  *regexp.Regexp
+	mapKey *regexp.Regexp
+	// types is nil if all types match.
+	types map[kwalletdEnumType]bool
+	// value is nil if values are not matched. It is lowercase if ignoreCase.
+	value []byte
+	// ignoreCase is SearchQuery.IgnoreCase.
+	ignoreCase bool
+	// limit is SearchQuery.Limit.
+	limit int
+}
+
+// WalkKind is the kind of a WalkEntry.
+type WalkKind string
+
+/*
+	WalkFunc is called by WalletManager.Walk (and Wallet.Walk) for each Wallet, Folder, and WalletItem visited.
+	If err is not nil, entry could not be opened (WalkKindWallet) or listed (WalkKindFolder) and will not be descended into;
+	returning nil skips it, and returning err (or any other error) stops the walk.
+	Return SkipFolder or SkipWallet to prune the walk.
+*/
+type WalkFunc func(entry *WalkEntry, err error) (walkErr error)
+
+/*
+	WalkEntry is a Wallet, Folder, or WalletItem visited by a walk. Only names (and, for WalletItems, the type) are known;
+	values are only fetched by WalkEntry.Item.
+*/
+type WalkEntry struct {
+	// Kind is the kind of the WalkEntry.
+	Kind WalkKind `json:"kind"`
+	// Path addresses the WalkEntry. Folder and Entry are empty for a Wallet, and Entry for a Folder.
+	Path *ItemPath `json:"path"`
+	// Type is the type of a WalletItem (KwalletdEnumTypeUnused for a Wallet or Folder).
+	Type kwalletdEnumType `json:"type"`
+	// wallet is the Wallet this WalkEntry is (in).
+	wallet *Wallet
+	// folder is the Folder this WalkEntry is (in); nil for a Wallet.
+	folder *Folder
 }
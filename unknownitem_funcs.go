@@ -1,7 +1,9 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"encoding/json"
+	"fmt"
+	"time"
 )
 
 /*
@@ -29,7 +31,7 @@ func NewUnknownItem(f *Folder, keyName string, recursion *RecurseOpts) (unknown
 
 	unknown.isInit = true
 
-	if unknown.Recurse.AllWalletItems || unknown.Recurse.UnknownItems {
+	if !unknown.Recurse.Lazy && (unknown.Recurse.AllWalletItems || unknown.Recurse.UnknownItems) {
 		if err = unknown.Update(); err != nil {
 			return
 		}
@@ -74,39 +76,241 @@ func (u *UnknownItem) Rename(newName string) (err error) {
 	return
 }
 
-// SetValue will replace this UnknownItem's UnknownItem.Value.
+// SetValue will replace this UnknownItem's UnknownItem.Value (or, in secure mode, UnknownItem.Secret).
 func (u *UnknownItem) SetValue(newValue []byte) (err error) {
 
 	if _, err = u.folder.WriteUnknown(u.Name, newValue); err != nil {
 		return
 	}
 
-	u.Value = newValue
+	if err = u.setValue(newValue); err != nil {
+		return
+	}
 
 	return
 }
 
-// Update fetches an UnknownItem's UnknownItem.Value.
+// Update fetches an UnknownItem's UnknownItem.Value (or, in secure mode, UnknownItem.Secret).
 func (u *UnknownItem) Update() (err error) {
 
-	var call *dbus.Call
-	var v dbus.Variant
+	var value []byte
+	var secret *SecretBytes
+
+	if value, err = u.read(); err != nil {
+		return
+	}
+
+	if u.folder.wallet.wm.secure {
+		defer wipeBytes(value)
+		if secret, err = u.folder.wallet.wm.newSecret(value); err != nil {
+			return
+		}
+		_ = u.Secret.Wipe()
+		u.Secret = secret
+		u.loaded = true
+		return
+	}
+
+	u.Value = value
+	u.loaded = true
+
+	return
+}
+
+/*
+	GetValue returns an UnknownItem's value, fetching it if it has not been fetched (or set) yet,
+	e.g. if the UnknownItem was created with RecurseOpts.Lazy.
+	The fetched value is only kept (in UnknownItem.Value, or UnknownItem.Secret in secure mode) if RecurseOpts.CacheValues is true;
+	otherwise it is fetched on every call. A kept value is returned as-is (not copied), so it is zeroed by UnknownItem.Wipe.
+	Unlike reading UnknownItem.Value directly, a failed fetch (e.g. an *ExpiredError) is returned rather than an empty value.
+*/
+func (u *UnknownItem) GetValue() (value []byte, err error) {
+
+	if !u.loaded && u.Recurse != nil && u.Recurse.CacheValues {
+		if err = u.Update(); err != nil {
+			return
+		}
+	}
+
+	if u.loaded {
+		value = u.value()
+		return
+	}
+
+	if value, err = u.read(); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Wipe wipes this UnknownItem's value from memory: UnknownItem.Secret is wiped (see SecretBytes.Wipe) and UnknownItem.Value is zeroed
+	(even if it is the slice that was passed to UnknownItem.SetValue) and dropped, so it will be fetched again if needed.
+*/
+func (u *UnknownItem) Wipe() (err error) {
+
+	err = u.Secret.Wipe()
+
+	wipeBytes(u.Value)
+
+	u.Secret = nil
+	u.Value = nil
+	u.loaded = false
+
+	return
+}
+
+// setValue sets an UnknownItem's known value (in UnknownItem.Secret in secure mode, otherwise UnknownItem.Value).
+func (u *UnknownItem) setValue(value []byte) (err error) {
+
+	var secret *SecretBytes
+
+	if u.folder.wallet.wm.secure {
+		if secret, err = u.folder.wallet.wm.newSecret(value); err != nil {
+			return
+		}
+		_ = u.Secret.Wipe()
+		u.Secret = secret
+		u.Value = nil
+	} else {
+		u.Value = value
+	}
+
+	u.loaded = true
+
+	return
+}
+
+// value returns an UnknownItem's known value (from UnknownItem.Secret in secure mode, otherwise UnknownItem.Value).
+func (u *UnknownItem) value() (value []byte) {
+
+	if u.Secret != nil {
+		value = u.Secret.Bytes()
+		return
+	}
+
+	value = u.Value
+
+	return
+}
+
+// read fetches an UnknownItem's value from the Backend.
+func (u *UnknownItem) read() (value []byte, err error) {
 
 	if err = u.folder.wallet.walletCheck(); err != nil {
 		return
 	}
 
-	if call = u.Dbus.Call(
-		DbusWMReadEntry, 0, u.folder.wallet.handle, u.folder.Name, u.Name, u.folder.wallet.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = u.folder.expiryCheck(u.Name); err != nil {
+		return
+	}
+
+	if value, err = u.folder.wallet.wm.Backend().ReadEntry(
+		u.folder.wallet.handle, u.folder.Name, u.Name, u.folder.wallet.wm.AppID,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+	Metadata returns the EntryMetadata of this UnknownItem (see Folder.EntryMetadata).
+	md is nil if no metadata has been recorded for it yet.
+*/
+func (u *UnknownItem) Metadata() (md *EntryMetadata, err error) {
+
+	if md, err = u.folder.EntryMetadata(u.Name); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetNote sets the EntryMetadata.Note of this UnknownItem.
+func (u *UnknownItem) SetNote(note string) (err error) {
+
+	if err = u.folder.SetEntryNote(u.Name, note); err != nil {
+		return
+	}
+
+	return
+}
+
+// SetTags replaces the EntryMetadata.Tags of this UnknownItem.
+func (u *UnknownItem) SetTags(tags ...string) (err error) {
+
+	if err = u.folder.SetEntryTags(u.Name, tags...); err != nil {
+		return
+	}
+
+	return
+}
+
+// Expiry returns when this UnknownItem expires (see Folder.EntryExpiry). expires is zero if it does not expire.
+func (u *UnknownItem) Expiry() (expires time.Time, err error) {
+
+	if expires, err = u.folder.EntryExpiry(u.Name); err != nil {
 		return
 	}
-	if err = call.Store(&v); err != nil {
+
+	return
+}
+
+// SetExpiry sets when this UnknownItem expires (see Folder.SetEntryExpiry). A zero expires removes its expiry.
+func (u *UnknownItem) SetExpiry(expires time.Time) (err error) {
+
+	if err = u.folder.SetEntryExpiry(u.Name, expires); err != nil {
 		return
 	}
 
-	u.Value = v.Value().([]byte)
+	return
+}
+
+/*
+	String returns a representation of a UnknownItem with its value replaced by RedactedValue,
+	unless WalletManager.SetShowValues(true) was called.
+*/
+func (u UnknownItem) String() (str string) {
+
+	var value string = RedactedValue
+
+	if u.wm.ShowValuesEnabled() {
+		value = bytesString(u.value(), true)
+	}
+
+	str = fmt.Sprintf("UnknownItem{Name: %q, Value: %v}", u.Name, value)
+
+	return
+}
+
+// Format prints UnknownItem.String for every verb (see formatRedacted).
+func (u UnknownItem) Format(s fmt.State, verb rune) {
+
+	formatRedacted(s, verb, u.String())
+
+	return
+}
+
+// MarshalJSON encodes a UnknownItem with its value replaced by RedactedValue, unless WalletManager.SetShowValues(true) was called.
+func (u UnknownItem) MarshalJSON() (b []byte, err error) {
+
+	var value interface{} = RedactedValue
+
+	if u.wm.ShowValuesEnabled() {
+		value = u.value()
+	}
+
+	if b, err = json.Marshal(&struct {
+		*jsonUnknownItem
+		Value interface{} `json:"value"`
+	}{
+		jsonUnknownItem: (*jsonUnknownItem)(&u),
+		Value:           value,
+	}); err != nil {
+		return
+	}
 
 	return
 }
@@ -3,7 +3,10 @@ package gokwallet
 import (
 	"bytes"
 	"encoding/binary"
+	"sort"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -67,6 +70,27 @@ func bytemapKeys(variant dbus.Variant) (keyNames []string) {
 // bytesToMap takes a byte slice and returns a map[string]string based on a Dbus QMap struct(ure).
 func bytesToMap(raw []byte) (m map[string]string, numEntries uint32, err error) {
 
+	var bm map[string][]byte
+
+	if bm, numEntries, err = bytesToByteMap(raw); err != nil {
+		return
+	}
+
+	m = make(map[string]string, len(bm))
+
+	for k, v := range bm {
+		m[k] = string(v)
+	}
+
+	return
+}
+
+/*
+	bytesToByteMap is like bytesToMap, but returns the values as byte slices (which, unlike strings, can be wiped).
+	Nothing else refers to the returned byte slices.
+*/
+func bytesToByteMap(raw []byte) (m map[string][]byte, numEntries uint32, err error) {
+
 	var buf *bytes.Reader
 	var kLen uint32
 	var vLen uint32
@@ -90,7 +114,7 @@ func bytesToMap(raw []byte) (m map[string]string, numEntries uint32, err error)
 		return
 	}
 
-	m = make(map[string]string, numEntries)
+	m = make(map[string][]byte, numEntries)
 
 	for i := uint32(0); i < numEntries; i++ {
 		if err = binary.Read(buf, binary.BigEndian, &kLen); err != nil {
@@ -115,11 +139,28 @@ func bytesToMap(raw []byte) (m map[string]string, numEntries uint32, err error)
 
 		// QMap does this infuriating thing where it separates each character with a null byte. So we need to strip them out.
 		k = bytes.ReplaceAll(k, []byte{0x0}, []byte{})
-		v = bytes.ReplaceAll(v, []byte{0x0}, []byte{})
+		m[string(k)] = stripNulls(v)
+	}
+
+	return
+}
 
-		m[string(k)] = string(v)
+// stripNulls removes the null bytes from b in place (wiping the leftover tail) and returns the shortened slice.
+func stripNulls(b []byte) (stripped []byte) {
+
+	var n int
+
+	for _, c := range b {
+		if c != 0x0 {
+			b[n] = c
+			n++
+		}
 	}
 
+	wipeBytes(b[n:])
+
+	stripped = b[:n]
+
 	return
 }
 
@@ -171,3 +212,276 @@ func mapToBytes(m map[string]string) (raw []byte, err error) {
 
 	return
 }
+
+// mapsEqual returns true if maps a and b have the same keys and values (a nil map is equal to an empty one).
+func mapsEqual(a, b map[string]string) (isEqual bool) {
+
+	var ok bool
+	var bv string
+
+	if len(a) != len(b) {
+		return
+	}
+
+	for k, v := range a {
+		if bv, ok = b[k]; !ok || bv != v {
+			return
+		}
+	}
+
+	isEqual = true
+
+	return
+}
+
+// sortedKeys returns the keys of set, sorted.
+func sortedKeys(set map[string]bool) (keys []string) {
+
+	keys = make([]string, 0, len(set))
+
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return
+}
+
+// entrySnapshotKeys returns the sorted union of the keys of a and b.
+func entrySnapshotKeys(a, b map[string]*EntrySnapshot) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(a)+len(b))
+
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// folderSnapshotKeys returns the sorted union of the keys of a and b.
+func folderSnapshotKeys(a, b map[string]*FolderSnapshot) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(a)+len(b))
+
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// walletSnapshotKeys returns the sorted union of the keys of a and b.
+func walletSnapshotKeys(a, b map[string]*WalletSnapshot) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(a)+len(b))
+
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// stringMapKeys returns the sorted union of the keys of a and b.
+func stringMapKeys(a, b map[string]string) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(a)+len(b))
+
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// interfaceMapKeys returns the keys of m, sorted.
+func interfaceMapKeys(m map[string]interface{}) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(m))
+
+	for k := range m {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// walletStateKeys returns the keys of m, sorted.
+func walletStateKeys(m map[string]*WalletStateSpec) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(m))
+
+	for k := range m {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+// folderStateKeys returns the keys of m, sorted.
+func folderStateKeys(m map[string]*FolderStateSpec) (keys []string) {
+
+	var set map[string]bool = make(map[string]bool, len(m))
+
+	for k := range m {
+		set[k] = true
+	}
+
+	keys = sortedKeys(set)
+
+	return
+}
+
+/*
+	stringToQString serializes a string the way QDataStream serializes a QString
+	(a big-endian uint32 byte length followed by UTF-16BE).
+	This is how kwalletd stores a Password's value (as returned by e.g. Backend.ReadEntry).
+*/
+func stringToQString(s string) (raw []byte) {
+
+	var buf *bytes.Buffer
+	var units []uint16
+
+	units = utf16.Encode([]rune(s))
+
+	buf = &bytes.Buffer{}
+
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(units)*2))
+	_ = binary.Write(buf, binary.BigEndian, units)
+
+	raw = buf.Bytes()
+
+	return
+}
+
+// qStringToString performs the inverse of stringToQString.
+func qStringToString(raw []byte) (s string, err error) {
+
+	var bLen uint32
+	var units []uint16
+
+	if len(raw) < 4 {
+		err = ErrBackendBadQString
+		return
+	}
+
+	bLen = binary.BigEndian.Uint32(raw[0:4])
+
+	// A "null" QString.
+	if bLen == 0xffffffff {
+		return
+	}
+
+	if bLen%2 != 0 || uint64(len(raw)-4) < uint64(bLen) {
+		err = ErrBackendBadQString
+		return
+	}
+
+	units = make([]uint16, bLen/2)
+
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(raw[4+(i*2):])
+	}
+
+	s = string(utf16.Decode(units))
+
+	return
+}
+
+/*
+	qStringToBytes is like qStringToString, but returns UTF-8 as a byte slice (which, unlike a string, can be wiped).
+	Nothing else refers to the returned byte slice, and no intermediate copies are left behind.
+*/
+func qStringToBytes(raw []byte) (b []byte, err error) {
+
+	var bLen uint32
+	var units []uint16
+	var runes []rune
+	var n int
+
+	if len(raw) < 4 {
+		err = ErrBackendBadQString
+		return
+	}
+
+	bLen = binary.BigEndian.Uint32(raw[0:4])
+
+	// A "null" QString.
+	if bLen == 0xffffffff {
+		b = make([]byte, 0)
+		return
+	}
+
+	if bLen%2 != 0 || uint64(len(raw)-4) < uint64(bLen) {
+		err = ErrBackendBadQString
+		return
+	}
+
+	units = make([]uint16, bLen/2)
+
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(raw[4+(i*2):])
+	}
+
+	runes = utf16.Decode(units)
+
+	for _, r := range runes {
+		n += utf8.RuneLen(r)
+	}
+
+	b = make([]byte, n)
+	n = 0
+
+	for i, r := range runes {
+		n += utf8.EncodeRune(b[n:], r)
+		runes[i] = 0
+	}
+	for i := range units {
+		units[i] = 0
+	}
+
+	return
+}
+
+// wipeBytes zeroes b.
+func wipeBytes(b []byte) {
+
+	for i := range b {
+		b[i] = 0
+	}
+
+	return
+}
+
+// stringPtr returns a pointer to (a copy of) s.
+func stringPtr(s string) (p *string) {
+
+	p = &s
+
+	return
+}
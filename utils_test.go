@@ -33,6 +33,61 @@ func getTestEnv(t *testing.T) (e *testEnv, err error) {
 	return
 }
 
+// getMemTestEnv is like getTestEnv, but uses a (new) MemoryBackend so that kwalletd is not needed.
+func getMemTestEnv(t *testing.T) (e *testEnv, err error) {
+
+	e = &testEnv{
+		wm: nil,
+		w:  nil,
+		f:  nil,
+		r: &RecurseOpts{
+			Wallets:        true,
+			Folders:        true,
+			AllWalletItems: true,
+		},
+	}
+
+	if e.wm, err = NewWalletManagerBackend(NewMemoryBackend(), e.r, appIdTest); err != nil {
+		t.Errorf("failure when getting WalletManager '%v': %v", appIdTest, err)
+		return
+	}
+
+	if e.w, err = NewWallet(e.wm, walletTest.String(), e.r); err != nil {
+		t.Errorf("failure when getting Wallet '%v:%v': %v", appIdTest, walletTest.String(), err)
+		return
+	}
+
+	if e.f, err = NewFolder(e.w, folderTest.String(), e.r); err != nil {
+		t.Errorf("failure when getting Folder '%v:%v:%v': %v", appIdTest, walletTest.String(), folderTest.String(), err)
+		return
+	}
+
+	return
+}
+
+// populate writes one of each WalletItem type (using the test names/values) to the testEnv's Folder.
+func (e *testEnv) populate(t *testing.T) (err error) {
+
+	if _, err = e.f.WritePassword(passwordTest.String(), testPassword); err != nil {
+		t.Errorf("failed to WritePassword in '%v:%v': %v", e.w.Name, e.f.Name, err)
+		return
+	}
+	if _, err = e.f.WriteMap(mapTest.String(), testMap); err != nil {
+		t.Errorf("failed to WriteMap in '%v:%v': %v", e.w.Name, e.f.Name, err)
+		return
+	}
+	if _, err = e.f.WriteBlob(blobTest.String(), testBytes); err != nil {
+		t.Errorf("failed to WriteBlob in '%v:%v': %v", e.w.Name, e.f.Name, err)
+		return
+	}
+	if _, err = e.f.WriteUnknown(unknownItemTest.String(), testBytes); err != nil {
+		t.Errorf("failed to WriteUnknown in '%v:%v': %v", e.w.Name, e.f.Name, err)
+		return
+	}
+
+	return
+}
+
 // cleanup closes connections and deletes created folders/wallets in a testEnv.
 func (e *testEnv) cleanup(t *testing.T) (err error) {
 
@@ -60,3 +115,181 @@ func (e *testEnv) cleanup(t *testing.T) (err error) {
 
 	return
 }
+
+/*
+	failBackend wraps a Backend, making a (mutating) method fail with ErrOperationFailed
+	once it has been called failAfter[<method name>] times.
+*/
+type failBackend struct {
+	Backend
+	failAfter map[string]int
+	calls     map[string]int
+}
+
+// newFailBackend returns a failBackend wrapping b.
+func newFailBackend(b Backend) (f *failBackend) {
+
+	f = &failBackend{
+		Backend:   b,
+		failAfter: make(map[string]int),
+		calls:     make(map[string]int),
+	}
+
+	return
+}
+
+// check counts a call to method, returning ErrOperationFailed if it should fail.
+func (f *failBackend) check(method string) (err error) {
+
+	var n int
+	var ok bool
+
+	f.calls[method]++
+
+	if n, ok = f.failAfter[method]; ok && f.calls[method] > n {
+		err = ErrOperationFailed
+		return
+	}
+
+	return
+}
+
+// CreateFolder wraps Backend.CreateFolder.
+func (f *failBackend) CreateFolder(handle int32, folderName, appID string) (err error) {
+
+	if err = f.check("CreateFolder"); err != nil {
+		return
+	}
+
+	err = f.Backend.CreateFolder(handle, folderName, appID)
+
+	return
+}
+
+// RemoveFolder wraps Backend.RemoveFolder.
+func (f *failBackend) RemoveFolder(handle int32, folderName, appID string) (err error) {
+
+	if err = f.check("RemoveFolder"); err != nil {
+		return
+	}
+
+	err = f.Backend.RemoveFolder(handle, folderName, appID)
+
+	return
+}
+
+// WriteEntry wraps Backend.WriteEntry.
+func (f *failBackend) WriteEntry(handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string) (err error) {
+
+	if err = f.check("WriteEntry"); err != nil {
+		return
+	}
+
+	err = f.Backend.WriteEntry(handle, folderName, entryName, entryType, value, appID)
+
+	return
+}
+
+// RemoveEntry wraps Backend.RemoveEntry.
+func (f *failBackend) RemoveEntry(handle int32, folderName, entryName, appID string) (err error) {
+
+	if err = f.check("RemoveEntry"); err != nil {
+		return
+	}
+
+	err = f.Backend.RemoveEntry(handle, folderName, entryName, appID)
+
+	return
+}
+
+// RenameEntry wraps Backend.RenameEntry.
+func (f *failBackend) RenameEntry(handle int32, folderName, entryName, newEntryName, appID string) (err error) {
+
+	if err = f.check("RenameEntry"); err != nil {
+		return
+	}
+
+	err = f.Backend.RenameEntry(handle, folderName, entryName, newEntryName, appID)
+
+	return
+}
+
+// WritePassword wraps Backend.WritePassword.
+func (f *failBackend) WritePassword(handle int32, folderName, entryName, value, appID string) (err error) {
+
+	if err = f.check("WritePassword"); err != nil {
+		return
+	}
+
+	err = f.Backend.WritePassword(handle, folderName, entryName, value, appID)
+
+	return
+}
+
+// WriteMap wraps Backend.WriteMap.
+func (f *failBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	if err = f.check("WriteMap"); err != nil {
+		return
+	}
+
+	err = f.Backend.WriteMap(handle, folderName, entryName, value, appID)
+
+	return
+}
+
+/*
+	onceFailBackend wraps a Backend, making only the call to WriteEntry after the first failAfter calls
+	fail with ErrOperationFailed. Calls after the failed one succeed again (e.g. for rollbacks).
+*/
+type onceFailBackend struct {
+	Backend
+	failAfter int
+	calls     int
+}
+
+// WriteEntry wraps Backend.WriteEntry.
+func (o *onceFailBackend) WriteEntry(handle int32, folderName, entryName string, entryType kwalletdEnumType, value []byte, appID string) (err error) {
+
+	o.calls++
+
+	if o.calls == o.failAfter+1 {
+		err = ErrOperationFailed
+		return
+	}
+
+	err = o.Backend.WriteEntry(handle, folderName, entryName, entryType, value, appID)
+
+	return
+}
+
+// folderListFailBackend wraps a Backend, making every call to FolderList fail with ErrOperationFailed.
+type folderListFailBackend struct {
+	Backend
+}
+
+// FolderList always returns ErrOperationFailed.
+func (f *folderListFailBackend) FolderList(handle int32, appID string) (folderNames []string, err error) {
+
+	err = ErrOperationFailed
+
+	return
+}
+
+// metadataFailBackend wraps a Backend, making every write to MetadataFolder fail with ErrOperationFailed.
+type metadataFailBackend struct {
+	Backend
+}
+
+// WriteMap wraps Backend.WriteMap.
+func (m *metadataFailBackend) WriteMap(handle int32, folderName, entryName string, value map[string]string, appID string) (err error) {
+
+	if folderName == MetadataFolder {
+		err = ErrOperationFailed
+		return
+	}
+
+	err = m.Backend.WriteMap(handle, folderName, entryName, value, appID)
+
+	return
+}
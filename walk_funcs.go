@@ -0,0 +1,191 @@
+package gokwallet
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Walk visits every Wallet of a WalletManager (in name order) and, in turn, each of their Folders and WalletItems,
+	much like filepath.WalkDir: fn is called for a Wallet, then for each of its Folders, each followed by its WalletItems.
+	Only names are listed; nothing is kept between calls and values are only fetched if fn calls WalkEntry.Item,
+	so memory use does not grow with the size of the Wallets.
+
+	fn may return SkipFolder or SkipWallet to prune the walk; any other non-nil error stops the walk and is returned.
+	Reserved Folders (see ReservedFolderPrefix) are not visited.
+*/
+func (wm *WalletManager) Walk(fn WalkFunc) (err error) {
+
+	var opened bool
+	var walletNames []string
+
+	if !wm.isInit {
+		err = ErrInitWM
+		return
+	}
+
+	if walletNames, err = wm.WalletNames(); err != nil {
+		return
+	}
+	sort.Strings(walletNames)
+
+	for _, wn := range walletNames {
+		opened = false
+		if err = wm.withWallet(wn, func(w *Wallet) (err error) {
+			opened = true
+			err = w.Walk(fn)
+			return
+		}); err != nil {
+			if opened {
+				return
+			}
+			// The Wallet couldn't be opened, so fn gets to decide whether that stops the walk.
+			err = fn(&WalkEntry{Kind: WalkKindWallet, Path: &ItemPath{Wallet: wn}, Type: KwalletdEnumTypeUnused}, err)
+			if err = walkSkip(err, SkipWallet, SkipFolder); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// Walk is like WalletManager.Walk, but only walks Wallet w.
+func (w *Wallet) Walk(fn WalkFunc) (err error) {
+
+	var folderNames []string
+	var entry *WalkEntry = &WalkEntry{
+		Kind:   WalkKindWallet,
+		Path:   &ItemPath{Wallet: w.Name},
+		Type:   KwalletdEnumTypeUnused,
+		wallet: w,
+	}
+
+	if err = w.walletCheck(); err != nil {
+		err = walkSkip(fn(entry, err), SkipWallet, SkipFolder)
+		return
+	}
+
+	if err = fn(entry, nil); err != nil {
+		err = walkSkip(err, SkipWallet, SkipFolder)
+		return
+	}
+
+	if folderNames, err = w.listUserFolders(); err != nil {
+		err = walkSkip(fn(entry, err), SkipWallet, SkipFolder)
+		return
+	}
+	sort.Strings(folderNames)
+
+	for _, folderName := range folderNames {
+		if err = w.walkFolder(folderName, fn); err != nil {
+			err = walkSkip(err, SkipWallet)
+			return
+		}
+	}
+
+	return
+}
+
+/*
+	Item returns the WalletItem of a WalkKindItem WalkEntry (a *Password, *Map, *Blob, or *UnknownItem),
+	fetching its value. ErrWalkKind is returned for other kinds.
+*/
+func (e *WalkEntry) Item() (item WalletItem, err error) {
+
+	if e.Kind != WalkKindItem {
+		err = fmt.Errorf("%w: %v is a %v", ErrWalkKind, e.Path.String(), e.Kind)
+		return
+	}
+
+	if item, err = newPathItem(e.folder, e.Path.Entry); err != nil {
+		return
+	}
+
+	return
+}
+
+// Wallet returns the Wallet a WalkEntry is (or is in).
+func (e *WalkEntry) Wallet() (w *Wallet) {
+
+	w = e.wallet
+
+	return
+}
+
+// Folder returns the Folder a WalkEntry is (or is in); it is nil for a WalkKindWallet WalkEntry or a Folder that could not be opened.
+func (e *WalkEntry) Folder() (f *Folder) {
+
+	f = e.folder
+
+	return
+}
+
+// walkFolder walks Folder folderName of Wallet w (see WalletManager.Walk).
+func (w *Wallet) walkFolder(folderName string, fn WalkFunc) (err error) {
+
+	var f *Folder
+	var entryNames []string
+	var types map[string]kwalletdEnumType
+	var entry *WalkEntry
+
+	entry = &WalkEntry{
+		Kind:   WalkKindFolder,
+		Path:   &ItemPath{Wallet: w.Name, Folder: folderName},
+		Type:   KwalletdEnumTypeUnused,
+		wallet: w,
+	}
+
+	// As with a Wallet that can't be opened, fn decides whether a Folder that can't be read ends the walk.
+	if f, err = NewFolder(w, folderName, &RecurseOpts{}); err != nil {
+		err = walkSkip(fn(entry, err), SkipFolder)
+		return
+	}
+	entry.folder = f
+
+	if err = fn(entry, nil); err != nil {
+		err = walkSkip(err, SkipFolder)
+		return
+	}
+
+	if entryNames, types, err = w.listEntryTypes(folderName); err != nil {
+		err = walkSkip(fn(entry, err), SkipFolder)
+		return
+	}
+
+	for _, en := range entryNames {
+		entry = &WalkEntry{
+			Kind:   WalkKindItem,
+			Path:   &ItemPath{Wallet: w.Name, Folder: folderName, Entry: en},
+			wallet: w,
+			folder: f,
+		}
+		if entry.Type, err = w.entryType(folderName, en, types); err != nil {
+			entry.Type = KwalletdEnumTypeUnknown
+			err = fn(entry, err)
+		} else {
+			err = fn(entry, nil)
+		}
+		if err != nil {
+			err = walkSkip(err, SkipFolder)
+			return
+		}
+	}
+
+	return
+}
+
+// walkSkip returns nil if err is one of skips (i.e. the relevant part of a walk is done), or err otherwise.
+func walkSkip(err error, skips ...error) (walkErr error) {
+
+	walkErr = err
+
+	for _, s := range skips {
+		if err == s {
+			walkErr = nil
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,117 @@
+package gokwallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestWalk tests WalletManager.Walk and Wallet.Walk.
+func TestWalk(t *testing.T) {
+
+	var err error
+	var e *testEnv
+	var visited []string
+	var fetched int
+	var item WalletItem
+	var mem *MemoryBackend
+	var handles int
+	var errStop error = errors.New("stop")
+
+	if e, err = getMemTestEnv(t); err != nil {
+		t.Fatalf("failure getting test env: %v", err)
+	}
+	if err = e.populate(t); err != nil {
+		t.Fatalf("failed to populate: %v", err)
+	}
+	for _, uri := range []string{
+		"kwallet://a/Skipped/x", "kwallet://a/Visited/y", "kwallet://b/F/z", "kwallet://c/F/z",
+	} {
+		if _, err = e.wm.Put(uri, "v"); err != nil {
+			t.Fatalf("failed to Put %v: %v", uri, err)
+		}
+	}
+	if err = e.wm.EnableMetadata(); err != nil {
+		t.Fatalf("failed to EnableMetadata: %v", err)
+	}
+	if _, err = e.wm.Put("kwallet://a/Visited/y", "v2"); err != nil {
+		t.Fatalf("failed to Put: %v", err)
+	}
+
+	if err = e.wm.Walk(func(entry *WalkEntry, err error) (walkErr error) {
+		if err != nil {
+			return err
+		}
+		if entry.Path.Wallet != "a" && entry.Path.Wallet != "b" && entry.Path.Wallet != "c" {
+			return SkipWallet
+		}
+		visited = append(visited, string(entry.Kind)+":"+entry.Path.String())
+		switch {
+		case entry.Kind == WalkKindFolder && entry.Path.Folder == "Skipped":
+			walkErr = SkipFolder
+		case entry.Kind == WalkKindWallet && entry.Path.Wallet == "b":
+			walkErr = SkipWallet
+		case entry.Kind == WalkKindItem && entry.Path.Wallet == "c":
+			if _, walkErr = entry.Folder().HasEntry(entry.Path.Entry); walkErr == nil {
+				walkErr = errStop
+			}
+		case entry.Kind != WalkKindItem:
+			if _, walkErr = entry.Item(); !errors.Is(walkErr, ErrWalkKind) {
+				t.Errorf("expected ErrWalkKind for a %v, got %v", entry.Kind, walkErr)
+			}
+			walkErr = nil
+		}
+		return
+	}); err != errStop {
+		t.Errorf("expected the WalkFunc's error, got %v", err)
+	}
+
+	if len(visited) != 8 ||
+		visited[0] != "wallet:kwallet://a//" ||
+		visited[1] != "folder:kwallet://a/Skipped/" ||
+		visited[2] != "folder:kwallet://a/Visited/" ||
+		visited[3] != "item:kwallet://a/Visited/y" ||
+		visited[4] != "wallet:kwallet://b//" ||
+		visited[5] != "wallet:kwallet://c//" ||
+		visited[6] != "folder:kwallet://c/F/" ||
+		visited[7] != "item:kwallet://c/F/z" {
+		t.Errorf("unexpected walk: %#v", visited)
+	}
+
+	visited = nil
+	if err = e.w.Walk(func(entry *WalkEntry, err error) (walkErr error) {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, entry.Path.String())
+		if entry.Kind == WalkKindItem && entry.Type == KwalletdEnumTypePassword {
+			if item, walkErr = entry.Item(); walkErr != nil {
+				return
+			}
+			if item.(*Password).Value != testPassword {
+				t.Errorf("unexpected Password value from WalkEntry.Item")
+			}
+			fetched++
+		}
+		return
+	}); err != nil {
+		t.Errorf("failed to Walk Wallet: %v", err)
+	}
+	if fetched != 1 || len(visited) != 6 {
+		t.Errorf("unexpected Wallet.Walk (%d Passwords fetched): %#v", fetched, visited)
+	}
+
+	// The Wallets opened for a walk are closed again, even if it is stopped early.
+	mem = baseBackend(e.wm.backend).(*MemoryBackend)
+	handles = len(mem.handles)
+	if err = e.wm.Walk(func(entry *WalkEntry, err error) (walkErr error) {
+		if entry.Path.Wallet == "b" {
+			walkErr = errStop
+		}
+		return
+	}); err != errStop {
+		t.Errorf("expected errStop from Walk, got %v", err)
+	}
+	if len(mem.handles) != handles {
+		t.Errorf("Walk leaked Wallet handles: %d before, %d after", handles, len(mem.handles))
+	}
+}
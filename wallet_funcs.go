@@ -1,7 +1,7 @@
 package gokwallet
 
 import (
-	"github.com/godbus/dbus/v5"
+	"sort"
 )
 
 /*
@@ -11,11 +11,18 @@ import (
 */
 func NewWallet(wm *WalletManager, name string, recursion *RecurseOpts) (wallet *Wallet, err error) {
 
+	var fileBackend *FileBackend
+	var ok bool
+
 	if !wm.isInit {
 		err = ErrInitWM
 		return
 	}
 
+	if err = recursion.checkFilters(); err != nil {
+		return
+	}
+
 	wallet = &Wallet{
 		DbusObject: wm.DbusObject,
 		Name:       name,
@@ -26,6 +33,10 @@ func NewWallet(wm *WalletManager, name string, recursion *RecurseOpts) (wallet *
 		isInit: false,
 	}
 
+	if fileBackend, ok = baseBackend(wm.Backend()).(*FileBackend); ok {
+		wallet.FilePath = fileBackend.WalletPath(name)
+	}
+
 	wallet.isInit = true
 
 	// TODO: remove this and leave to caller, since it might use PamOpen instead? Fail back to it?
@@ -50,26 +61,15 @@ func NewWallet(wm *WalletManager, name string, recursion *RecurseOpts) (wallet *
 */
 func (w *Wallet) Disconnect() (err error) {
 
-	var call *dbus.Call
-	var ok bool
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMDisconnectApp, 0, w.Name, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&ok); err != nil {
+	if err = w.wm.Backend().DisconnectApplication(w.Name, w.wm.AppID); err != nil {
 		return
 	}
 
-	if !ok {
-		err = ErrNoDisconnect
-	}
+	w.hasHandle = false
 
 	return
 }
@@ -77,27 +77,14 @@ func (w *Wallet) Disconnect() (err error) {
 // DisconnectApplication disconnects this Wallet from a specified WalletManager/application (see Wallet.Connections).
 func (w *Wallet) DisconnectApplication(appName string) (err error) {
 
-	var call *dbus.Call
-	var ok bool
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMDisconnectApp, 0, w.Name, appName,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&ok); err != nil {
+	if err = w.wm.Backend().DisconnectApplication(w.Name, appName); err != nil {
 		return
 	}
 
-	if !ok {
-		err = ErrNoDisconnect
-	}
-
 	return
 }
 
@@ -108,16 +95,11 @@ func (w *Wallet) DisconnectApplication(appName string) (err error) {
 */
 func (w *Wallet) ChangePassword() (err error) {
 
-	var call *dbus.Call
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMChangePassword, 0, w.Name, DefaultWindowID, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if err = w.wm.Backend().ChangePassword(w.Name, w.wm.AppID); err != nil {
 		return
 	}
 
@@ -127,25 +109,16 @@ func (w *Wallet) ChangePassword() (err error) {
 // Close closes a Wallet.
 func (w *Wallet) Close() (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
 	// Using a handler allows us to close access for this particular parent WalletManager.
-	if call = w.Dbus.Call(
-		DbusWMClose, 0, w.handle, false, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
+	if err = w.wm.Backend().Close(w.handle, false, w.wm.AppID); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
+	w.hasHandle = false
 
 	return
 }
@@ -153,19 +126,11 @@ func (w *Wallet) Close() (err error) {
 // Connections lists the application names for connections to ("users of") this Wallet.
 func (w *Wallet) Connections() (connList []string, err error) {
 
-	var call *dbus.Call
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMUsers, 0, w.Name,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&connList); err != nil {
+	if connList, err = w.wm.Backend().Users(w.Name); err != nil {
 		return
 	}
 
@@ -178,51 +143,25 @@ func (w *Wallet) Connections() (connList []string, err error) {
 */
 func (w *Wallet) CreateFolder(name string) (err error) {
 
-	var call *dbus.Call
-	var ok bool
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMCreateFolder, 0, w.handle, name, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&ok); err != nil {
+	if err = w.wm.Backend().CreateFolder(w.handle, name, w.wm.AppID); err != nil {
 		return
 	}
 
-	if !ok {
-		err = ErrNoCreate
-	}
-
 	return
 }
 
 // Delete deletes a Wallet.
 func (w *Wallet) Delete() (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMDeleteWallet, 0, w.Name,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
-		return
-	}
-
-	err = resultCheck(rslt)
+	err = w.wm.Backend().DeleteWallet(w.Name)
 
 	w = nil
 
@@ -235,18 +174,11 @@ func (w *Wallet) Delete() (err error) {
 */
 func (w *Wallet) FolderExists(folderName string) (exists bool, err error) {
 
-	var call *dbus.Call
 	var notExists bool
 
 	// We don't need a walletcheck here since we don't need a handle.
 
-	if call = w.Dbus.Call(
-		DbusWMFolderNotExist, 0, w.Name, folderName,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&notExists); err != nil {
+	if notExists, err = w.wm.Backend().FolderDoesNotExist(w.Name, folderName); err != nil {
 		return
 	}
 
@@ -261,25 +193,16 @@ func (w *Wallet) FolderExists(folderName string) (exists bool, err error) {
 */
 func (w *Wallet) ForceClose() (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
 	// Using a handler allows us to close access for this particular parent WalletManager.
-	if call = w.Dbus.Call(
-		DbusWMClose, 0, w.handle, true, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
+	if err = w.wm.Backend().Close(w.handle, true, w.wm.AppID); err != nil {
 		return
 	}
 
-	err = resultCheck(rslt)
+	w.hasHandle = false
 
 	return
 }
@@ -287,19 +210,11 @@ func (w *Wallet) ForceClose() (err error) {
 // HasFolder indicates if a Wallet has a Folder in it named folderName.
 func (w *Wallet) HasFolder(folderName string) (hasFolder bool, err error) {
 
-	var call *dbus.Call
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMHasFolder, 0, w.handle, folderName, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&hasFolder); err != nil {
+	if hasFolder, err = w.wm.Backend().HasFolder(w.handle, folderName, w.wm.AppID); err != nil {
 		return
 	}
 
@@ -309,8 +224,6 @@ func (w *Wallet) HasFolder(folderName string) (hasFolder bool, err error) {
 // IsOpen returns whether a Wallet is open ("unlocked") or not (as well as updates Wallet.IsOpen).
 func (w *Wallet) IsOpen() (isOpen bool, err error) {
 
-	var call *dbus.Call
-
 	// We don't call walletcheck here because this method is called by a walletcheck.
 	if !w.isInit {
 		err = ErrInitWallet
@@ -318,13 +231,7 @@ func (w *Wallet) IsOpen() (isOpen bool, err error) {
 	}
 
 	// We can call the same method with w.handle instead of w.Name. We don't have a handler yet though.
-	if call = w.Dbus.Call(
-		DbusWMIsOpen, 0, w.Name,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&w.IsUnlocked); err != nil {
+	if w.IsUnlocked, err = w.wm.Backend().IsOpen(w.Name); err != nil {
 		return
 	}
 
@@ -333,23 +240,29 @@ func (w *Wallet) IsOpen() (isOpen bool, err error) {
 	return
 }
 
-// ListFolders lists all Folder names in a Wallet.
+/*
+	ListFolders lists all Folder names in a Wallet.
+	The reserved Folders of bookkeeping features enabled on the WalletManager (e.g. MetadataFolder if
+	WalletManager.EnableMetadata has been called) are not included; otherwise, every Folder is listed.
+*/
 func (w *Wallet) ListFolders() (folderList []string, err error) {
 
-	var call *dbus.Call
+	var allFolders []string
 
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMFolderList, 0, w.handle, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
+	if allFolders, err = w.wm.Backend().FolderList(w.handle, w.wm.AppID); err != nil {
 		return
 	}
-	if err = call.Store(&folderList); err != nil {
-		return
+
+	folderList = make([]string, 0, len(allFolders))
+	for _, fn := range allFolders {
+		if isTrackedFolder(w.wm.Backend(), fn) {
+			continue
+		}
+		folderList = append(folderList, fn)
 	}
 
 	return
@@ -361,8 +274,7 @@ func (w *Wallet) ListFolders() (folderList []string, err error) {
 */
 func (w *Wallet) Open() (err error) {
 
-	var call *dbus.Call
-	var handler *int32 = new(int32)
+	var handler int32
 
 	// We don't call walletcheck here because this method is called by a walletcheck.
 	if !w.isInit {
@@ -370,25 +282,16 @@ func (w *Wallet) Open() (err error) {
 		return
 	}
 
-	if !w.IsUnlocked || !w.hasHandle {
-		if call = w.Dbus.Call(
-			DbusWMOpen, 0, w.Name, DefaultWindowID, w.wm.AppID,
-		); call.Err != nil {
-			err = call.Err
-			return
-		}
-		if err = call.Store(handler); err != nil {
-			return
-		}
+	if w.IsUnlocked && w.hasHandle {
+		return
 	}
 
-	if handler == nil {
-		err = ErrDbusOpfailNoHandle
+	if handler, err = w.wm.Backend().Open(w.Name, w.wm.AppID); err != nil {
 		return
-	} else {
-		w.handle = *handler
 	}
 
+	w.handle = handler
+
 	w.hasHandle = true
 	w.IsUnlocked = true
 
@@ -398,38 +301,27 @@ func (w *Wallet) Open() (err error) {
 /*
 	RemoveFolder removes a Folder folderName from a Wallet.
 	Note that this will also remove all WalletItems in the given Folder.
+	If history is retained for the Folder (see WalletManager.EnableHistory), the history of each WalletItem
+	is replaced by a single HistoryVersion of its last value; use Folder.ClearHistory to remove that too.
 */
 func (w *Wallet) RemoveFolder(folderName string) (err error) {
 
-	var call *dbus.Call
-	var success bool
-
 	if err = w.walletCheck(); err != nil {
 		return
 	}
 
-	if call = w.Dbus.Call(
-		DbusWMRemoveFolder, 0, w.handle, folderName, w.wm.AppID,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&success); err != nil {
-		return
-	}
-
-	if !success {
-		err = ErrDbusOpfailRemoveFolder
+	if err = w.wm.Backend().RemoveFolder(w.handle, folderName, w.wm.AppID); err != nil {
 		return
 	}
 
 	return
 }
 
-// Update fetches/updates all Folder objects in a Wallet.
+// Update fetches/updates all Folder objects in a Wallet (filtered by RecurseOpts.IncludeFolders and RecurseOpts.ExcludeFolders).
 func (w *Wallet) Update() (err error) {
 
 	var folderNames []string
+	var wanted bool
 	var errs []error = make([]error, 0)
 
 	if err = w.walletCheck(); err != nil {
@@ -443,6 +335,11 @@ func (w *Wallet) Update() (err error) {
 	w.Folders = make(map[string]*Folder)
 
 	for _, fn := range folderNames {
+		if wanted, err = w.Recurse.wantFolder(fn); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
 		if w.Folders[fn], err = NewFolder(w, fn, w.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -458,6 +355,25 @@ func (w *Wallet) Update() (err error) {
 	return
 }
 
+// Wipe wipes the values of every WalletItem held by the Folders of a Wallet from memory (see Folder.Wipe).
+func (w *Wallet) Wipe() (err error) {
+
+	var errs []error = make([]error, 0)
+
+	for _, f := range w.Folders {
+		if err = f.Wipe(); err != nil {
+			errs = append(errs, err)
+			err = nil
+		}
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
+	}
+
+	return
+}
+
 // walletCheck will check if a Wallet is (initialized and) opened and, if not, attempt to open it.
 func (w *Wallet) walletCheck() (err error) {
 
@@ -478,3 +394,75 @@ func (w *Wallet) walletCheck() (err error) {
 
 	return
 }
+
+/*
+	listEntryTypes returns the (sorted) names of all WalletItems in Folder folderName, along with the types of those that
+	can be told from the Backend's name listings alone (Passwords and Maps). See Wallet.entryType for the rest.
+*/
+func (w *Wallet) listEntryTypes(folderName string) (entryNames []string, types map[string]kwalletdEnumType, err error) {
+
+	var passwords []string
+	var maps []string
+
+	if entryNames, err = w.wm.Backend().EntryList(w.handle, folderName, w.wm.AppID); err != nil {
+		return
+	}
+	if passwords, err = w.wm.Backend().PasswordList(w.handle, folderName, w.wm.AppID); err != nil {
+		return
+	}
+	if maps, err = w.wm.Backend().MapList(w.handle, folderName, w.wm.AppID); err != nil {
+		return
+	}
+	sort.Strings(entryNames)
+
+	types = make(map[string]kwalletdEnumType, len(passwords)+len(maps))
+	for _, en := range passwords {
+		types[en] = KwalletdEnumTypePassword
+	}
+	for _, en := range maps {
+		types[en] = KwalletdEnumTypeMap
+	}
+
+	return
+}
+
+// entryType returns the type of WalletItem entryName in Folder folderName, using types (see Wallet.listEntryTypes) if possible.
+func (w *Wallet) entryType(folderName, entryName string, types map[string]kwalletdEnumType) (entryType kwalletdEnumType, err error) {
+
+	var ok bool
+
+	// Only Blobs and UnknownItems need a call to tell apart.
+	if entryType, ok = types[entryName]; ok {
+		return
+	}
+
+	if entryType, err = w.wm.Backend().EntryType(w.handle, folderName, entryName, w.wm.AppID); err != nil {
+		return
+	}
+
+	return
+}
+
+// listUserFolders lists the Folder names in a Wallet, never including reserved Folders (see ReservedFolderPrefix).
+func (w *Wallet) listUserFolders() (folderList []string, err error) {
+
+	var allFolders []string
+
+	if err = w.walletCheck(); err != nil {
+		return
+	}
+
+	if allFolders, err = w.wm.Backend().FolderList(w.handle, w.wm.AppID); err != nil {
+		return
+	}
+
+	folderList = make([]string, 0, len(allFolders))
+	for _, fn := range allFolders {
+		if isReservedFolder(fn) {
+			continue
+		}
+		folderList = append(folderList, fn)
+	}
+
+	return
+}
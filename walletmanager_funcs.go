@@ -1,9 +1,5 @@
 package gokwallet
 
-import (
-	"github.com/godbus/dbus/v5"
-)
-
 /*
 	NewWalletManager returns a WalletManager. It requires a RecurseOpts
 	(you can use DefaultRecurseOpts, call NewRecurseOpts, or provide your own RecurseOpts struct).
@@ -13,6 +9,7 @@ import (
 func NewWalletManager(recursion *RecurseOpts, appID ...string) (wm *WalletManager, err error) {
 
 	var realAppID string
+	var backend *DbusBackend
 
 	if appID != nil && len(appID) > 0 {
 		realAppID = appID[0]
@@ -20,7 +17,11 @@ func NewWalletManager(recursion *RecurseOpts, appID ...string) (wm *WalletManage
 		realAppID = DefaultAppID
 	}
 
-	if wm, err = newWM(realAppID, recursion); err != nil {
+	if backend, err = NewDbusBackend(); err != nil {
+		return
+	}
+
+	if wm, err = newWM(backend, realAppID, recursion); err != nil {
 		return
 	}
 
@@ -28,61 +29,91 @@ func NewWalletManager(recursion *RecurseOpts, appID ...string) (wm *WalletManage
 }
 
 /*
-	NewWalletManagerFiles returns a WalletManager from one or more filePaths (*.kwl, *.tar, or *.xml exports).
-	Note that if the wallet file was created via an "encrypted export", it will be a .kwl file
-	inside a .tar.
-	err will contain a MultiError if any filepaths specified do not exist or cannot be opened.
+	NewWalletManagerFiles returns a WalletManager backed by a FileBackend for the wallet files in directory walletDir
+	(see FileBackend for details on the file format).
 	It requires a RecurseOpts (you can use DefaultRecurseOpts, call NewRecurseOpts,
 	or provide your own RecurseOpts struct).
 	If appId is empty, DefaultAppID will be used as the app ID.
 */
-/* TODO: POC this before exposing. I have NO idea if/how it'll work.
-func NewWalletManagerFiles(recursion *RecurseOpts, appId string, filePaths ...string) (wm *WalletManager, err error) {
+func NewWalletManagerFiles(recursion *RecurseOpts, appId string, walletDir string) (wm *WalletManager, err error) {
 
-	var exist bool
-	var errs []error = make([]error, 0)
-	var realFilePaths []string = make([]string, 0)
+	var backend *FileBackend
 
 	if appId == "" {
 		appId = DefaultAppID
 	}
 
-	for _, f := range filePaths {
-		if f == "" {
-			continue
-		}
-		if exist, err = paths.RealPathExists(&f); err != nil {
-			errs = append(errs, err)
-			err = nil
-			continue
-		}
-		if !exist {
-			err = errors.New(fmt.Sprintf("%v does not exist", f))
-			err = nil
-			continue
-		}
-		realFilePaths = append(realFilePaths, f)
+	if backend, err = NewFileBackend(walletDir); err != nil {
+		return
 	}
 
-	// TODO: do the actual newWM here.
+	if wm, err = newWM(backend, appId, recursion); err != nil {
+		return
+	}
 
-	if errs != nil && len(errs) > 0 {
-		err = NewErrors(errs...)
+	return
+}
+
+/*
+	NewWalletManagerBackend returns a WalletManager that uses the given Backend instead of Dbus.
+	It requires a RecurseOpts (you can use DefaultRecurseOpts, call NewRecurseOpts,
+	or provide your own RecurseOpts struct).
+	If appId is empty/nil, DefaultAppID will be used as the app ID.
+	If appId is specified, only the first string is used.
+*/
+func NewWalletManagerBackend(backend Backend, recursion *RecurseOpts, appID ...string) (wm *WalletManager, err error) {
+
+	var realAppID string
+
+	if appID != nil && len(appID) > 0 {
+		realAppID = appID[0]
+	} else {
+		realAppID = DefaultAppID
+	}
+
+	if wm, err = newWM(backend, realAppID, recursion); err != nil {
+		return
 	}
 
 	return
 }
+
+/*
+	Backend returns the Backend this WalletManager operates through.
+	If gokwallet bookkeeping such as EntryMetadata is enabled (see WalletManager.EnableMetadata),
+	this is a wrapper around the Backend the WalletManager was created with that maintains it.
 */
+func (wm *WalletManager) Backend() (backend Backend) {
+
+	wm.backendLock.RLock()
+	backend = wm.backend
+	wm.backendLock.RUnlock()
+
+	return
+}
 
 /*
-	Close closes the Dbus connection.
+	Close closes the Dbus connection (or releases the Backend), after wiping every WalletItem value it loaded (see WalletManager.Wipe).
 	This does NOT close wallets; use WalletManager.CloseWallet, WalletManager.ForceCloseWallet, or
 	WalletManager.CloseAllWallets instead for that.
 */
 func (wm *WalletManager) Close() (err error) {
 
-	if err = wm.Conn.Close(); err != nil {
-		return
+	var errs []error = make([]error, 0)
+
+	// Wiping everything that was loaded must not prevent releasing the Backend (or vice versa).
+	if err = wm.Wipe(); err != nil {
+		errs = append(errs, err)
+		err = nil
+	}
+
+	if err = wm.Backend().Release(); err != nil {
+		errs = append(errs, err)
+		err = nil
+	}
+
+	if errs != nil && len(errs) > 0 {
+		err = NewErrors(errs...)
 	}
 
 	return
@@ -95,26 +126,12 @@ func (wm *WalletManager) Close() (err error) {
 */
 func (wm *WalletManager) CloseWallet(walletName string) (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if !wm.isInit {
 		err = ErrInitWM
 		return
 	}
 
-	// Using a handler allows us to close access for this particular parent WalletManager.
-	if call = wm.Dbus.Call(
-		DbusWMClose, 0, walletName, false,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
-		return
-	}
-
-	err = resultCheck(rslt)
+	err = wm.Backend().CloseWallet(walletName, false)
 
 	return
 }
@@ -126,26 +143,12 @@ func (wm *WalletManager) CloseWallet(walletName string) (err error) {
 */
 func (wm *WalletManager) ForceCloseWallet(walletName string) (err error) {
 
-	var call *dbus.Call
-	var rslt int32
-
 	if !wm.isInit {
 		err = ErrInitWM
 		return
 	}
 
-	// Using a handler allows us to close access for this particular parent WalletManager.
-	if call = wm.Dbus.Call(
-		DbusWMClose, 0, walletName, false,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&rslt); err != nil {
-		return
-	}
-
-	err = resultCheck(rslt)
+	err = wm.Backend().CloseWallet(walletName, true)
 
 	return
 }
@@ -156,17 +159,12 @@ func (wm *WalletManager) ForceCloseWallet(walletName string) (err error) {
 */
 func (wm *WalletManager) CloseAllWallets() (err error) {
 
-	var call *dbus.Call
-
 	if !wm.isInit {
 		err = ErrInitWM
 		return
 	}
 
-	if call = wm.Dbus.Call(
-		DbusWMCloseAllWallets, 0,
-	); call.Err != nil {
-		err = call.Err
+	if err = wm.Backend().CloseAllWallets(); err != nil {
 		return
 	}
 
@@ -176,20 +174,12 @@ func (wm *WalletManager) CloseAllWallets() (err error) {
 // IsEnabled returns whether KWallet is enabled or not (and also updates WalletManager.Enabled).
 func (wm *WalletManager) IsEnabled() (enabled bool, err error) {
 
-	var call *dbus.Call
-
 	if !wm.isInit {
 		err = ErrInitWM
 		return
 	}
 
-	if call = wm.Dbus.Call(
-		DbusWMIsEnabled, 0,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&wm.Enabled); err != nil {
+	if wm.Enabled, err = wm.Backend().IsEnabled(); err != nil {
 		return
 	}
 
@@ -201,7 +191,6 @@ func (wm *WalletManager) IsEnabled() (enabled bool, err error) {
 // LocalWallet returns the "local" wallet (and updates WalletManager.Local).
 func (wm *WalletManager) LocalWallet() (w *Wallet, err error) {
 
-	var call *dbus.Call
 	var wn string
 
 	if !wm.isInit {
@@ -209,13 +198,7 @@ func (wm *WalletManager) LocalWallet() (w *Wallet, err error) {
 		return
 	}
 
-	if call = wm.Dbus.Call(
-		DbusWMLocalWallet, 0,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&wn); err != nil {
+	if wn, err = wm.Backend().LocalWallet(); err != nil {
 		return
 	}
 
@@ -231,7 +214,6 @@ func (wm *WalletManager) LocalWallet() (w *Wallet, err error) {
 // NetworkWallet returns the "network" wallet (and updates WalletManager.Network).
 func (wm *WalletManager) NetworkWallet() (w *Wallet, err error) {
 
-	var call *dbus.Call
 	var wn string
 
 	if !wm.isInit {
@@ -239,13 +221,7 @@ func (wm *WalletManager) NetworkWallet() (w *Wallet, err error) {
 		return
 	}
 
-	if call = wm.Dbus.Call(
-		DbusWMNetWallet, 0,
-	); call.Err != nil {
-		err = call.Err
-		return
-	}
-	if err = call.Store(&wn); err != nil {
+	if wn, err = wm.Backend().NetworkWallet(); err != nil {
 		return
 	}
 
@@ -261,25 +237,23 @@ func (wm *WalletManager) NetworkWallet() (w *Wallet, err error) {
 // WalletNames returns a list of existing Wallet names.
 func (wm *WalletManager) WalletNames() (wallets []string, err error) {
 
-	var call *dbus.Call
-
-	if call = wm.Dbus.Call(
-		DbusWMWallets, 0,
-	); call.Err != nil {
-		err = call.Err
+	if !wm.isInit {
+		err = ErrInitWM
 		return
 	}
-	if err = call.Store(&wallets); err != nil {
+
+	if wallets, err = wm.Backend().Wallets(); err != nil {
 		return
 	}
 
 	return
 }
 
-// Update fetches/updates all Wallet objects in a WalletManager.
+// Update fetches/updates all Wallet objects in a WalletManager (filtered by RecurseOpts.IncludeWallets and RecurseOpts.ExcludeWallets).
 func (wm *WalletManager) Update() (err error) {
 
 	var walletNames []string
+	var wanted bool
 	var errs []error = make([]error, 0)
 
 	if !wm.isInit {
@@ -295,6 +269,12 @@ func (wm *WalletManager) Update() (err error) {
 
 	for _, wn := range walletNames {
 
+		if wanted, err = wm.Recurse.wantWallet(wn); err != nil {
+			return
+		} else if !wanted {
+			continue
+		}
+
 		if wm.Wallets[wn], err = NewWallet(wm, wn, wm.Recurse); err != nil {
 			errs = append(errs, err)
 			err = nil
@@ -310,8 +290,57 @@ func (wm *WalletManager) Update() (err error) {
 	return
 }
 
-// newWM is what does the heavy lifting behind NewWalletManager and NewWalletManagerFiles.
-func newWM(appId string, recursion *RecurseOpts, filePaths ...string) (wm *WalletManager, err error) {
+/*
+	swapBackend replaces the Backend of a WalletManager with the one fn returns for the current one,
+	e.g. to add or remove a wrapper (see WalletManager.EnableCache). fn must not modify the current Backend (or any
+	wrapper in it) in place, as operations already running (e.g. a Sweeper's) may still be using it; it should return
+	a new wrapper instead. Concurrent swaps are serialized.
+*/
+func (wm *WalletManager) swapBackend(fn func(cur Backend) (next Backend)) {
+
+	wm.backendLock.Lock()
+	wm.backend = fn(wm.backend)
+	wm.backendLock.Unlock()
+
+	return
+}
+
+/*
+	withWallet calls fn with a (non-recursing) Wallet for walletName and closes that Wallet afterwards,
+	so background and one-shot operations don't leave a handle open for every call.
+	An error from fn is returned in preference to one from closing the Wallet.
+*/
+func (wm *WalletManager) withWallet(walletName string, fn func(w *Wallet) (err error)) (err error) {
+
+	var w *Wallet
+	var closeErr error
+
+	if w, err = NewWallet(wm, walletName, &RecurseOpts{}); err != nil {
+		return
+	}
+
+	err = fn(w)
+
+	if w.hasHandle {
+		if closeErr = w.wm.Backend().Close(w.handle, false, wm.AppID); closeErr == nil {
+			w.hasHandle = false
+		} else if err == nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+// newWM is what does the heavy lifting behind NewWalletManager, NewWalletManagerFiles, and NewWalletManagerBackend.
+func newWM(backend Backend, appId string, recursion *RecurseOpts) (wm *WalletManager, err error) {
+
+	var dbusBackend *DbusBackend
+	var ok bool
+
+	if err = recursion.checkFilters(); err != nil {
+		return
+	}
 
 	wm = &WalletManager{
 		DbusObject: &DbusObject{
@@ -321,12 +350,13 @@ func newWM(appId string, recursion *RecurseOpts, filePaths ...string) (wm *Walle
 		AppID:   appId,
 		Wallets: nil,
 		Recurse: recursion,
+		backend: backend,
 	}
 
-	if wm.DbusObject.Conn, err = dbus.SessionBus(); err != nil {
-		return
+	// The Dbus objects are still exposed for a DbusBackend for compatibility.
+	if dbusBackend, ok = backend.(*DbusBackend); ok {
+		wm.DbusObject = dbusBackend.DbusObject
 	}
-	wm.DbusObject.Dbus = wm.DbusObject.Conn.Object(DbusService, dbus.ObjectPath(DbusPath))
 
 	wm.isInit = true
 